@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
 	"fmt"
 	"frappuccino/internal/api"
 	"frappuccino/internal/api/handlers"
+	"frappuccino/internal/auth"
 	"frappuccino/internal/repo"
 	"frappuccino/internal/service"
+	"frappuccino/internal/storage"
 	"log"
 	"net/http"
 	"os"
@@ -37,11 +41,62 @@ func main() {
 		log.Fatalf("cannot ping db: %v", err)
 	}
 
+	// uploaded images are kept in MEDIA_DIR and served under /media/, or
+	// under MEDIA_URL when something else serves that directory
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = "media"
+	}
+	mediaURL := os.Getenv("MEDIA_URL")
+	if mediaURL == "" {
+		mediaURL = "/media"
+	}
+	media := storage.NewLocalStorage(mediaDir, mediaURL)
+
+	// login codes are posted to LOGIN_CODE_WEBHOOK_URL, with the magic link
+	// to the order-ahead app when LOGIN_LINK_URL is set. LOGIN_CODE_LOG=true
+	// logs them instead, for development only. Without either customers
+	// cannot log in with codes
+	var sender auth.CodeSender
+	switch {
+	case os.Getenv("LOGIN_CODE_WEBHOOK_URL") != "":
+		sender = auth.NewWebhookSender(os.Getenv("LOGIN_CODE_WEBHOOK_URL"), os.Getenv("LOGIN_LINK_URL"))
+	case os.Getenv("LOGIN_CODE_LOG") == "true":
+		log.Println("LOGIN_CODE_LOG is set, login codes are written to the log; do not use this in production")
+		sender = auth.NewLogSender(os.Getenv("LOGIN_LINK_URL"))
+	default:
+		log.Println("LOGIN_CODE_WEBHOOK_URL is not set, customers can only log in with a password")
+	}
+
+	// staff tokens are signed with STAFF_TOKEN_SECRET; without it a random
+	// key is used and staff must log in again after every restart
+	secret := []byte(os.Getenv("STAFF_TOKEN_SECRET"))
+	if len(secret) == 0 {
+		log.Println("STAFF_TOKEN_SECRET is not set, staff tokens will not survive a restart")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("failed to generate token secret: %v", err)
+		}
+	}
+
 	repo := repo.New(db)
-	svc := service.New(repo)
+	svc := service.New(repo, media, sender, auth.NewSigner(secret))
+	if err := svc.StaffService.Bootstrap(context.Background(), os.Getenv("STAFF_ADMIN_USERNAME"), os.Getenv("STAFF_ADMIN_PASSWORD")); err != nil {
+		log.Fatalf("failed to create the first admin: %v", err)
+	}
 	handler := handlers.New(svc)
 
+	// publish scheduled menu versions once their time has come
+	go func() {
+		for range time.Tick(time.Minute) {
+			if err := svc.MenuVersionService.PublishDue(context.Background()); err != nil {
+				log.Printf("Failed to publish scheduled menu versions: %v", err)
+			}
+		}
+	}()
+
 	mux := api.Router(handler)
+	mux.Handle("GET /media/", http.StripPrefix("/media/", http.FileServer(media.FileSystem())))
 
 	fmt.Println("Starting server on :8080")
 	if err := http.ListenAndServe(":8080", logger(mux)); err != nil {
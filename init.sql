@@ -1,29 +1,73 @@
 -- Enable UUID extension
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+-- Trigram matching for fuzzy customer search
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
 
 -- ENUM Types
 CREATE TYPE all_order_status AS ENUM ('PENDING', 'COMPLETED', 'CANCELLED');
 CREATE TYPE all_order_payment_method AS ENUM ('CASH', 'CARD');
 CREATE TYPE all_inventory_transaction_action AS ENUM ('ADD', 'REMOVE', 'ADJUST');
 CREATE TYPE all_unit AS ENUM ('KG', 'G', 'L','ML' );
+CREATE TYPE all_modifier_action AS ENUM ('ADD', 'REMOVE', 'SUBSTITUTE');
+CREATE TYPE all_transfer_status AS ENUM ('REQUESTED', 'SHIPPED', 'RECEIVED', 'CANCELLED');
+CREATE TYPE all_menu_version_status AS ENUM ('DRAFT', 'SCHEDULED', 'PUBLISHED', 'ARCHIVED');
+CREATE TYPE all_price_change_type AS ENUM ('PERCENT', 'ABSOLUTE');
+CREATE TYPE all_price_rounding AS ENUM ('NEAREST', 'UP', 'DOWN');
+CREATE TYPE all_staff_role AS ENUM ('BARISTA', 'SHIFT_LEAD', 'MANAGER', 'ADMIN');
 
 -- Tables
 CREATE TABLE customers (
     customer_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     full_name VARCHAR(255) NOT NULL,
-    phone_number VARCHAR(15) NOT NULL,
-    email VARCHAR(255) NOT NULL,
-    preferences JSONB DEFAULT '{}'::JSONB,
+    -- E.164 such as +77011234567, or empty; normalised by the service
+    phone_number VARCHAR(16) NOT NULL,
+    -- Lower-cased by the service, or empty
+    email VARCHAR(255) NOT NULL CHECK (email = lower(email)),
+    -- favourite_drink, milk_preference, sugar_level and allergies, checked by the service
+    preferences JSONB NOT NULL DEFAULT '{}'::JSONB CHECK (jsonb_typeof(preferences) = 'object'),
+    -- Set instead of deleting; archived customers are left out of listings
+    archived_at TIMESTAMP WITH TIME ZONE,
+    -- Customer this one was merged into as a duplicate; set with archived_at
+    merged_into UUID REFERENCES customers(customer_id) ON DELETE SET NULL,
+    -- Set when the personal data was erased; the row stays for the orders
+    erased_at TIMESTAMP WITH TIME ZONE,
     created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
     updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
 );
 
+CREATE TABLE stores (
+    store_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    store_name VARCHAR(255) NOT NULL UNIQUE,
+    address TEXT NOT NULL DEFAULT '',
+    -- IANA name of the zone the menu schedules of the store are read in
+    time_zone TEXT NOT NULL DEFAULT 'UTC',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- Managed menu categories. A category may sit under a parent; siblings
+-- are shown in display_order. Names are unique regardless of case
+CREATE TABLE categories (
+    category_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    category_name VARCHAR(100) NOT NULL CHECK (btrim(category_name) <> ''),
+    parent_id UUID REFERENCES categories(category_id) ON DELETE RESTRICT,
+    description TEXT NOT NULL DEFAULT '',
+    display_order INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CHECK (parent_id <> category_id)
+);
+
 CREATE TABLE menu_items (
     menu_item_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     item_name VARCHAR(255) NOT NULL DEFAULT '',
     item_description TEXT NOT NULL DEFAULT '',
     price DECIMAL(10,2) NOT NULL CHECK (price >= 0),
-    categories TEXT[] NOT NULL DEFAULT '{}',
+    -- Free-form labels (seasonal, espresso, ...) for targeting groups of items
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    -- Set when the item is deleted or a published menu version no longer
+    -- lists it. Past orders keep referencing archived items
+    archived_at TIMESTAMP WITH TIME ZONE,
     created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
     updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
 );
@@ -32,15 +76,297 @@ CREATE TABLE inventory (
     ingredient_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     ingredient_name VARCHAR(255) NOT NULL UNIQUE,
     unit VARCHAR(15) NOT NULL,
-    quantity DECIMAL(10,2) NOT NULL CHECK (quantity >= 0),
-    reorder_level DECIMAL(10,2) NOT NULL CHECK (reorder_level >= 0),
+    unit_cost DECIMAL(10,4) NOT NULL DEFAULT 0 CHECK (unit_cost >= 0),
+    -- Prepared ingredients are made in-house from their ingredient_recipes
+    is_prepared BOOLEAN NOT NULL DEFAULT FALSE,
+    batch_yield DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (batch_yield >= 0),
+    -- Allergens the ingredient contains (dairy, nuts, gluten, ...) and the
+    -- diets it suits (vegan, vegetarian, ...); menu items inherit them
+    allergens TEXT[] NOT NULL DEFAULT '{}',
+    diets TEXT[] NOT NULL DEFAULT '{}',
+    -- Nutrition per unit of the ingredient
+    kcal DECIMAL(10,4) NOT NULL DEFAULT 0 CHECK (kcal >= 0),
+    sugar_g DECIMAL(10,4) NOT NULL DEFAULT 0 CHECK (sugar_g >= 0),
+    fat_g DECIMAL(10,4) NOT NULL DEFAULT 0 CHECK (fat_g >= 0),
+    protein_g DECIMAL(10,4) NOT NULL DEFAULT 0 CHECK (protein_g >= 0),
+    caffeine_mg DECIMAL(10,4) NOT NULL DEFAULT 0 CHECK (caffeine_mg >= 0),
+    -- Set instead of deleting, as recipes and past orders still use it
+    archived_at TIMESTAMP WITH TIME ZONE,
     created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
     updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()  
 );
 
+-- Stock of each ingredient held by a store
+CREATE TABLE store_inventory (
+    store_id UUID NOT NULL REFERENCES stores(store_id) ON DELETE CASCADE,
+    ingredient_id UUID NOT NULL REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
+    quantity DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    reorder_level DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (reorder_level >= 0),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (store_id, ingredient_id)
+);
+
+-- Per-store menu overrides: a NULL price keeps the menu_items price. The
+-- difference from the menu_items price applies to every variant of the item
+CREATE TABLE store_menu_items (
+    store_id UUID NOT NULL REFERENCES stores(store_id) ON DELETE CASCADE,
+    menu_item_id UUID NOT NULL REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
+    price DECIMAL(10,2) CHECK (price >= 0),
+    is_available BOOLEAN NOT NULL DEFAULT TRUE,
+    PRIMARY KEY (store_id, menu_item_id)
+);
+
+-- Sizes of a menu item. A variant uses its own recipe lines when it has
+-- any, otherwise the menu item recipe scaled by recipe_multiplier. Variants
+-- a menu item no longer lists are archived, as orders may refer to them.
+CREATE TABLE menu_item_variants (
+    variant_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    menu_item_id UUID NOT NULL REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
+    variant_name VARCHAR(100) NOT NULL,
+    price DECIMAL(10,2) NOT NULL CHECK (price >= 0),
+    recipe_multiplier DECIMAL(10,4) NOT NULL DEFAULT 1 CHECK (recipe_multiplier > 0),
+    display_order INT NOT NULL DEFAULT 0,
+    archived_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE(menu_item_id, variant_name)
+);
+
+-- Pictures of menu items. The files are in media storage; the keys are
+-- relative to its root
+CREATE TABLE menu_item_images (
+    image_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    menu_item_id UUID NOT NULL REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
+    image_key TEXT NOT NULL UNIQUE,
+    thumbnail_key TEXT NOT NULL UNIQUE,
+    content_type VARCHAR(50) NOT NULL,
+    width INT NOT NULL CHECK (width > 0),
+    height INT NOT NULL CHECK (height > 0),
+    size_bytes INT NOT NULL CHECK (size_bytes > 0),
+    display_order INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE TABLE menu_item_variant_ingredients (
+    variant_id UUID NOT NULL REFERENCES menu_item_variants(variant_id) ON DELETE CASCADE,
+    ingredient_id UUID NOT NULL REFERENCES inventory(ingredient_id) ON DELETE RESTRICT,
+    quantity DECIMAL(10,2) NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (variant_id, ingredient_id)
+);
+
+-- Modifier groups such as milk type or extra shots, attached to menu items
+CREATE TABLE modifier_groups (
+    modifier_group_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    group_name VARCHAR(100) NOT NULL UNIQUE,
+    min_selections INT NOT NULL DEFAULT 0 CHECK (min_selections >= 0),
+    max_selections INT NOT NULL DEFAULT 1 CHECK (max_selections >= min_selections),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE TABLE modifiers (
+    modifier_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    modifier_group_id UUID NOT NULL REFERENCES modifier_groups(modifier_group_id) ON DELETE CASCADE,
+    modifier_name VARCHAR(100) NOT NULL,
+    price_delta DECIMAL(10,2) NOT NULL DEFAULT 0,
+    UNIQUE(modifier_group_id, modifier_name)
+);
+
+-- Recipe changes of a modifier: ADD adds ingredient_id, REMOVE drops
+-- replaces_ingredient_id, SUBSTITUTE swaps replaces_ingredient_id for
+-- ingredient_id (keeping its quantity when quantity is 0)
+CREATE TABLE modifier_ingredients (
+    modifier_ingredient_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    modifier_id UUID NOT NULL REFERENCES modifiers(modifier_id) ON DELETE CASCADE,
+    modifier_action all_modifier_action NOT NULL,
+    ingredient_id UUID REFERENCES inventory(ingredient_id) ON DELETE RESTRICT,
+    replaces_ingredient_id UUID REFERENCES inventory(ingredient_id) ON DELETE RESTRICT,
+    quantity DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    CHECK (
+        (modifier_action = 'ADD' AND ingredient_id IS NOT NULL AND quantity > 0) OR
+        (modifier_action = 'REMOVE' AND replaces_ingredient_id IS NOT NULL) OR
+        (modifier_action = 'SUBSTITUTE' AND ingredient_id IS NOT NULL AND replaces_ingredient_id IS NOT NULL)
+    )
+);
+
+CREATE TABLE menu_item_modifier_groups (
+    menu_item_id UUID NOT NULL REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
+    modifier_group_id UUID NOT NULL REFERENCES modifier_groups(modifier_group_id) ON DELETE CASCADE,
+    PRIMARY KEY (menu_item_id, modifier_group_id)
+);
+
+-- Text of menu items, categories, modifier groups and modifiers in other
+-- locales than the default one their own columns are written in. Each row
+-- translates exactly one of them
+CREATE TABLE translations (
+    translation_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    locale VARCHAR(35) NOT NULL CHECK (locale ~ '^[a-z]{2,3}(-[a-z0-9]{2,8})*$'),
+    menu_item_id UUID REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
+    category_id UUID REFERENCES categories(category_id) ON DELETE CASCADE,
+    modifier_group_id UUID REFERENCES modifier_groups(modifier_group_id) ON DELETE CASCADE,
+    modifier_id UUID REFERENCES modifiers(modifier_id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL CHECK (btrim(name) <> ''),
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CHECK (num_nonnulls(menu_item_id, category_id, modifier_group_id, modifier_id) = 1)
+);
+
+-- Snapshots of the whole menu. Drafts can be edited and previewed;
+-- publishing applies what the items change from base_items to menu_items in
+-- one transaction, at publish_at when scheduled, unless the items it
+-- changes were edited since based_at. Older versions are kept for rollback
+CREATE TABLE menu_versions (
+    version_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    version_number INT GENERATED ALWAYS AS IDENTITY UNIQUE,
+    version_name VARCHAR(255) NOT NULL DEFAULT '',
+    version_status all_menu_version_status NOT NULL DEFAULT 'DRAFT',
+    items JSONB NOT NULL DEFAULT '[]'::JSONB,
+    -- The live menu the version was drafted from, and when
+    base_items JSONB NOT NULL DEFAULT '[]'::JSONB,
+    based_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    publish_at TIMESTAMP WITH TIME ZONE,
+    published_at TIMESTAMP WITH TIME ZONE,
+    -- Why the version went back to draft when it failed to publish on schedule
+    publish_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CHECK (version_status <> 'SCHEDULED' OR publish_at IS NOT NULL)
+);
+
+-- Windows in which a menu item, or every item of a category, can be sold.
+-- NULL bounds are open; a window with start_time after end_time runs past
+-- midnight. days_of_week uses ISO numbering (1 = Monday ... 7 = Sunday)
+CREATE TABLE menu_schedules (
+    schedule_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    menu_item_id UUID REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
+    category_id UUID REFERENCES categories(category_id) ON DELETE CASCADE,
+    start_time TIME,
+    end_time TIME,
+    days_of_week INT[] NOT NULL DEFAULT '{1,2,3,4,5,6,7}',
+    start_date DATE,
+    end_date DATE,
+    CHECK ((menu_item_id IS NULL) <> (category_id IS NULL)),
+    CHECK (start_date IS NULL OR end_date IS NULL OR start_date <= end_date),
+    CHECK (days_of_week <@ '{1,2,3,4,5,6,7}')
+);
+
+-- Components of a bundle menu item. A slot with one option is a fixed
+-- component; with several the order picks one, as in "any pastry"
+CREATE TABLE bundle_slots (
+    slot_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    bundle_id UUID NOT NULL REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
+    slot_name VARCHAR(100) NOT NULL,
+    quantity DECIMAL(10,2) NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    display_order INT NOT NULL DEFAULT 0,
+    UNIQUE(bundle_id, slot_name)
+);
+
+CREATE TABLE bundle_slot_options (
+    slot_id UUID NOT NULL REFERENCES bundle_slots(slot_id) ON DELETE CASCADE,
+    menu_item_id UUID NOT NULL REFERENCES menu_items(menu_item_id) ON DELETE RESTRICT,
+    PRIMARY KEY (slot_id, menu_item_id)
+);
+
+-- Audit trail of customer erasures. It holds no personal data of its own.
+CREATE TABLE customer_erasures (
+    erasure_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    customer_id UUID NOT NULL REFERENCES customers(customer_id) ON DELETE RESTRICT,
+    requested_by VARCHAR(255) NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    erased_fields TEXT[] NOT NULL,
+    orders_kept INT NOT NULL CHECK (orders_kept >= 0),
+    erased_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- Saved RFM segment definitions. A customer belongs to the first segment,
+-- lowest priority first, whose score ranges hold its recency, frequency and
+-- monetary scores; the built-in segments of the service apply after them.
+CREATE TABLE customer_segments (
+    segment_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    segment_name VARCHAR(100) NOT NULL UNIQUE CHECK (btrim(segment_name) <> ''),
+    description TEXT NOT NULL DEFAULT '',
+    min_recency INT NOT NULL DEFAULT 1 CHECK (min_recency BETWEEN 1 AND 5),
+    max_recency INT NOT NULL DEFAULT 5 CHECK (max_recency BETWEEN min_recency AND 5),
+    min_frequency INT NOT NULL DEFAULT 1 CHECK (min_frequency BETWEEN 1 AND 5),
+    max_frequency INT NOT NULL DEFAULT 5 CHECK (max_frequency BETWEEN min_frequency AND 5),
+    min_monetary INT NOT NULL DEFAULT 1 CHECK (min_monetary BETWEEN 1 AND 5),
+    max_monetary INT NOT NULL DEFAULT 5 CHECK (max_monetary BETWEEN min_monetary AND 5),
+    priority INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- Password of a customer account, as a salted PBKDF2 hash. Customers without
+-- one log in with codes sent to their email or phone
+CREATE TABLE customer_credentials (
+    customer_id UUID PRIMARY KEY REFERENCES customers(customer_id) ON DELETE CASCADE,
+    password_hash TEXT NOT NULL,
+    -- Wrong passwords in a row; too many lock password logins until locked_until
+    failed_logins INT NOT NULL DEFAULT 0 CHECK (failed_logins >= 0),
+    locked_until TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- One-time login codes sent to customers; only their hash is kept. A code is
+-- used up once it logs in, when a newer one is sent or after too many tries
+CREATE TABLE customer_login_codes (
+    code_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    customer_id UUID NOT NULL REFERENCES customers(customer_id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    attempts INT NOT NULL DEFAULT 0 CHECK (attempts >= 0),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- Customer sessions, by the hash of their bearer token
+CREATE TABLE customer_sessions (
+    session_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    customer_id UUID NOT NULL REFERENCES customers(customer_id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- Staff users. A staff user with a store works in that store only and, but
+-- for admins, cannot change the catalog all stores share; without one they
+-- work in all of them. What each role may do is set by models.RolePermissions
+CREATE TABLE staff_users (
+    staff_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    username VARCHAR(50) NOT NULL UNIQUE CHECK (username ~ '^[a-z0-9._-]{3,50}$'),
+    full_name VARCHAR(255) NOT NULL DEFAULT '',
+    staff_role all_staff_role NOT NULL,
+    store_id UUID REFERENCES stores(store_id) ON DELETE RESTRICT,
+    password_hash TEXT NOT NULL,
+    -- Wrong passwords in a row; too many lock logins until locked_until
+    failed_logins INT NOT NULL DEFAULT 0 CHECK (failed_logins >= 0),
+    locked_until TIMESTAMP WITH TIME ZONE,
+    -- Tokens issued before the password last changed are no longer accepted
+    password_changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    -- Set instead of deleting; archived staff cannot log in
+    archived_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- Requests of logged-in staff to staff endpoints that were denied. Denied
+-- anonymous requests are only logged, so that they cannot fill the table
+CREATE TABLE access_denials (
+    denial_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    staff_id UUID REFERENCES staff_users(staff_id) ON DELETE SET NULL,
+    method VARCHAR(10) NOT NULL,
+    path TEXT NOT NULL,
+    permission VARCHAR(50) NOT NULL,
+    reason TEXT NOT NULL,
+    remote_addr VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
 CREATE TABLE orders (
     order_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     customer_id UUID NOT NULL REFERENCES customers(customer_id) ON DELETE RESTRICT,
+    store_id UUID NOT NULL REFERENCES stores(store_id) ON DELETE RESTRICT,
     special_instructions JSONB NOT NULL DEFAULT '{}'::JSONB,
     total_price DECIMAL(10,2) NOT NULL CHECK (total_price >= 0),
     order_status all_order_status NOT NULL DEFAULT 'PENDING',
@@ -61,20 +387,81 @@ CREATE TABLE order_items (
     order_item_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     order_id UUID NOT NULL REFERENCES orders(order_id) ON DELETE CASCADE,
     menu_item_id UUID NOT NULL REFERENCES menu_items(menu_item_id) ON DELETE RESTRICT,
+    variant_id UUID REFERENCES menu_item_variants(variant_id) ON DELETE RESTRICT,
     customizations JSONB NOT NULL DEFAULT '{}'::JSONB,
     quantity DECIMAL(10,2) NOT NULL CHECK (quantity >= 0),
     unit_price DECIMAL(10,2) NOT NULL CHECK (unit_price >= 0)
 );
 
+-- Modifiers chosen for an order item with the price delta charged
+CREATE TABLE order_item_modifiers (
+    order_item_id UUID NOT NULL REFERENCES order_items(order_item_id) ON DELETE CASCADE,
+    modifier_id UUID NOT NULL REFERENCES modifiers(modifier_id) ON DELETE RESTRICT,
+    price_delta DECIMAL(10,2) NOT NULL,
+    PRIMARY KEY (order_item_id, modifier_id)
+);
+
+-- Menu items sold as part of a bundle order item. allocated_price is the
+-- share of one bundle's price credited to the component
+CREATE TABLE order_item_components (
+    order_item_component_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    order_item_id UUID NOT NULL REFERENCES order_items(order_item_id) ON DELETE CASCADE,
+    menu_item_id UUID NOT NULL REFERENCES menu_items(menu_item_id) ON DELETE RESTRICT,
+    slot_name VARCHAR(100) NOT NULL,
+    quantity DECIMAL(10,2) NOT NULL CHECK (quantity > 0),
+    allocated_price DECIMAL(10,2) NOT NULL CHECK (allocated_price >= 0)
+);
+
+-- Menu items sold with their revenue; bundle order items count as their components
+CREATE VIEW order_item_sales AS
+SELECT oi.order_id, oi.order_item_id, oi.menu_item_id, oi.variant_id,
+    oi.quantity, oi.quantity * oi.unit_price AS revenue
+FROM order_items oi
+WHERE NOT EXISTS (SELECT 1 FROM order_item_components c WHERE c.order_item_id = oi.order_item_id)
+UNION ALL
+SELECT oi.order_id, oi.order_item_id, c.menu_item_id, NULL::uuid,
+    oi.quantity * c.quantity, oi.quantity * c.allocated_price
+FROM order_items oi
+JOIN order_item_components c USING(order_item_id);
+
+-- Bulk price changes. The price_history rows of a batch point to it, so
+-- the batch can be reverted to their previous_price
+CREATE TABLE price_adjustments (
+    adjustment_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    reason TEXT NOT NULL DEFAULT '',
+    category_id UUID REFERENCES categories(category_id) ON DELETE SET NULL,
+    tag TEXT,
+    menu_item_ids UUID[] NOT NULL DEFAULT '{}',
+    change_type all_price_change_type NOT NULL,
+    change_value DECIMAL(10,4) NOT NULL,
+    round_to DECIMAL(10,2) NOT NULL DEFAULT 0.01 CHECK (round_to > 0),
+    rounding all_price_rounding NOT NULL DEFAULT 'NEAREST',
+    reverted_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
 CREATE TABLE price_history (
     price_history_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     menu_item_id UUID NOT NULL REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
     price DECIMAL(10,2) NOT NULL CHECK (price >= 0),
+    previous_price DECIMAL(10,2),
+    -- Set when the change was part of a bulk price adjustment
+    adjustment_id UUID REFERENCES price_adjustments(adjustment_id) ON DELETE SET NULL,
+    -- Set when the row is the price of a variant or the price override of a
+    -- store rather than the menu_items price
+    variant_id UUID REFERENCES menu_item_variants(variant_id) ON DELETE CASCADE,
+    store_id UUID REFERENCES stores(store_id) ON DELETE CASCADE,
     effective_from TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
     effective_to TIMESTAMP WITH TIME ZONE,
     updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
 );
 
+CREATE TABLE menu_item_categories (
+    menu_item_id UUID NOT NULL REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
+    category_id UUID NOT NULL REFERENCES categories(category_id) ON DELETE CASCADE,
+    PRIMARY KEY (menu_item_id, category_id)
+);
+
 CREATE TABLE menu_item_ingredients (
     menu_item_ingredients_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     menu_item_id UUID NOT NULL REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
@@ -87,6 +474,7 @@ CREATE TABLE menu_item_ingredients (
 CREATE TABLE inventory_transactions (
     inventory_transactions_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     ingredient_id UUID REFERENCES inventory(ingredient_id) ON DELETE RESTRICT NOT NULL,
+    store_id UUID NOT NULL REFERENCES stores(store_id) ON DELETE RESTRICT,
     quantity DECIMAL(10,2) NOT NULL,
     inventory_transaction_action all_inventory_transaction_action NOT NULL,
     reference_id UUID,	
@@ -94,37 +482,163 @@ CREATE TABLE inventory_transactions (
     created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
 );    
 
+-- Components consumed by one batch of a prepared ingredient
+CREATE TABLE ingredient_recipes (
+    ingredient_recipe_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    prepared_ingredient_id UUID NOT NULL REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
+    component_ingredient_id UUID NOT NULL REFERENCES inventory(ingredient_id) ON DELETE RESTRICT,
+    quantity DECIMAL(10,2) NOT NULL CHECK (quantity > 0),
+    UNIQUE(prepared_ingredient_id, component_ingredient_id),
+    CHECK (prepared_ingredient_id <> component_ingredient_id)
+);
+
+-- Batches of prepared ingredients produced in a store
+CREATE TABLE ingredient_productions (
+    production_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    ingredient_id UUID NOT NULL REFERENCES inventory(ingredient_id) ON DELETE RESTRICT,
+    store_id UUID NOT NULL REFERENCES stores(store_id) ON DELETE RESTRICT,
+    batches DECIMAL(10,2) NOT NULL CHECK (batches > 0),
+    quantity DECIMAL(10,2) NOT NULL CHECK (quantity >= 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- Stock moved between stores
+CREATE TABLE stock_transfers (
+    transfer_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    from_store_id UUID NOT NULL REFERENCES stores(store_id) ON DELETE RESTRICT,
+    to_store_id UUID NOT NULL REFERENCES stores(store_id) ON DELETE RESTRICT,
+    transfer_status all_transfer_status NOT NULL DEFAULT 'REQUESTED',
+    notes TEXT NOT NULL DEFAULT '',
+    shipped_at TIMESTAMP WITH TIME ZONE,
+    received_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CHECK (from_store_id <> to_store_id)
+);
+
+CREATE TABLE stock_transfer_items (
+    transfer_item_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transfer_id UUID NOT NULL REFERENCES stock_transfers(transfer_id) ON DELETE CASCADE,
+    ingredient_id UUID NOT NULL REFERENCES inventory(ingredient_id) ON DELETE RESTRICT,
+    requested_quantity DECIMAL(10,2) NOT NULL CHECK (requested_quantity > 0),
+    shipped_quantity DECIMAL(10,2) CHECK (shipped_quantity >= 0),
+    received_quantity DECIMAL(10,2) CHECK (received_quantity >= 0),
+    -- Negative when less arrived than was shipped
+    discrepancy DECIMAL(10,2) GENERATED ALWAYS AS (received_quantity - shipped_quantity) STORED,
+    discrepancy_notes TEXT NOT NULL DEFAULT '',
+    UNIQUE(transfer_id, ingredient_id)
+);
+
 -- Indexes for order_items table
 CREATE INDEX idx_order_items_order_id ON order_items(order_id);
 CREATE INDEX idx_order_items_menu_item_id ON order_items(menu_item_id);
+CREATE INDEX idx_order_items_variant_id ON order_items(variant_id);
+
+-- Indexes for modifier tables
+CREATE INDEX idx_modifiers_modifier_group_id ON modifiers(modifier_group_id);
+CREATE INDEX idx_modifier_ingredients_modifier_id ON modifier_ingredients(modifier_id);
+CREATE INDEX idx_menu_item_modifier_groups_modifier_group_id ON menu_item_modifier_groups(modifier_group_id);
+CREATE INDEX idx_order_item_modifiers_modifier_id ON order_item_modifiers(modifier_id);
+
+-- Indexes for translations table
+CREATE UNIQUE INDEX idx_translations_menu_item_id ON translations(menu_item_id, locale) WHERE menu_item_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_translations_category_id ON translations(category_id, locale) WHERE category_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_translations_modifier_group_id ON translations(modifier_group_id, locale) WHERE modifier_group_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_translations_modifier_id ON translations(modifier_id, locale) WHERE modifier_id IS NOT NULL;
+CREATE INDEX idx_translations_locale ON translations(locale);
+
+-- Indexes for menu_versions table
+CREATE UNIQUE INDEX idx_menu_versions_published ON menu_versions(version_status) WHERE version_status = 'PUBLISHED';
+CREATE INDEX idx_menu_versions_publish_at ON menu_versions(publish_at) WHERE version_status = 'SCHEDULED';
+
+-- Indexes for menu_schedules table
+CREATE INDEX idx_menu_schedules_menu_item_id ON menu_schedules(menu_item_id);
+CREATE INDEX idx_menu_schedules_category_id ON menu_schedules(category_id);
+
+-- Indexes for bundle tables
+CREATE INDEX idx_bundle_slots_bundle_id ON bundle_slots(bundle_id);
+CREATE INDEX idx_bundle_slot_options_menu_item_id ON bundle_slot_options(menu_item_id);
+CREATE INDEX idx_order_item_components_order_item_id ON order_item_components(order_item_id);
+CREATE INDEX idx_order_item_components_menu_item_id ON order_item_components(menu_item_id);
+
+-- Indexes for menu_item_variants table
+CREATE INDEX idx_menu_item_variants_menu_item_id ON menu_item_variants(menu_item_id);
+CREATE INDEX idx_menu_item_images_menu_item_id ON menu_item_images(menu_item_id);
 
 -- Indexes for menu_items table
-CREATE INDEX idx_menu_items_categories ON menu_items USING GIN(categories);
+CREATE UNIQUE INDEX idx_categories_name ON categories(lower(category_name));
+CREATE INDEX idx_categories_parent_id ON categories(parent_id);
+CREATE INDEX idx_menu_item_categories_category_id ON menu_item_categories(category_id);
 CREATE INDEX idx_menu_items_price ON menu_items(price);
+CREATE INDEX idx_menu_items_tags ON menu_items USING GIN(tags);
 
 -- Indexes for price_history table
 CREATE INDEX idx_price_history_menu_item_id ON price_history(menu_item_id);
 CREATE INDEX idx_price_history_updated_at ON price_history(updated_at);
+CREATE INDEX idx_price_history_adjustment_id ON price_history(adjustment_id);
+CREATE INDEX idx_price_history_variant_id ON price_history(variant_id) WHERE variant_id IS NOT NULL;
+CREATE INDEX idx_price_history_store_id ON price_history(store_id) WHERE store_id IS NOT NULL;
 
 -- Indexes for inventory table
 CREATE INDEX idx_inventory_ingredient_name ON inventory(ingredient_name);
-CREATE INDEX idx_inventory_reorder_level ON inventory(reorder_level);
+
+-- Indexes for ingredient_recipes table
+CREATE INDEX idx_ingredient_recipes_component_ingredient_id ON ingredient_recipes(component_ingredient_id);
+
+-- Indexes for ingredient_productions table
+CREATE INDEX idx_ingredient_productions_ingredient_id ON ingredient_productions(ingredient_id);
+CREATE INDEX idx_ingredient_productions_store_id ON ingredient_productions(store_id);
+
+-- Indexes for store_inventory table
+CREATE INDEX idx_store_inventory_ingredient_id ON store_inventory(ingredient_id);
+
+-- Indexes for store_menu_items table
+CREATE INDEX idx_store_menu_items_menu_item_id ON store_menu_items(menu_item_id);
 
 -- Indexes for menu_item_ingredients table
 CREATE INDEX idx_menu_item_ingredients_menu_item_id ON menu_item_ingredients(menu_item_id);
 CREATE INDEX idx_menu_item_ingredients_ingredient_id ON menu_item_ingredients(ingredient_id);
 
 -- Indexes for customer table
-CREATE INDEX idx_customers_full_name ON customers(full_name);
-CREATE INDEX idx_customers_email ON customers(email);
+CREATE INDEX idx_customers_full_name ON customers USING GIN (full_name gin_trgm_ops);
+CREATE INDEX idx_customers_email ON customers USING GIN (email gin_trgm_ops);
+CREATE INDEX idx_customers_phone_number ON customers USING GIN (phone_number gin_trgm_ops);
+CREATE INDEX idx_customers_preferences ON customers USING GIN (preferences jsonb_path_ops);
+-- An email or phone number belongs to one customer; merged and erased
+-- customers are left out
+CREATE UNIQUE INDEX idx_customers_email_unique ON customers(email)
+    WHERE email <> '' AND merged_into IS NULL AND erased_at IS NULL;
+CREATE UNIQUE INDEX idx_customers_phone_number_unique ON customers(phone_number)
+    WHERE phone_number <> '' AND merged_into IS NULL AND erased_at IS NULL;
+
+-- Indexes for customer_erasures table
+CREATE INDEX idx_customer_erasures_customer_id ON customer_erasures(customer_id);
+
+-- Indexes for customer_login_codes and customer_sessions tables
+CREATE INDEX idx_customer_login_codes_customer_id ON customer_login_codes(customer_id);
+CREATE INDEX idx_customer_sessions_customer_id ON customer_sessions(customer_id);
+
+-- Indexes for access_denials table
+CREATE INDEX idx_access_denials_created_at ON access_denials(created_at);
+CREATE INDEX idx_access_denials_staff_id ON access_denials(staff_id);
 
 -- Indexes for inventory_transactions table
 CREATE INDEX idx_inventory_transactions_ingredient_id ON inventory_transactions(ingredient_id);
 CREATE INDEX idx_inventory_transactions_created_at ON inventory_transactions(created_at);
+CREATE INDEX idx_inventory_transactions_store_id ON inventory_transactions(store_id);
 CREATE INDEX idx_inventory_transactions_action ON inventory_transactions(inventory_transaction_action);
 
+-- Indexes for stock_transfers table
+CREATE INDEX idx_stock_transfers_from_store_id ON stock_transfers(from_store_id);
+CREATE INDEX idx_stock_transfers_to_store_id ON stock_transfers(to_store_id);
+CREATE INDEX idx_stock_transfers_status ON stock_transfers(transfer_status);
+
+-- Indexes for stock_transfer_items table
+CREATE INDEX idx_stock_transfer_items_transfer_id ON stock_transfer_items(transfer_id);
+
 -- Indexes for orders table
 CREATE INDEX idx_orders_customer_id ON orders(customer_id);
+CREATE INDEX idx_orders_store_id ON orders(store_id);
 CREATE INDEX idx_orders_created_at ON orders(created_at);
 CREATE INDEX idx_orders_order_status ON orders(order_status);
 CREATE INDEX idx_orders_payment_method ON orders(order_payment_method); 
@@ -149,6 +663,56 @@ CREATE TRIGGER update_customers_timestamp
     FOR EACH ROW
     EXECUTE FUNCTION update_timestamp();
 
+CREATE TRIGGER update_stores_timestamp
+    BEFORE UPDATE ON stores
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
+CREATE TRIGGER update_store_inventory_timestamp
+    BEFORE UPDATE ON store_inventory
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
+CREATE TRIGGER update_stock_transfers_timestamp
+    BEFORE UPDATE ON stock_transfers
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
+CREATE TRIGGER update_menu_versions_timestamp
+    BEFORE UPDATE ON menu_versions
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
+CREATE TRIGGER update_categories_timestamp
+    BEFORE UPDATE ON categories
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
+CREATE TRIGGER update_translations_timestamp
+    BEFORE UPDATE ON translations
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
+CREATE TRIGGER update_customer_segments_timestamp
+    BEFORE UPDATE ON customer_segments
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
+CREATE TRIGGER update_customer_credentials_timestamp
+    BEFORE UPDATE ON customer_credentials
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
+CREATE TRIGGER update_staff_users_timestamp
+    BEFORE UPDATE ON staff_users
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
+CREATE TRIGGER update_modifier_groups_timestamp
+    BEFORE UPDATE ON modifier_groups
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
 CREATE TRIGGER update_menu_items_timestamp
     BEFORE UPDATE ON menu_items
     FOR EACH ROW
@@ -180,38 +744,6 @@ CREATE TRIGGER trigger_order_status_change
 AFTER UPDATE ON orders
 FOR EACH ROW EXECUTE FUNCTION log_order_status_change();
 
--- Function to update inventory when order is completed
-CREATE OR REPLACE FUNCTION update_inventory_on_order_complete()
-RETURNS TRIGGER AS $$
-BEGIN
-    IF NEW.order_status = 'COMPLETE' AND OLD.order_status <> 'COMPLETE' THEN
-        -- Reduce inventory for each ingredient used in this order
-        INSERT INTO inventory_transactions (ingredient_id, quantity, inventory_transaction_action, reference_id, notes)
-        SELECT mii.ingredient_id, -1 * (mii.quantity * oi.quantity), 'REMOVE', NEW.order_id, 'Automatic deduction for order ' || NEW.order_id
-        FROM order_items oi
-        JOIN menu_item_ingredients mii ON oi.menu_item_id = mii.menu_item_id
-        WHERE oi.order_id = NEW.order_id;
-        
-        -- Update inventory quantities
-        UPDATE inventory i
-        SET quantity = i.quantity - subquery.total_quantity
-        FROM (
-            SELECT mii.ingredient_id, SUM(mii.quantity * oi.quantity) as total_quantity
-            FROM order_items oi
-            JOIN menu_item_ingredients mii ON oi.menu_item_id = mii.menu_item_id
-            WHERE oi.order_id = NEW.order_id
-            GROUP BY mii.ingredient_id
-        ) as subquery
-        WHERE i.ingredient_id = subquery.ingredient_id;
-    END IF;
-    RETURN NEW;
-END;
-$$ LANGUAGE plpgsql;
-
-CREATE TRIGGER trigger_update_inventory_on_order_complete
-AFTER UPDATE ON orders
-FOR EACH ROW EXECUTE FUNCTION update_inventory_on_order_complete();
-
 -- Function to track price changes in price_history (consolidated version)
 CREATE OR REPLACE FUNCTION track_menu_item_price_change()
 RETURNS TRIGGER AS $$
@@ -224,12 +756,15 @@ BEGIN
         -- Close previous price period
         UPDATE price_history
         SET effective_to = now()
-        WHERE menu_item_id = NEW.menu_item_id 
+        WHERE menu_item_id = NEW.menu_item_id
+          AND variant_id IS NULL AND store_id IS NULL
           AND effective_to IS NULL;
-        
-        -- Insert new price with NULL effective_to
-        INSERT INTO price_history (menu_item_id, price, effective_from)
-        VALUES (NEW.menu_item_id, NEW.price, now());
+
+        -- Insert new price with NULL effective_to; bulk adjustments name
+        -- their batch in the app.price_adjustment_id setting
+        INSERT INTO price_history (menu_item_id, price, previous_price, adjustment_id, effective_from)
+        VALUES (NEW.menu_item_id, NEW.price, OLD.price,
+            NULLIF(current_setting('app.price_adjustment_id', true), '')::uuid, now());
     END IF;
     RETURN NEW;
 END;
@@ -244,20 +779,76 @@ CREATE TRIGGER trigger_create_initial_price_history
 AFTER INSERT ON menu_items
 FOR EACH ROW EXECUTE FUNCTION track_menu_item_price_change();
 
+-- Variant prices and store price overrides are tracked the same way, in
+-- rows carrying their variant_id or store_id
+CREATE OR REPLACE FUNCTION track_variant_price_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.price = OLD.price THEN
+        RETURN NEW;
+    END IF;
+    UPDATE price_history
+    SET effective_to = now()
+    WHERE variant_id = NEW.variant_id
+      AND effective_to IS NULL;
+
+    INSERT INTO price_history (menu_item_id, variant_id, price, previous_price, adjustment_id, effective_from)
+    VALUES (NEW.menu_item_id, NEW.variant_id, NEW.price,
+        CASE WHEN TG_OP = 'UPDATE' THEN OLD.price END,
+        NULLIF(current_setting('app.price_adjustment_id', true), '')::uuid, now());
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_track_variant_price_change
+AFTER INSERT OR UPDATE OF price ON menu_item_variants
+FOR EACH ROW EXECUTE FUNCTION track_variant_price_change();
+
+CREATE OR REPLACE FUNCTION track_store_price_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.price IS NOT DISTINCT FROM OLD.price THEN
+        RETURN NEW;
+    END IF;
+    UPDATE price_history
+    SET effective_to = now()
+    WHERE store_id = COALESCE(NEW.store_id, OLD.store_id)
+      AND menu_item_id = COALESCE(NEW.menu_item_id, OLD.menu_item_id)
+      AND effective_to IS NULL;
+
+    -- Dropping the override or its price ends its price period
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    ELSIF NEW.price IS NOT NULL THEN
+        INSERT INTO price_history (menu_item_id, store_id, price, previous_price, adjustment_id, effective_from)
+        VALUES (NEW.menu_item_id, NEW.store_id, NEW.price,
+            CASE WHEN TG_OP = 'UPDATE' THEN OLD.price END,
+            NULLIF(current_setting('app.price_adjustment_id', true), '')::uuid, now());
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_track_store_price_change
+AFTER INSERT OR UPDATE OF price OR DELETE ON store_menu_items
+FOR EACH ROW EXECUTE FUNCTION track_store_price_change();
+
 -- Function to check inventory levels and alert on low stock
 CREATE OR REPLACE FUNCTION check_inventory_levels()
 RETURNS TRIGGER AS $$
 BEGIN
     IF NEW.quantity <= NEW.reorder_level AND OLD.quantity > OLD.reorder_level THEN
         -- In a real system, this would send alerts
-        RAISE NOTICE 'Inventory for % is low (% %)', NEW.ingredient_name, NEW.quantity, NEW.unit;
+        RAISE NOTICE 'Inventory for % in store % is low (%)',
+            (SELECT ingredient_name FROM inventory WHERE ingredient_id = NEW.ingredient_id),
+            NEW.store_id, NEW.quantity;
     END IF;
     RETURN NEW;
 END;
 $$ LANGUAGE plpgsql;
 
 CREATE TRIGGER trigger_check_inventory_levels
-AFTER UPDATE ON inventory
+AFTER UPDATE ON store_inventory
 FOR EACH ROW EXECUTE FUNCTION check_inventory_levels();
 
 -- Function to update order total price
@@ -266,7 +857,7 @@ RETURNS TRIGGER AS $$
 BEGIN
     UPDATE orders
     SET total_price = (
-        SELECT COALESCE(SUM(total_price), 0)
+        SELECT COALESCE(SUM(quantity * unit_price), 0)
         FROM order_items
         WHERE order_id = 
             CASE 
@@ -287,3 +878,40 @@ CREATE TRIGGER trigger_update_order_total_price
 AFTER INSERT OR UPDATE OR DELETE ON order_items
 FOR EACH ROW EXECUTE FUNCTION update_order_total_price();
 
+-- Whether a menu item can be sold at a given time. Schedules are read in
+-- the time zone tz, that of the store selling the item. An item follows its
+-- own schedules, or those of its categories and their parents when it has
+-- none, and is always orderable when neither has any.
+CREATE OR REPLACE FUNCTION menu_item_orderable(item UUID, at TIMESTAMP WITH TIME ZONE, tz TEXT)
+RETURNS BOOLEAN AS $$
+    WITH RECURSIVE item_categories AS (
+        SELECT c.category_id, c.parent_id
+        FROM menu_item_categories mc
+        JOIN categories c USING(category_id)
+        WHERE mc.menu_item_id = item
+        UNION
+        SELECT p.category_id, p.parent_id
+        FROM categories p
+        JOIN item_categories ic ON p.category_id = ic.parent_id
+    ),
+    windows AS (
+        SELECT s.* FROM menu_schedules s WHERE s.menu_item_id = item
+        UNION ALL
+        SELECT s.* FROM menu_schedules s
+        JOIN item_categories ic USING(category_id)
+        WHERE NOT EXISTS (SELECT 1 FROM menu_schedules WHERE menu_item_id = item)
+    )
+    SELECT NOT EXISTS (SELECT 1 FROM windows) OR EXISTS (
+        SELECT 1 FROM windows w, (SELECT at AT TIME ZONE tz AS local_at) l
+        WHERE EXTRACT(ISODOW FROM l.local_at)::int = ANY(w.days_of_week)
+        AND (w.start_date IS NULL OR l.local_at::date >= w.start_date)
+        AND (w.end_date IS NULL OR l.local_at::date <= w.end_date)
+        AND CASE
+            WHEN w.start_time IS NULL AND w.end_time IS NULL THEN TRUE
+            WHEN w.end_time IS NULL THEN l.local_at::time >= w.start_time
+            WHEN w.start_time IS NULL THEN l.local_at::time < w.end_time
+            WHEN w.start_time <= w.end_time THEN l.local_at::time >= w.start_time AND l.local_at::time < w.end_time
+            ELSE l.local_at::time >= w.start_time OR l.local_at::time < w.end_time
+        END
+    );
+$$ LANGUAGE sql STABLE;
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"frappuccino/internal/service"
+	"frappuccino/models"
+	"net/http"
+	"strings"
+)
+
+type AccountHandler struct {
+	accountService service.AccountServiceInf
+}
+
+func NewAccountHandler(service service.AccountServiceInf) *AccountHandler {
+	return &AccountHandler{accountService: service}
+}
+
+// customerKey is the context key of the logged-in customer.
+type customerKey struct{}
+
+// customerFromRequest returns the id of the logged-in customer, or "" when
+// the request does not come from a customer session.
+func customerFromRequest(r *http.Request) string {
+	id, _ := r.Context().Value(customerKey{}).(string)
+	return id
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// Customer lets only logged-in customers through, each acting on their own
+// account: the {id} path value is set to the customer of the session, so
+// that customer endpoints serve the customer themselves.
+func (h *AccountHandler) Customer(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		CustomerId, err := h.accountService.Authenticate(r.Context(), bearerToken(r))
+		if err != nil {
+			writeAccountError(w, "failed to authenticate", err)
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), customerKey{}, CustomerId))
+		r.SetPathValue("id", CustomerId)
+		next(w, r)
+	}
+}
+
+// Register opens an account for a new customer and returns their session.
+func (h *AccountHandler) Register(w http.ResponseWriter, r *http.Request) {
+	var input models.AccountRegistration
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeCustomerInputError(w, err)
+		return
+	}
+	defer r.Body.Close()
+	session, err := h.accountService.Register(r.Context(), &input)
+	if err != nil {
+		writeAccountError(w, "failed to register", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session)
+}
+
+// Login logs a customer in with their email or phone number and password.
+func (h *AccountHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var input models.Login
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	session, err := h.accountService.Login(r.Context(), input)
+	if err != nil {
+		writeAccountError(w, "failed to log in", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+}
+
+// SendLoginCode sends a login code to the email or phone number of the body.
+// It answers 202 Accepted whether or not there is such a customer.
+func (h *AccountHandler) SendLoginCode(w http.ResponseWriter, r *http.Request) {
+	var input models.Login
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if err := h.accountService.SendLoginCode(r.Context(), input); err != nil {
+		writeAccountError(w, "failed to send login code", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte(`{"message":"If the customer exists, a login code was sent"}`))
+}
+
+// LoginWithCode logs a customer in with a code of SendLoginCode.
+func (h *AccountHandler) LoginWithCode(w http.ResponseWriter, r *http.Request) {
+	var input models.Login
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	session, err := h.accountService.LoginWithCode(r.Context(), input)
+	if err != nil {
+		writeAccountError(w, "failed to log in", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+}
+
+// Logout ends the session of the request.
+func (h *AccountHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if err := h.accountService.Logout(r.Context(), bearerToken(r)); err != nil {
+		writeAccountError(w, "failed to log out", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"Logged out successfully"}`))
+}
+
+// SetPassword sets the password of the logged-in customer and logs them out
+// of their other sessions. A customer who has a password gives it as
+// current_password.
+func (h *AccountHandler) SetPassword(w http.ResponseWriter, r *http.Request) {
+	var input models.PasswordChange
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	err := h.accountService.SetPassword(r.Context(), customerFromRequest(r), bearerToken(r), input)
+	if err != nil {
+		writeAccountError(w, "failed to set password", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"Password set successfully"}`))
+}
+
+func writeAccountError(w http.ResponseWriter, message string, err error) {
+	var invalid *models.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		writeValidationError(w, invalid)
+	case errors.Is(err, models.ErrInvalidLogin), errors.Is(err, models.ErrInvalidPreferences):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrInvalidCredentials), errors.Is(err, models.ErrUnauthenticated):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="customer"`)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, models.ErrLoginLocked):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, models.ErrLoginCodesUnavailable):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Customer not found", http.StatusNotFound)
+	default:
+		http.Error(w, message+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"frappuccino/internal/service"
+	"frappuccino/models"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type AggregationHandler struct {
@@ -17,8 +23,12 @@ func NewAggregationHandler(service service.AggregationServiceInf) *AggregationHa
 }
 
 func (h *AggregationHandler) PopularItems(w http.ResponseWriter, r *http.Request) {
-	popularItems, err := h.aggregationService.PopularItems()
+	popularItems, err := h.aggregationService.PopularItems(storeFromRequest(r), r.URL.Query().Get("group_by"))
 	if err != nil {
+		if errors.Is(err, models.ErrInvalidGroupBy) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Failed to get popular items", http.StatusInternalServerError)
 		return
 	}
@@ -29,14 +39,37 @@ func (h *AggregationHandler) PopularItems(w http.ResponseWriter, r *http.Request
 	}
 }
 
+// SalesByCategory reports sales per category, or with ?group_by=parent per top-level category.
+func (h *AggregationHandler) SalesByCategory(w http.ResponseWriter, r *http.Request) {
+	sales, err := h.aggregationService.SalesByCategory(storeFromRequest(r), r.URL.Query().Get("group_by"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidGroupBy) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to get sales by category", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(sales); err != nil {
+		http.Error(w, "Failed to encode sales by category", http.StatusInternalServerError)
+		return
+	}
+}
+
 func (h *AggregationHandler) TotalPrice(w http.ResponseWriter, r *http.Request) {
-	totalPrice, err := h.aggregationService.TotalPrice()
+	storeID := storeFromRequest(r)
+	totalPrice, err := h.aggregationService.TotalPrice(storeID)
 	if err != nil {
 		http.Error(w, "Failed to get total price", http.StatusInternalServerError)
 		return
 	}
+	response := map[string]any{"total_price": totalPrice}
+	if storeID != "" {
+		response["store_id"] = storeID
+	}
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]float64{"total_price": totalPrice}); err != nil {
+	if err := json.NewEncoder(w).Encode(response); err != nil {
 		http.Error(w, "Failed to encode total price", http.StatusInternalServerError)
 		return
 	}
@@ -77,3 +110,113 @@ func (h *AggregationHandler) OrderedItemByPeriod(w http.ResponseWriter, r *http.
 		return
 	}
 }
+
+// CustomerSegments reports the RFM scores and segment of each customer, only
+// those of ?segment= when given, as JSON or, with ?format=csv, as CSV.
+func (h *AggregationHandler) CustomerSegments(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		http.Error(w, "format must be csv or json", http.StatusBadRequest)
+		return
+	}
+	segments, err := h.aggregationService.CustomerSegments(r.Context(), storeFromRequest(r), query.Get("segment"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidSegment) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to get customer segments", http.StatusInternalServerError)
+		return
+	}
+	if format == "csv" {
+		name := "customers"
+		if segments.Segment != "" {
+			name += "-" + segments.Segment
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.csv"`)
+		if err := writeCustomerSegmentsCSV(w, segments.Customers); err != nil {
+			log.Printf("failed to write customer segments csv: %v", err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(segments); err != nil {
+		http.Error(w, "Failed to encode customer segments", http.StatusInternalServerError)
+		return
+	}
+}
+
+// customerSegmentsCSVHeader is the layout of customer segment CSV files.
+var customerSegmentsCSVHeader = []string{
+	"customer_id", "full_name", "email", "phone_number", "segment", "recency", "frequency", "monetary",
+	"last_order_at", "days_since_last_order", "orders", "spent",
+}
+
+func writeCustomerSegmentsCSV(w io.Writer, customers []models.CustomerRFM) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(customerSegmentsCSVHeader); err != nil {
+		return err
+	}
+	for _, c := range customers {
+		err := out.Write([]string{
+			c.CustomerId, c.FullName, c.Email, c.PhoneNumber, c.Segment,
+			strconv.Itoa(c.Recency), strconv.Itoa(c.Frequency), strconv.Itoa(c.Monetary),
+			c.LastOrderAt.Format(time.RFC3339), strconv.Itoa(c.DaysSince), strconv.Itoa(c.Orders),
+			strconv.FormatFloat(c.Spent, 'f', 2, 64),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// CohortRetention reports the monthly cohort retention table, for the
+// cohorts from ?from=YYYY-MM on over ?months= months.
+func (h *AggregationHandler) CohortRetention(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var months int
+	if query.Get("months") != "" {
+		var err error
+		if months, err = strconv.Atoi(query.Get("months")); err != nil || months <= 0 {
+			http.Error(w, models.ErrInvalidCohortRange.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	retention, err := h.aggregationService.CohortRetention(r.Context(), storeFromRequest(r), query.Get("from"), months)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCohortRange) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to get cohort retention", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(retention); err != nil {
+		http.Error(w, "Failed to encode cohort retention", http.StatusInternalServerError)
+		return
+	}
+}
+
+// LifetimeValue reports lifetime value per customer, or with
+// ?group_by=cohort per signup cohort.
+func (h *AggregationHandler) LifetimeValue(w http.ResponseWriter, r *http.Request) {
+	ltv, err := h.aggregationService.LifetimeValue(r.Context(), storeFromRequest(r), r.URL.Query().Get("group_by"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidGroupBy) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to get lifetime value", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ltv); err != nil {
+		http.Error(w, "Failed to encode lifetime value", http.StatusInternalServerError)
+		return
+	}
+}
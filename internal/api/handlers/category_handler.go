@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"frappuccino/internal/service"
+	"frappuccino/models"
+	"frappuccino/utils"
+	"net/http"
+)
+
+type CategoryHandler struct {
+	categoryService service.CategoryServiceInf
+}
+
+func NewCategoryHandler(service service.CategoryServiceInf) *CategoryHandler {
+	return &CategoryHandler{categoryService: service}
+}
+
+func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	var input models.Category
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	err := h.categoryService.Create(r.Context(), &input)
+	if err != nil {
+		writeCategoryError(w, "failed to create category", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(input)
+}
+
+// GetCategories returns the category tree in the locale of Accept-Language.
+func (h *CategoryHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.categoryService.GetAll(r.Context(), preferredLocales(r))
+	if err != nil {
+		http.Error(w, "failed to get categories", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Vary", "Accept-Language")
+	json.NewEncoder(w).Encode(categories)
+}
+
+func (h *CategoryHandler) GetCategoryByID(w http.ResponseWriter, r *http.Request) {
+	category, err := h.categoryService.GetCategoryByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeCategoryError(w, "failed to get category", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(category)
+}
+
+func (h *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	var input models.Category
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	input.CategoryId = utils.TEXT(r.PathValue("id"))
+
+	err := h.categoryService.UpdateCategoryByID(r.Context(), &input)
+	if err != nil {
+		writeCategoryError(w, "failed to update category", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(input)
+}
+
+func (h *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	err := h.categoryService.DeleteCategoryByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeCategoryError(w, "failed to delete category", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"Category deleted successfully"}`))
+}
+
+func writeCategoryError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidCategory):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrCategoryInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "category not found", http.StatusNotFound)
+	default:
+		http.Error(w, message+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"frappuccino/internal/service"
 	"frappuccino/models"
+	"frappuccino/utils"
 	"log"
 	"net/http"
+	"strings"
 )
 
 type CustomerHandler struct {
@@ -19,7 +23,7 @@ func NewCustomerHandler(service service.CustomerServiceInf) *CustomerHandler {
 func (h *CustomerHandler) CreateCustomer(w http.ResponseWriter, r *http.Request) {
 	var input models.Customer
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		writeCustomerInputError(w, err)
 		return
 	}
 	defer r.Body.Close()
@@ -28,16 +32,33 @@ func (h *CustomerHandler) CreateCustomer(w http.ResponseWriter, r *http.Request)
 	err := h.customerService.Create(r.Context(), &input)
 	if err != nil {
 		log.Printf("failed to create Customer: %v", err) // <- вот здесь логируем ошибку
-		http.Error(w, "failed to create Customer", http.StatusInternalServerError)
+		writeCustomerError(w, "failed to create Customer", err)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
 }
 
 func (h *CustomerHandler) GetAllCustomers(w http.ResponseWriter, r *http.Request) {
-	customer, err := h.customerService.GetAll(r.Context())
+	query := r.URL.Query()
+	filter := models.CustomerFilter{
+		Query:    query.Get("q"),
+		Archived: query.Get("archived") == "true",
+		Preferences: models.Preferences{
+			FavouriteDrink: utils.TEXT(query.Get("favourite_drink")),
+			MilkPreference: utils.TEXT(query.Get("milk_preference")),
+			SugarLevel:     utils.TEXT(query.Get("sugar_level")),
+		},
+	}
+	for _, allergy := range query["allergy"] {
+		for _, allergy := range strings.Split(allergy, ",") {
+			if allergy = strings.TrimSpace(allergy); allergy != "" {
+				filter.Preferences.Allergies = append(filter.Preferences.Allergies, allergy)
+			}
+		}
+	}
+	customer, err := h.customerService.GetAll(r.Context(), filter)
 	if err != nil {
-		http.Error(w, "failed to get Customers", http.StatusInternalServerError)
+		writeCustomerError(w, "failed to get Customers", err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -45,7 +66,7 @@ func (h *CustomerHandler) GetAllCustomers(w http.ResponseWriter, r *http.Request
 }
 
 func (h *CustomerHandler) GetCustomerByID(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id") // FIX
+	idStr := r.PathValue("id")
 	if idStr == "" {
 		http.Error(w, "missing id", http.StatusBadRequest)
 		return
@@ -64,14 +85,15 @@ func (h *CustomerHandler) GetCustomerByID(w http.ResponseWriter, r *http.Request
 func (h *CustomerHandler) UpdateCustomer(w http.ResponseWriter, r *http.Request) {
 	var input models.Customer
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		writeCustomerInputError(w, err)
 		return
 	}
 	defer r.Body.Close()
+	input.CustomerId = utils.TEXT(r.PathValue("id"))
 
 	err := h.customerService.UpdateCustomerByID(r.Context(), &input)
 	if err != nil {
-		http.Error(w, "failed to update customer: "+err.Error(), http.StatusInternalServerError)
+		writeCustomerError(w, "failed to update customer", err)
 		return
 	}
 
@@ -79,8 +101,9 @@ func (h *CustomerHandler) UpdateCustomer(w http.ResponseWriter, r *http.Request)
 	w.Write([]byte(`{"message":"Customer updated successfully"}`))
 }
 
+// DeleteCustomer archives a customer; see RestoreCustomer.
 func (h *CustomerHandler) DeleteCustomer(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id") // FIX
+	idStr := r.PathValue("id")
 	if idStr == "" {
 		http.Error(w, "missing id", http.StatusBadRequest)
 		return
@@ -88,6 +111,10 @@ func (h *CustomerHandler) DeleteCustomer(w http.ResponseWriter, r *http.Request)
 
 	err := h.customerService.DeleteCustomerByID(r.Context(), idStr)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Customer not found", http.StatusNotFound)
+			return
+		}
 		http.Error(w, "failed to delete customer: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -95,3 +122,136 @@ func (h *CustomerHandler) DeleteCustomer(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"message":"Customer deleted successfully"}`))
 }
+
+func (h *CustomerHandler) RestoreCustomer(w http.ResponseWriter, r *http.Request) {
+	err := h.customerService.RestoreCustomerByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		var invalid *models.ValidationError
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, "archived customer not found", http.StatusNotFound)
+		case errors.As(err, &invalid):
+			writeValidationError(w, invalid)
+		default:
+			http.Error(w, "failed to restore customer: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"Customer restored successfully"}`))
+}
+
+func (h *CustomerHandler) GetDuplicates(w http.ResponseWriter, r *http.Request) {
+	duplicates, err := h.customerService.Duplicates(r.Context())
+	if err != nil {
+		writeCustomerError(w, "failed to find duplicate customers", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(duplicates)
+}
+
+func (h *CustomerHandler) MergeCustomers(w http.ResponseWriter, r *http.Request) {
+	var input models.CustomerMerge
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	result, err := h.customerService.Merge(r.Context(), input)
+	if err != nil {
+		writeCustomerError(w, "failed to merge customers", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// ExportCustomer sends all the data kept about a customer as a JSON file.
+func (h *CustomerHandler) ExportCustomer(w http.ResponseWriter, r *http.Request) {
+	export, err := h.customerService.Export(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeCustomerError(w, "failed to export customer", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="customer-`+string(export.Customer.CustomerId)+`.json"`)
+	json.NewEncoder(w).Encode(export)
+}
+
+// EraseCustomer anonymises a customer. The optional body tells who asked
+// for it, by default the staff user erasing, and why, for the audit trail.
+func (h *CustomerHandler) EraseCustomer(w http.ResponseWriter, r *http.Request) {
+	var input models.CustomerErasure
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+	}
+	defer r.Body.Close()
+	erasure := models.CustomerErasure{
+		CustomerId:  utils.TEXT(r.PathValue("id")),
+		RequestedBy: input.RequestedBy,
+		Reason:      input.Reason,
+	}
+	if staff, ok := staffFromRequest(r); ok && erasure.RequestedBy == "" {
+		erasure.RequestedBy = staff.Username
+	}
+	if err := h.customerService.Erase(r.Context(), &erasure); err != nil {
+		writeCustomerError(w, "failed to erase customer", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(erasure)
+}
+
+func (h *CustomerHandler) GetErasures(w http.ResponseWriter, r *http.Request) {
+	erasures, err := h.customerService.Erasures(r.Context(), r.URL.Query().Get("customer_id"))
+	if err != nil {
+		writeCustomerError(w, "failed to get customer erasures", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(erasures)
+}
+
+func writeCustomerError(w http.ResponseWriter, message string, err error) {
+	var invalid *models.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		writeValidationError(w, invalid)
+	case errors.Is(err, models.ErrInvalidMerge), errors.Is(err, models.ErrInvalidPreferences):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrCustomerErased):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Customer not found", http.StatusNotFound)
+	default:
+		http.Error(w, message+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeCustomerInputError reports a customer body that could not be decoded,
+// telling why when it has preferences outside their schema.
+func writeCustomerInputError(w http.ResponseWriter, err error) {
+	if errors.Is(err, models.ErrInvalidPreferences) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, "Invalid input", http.StatusBadRequest)
+}
+
+// writeValidationError reports the invalid fields of an input as
+// {"error": ..., "fields": {field: problem}}, with 409 Conflict when a field
+// clashes with another record and 400 Bad Request otherwise.
+func writeValidationError(w http.ResponseWriter, err *models.ValidationError) {
+	status := http.StatusBadRequest
+	if errors.Is(err, models.ErrContactTaken) {
+		status = http.StatusConflict
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"error": err.Err.Error(), "fields": err.Fields})
+}
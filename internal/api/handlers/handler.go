@@ -3,18 +3,41 @@ package handlers
 import "frappuccino/internal/service"
 
 type Handler struct {
-	CustomerHandler    *CustomerHandler
-	InventoryHandler   *InventoryHandler
-	MenuHandler        *MenuHandler
-	OrderHandler       *OrderHandler
-	AggregationHandler *AggregationHandler
+	CustomerHandler        *CustomerHandler
+	InventoryHandler       *InventoryHandler
+	MenuHandler            *MenuHandler
+	OrderHandler           *OrderHandler
+	AggregationHandler     *AggregationHandler
+	StoreHandler           *StoreHandler
+	TransferHandler        *TransferHandler
+	ModifierHandler        *ModifierHandler
+	ScheduleHandler        *ScheduleHandler
+	MenuVersionHandler     *MenuVersionHandler
+	PriceAdjustmentHandler *PriceAdjustmentHandler
+	CategoryHandler        *CategoryHandler
+	TranslationHandler     *TranslationHandler
+	SegmentHandler         *SegmentHandler
+	AccountHandler         *AccountHandler
+	StaffHandler           *StaffHandler
 }
 
 func New(service *service.Service) *Handler {
 	return &Handler{
-		CustomerHandler:  NewCustomerHandler(service.CustomerService),
-		InventoryHandler: NewInventoryHandler(service.InventoryService),
-		MenuHandler:      NewMenuHandler(service.MenuService),
-		OrderHandler:     NewOrderHandler(service.OrderService),
+		CustomerHandler:        NewCustomerHandler(service.CustomerService),
+		InventoryHandler:       NewInventoryHandler(service.InventoryService),
+		MenuHandler:            NewMenuHandler(service.MenuService),
+		OrderHandler:           NewOrderHandler(service.OrderService),
+		AggregationHandler:     NewAggregationHandler(service.AggregationService),
+		StoreHandler:           NewStoreHandler(service.StoreService),
+		TransferHandler:        NewTransferHandler(service.TransferService),
+		ModifierHandler:        NewModifierHandler(service.ModifierService),
+		ScheduleHandler:        NewScheduleHandler(service.ScheduleService),
+		MenuVersionHandler:     NewMenuVersionHandler(service.MenuVersionService),
+		PriceAdjustmentHandler: NewPriceAdjustmentHandler(service.PriceAdjustmentService),
+		CategoryHandler:        NewCategoryHandler(service.CategoryService),
+		TranslationHandler:     NewTranslationHandler(service.TranslationService),
+		SegmentHandler:         NewSegmentHandler(service.SegmentService),
+		AccountHandler:         NewAccountHandler(service.AccountService),
+		StaffHandler:           NewStaffHandler(service.StaffService),
 	}
 }
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"frappuccino/internal/service"
 	"frappuccino/models"
+	"frappuccino/utils"
 	"log"
 	"net/http"
 )
@@ -24,6 +27,7 @@ func (h *InventoryHandler) CreateInventoryIngredient(w http.ResponseWriter, r *h
 	}
 	defer r.Body.Close()
 	log.Printf("input %v", input)
+	input.StoreId = utils.TEXT(storeFromRequest(r))
 
 	err := h.inventoryService.Create(r.Context(), &input)
 	if err != nil {
@@ -35,7 +39,7 @@ func (h *InventoryHandler) CreateInventoryIngredient(w http.ResponseWriter, r *h
 }
 
 func (h *InventoryHandler) GetInventory(w http.ResponseWriter, r *http.Request) {
-	inventory, err := h.inventoryService.GetAll(r.Context())
+	inventory, err := h.inventoryService.GetAll(r.Context(), storeFromRequest(r), r.URL.Query().Get("archived") == "true")
 	if err != nil {
 		http.Error(w, "failed to get inventory", http.StatusInternalServerError)
 		return
@@ -45,13 +49,13 @@ func (h *InventoryHandler) GetInventory(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *InventoryHandler) GetIngredientByID(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id")
+	idStr := r.PathValue("id")
 	if idStr == "" {
 		http.Error(w, "missing id", http.StatusBadRequest)
 		return
 	}
 
-	item, err := h.inventoryService.GetIngredientByID(r.Context(), idStr)
+	item, err := h.inventoryService.GetIngredientByID(r.Context(), storeFromRequest(r), idStr)
 	if err != nil {
 		http.Error(w, "ingredient not found", http.StatusNotFound)
 		return
@@ -68,6 +72,8 @@ func (h *InventoryHandler) UpdateIngredient(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	defer r.Body.Close()
+	input.IngredientId = utils.TEXT(r.PathValue("id"))
+	input.StoreId = utils.TEXT(storeFromRequest(r))
 
 	err := h.inventoryService.UpdateIngredientByID(r.Context(), &input)
 	if err != nil {
@@ -79,8 +85,9 @@ func (h *InventoryHandler) UpdateIngredient(w http.ResponseWriter, r *http.Reque
 	w.Write([]byte(`{"message":"ingredient updated successfully"}`))
 }
 
+// DeleteIngredient archives an ingredient; see RestoreIngredient.
 func (h *InventoryHandler) DeleteIngredient(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id")
+	idStr := r.PathValue("id")
 	if idStr == "" {
 		http.Error(w, "missing id", http.StatusBadRequest)
 		return
@@ -88,6 +95,10 @@ func (h *InventoryHandler) DeleteIngredient(w http.ResponseWriter, r *http.Reque
 
 	err := h.inventoryService.DeleteIngredientByID(r.Context(), idStr)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "ingredient not found", http.StatusNotFound)
+			return
+		}
 		http.Error(w, "failed to delete ingredient: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -95,3 +106,106 @@ func (h *InventoryHandler) DeleteIngredient(w http.ResponseWriter, r *http.Reque
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"message":"ingredient deleted successfully"}`))
 }
+
+func (h *InventoryHandler) RestoreIngredient(w http.ResponseWriter, r *http.Request) {
+	err := h.inventoryService.RestoreIngredientByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "archived ingredient not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to restore ingredient: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"ingredient restored successfully"}`))
+}
+
+func (h *InventoryHandler) SetRecipe(w http.ResponseWriter, r *http.Request) {
+	var input models.Recipe
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	input.IngredientId = utils.TEXT(r.PathValue("id"))
+
+	err := h.inventoryService.SetRecipe(r.Context(), &input)
+	if err != nil {
+		writeRecipeError(w, "failed to set recipe", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(input)
+}
+
+func (h *InventoryHandler) GetRecipe(w http.ResponseWriter, r *http.Request) {
+	recipe, err := h.inventoryService.GetRecipe(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, models.ErrNotPrepared) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "recipe not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recipe)
+}
+
+// Produce makes batches of a prepared ingredient in the store context.
+func (h *InventoryHandler) Produce(w http.ResponseWriter, r *http.Request) {
+	var input models.Production
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	input.IngredientId = utils.TEXT(r.PathValue("id"))
+	input.StoreId = utils.TEXT(storeFromRequest(r))
+
+	err := h.inventoryService.Produce(r.Context(), &input)
+	if err != nil {
+		writeRecipeError(w, "failed to produce ingredient", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(input)
+}
+
+func (h *InventoryHandler) GetIngredientCost(w http.ResponseWriter, r *http.Request) {
+	cost, err := h.inventoryService.GetIngredientCost(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeRecipeError(w, "failed to cost ingredient", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cost)
+}
+
+func (h *InventoryHandler) GetAvailability(w http.ResponseWriter, r *http.Request) {
+	availability, err := h.inventoryService.GetAvailability(r.Context(), storeFromRequest(r), r.PathValue("id"))
+	if err != nil {
+		writeRecipeError(w, "failed to get availability", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(availability)
+}
+
+func writeRecipeError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidIngredientId):
+		http.Error(w, msg+": "+err.Error(), http.StatusNotFound)
+	case errors.Is(err, models.ErrRecipeCycle), errors.Is(err, models.ErrInvalidRecipe), errors.Is(err, models.ErrInvalidVariant),
+		errors.Is(err, models.ErrNotPrepared), errors.Is(err, models.ErrInvalidQuantity),
+		errors.Is(err, models.ErrMissingStore):
+		http.Error(w, msg+": "+err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrInsufficientStock):
+		http.Error(w, msg+": "+err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, msg+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"frappuccino/models"
+	"frappuccino/utils"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// menuCSVHeader is the layout of menu CSV files: one line per recipe line,
+// repeating the item columns. Nutrition per serving is exported but ignored
+// on import, as it is derived from the recipe.
+var menuCSVHeader = []string{
+	"item_name", "item_description", "price", "categories", "ingredient_name", "quantity",
+	"kcal", "sugar_g", "fat_g", "protein_g", "caffeine_mg",
+}
+
+// categorySeparator joins the categories of an item in one CSV column.
+const categorySeparator = "|"
+
+func writeMenuCSV(w io.Writer, items []models.MenuItems) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(menuCSVHeader); err != nil {
+		return err
+	}
+	for _, item := range items {
+		var n models.Nutrition
+		if item.Nutrition != nil {
+			n = *item.Nutrition
+		}
+		record := []string{
+			string(item.ItemName), string(item.ItemDescription), formatDEC(item.Price),
+			strings.Join(item.Categories, categorySeparator), "", "",
+			formatDEC(n.Kcal), formatDEC(n.SugarG), formatDEC(n.FatG), formatDEC(n.ProteinG), formatDEC(n.CaffeineMg),
+		}
+		if len(item.Ingredients) == 0 {
+			if err := out.Write(record); err != nil {
+				return err
+			}
+		}
+		for _, line := range item.Ingredients {
+			record[4], record[5] = string(line.IngredientName), formatDEC(line.Quantity)
+			if err := out.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// readMenuCSV groups the lines of a menu CSV file by item name. Item columns
+// are taken from the first line of each item; a line without an ingredient
+// adds no recipe line.
+func readMenuCSV(r io.Reader) ([]models.MenuImportRow, error) {
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = -1
+	header, err := in.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", models.ErrInvalidImport)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"item_name", "price"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing column %s: %w", required, models.ErrInvalidImport)
+		}
+	}
+
+	var rows []models.MenuImportRow
+	byName := make(map[string]int)
+	for line := 2; ; line++ {
+		record, err := in.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v: %w", line, err, models.ErrInvalidImport)
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		name := field("item_name")
+		i, ok := byName[name]
+		if !ok {
+			price, err := parseDEC(field("price"))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: price %q: %w", line, field("price"), models.ErrInvalidImport)
+			}
+			item := models.MenuItems{
+				ItemName:        utils.TEXT(name),
+				ItemDescription: utils.TEXT(field("item_description")),
+				Price:           price,
+				Categories:      utils.TEXTARR{},
+				Ingredients:     []models.MenuItemsIngredients{},
+			}
+			for _, category := range strings.Split(field("categories"), categorySeparator) {
+				if category = strings.TrimSpace(category); category != "" {
+					item.Categories = append(item.Categories, category)
+				}
+			}
+			i = len(rows)
+			byName[name] = i
+			rows = append(rows, models.MenuImportRow{Row: line, Item: item})
+		}
+
+		if ingredient := field("ingredient_name"); ingredient != "" {
+			quantity, err := parseDEC(field("quantity"))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: quantity %q: %w", line, field("quantity"), models.ErrInvalidImport)
+			}
+			rows[i].Item.Ingredients = append(rows[i].Item.Ingredients, models.MenuItemsIngredients{
+				IngredientName: utils.TEXT(ingredient),
+				Quantity:       quantity,
+			})
+		}
+	}
+	return rows, nil
+}
+
+func parseDEC(s string) (utils.DEC, error) {
+	if s == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return utils.DEC(f), err
+}
+
+func formatDEC(d utils.DEC) string {
+	return strconv.FormatFloat(float64(d), 'f', -1, 64)
+}
@@ -1,11 +1,16 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"frappuccino/internal/service"
 	"frappuccino/models"
+	"frappuccino/utils"
+	"io"
 	"log"
 	"net/http"
+	"strings"
 )
 
 type MenuHandler struct {
@@ -28,6 +33,10 @@ func (h *MenuHandler) CreateMenuItem(w http.ResponseWriter, r *http.Request) {
 	err := h.menuService.Create(r.Context(), &input)
 	if err != nil {
 		log.Printf("failed to create ingredient: %v", err) // <- вот здесь логируем ошибку
+		if errors.Is(err, models.ErrInvalidBundle) || errors.Is(err, models.ErrInvalidCategory) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "failed to create ingredient", http.StatusInternalServerError)
 		return
 	}
@@ -35,29 +44,118 @@ func (h *MenuHandler) CreateMenuItem(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *MenuHandler) GetAllMenu(w http.ResponseWriter, r *http.Request) {
-	items, err := h.menuService.GetAll(r.Context())
+	filter := models.MenuFilter{
+		ExcludeAllergens: queryList(r, "exclude_allergens"),
+		Diets:            queryList(r, "diet"),
+		All:              r.URL.Query().Get("all") == "true",
+		Archived:         r.URL.Query().Get("archived") == "true",
+		Locales:          preferredLocales(r),
+	}
+	items, err := h.menuService.GetAll(r.Context(), storeFromRequest(r), filter)
 	if err != nil {
 		http.Error(w, "failed to get menu", http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Vary", "Accept-Language")
+	json.NewEncoder(w).Encode(items)
+}
+
+// ExportMenu writes the whole menu with recipes and nutrition per serving,
+// as JSON or, with ?format=csv, as CSV.
+func (h *MenuHandler) ExportMenu(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		http.Error(w, "format must be csv or json", http.StatusBadRequest)
+		return
+	}
+	items, err := h.menuService.GetAll(r.Context(), storeFromRequest(r), models.MenuFilter{All: true})
+	if err != nil {
+		http.Error(w, "failed to export menu", http.StatusInternalServerError)
+		return
+	}
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="menu.csv"`)
+		if err := writeMenuCSV(w, items); err != nil {
+			log.Printf("failed to write menu csv: %v", err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(items)
 }
 
+// ImportMenu upserts menu items by name from a JSON array of menu items or,
+// with ?format=csv or a text/csv body, from a menu CSV file. With
+// ?dry_run=true it only reports the errors per row.
+func (h *MenuHandler) ImportMenu(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		format = "csv"
+	}
+
+	var rows []models.MenuImportRow
+	switch format {
+	case "csv":
+		var err error
+		if rows, err = readMenuCSV(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case "", "json":
+		var items []models.MenuItems
+		if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+		for i, item := range items {
+			rows = append(rows, models.MenuImportRow{Row: i + 1, Item: item})
+		}
+	default:
+		http.Error(w, "format must be csv or json", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.menuService.Import(r.Context(), rows, r.URL.Query().Get("dry_run") == "true")
+	if err != nil {
+		http.Error(w, "failed to import menu", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if len(result.Errors) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// queryList splits a comma-separated query parameter into lowercase values.
+func queryList(r *http.Request, key string) []string {
+	var values []string
+	for _, value := range strings.Split(r.URL.Query().Get(key), ",") {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func (h *MenuHandler) GetIngredientByID(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id") // FIX
+	idStr := r.PathValue("id")
 	if idStr == "" {
 		http.Error(w, "missing id", http.StatusBadRequest)
 		return
 	}
 
-	item, err := h.menuService.GetItemByID(r.Context(), idStr)
+	item, err := h.menuService.GetItemByID(r.Context(), storeFromRequest(r), idStr, preferredLocales(r))
 	if err != nil {
 		http.Error(w, "Item not found", http.StatusNotFound)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Vary", "Accept-Language")
 	json.NewEncoder(w).Encode(item)
 }
 
@@ -68,9 +166,14 @@ func (h *MenuHandler) UpdateMenuItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer r.Body.Close()
+	input.MenuItemId = utils.TEXT(r.PathValue("id"))
 
 	err := h.menuService.UpdateItemByID(r.Context(), &input)
 	if err != nil {
+		if errors.Is(err, models.ErrInvalidBundle) || errors.Is(err, models.ErrInvalidCategory) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "failed to update Item: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -79,8 +182,9 @@ func (h *MenuHandler) UpdateMenuItem(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"message":"Item updated successfully"}`))
 }
 
+// DeleteMenuItem archives a menu item; see RestoreMenuItem.
 func (h *MenuHandler) DeleteMenuItem(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id") // FIX
+	idStr := r.PathValue("id")
 	if idStr == "" {
 		http.Error(w, "missing id", http.StatusBadRequest)
 		return
@@ -88,6 +192,10 @@ func (h *MenuHandler) DeleteMenuItem(w http.ResponseWriter, r *http.Request) {
 
 	err := h.menuService.DeleteItemByID(r.Context(), idStr)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Item not found", http.StatusNotFound)
+			return
+		}
 		http.Error(w, "failed to delete item: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -95,3 +203,118 @@ func (h *MenuHandler) DeleteMenuItem(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"message":"item deleted successfully"}`))
 }
+
+func (h *MenuHandler) RestoreMenuItem(w http.ResponseWriter, r *http.Request) {
+	err := h.menuService.RestoreItemByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "archived item not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to restore item: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"item restored successfully"}`))
+}
+
+func (h *MenuHandler) GetItemCost(w http.ResponseWriter, r *http.Request) {
+	cost, err := h.menuService.GetItemCost(r.Context(), r.PathValue("id"), r.URL.Query().Get("variant_id"))
+	if err != nil {
+		writeRecipeError(w, "failed to cost menu item", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cost)
+}
+
+func (h *MenuHandler) GetItemAvailability(w http.ResponseWriter, r *http.Request) {
+	availability, err := h.menuService.GetItemAvailability(r.Context(), storeFromRequest(r), r.PathValue("id"), r.URL.Query().Get("variant_id"))
+	if err != nil {
+		writeRecipeError(w, "failed to get availability", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(availability)
+}
+
+// GetItemNutrition returns the nutrition per serving of a menu item and its variants.
+func (h *MenuHandler) GetItemNutrition(w http.ResponseWriter, r *http.Request) {
+	nutrition, err := h.menuService.GetItemNutrition(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeRecipeError(w, "failed to get menu item nutrition", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(nutrition)
+}
+
+// UploadMenuItemImage adds an image to a menu item, sent either as the
+// "image" field of a multipart form or as the raw request body.
+func (h *MenuHandler) UploadMenuItemImage(w http.ResponseWriter, r *http.Request) {
+	// leave room for the multipart framing around the file
+	r.Body = http.MaxBytesReader(w, r.Body, service.MaxImageBytes+1<<20)
+	defer r.Body.Close()
+
+	var source io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("image")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeImageError(w, "failed to read image", models.ErrImageTooLarge)
+				return
+			}
+			http.Error(w, "missing image file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		source = file
+	}
+	data, err := io.ReadAll(io.LimitReader(source, service.MaxImageBytes+1))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			err = models.ErrImageTooLarge
+		}
+		writeImageError(w, "failed to read image", err)
+		return
+	}
+	if len(data) == 0 {
+		http.Error(w, "missing image file", http.StatusBadRequest)
+		return
+	}
+
+	image, err := h.menuService.UploadImage(r.Context(), r.PathValue("id"), data)
+	if err != nil {
+		writeImageError(w, "failed to upload image", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(image)
+}
+
+func (h *MenuHandler) DeleteMenuItemImage(w http.ResponseWriter, r *http.Request) {
+	err := h.menuService.DeleteImage(r.Context(), r.PathValue("id"), r.PathValue("image_id"))
+	if err != nil {
+		writeImageError(w, "failed to delete image", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"image deleted successfully"}`))
+}
+
+func writeImageError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, models.ErrImageTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, models.ErrInvalidImage):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Item or image not found", http.StatusNotFound)
+	default:
+		http.Error(w, message+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
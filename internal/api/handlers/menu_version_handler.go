@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"frappuccino/internal/service"
+	"frappuccino/models"
+	"frappuccino/utils"
+	"net/http"
+)
+
+type MenuVersionHandler struct {
+	versionService service.MenuVersionServiceInf
+}
+
+func NewMenuVersionHandler(service service.MenuVersionServiceInf) *MenuVersionHandler {
+	return &MenuVersionHandler{versionService: service}
+}
+
+// publishInput is the body of POST /menu-versions/{id}/publish. Without
+// publish_at, or with a time in the past, the version is published now.
+type publishInput struct {
+	PublishAt *utils.TIME `json:"publish_at"`
+}
+
+// CreateMenuVersion saves a draft. A draft without items copies the live menu.
+func (h *MenuVersionHandler) CreateMenuVersion(w http.ResponseWriter, r *http.Request) {
+	var input models.MenuVersion
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	err := h.versionService.Create(r.Context(), &input)
+	if err != nil {
+		writeMenuVersionError(w, "failed to create menu version", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(input)
+}
+
+// GetMenuVersions lists menu versions, filtered by ?status=.
+func (h *MenuVersionHandler) GetMenuVersions(w http.ResponseWriter, r *http.Request) {
+	versions, err := h.versionService.GetAll(r.Context(), r.URL.Query().Get("status"))
+	if err != nil {
+		http.Error(w, "failed to get menu versions", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
+
+func (h *MenuVersionHandler) GetMenuVersionByID(w http.ResponseWriter, r *http.Request) {
+	version, err := h.versionService.GetVersionByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeMenuVersionError(w, "failed to get menu version", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(version)
+}
+
+func (h *MenuVersionHandler) UpdateMenuVersion(w http.ResponseWriter, r *http.Request) {
+	var input models.MenuVersion
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	input.VersionId = utils.TEXT(r.PathValue("id"))
+
+	err := h.versionService.UpdateVersionByID(r.Context(), &input)
+	if err != nil {
+		writeMenuVersionError(w, "failed to update menu version", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(input)
+}
+
+func (h *MenuVersionHandler) DeleteMenuVersion(w http.ResponseWriter, r *http.Request) {
+	err := h.versionService.DeleteVersionByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeMenuVersionError(w, "failed to delete menu version", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"Menu version deleted successfully"}`))
+}
+
+func (h *MenuVersionHandler) PublishMenuVersion(w http.ResponseWriter, r *http.Request) {
+	var input publishInput
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+	}
+	defer r.Body.Close()
+
+	id := r.PathValue("id")
+	if err := h.versionService.Publish(r.Context(), id, input.PublishAt); err != nil {
+		writeMenuVersionError(w, "failed to publish menu version", err)
+		return
+	}
+	version, err := h.versionService.GetVersionByID(r.Context(), id)
+	if err != nil {
+		writeMenuVersionError(w, "failed to get menu version", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(version)
+}
+
+// RollbackMenuVersion publishes a copy of an earlier version.
+func (h *MenuVersionHandler) RollbackMenuVersion(w http.ResponseWriter, r *http.Request) {
+	version, err := h.versionService.Rollback(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeMenuVersionError(w, "failed to roll back menu", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(version)
+}
+
+// GetMenuVersionDiff compares a version with ?against=, by default with the published version.
+func (h *MenuVersionHandler) GetMenuVersionDiff(w http.ResponseWriter, r *http.Request) {
+	diff, err := h.versionService.Diff(r.Context(), r.PathValue("id"), r.URL.Query().Get("against"))
+	if err != nil {
+		writeMenuVersionError(w, "failed to compare menu versions", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+func writeMenuVersionError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, models.ErrMenuVersionStatus), errors.Is(err, models.ErrMenuVersionConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, models.ErrInvalidBundle), errors.Is(err, models.ErrInvalidCategory):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "menu version not found", http.StatusNotFound)
+	default:
+		http.Error(w, message+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"frappuccino/internal/service"
+	"frappuccino/models"
+	"frappuccino/utils"
+	"log"
+	"net/http"
+)
+
+type ModifierHandler struct {
+	modifierService service.ModifierServiceInf
+}
+
+func NewModifierHandler(service service.ModifierServiceInf) *ModifierHandler {
+	return &ModifierHandler{modifierService: service}
+}
+
+// menuItemGroupsInput is the body of PUT /menu/{id}/modifier-groups.
+type menuItemGroupsInput struct {
+	ModifierGroupIds []string `json:"modifier_group_ids"`
+}
+
+func (h *ModifierHandler) CreateModifierGroup(w http.ResponseWriter, r *http.Request) {
+	input := models.ModifierGroup{MaxSelections: 1}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	err := h.modifierService.Create(r.Context(), &input)
+	if err != nil {
+		log.Printf("failed to create modifier group: %v", err)
+		writeModifierError(w, "failed to create modifier group", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(input)
+}
+
+func (h *ModifierHandler) GetModifierGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.modifierService.GetAll(r.Context())
+	if err != nil {
+		http.Error(w, "failed to get modifier groups", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(groups)
+}
+
+func (h *ModifierHandler) GetModifierGroupByID(w http.ResponseWriter, r *http.Request) {
+	group, err := h.modifierService.GetGroupByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "modifier group not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(group)
+}
+
+func (h *ModifierHandler) UpdateModifierGroup(w http.ResponseWriter, r *http.Request) {
+	input := models.ModifierGroup{MaxSelections: 1}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	input.ModifierGroupId = utils.TEXT(r.PathValue("id"))
+
+	err := h.modifierService.UpdateGroupByID(r.Context(), &input)
+	if err != nil {
+		writeModifierError(w, "failed to update modifier group", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(input)
+}
+
+func (h *ModifierHandler) DeleteModifierGroup(w http.ResponseWriter, r *http.Request) {
+	err := h.modifierService.DeleteGroupByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "failed to delete modifier group: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"Modifier group deleted successfully"}`))
+}
+
+// SetMenuItemGroups replaces the modifier groups offered on a menu item.
+func (h *ModifierHandler) SetMenuItemGroups(w http.ResponseWriter, r *http.Request) {
+	var input menuItemGroupsInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	err := h.modifierService.SetMenuItemGroups(r.Context(), r.PathValue("id"), input.ModifierGroupIds)
+	if err != nil {
+		http.Error(w, "failed to set menu item modifier groups: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"Menu item modifier groups updated successfully"}`))
+}
+
+func writeModifierError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidModifierGroup), errors.Is(err, models.ErrInvalidModifier):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, message+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -1,11 +1,16 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"frappuccino/internal/service"
 	"frappuccino/models"
+	"frappuccino/utils"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type OrderHandler struct {
@@ -23,18 +28,38 @@ func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer r.Body.Close()
-	log.Println("input %v", input)
+	log.Printf("input %v", input)
+	input.StoreId = utils.TEXT(storeFromRequest(r))
+	// a logged-in customer orders for themselves
+	if customer := customerFromRequest(r); customer != "" {
+		input.CustomerId = utils.TEXT(customer)
+	}
+	if input.StoreId == "" {
+		http.Error(w, models.ErrMissingStore.Error(), http.StatusBadRequest)
+		return
+	}
 	err := h.orderServise.Create(r.Context(), &input)
 	if err != nil {
-		log.Printf("failed to create ingredient: %v", err) // <- вот здесь логируем ошибку
-		http.Error(w, "failed to create ingredient", http.StatusInternalServerError)
+		log.Printf("failed to create order: %v", err) // <- вот здесь логируем ошибку
+		if errors.Is(err, models.ErrInsufficientStock) || errors.Is(err, models.ErrItemNotAvailable) ||
+			errors.Is(err, models.ErrOutsideSchedule) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, models.ErrInvalidVariant) || errors.Is(err, models.ErrInvalidModifier) || errors.Is(err, models.ErrInvalidBundle) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "failed to create order", http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(input)
 }
 
 func (h *OrderHandler) Orders(w http.ResponseWriter, r *http.Request) {
-	orders, err := h.orderServise.Orders(r.Context())
+	orders, err := h.orderServise.Orders(r.Context(), storeFromRequest(r))
 	if err != nil {
 		http.Error(w, "failed to get orders", http.StatusInternalServerError)
 		return
@@ -51,9 +76,10 @@ func (h *OrderHandler) UpdateOrderItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer r.Body.Close()
-	err := h.orderServise.UpdateOrderItemByID(r.Context(), &input)
+	input.OrderId = utils.TEXT(r.PathValue("id"))
+	err := h.orderServise.UpdateOrderItemByID(r.Context(), &input, storeFromRequest(r))
 	if err != nil {
-		http.Error(w, "failed to update order Item: "+err.Error(), http.StatusInternalServerError)
+		writeOrderError(w, "failed to update order Item", err)
 		return
 	}
 
@@ -62,14 +88,14 @@ func (h *OrderHandler) UpdateOrderItem(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *OrderHandler) DeleteOrderByID(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id") // FIX
+	idStr := r.PathValue("id")
 	if idStr == "" {
 		http.Error(w, "missing id", http.StatusBadRequest)
 		return
 	}
-	err := h.orderServise.DeleteOrderByID(r.Context(), idStr)
+	err := h.orderServise.DeleteOrderByID(r.Context(), idStr, storeFromRequest(r))
 	if err != nil {
-		http.Error(w, "failed to delete order: "+err.Error(), http.StatusInternalServerError)
+		writeOrderError(w, "failed to delete order", err)
 		return
 	}
 
@@ -78,12 +104,12 @@ func (h *OrderHandler) DeleteOrderByID(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *OrderHandler) GetOrderByID(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id") // FIX
+	idStr := r.PathValue("id")
 	if idStr == "" {
 		http.Error(w, "missing id", http.StatusBadRequest)
 		return
 	}
-	order, err := h.orderServise.GetOrderByID(r.Context(), idStr)
+	order, err := h.orderServise.GetOrderByID(r.Context(), idStr, storeFromRequest(r))
 	if err != nil {
 		http.Error(w, "order not found", http.StatusNotFound)
 		return
@@ -93,9 +119,9 @@ func (h *OrderHandler) GetOrderByID(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(order)
 }
 func (h *OrderHandler) UpdateStatusOrder(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id") // FIX
+	idStr := r.PathValue("id")
 	status := r.URL.Query().Get("status")
-	if status != "CANCELLED" || status != "PENDING" || status != "COMPLETED" {
+	if status != "CANCELLED" && status != "PENDING" && status != "COMPLETED" {
 		http.Error(w, "incorrect status", http.StatusBadRequest)
 		return
 	}
@@ -103,11 +129,103 @@ func (h *OrderHandler) UpdateStatusOrder(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "missing id", http.StatusBadRequest)
 		return
 	}
-	err := h.orderServise.UpdateStatusOrder(r.Context(), idStr, status)
+	err := h.orderServise.UpdateStatusOrder(r.Context(), idStr, status, storeFromRequest(r))
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "order not found", http.StatusNotFound)
+			return
+		}
 		http.Error(w, "Can not update status", http.StatusInternalServerError)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"message":"Order status updated successfully"}`))
 }
+
+// CustomerOrders lists the orders of a customer, newest first, filtered by
+// ?status=PENDING,COMPLETED and paged with ?page= and ?page_size=. Staff see
+// the orders of their store context only; customers see all of theirs.
+func (h *OrderHandler) CustomerOrders(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var filter models.OrderFilter
+	for _, status := range strings.Split(query.Get("status"), ",") {
+		if status = strings.ToUpper(strings.TrimSpace(status)); status != "" {
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+	for param, value := range map[string]*int{"page": &filter.Page, "page_size": &filter.PageSize} {
+		if query.Get(param) == "" {
+			continue
+		}
+		n, err := strconv.Atoi(query.Get(param))
+		if err != nil || n <= 0 {
+			http.Error(w, param+" must be a positive number", http.StatusBadRequest)
+			return
+		}
+		*value = n
+	}
+	filter.StoreId = staffStore(r)
+	page, err := h.orderServise.CustomerOrders(r.Context(), r.PathValue("id"), filter)
+	if err != nil {
+		writeOrderError(w, "failed to get customer orders", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// CustomerOrder returns one order of the customer.
+func (h *OrderHandler) CustomerOrder(w http.ResponseWriter, r *http.Request) {
+	order, err := h.orderServise.CustomerOrder(r.Context(), r.PathValue("id"), r.PathValue("orderId"), staffStore(r))
+	if err != nil {
+		writeOrderError(w, "failed to get customer order", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
+
+// Reorder places a past order of the customer again at current prices. The
+// store defaults to the one of the past order and the optional body may
+// change the payment method. Items that can no longer be ordered are left
+// out and listed; when none can, nothing is ordered and 409 is returned.
+func (h *OrderHandler) Reorder(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		PaymentMethod string `json:"payment_method"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+	}
+	defer r.Body.Close()
+	reorder, err := h.orderServise.Reorder(r.Context(), r.PathValue("id"), r.PathValue("orderId"), storeFromRequest(r), input.PaymentMethod)
+	if err != nil {
+		if errors.Is(err, models.ErrNothingToReorder) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(reorder)
+			return
+		}
+		writeOrderError(w, "failed to reorder", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(reorder)
+}
+
+func writeOrderError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidOrderFilter), errors.Is(err, models.ErrMissingStore),
+		errors.Is(err, models.ErrInvalidOrderItemChange), errors.Is(err, models.ErrInvalidQuantity):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrInsufficientStock), errors.Is(err, models.ErrItemNotAvailable), errors.Is(err, models.ErrOutsideSchedule):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "not found", http.StatusNotFound)
+	default:
+		http.Error(w, message+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"frappuccino/internal/service"
+	"frappuccino/models"
+	"net/http"
+)
+
+type PriceAdjustmentHandler struct {
+	adjustmentService service.PriceAdjustmentServiceInf
+}
+
+func NewPriceAdjustmentHandler(service service.PriceAdjustmentServiceInf) *PriceAdjustmentHandler {
+	return &PriceAdjustmentHandler{adjustmentService: service}
+}
+
+// CreatePriceAdjustment adjusts prices in one batch. With ?preview=true it
+// returns the new prices without applying them.
+func (h *PriceAdjustmentHandler) CreatePriceAdjustment(w http.ResponseWriter, r *http.Request) {
+	var input models.PriceAdjustment
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	preview := r.URL.Query().Get("preview") == "true"
+	err := h.adjustmentService.Create(r.Context(), &input, preview)
+	if err != nil {
+		writePriceAdjustmentError(w, "failed to adjust prices", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !preview {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(input)
+}
+
+func (h *PriceAdjustmentHandler) GetPriceAdjustments(w http.ResponseWriter, r *http.Request) {
+	adjustments, err := h.adjustmentService.GetAll(r.Context())
+	if err != nil {
+		http.Error(w, "failed to get price adjustments", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(adjustments)
+}
+
+func (h *PriceAdjustmentHandler) RevertPriceAdjustment(w http.ResponseWriter, r *http.Request) {
+	adjustment, err := h.adjustmentService.Revert(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writePriceAdjustmentError(w, "failed to revert price adjustment", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(adjustment)
+}
+
+func writePriceAdjustmentError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidPriceAdjustment):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrPriceAdjustmentReverted):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "price adjustment not found", http.StatusNotFound)
+	default:
+		http.Error(w, message+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"frappuccino/internal/service"
+	"frappuccino/models"
+	"frappuccino/utils"
+	"net/http"
+)
+
+type ScheduleHandler struct {
+	scheduleService service.ScheduleServiceInf
+}
+
+func NewScheduleHandler(service service.ScheduleServiceInf) *ScheduleHandler {
+	return &ScheduleHandler{scheduleService: service}
+}
+
+func (h *ScheduleHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	var input models.MenuSchedule
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	err := h.scheduleService.Create(r.Context(), &input)
+	if err != nil {
+		writeScheduleError(w, "failed to create schedule", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(input)
+}
+
+// GetSchedules lists schedules, filtered by ?menu_item_id= or ?category_id=.
+func (h *ScheduleHandler) GetSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := h.scheduleService.GetAll(r.Context(), r.URL.Query().Get("menu_item_id"), r.URL.Query().Get("category_id"))
+	if err != nil {
+		http.Error(w, "failed to get schedules", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedules)
+}
+
+func (h *ScheduleHandler) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	var input models.MenuSchedule
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	input.ScheduleId = utils.TEXT(r.PathValue("id"))
+
+	err := h.scheduleService.UpdateScheduleByID(r.Context(), &input)
+	if err != nil {
+		writeScheduleError(w, "failed to update schedule", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(input)
+}
+
+func (h *ScheduleHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	err := h.scheduleService.DeleteScheduleByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeScheduleError(w, "failed to delete schedule", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"Schedule deleted successfully"}`))
+}
+
+func writeScheduleError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidSchedule):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "schedule not found", http.StatusNotFound)
+	default:
+		http.Error(w, message+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
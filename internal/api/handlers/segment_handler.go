@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"frappuccino/internal/service"
+	"frappuccino/models"
+	"frappuccino/utils"
+	"net/http"
+)
+
+type SegmentHandler struct {
+	segmentService service.SegmentServiceInf
+}
+
+func NewSegmentHandler(service service.SegmentServiceInf) *SegmentHandler {
+	return &SegmentHandler{segmentService: service}
+}
+
+func (h *SegmentHandler) CreateSegment(w http.ResponseWriter, r *http.Request) {
+	var input models.SegmentDefinition
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if err := h.segmentService.Create(r.Context(), &input); err != nil {
+		writeSegmentError(w, "failed to create segment", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(input)
+}
+
+// GetSegments lists the segments in the order customers are matched
+// against them, the built-in ones without an id.
+func (h *SegmentHandler) GetSegments(w http.ResponseWriter, r *http.Request) {
+	segments, err := h.segmentService.GetAll(r.Context())
+	if err != nil {
+		http.Error(w, "failed to get segments", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(segments)
+}
+
+func (h *SegmentHandler) UpdateSegment(w http.ResponseWriter, r *http.Request) {
+	var input models.SegmentDefinition
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	input.SegmentId = utils.TEXT(r.PathValue("id"))
+
+	if err := h.segmentService.UpdateSegmentByID(r.Context(), &input); err != nil {
+		writeSegmentError(w, "failed to update segment", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(input)
+}
+
+func (h *SegmentHandler) DeleteSegment(w http.ResponseWriter, r *http.Request) {
+	if err := h.segmentService.DeleteSegmentByID(r.Context(), r.PathValue("id")); err != nil {
+		writeSegmentError(w, "failed to delete segment", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"Segment deleted successfully"}`))
+}
+
+func writeSegmentError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidSegment):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "segment not found", http.StatusNotFound)
+	default:
+		http.Error(w, message+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"frappuccino/internal/service"
+	"frappuccino/models"
+	"frappuccino/utils"
+	"net/http"
+	"strconv"
+)
+
+type StaffHandler struct {
+	staffService service.StaffServiceInf
+}
+
+func NewStaffHandler(service service.StaffServiceInf) *StaffHandler {
+	return &StaffHandler{staffService: service}
+}
+
+// staffKey is the context key of the logged-in staff user.
+type staffKey struct{}
+
+// staffFromRequest returns the logged-in staff user, if any.
+func staffFromRequest(r *http.Request) (models.StaffUser, bool) {
+	staff, ok := r.Context().Value(staffKey{}).(models.StaffUser)
+	return staff, ok
+}
+
+// staffStore returns the store context of a request of staff, or "" for
+// requests of customers.
+func staffStore(r *http.Request) string {
+	if _, ok := staffFromRequest(r); !ok {
+		return ""
+	}
+	return storeFromRequest(r)
+}
+
+// Require lets through only staff whose role has the permission, or any
+// logged-in staff when the permission is empty. Staff tied to a store work
+// in that store: it is the store context of their requests unless they name
+// it themselves, and other stores are denied. Denied requests are logged;
+// those of logged-in staff are recorded too.
+func (h *StaffHandler) Require(permission models.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		staff, err := h.staffService.Authenticate(r.Context(), bearerToken(r))
+		if err != nil {
+			if errors.Is(err, models.ErrUnauthenticated) {
+				h.deny(r, staff, permission, "not logged in as staff")
+			}
+			writeStaffError(w, "failed to authenticate", err)
+			return
+		}
+		if permission != "" && !staff.Role.Can(permission) {
+			h.deny(r, staff, permission, "role "+string(staff.Role)+" lacks the permission")
+			writeStaffError(w, "", models.ErrForbidden)
+			return
+		}
+		if staff.StoreId != "" {
+			switch store := storeFromRequest(r); store {
+			case "":
+				r = r.Clone(r.Context())
+				r.Header.Set(StoreHeader, string(staff.StoreId))
+			case string(staff.StoreId):
+			default:
+				h.deny(r, staff, permission, "staff of another store")
+				writeStaffError(w, "", models.ErrForbidden)
+				return
+			}
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), staffKey{}, staff)))
+	}
+}
+
+// RequireGlobal is Require for changes to what all stores share, such as
+// the menu and its prices: staff tied to a store are denied unless they are
+// admins.
+func (h *StaffHandler) RequireGlobal(permission models.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return h.Require(permission, func(w http.ResponseWriter, r *http.Request) {
+		if staff, _ := staffFromRequest(r); staff.StoreId != "" && staff.Role != models.RoleAdmin {
+			h.deny(r, staff, permission, "staff of one store cannot change what all stores share")
+			writeStaffError(w, "", models.ErrForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// deny logs and, for logged-in staff, records a denied request. Failing to
+// record it does not change the answer.
+func (h *StaffHandler) deny(r *http.Request, staff models.StaffUser, permission models.Permission, reason string) {
+	h.staffService.RecordDenial(r.Context(), &models.AccessDenial{
+		StaffId:    staff.StaffId,
+		Username:   staff.Username,
+		Method:     utils.TEXT(r.Method),
+		Path:       utils.TEXT(r.URL.Path),
+		Permission: permission,
+		Reason:     utils.TEXT(reason),
+		RemoteAddr: utils.TEXT(r.RemoteAddr),
+	})
+}
+
+// Login logs a member of staff in and returns a signed token.
+func (h *StaffHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var input models.StaffLogin
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	session, err := h.staffService.Login(r.Context(), input)
+	if err != nil {
+		writeStaffError(w, "failed to log in", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+}
+
+// Me returns the logged-in staff user.
+func (h *StaffHandler) Me(w http.ResponseWriter, r *http.Request) {
+	staff, _ := staffFromRequest(r)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(staff)
+}
+
+func (h *StaffHandler) CreateStaff(w http.ResponseWriter, r *http.Request) {
+	var input models.StaffUser
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if err := h.staffService.Create(r.Context(), &input); err != nil {
+		writeStaffError(w, "failed to create staff user", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(input)
+}
+
+func (h *StaffHandler) GetAllStaff(w http.ResponseWriter, r *http.Request) {
+	users, err := h.staffService.GetAll(r.Context(), r.URL.Query().Get("archived") == "true")
+	if err != nil {
+		writeStaffError(w, "failed to get staff users", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
+func (h *StaffHandler) GetStaffByID(w http.ResponseWriter, r *http.Request) {
+	staff, err := h.staffService.GetStaffByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeStaffError(w, "failed to get staff user", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(staff)
+}
+
+func (h *StaffHandler) UpdateStaff(w http.ResponseWriter, r *http.Request) {
+	var input models.StaffUser
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	input.StaffId = utils.TEXT(r.PathValue("id"))
+	if err := h.staffService.UpdateStaffByID(r.Context(), &input); err != nil {
+		writeStaffError(w, "failed to update staff user", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"Staff user updated successfully"}`))
+}
+
+// SetStaffPassword sets the password of a staff user, whose tokens end. Staff
+// may set their own password, giving the current one as current_password;
+// setting that of others takes the staff permission.
+func (h *StaffHandler) SetStaffPassword(w http.ResponseWriter, r *http.Request) {
+	staff, _ := staffFromRequest(r)
+	if string(staff.StaffId) != r.PathValue("id") && !staff.Role.Can(models.PermStaff) {
+		h.deny(r, staff, models.PermStaff, "password of another staff user")
+		writeStaffError(w, "", models.ErrForbidden)
+		return
+	}
+	var input models.PasswordChange
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	var err error
+	if string(staff.StaffId) == r.PathValue("id") {
+		err = h.staffService.ChangeOwnPassword(r.Context(), r.PathValue("id"), input)
+	} else {
+		err = h.staffService.SetPassword(r.Context(), r.PathValue("id"), input.Password)
+	}
+	if err != nil {
+		writeStaffError(w, "failed to set staff password", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"Password set successfully"}`))
+}
+
+// DeleteStaff archives a staff user.
+func (h *StaffHandler) DeleteStaff(w http.ResponseWriter, r *http.Request) {
+	if err := h.staffService.DeleteStaffByID(r.Context(), r.PathValue("id")); err != nil {
+		writeStaffError(w, "failed to delete staff user", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"Staff user deleted successfully"}`))
+}
+
+// GetDenials returns the latest denied requests, at most ?limit= of them.
+func (h *StaffHandler) GetDenials(w http.ResponseWriter, r *http.Request) {
+	var limit int
+	if r.URL.Query().Get("limit") != "" {
+		var err error
+		if limit, err = strconv.Atoi(r.URL.Query().Get("limit")); err != nil || limit <= 0 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+	denials, err := h.staffService.Denials(r.Context(), limit)
+	if err != nil {
+		writeStaffError(w, "failed to get access denials", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(denials)
+}
+
+func writeStaffError(w http.ResponseWriter, message string, err error) {
+	var invalid *models.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		writeValidationError(w, invalid)
+	case errors.Is(err, models.ErrInvalidStaff):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrLastAdmin):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, models.ErrInvalidCredentials), errors.Is(err, models.ErrUnauthenticated):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="staff"`)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, models.ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, models.ErrLoginLocked):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Staff user not found", http.StatusNotFound)
+	default:
+		http.Error(w, message+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
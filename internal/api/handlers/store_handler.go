@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"frappuccino/internal/service"
+	"frappuccino/models"
+	"frappuccino/utils"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// StoreHeader carries the store context of a request that is not
+// routed through /stores/{store_id}/...
+const StoreHeader = "X-Store-ID"
+
+// storeFromRequest returns the store context of the request, or "" when
+// the request is not scoped to a store.
+func storeFromRequest(r *http.Request) string {
+	return r.Header.Get(StoreHeader)
+}
+
+type StoreHandler struct {
+	storeService service.StoreServiceInf
+}
+
+func NewStoreHandler(service service.StoreServiceInf) *StoreHandler {
+	return &StoreHandler{storeService: service}
+}
+
+// Scoped serves /stores/{store_id}/<path> as /<path> with the store as context.
+func (h *StoreHandler) Scoped(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		storeID := r.PathValue("store_id")
+		if _, err := h.storeService.GetStoreByID(r.Context(), storeID); err != nil {
+			http.Error(w, "store not found", http.StatusNotFound)
+			return
+		}
+		scoped := r.Clone(r.Context())
+		scoped.Header.Set(StoreHeader, storeID)
+		scoped.URL.Path = strings.TrimPrefix(r.URL.Path, "/stores/"+storeID)
+		scoped.URL.RawPath = ""
+		next.ServeHTTP(w, scoped)
+	})
+}
+
+func (h *StoreHandler) CreateStore(w http.ResponseWriter, r *http.Request) {
+	var input models.Store
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	err := h.storeService.Create(r.Context(), &input)
+	if isStoreInputError(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("failed to create store: %v", err)
+		http.Error(w, "failed to create store", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(input)
+}
+
+func (h *StoreHandler) GetAllStores(w http.ResponseWriter, r *http.Request) {
+	stores, err := h.storeService.GetAll(r.Context())
+	if err != nil {
+		http.Error(w, "failed to get stores", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stores)
+}
+
+func (h *StoreHandler) GetStoreByID(w http.ResponseWriter, r *http.Request) {
+	store, err := h.storeService.GetStoreByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "store not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(store)
+}
+
+func (h *StoreHandler) UpdateStore(w http.ResponseWriter, r *http.Request) {
+	var input models.Store
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	input.StoreId = utils.TEXT(r.PathValue("id"))
+
+	err := h.storeService.UpdateStoreByID(r.Context(), &input)
+	if isStoreInputError(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to update store: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"Store updated successfully"}`))
+}
+
+func (h *StoreHandler) DeleteStore(w http.ResponseWriter, r *http.Request) {
+	err := h.storeService.DeleteStoreByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "failed to delete store: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"Store deleted successfully"}`))
+}
+
+// SetMenuItem overrides the price or availability of a menu item in the store context.
+func (h *StoreHandler) SetMenuItem(w http.ResponseWriter, r *http.Request) {
+	storeID := storeFromRequest(r)
+	if storeID == "" {
+		http.Error(w, models.ErrMissingStore.Error(), http.StatusBadRequest)
+		return
+	}
+	input := models.StoreMenuItem{IsAvailable: true}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	input.StoreId = utils.TEXT(storeID)
+	input.MenuItemId = utils.TEXT(r.PathValue("id"))
+
+	err := h.storeService.SetMenuItem(r.Context(), &input)
+	if err != nil {
+		http.Error(w, "failed to set store menu item: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(input)
+}
+
+func (h *StoreHandler) DeleteMenuItem(w http.ResponseWriter, r *http.Request) {
+	storeID := storeFromRequest(r)
+	if storeID == "" {
+		http.Error(w, models.ErrMissingStore.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := h.storeService.DeleteMenuItem(r.Context(), storeID, r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "failed to delete store menu item: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"Store menu item removed successfully"}`))
+}
+
+// isStoreInputError reports whether err rejects the store given by the client.
+func isStoreInputError(err error) bool {
+	return errors.Is(err, models.ErrInvalidStoreName) || errors.Is(err, models.ErrInvalidTimeZone)
+}
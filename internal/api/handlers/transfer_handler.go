@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"frappuccino/internal/service"
+	"frappuccino/models"
+	"log"
+	"net/http"
+)
+
+type TransferHandler struct {
+	transferService service.TransferServiceInf
+}
+
+func NewTransferHandler(service service.TransferServiceInf) *TransferHandler {
+	return &TransferHandler{transferService: service}
+}
+
+// transferItemsInput is the body of ship and receive requests.
+type transferItemsInput struct {
+	Items []models.TransferItem `json:"items"`
+}
+
+func (h *TransferHandler) CreateTransfer(w http.ResponseWriter, r *http.Request) {
+	var input models.Transfer
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	err := h.transferService.Create(r.Context(), &input, staffStore(r))
+	if err != nil {
+		log.Printf("failed to create transfer: %v", err)
+		if errors.Is(err, models.ErrInvalidTransfer) || errors.Is(err, models.ErrInvalidQuantity) || errors.Is(err, models.ErrInvalidIngredientId) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "store not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to create transfer", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(input)
+}
+
+func (h *TransferHandler) GetTransfers(w http.ResponseWriter, r *http.Request) {
+	transfers, err := h.transferService.GetAll(r.Context(), storeFromRequest(r), r.URL.Query().Get("status"))
+	if err != nil {
+		http.Error(w, "failed to get transfers", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfers)
+}
+
+func (h *TransferHandler) GetTransferByID(w http.ResponseWriter, r *http.Request) {
+	transfer, err := h.transferService.GetTransferByID(r.Context(), r.PathValue("id"), staffStore(r))
+	if err != nil {
+		http.Error(w, "transfer not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfer)
+}
+
+func (h *TransferHandler) ShipTransfer(w http.ResponseWriter, r *http.Request) {
+	var input transferItemsInput
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+	}
+	defer r.Body.Close()
+
+	err := h.transferService.Ship(r.Context(), r.PathValue("id"), staffStore(r), input.Items)
+	if err != nil {
+		writeTransferError(w, "failed to ship transfer", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"Transfer shipped successfully"}`))
+}
+
+func (h *TransferHandler) ReceiveTransfer(w http.ResponseWriter, r *http.Request) {
+	var input transferItemsInput
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+	}
+	defer r.Body.Close()
+
+	err := h.transferService.Receive(r.Context(), r.PathValue("id"), staffStore(r), input.Items)
+	if err != nil {
+		writeTransferError(w, "failed to receive transfer", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"Transfer received successfully"}`))
+}
+
+func (h *TransferHandler) CancelTransfer(w http.ResponseWriter, r *http.Request) {
+	err := h.transferService.Cancel(r.Context(), r.PathValue("id"), staffStore(r))
+	if err != nil {
+		writeTransferError(w, "failed to cancel transfer", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"Transfer cancelled successfully"}`))
+}
+
+// InTransit lists stock shipped to the store context (or to any store) and not yet received.
+func (h *TransferHandler) InTransit(w http.ResponseWriter, r *http.Request) {
+	stock, err := h.transferService.InTransit(r.Context(), storeFromRequest(r))
+	if err != nil {
+		http.Error(w, "failed to get stock in transit", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stock)
+}
+
+func writeTransferError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, models.ErrTransferStatus), errors.Is(err, models.ErrInsufficientStock):
+		http.Error(w, msg+": "+err.Error(), http.StatusConflict)
+	case errors.Is(err, models.ErrInvalidQuantity), errors.Is(err, models.ErrInvalidTransfer):
+		http.Error(w, msg+": "+err.Error(), http.StatusBadRequest)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "transfer not found", http.StatusNotFound)
+	default:
+		http.Error(w, msg+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
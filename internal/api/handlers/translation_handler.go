@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"frappuccino/internal/service"
+	"frappuccino/models"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type TranslationHandler struct {
+	translationService service.TranslationServiceInf
+}
+
+func NewTranslationHandler(service service.TranslationServiceInf) *TranslationHandler {
+	return &TranslationHandler{translationService: service}
+}
+
+// preferredLocales returns the locales a request asks for, most preferred
+// first: those of ?locale= or else of Accept-Language by quality. Each tag
+// is followed by its parents ("de-ch", then "de"), and the list stops at
+// the default locale since its texts need no translation.
+func preferredLocales(r *http.Request) []string {
+	header := r.URL.Query().Get("locale")
+	if header == "" {
+		header = r.Header.Get("Accept-Language")
+	}
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if tag != "" && tag != "*" && q > 0 {
+			tags = append(tags, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	var locales []string
+	seen := make(map[string]bool)
+	for _, t := range tags {
+		for tag := t.tag; ; {
+			if tag == models.DefaultLocale {
+				return locales
+			}
+			if !seen[tag] {
+				seen[tag] = true
+				locales = append(locales, tag)
+			}
+			i := strings.LastIndex(tag, "-")
+			if i < 0 {
+				break
+			}
+			tag = tag[:i]
+		}
+	}
+	return locales
+}
+
+// SetTranslation adds or replaces the translation of a menu item, category,
+// modifier group or modifier in one locale.
+func (h *TranslationHandler) SetTranslation(w http.ResponseWriter, r *http.Request) {
+	var input models.Translation
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if err := h.translationService.Set(r.Context(), &input); err != nil {
+		writeTranslationError(w, "failed to save translation", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(input)
+}
+
+func (h *TranslationHandler) GetTranslations(w http.ResponseWriter, r *http.Request) {
+	translations, err := h.translationService.GetAll(r.Context(), r.URL.Query().Get("locale"))
+	if err != nil {
+		writeTranslationError(w, "failed to get translations", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(translations)
+}
+
+func (h *TranslationHandler) DeleteTranslation(w http.ResponseWriter, r *http.Request) {
+	if err := h.translationService.Delete(r.Context(), r.PathValue("id")); err != nil {
+		writeTranslationError(w, "failed to delete translation", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"message":"translation deleted successfully"}`))
+}
+
+// GetMissingTranslations reports what lacks a translation in each locale of
+// the comma-separated ?locale=, or in every locale translated so far.
+func (h *TranslationHandler) GetMissingTranslations(w http.ResponseWriter, r *http.Request) {
+	report, err := h.translationService.Missing(r.Context(), queryList(r, "locale"))
+	if err != nil {
+		writeTranslationError(w, "failed to report missing translations", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func writeTranslationError(w http.ResponseWriter, message string, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidTranslation), errors.Is(err, models.ErrInvalidLocale):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "translation not found", http.StatusNotFound)
+	default:
+		http.Error(w, message+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
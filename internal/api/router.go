@@ -2,39 +2,156 @@ package api
 
 import (
 	"frappuccino/internal/api/handlers"
+	"frappuccino/models"
 	"net/http"
 )
 
+// Router routes the API. Staff endpoints take a staff token whose role has
+// the permission of the route, see models.RolePermissions; reading the menu,
+// categories, modifier groups and stores is open to customers and anyone.
+// Changes to the catalog all stores share take staff tied to no store, or
+// an admin.
 func Router(handlers *handlers.Handler) *http.ServeMux {
 	mux := http.NewServeMux()
-	mux.HandleFunc("POST /inventory", handlers.InventoryHandler.CreateInventoryIngredient)
-	mux.HandleFunc("GET /inventory", handlers.InventoryHandler.GetInventory)
-	mux.HandleFunc("GET /inventory/{id}", handlers.InventoryHandler.GetIngredientByID)
-	mux.HandleFunc("PUT /inventory/{id}", handlers.InventoryHandler.UpdateIngredient)
-	mux.HandleFunc("DELETE /inventory/{id}", handlers.InventoryHandler.DeleteIngredient)
+	staff := handlers.StaffHandler.Require
+	global := handlers.StaffHandler.RequireGlobal
 
-	mux.HandleFunc("POST /menu", handlers.MenuHandler.CreateMenuItem)
+	mux.HandleFunc("POST /staff/login", handlers.StaffHandler.Login)
+	mux.HandleFunc("GET /staff/me", staff("", handlers.StaffHandler.Me))
+	mux.HandleFunc("PUT /staff/{id}/password", staff("", handlers.StaffHandler.SetStaffPassword))
+	mux.HandleFunc("POST /staff", staff(models.PermStaff, handlers.StaffHandler.CreateStaff))
+	mux.HandleFunc("GET /staff", staff(models.PermStaff, handlers.StaffHandler.GetAllStaff))
+	mux.HandleFunc("GET /staff/denials", staff(models.PermStaff, handlers.StaffHandler.GetDenials))
+	mux.HandleFunc("GET /staff/{id}", staff(models.PermStaff, handlers.StaffHandler.GetStaffByID))
+	mux.HandleFunc("PUT /staff/{id}", staff(models.PermStaff, handlers.StaffHandler.UpdateStaff))
+	mux.HandleFunc("DELETE /staff/{id}", staff(models.PermStaff, handlers.StaffHandler.DeleteStaff))
+
+	mux.HandleFunc("POST /inventory", global(models.PermCatalog, handlers.InventoryHandler.CreateInventoryIngredient))
+	mux.HandleFunc("GET /inventory", staff(models.PermInventory, handlers.InventoryHandler.GetInventory))
+	mux.HandleFunc("GET /inventory/{id}", staff(models.PermInventory, handlers.InventoryHandler.GetIngredientByID))
+	mux.HandleFunc("PUT /inventory/{id}", staff(models.PermInventoryManage, handlers.InventoryHandler.UpdateIngredient))
+	mux.HandleFunc("DELETE /inventory/{id}", global(models.PermCatalog, handlers.InventoryHandler.DeleteIngredient))
+	mux.HandleFunc("POST /inventory/{id}/restore", global(models.PermCatalog, handlers.InventoryHandler.RestoreIngredient))
+	mux.HandleFunc("PUT /inventory/{id}/recipe", global(models.PermCatalog, handlers.InventoryHandler.SetRecipe))
+	mux.HandleFunc("GET /inventory/{id}/recipe", staff(models.PermInventory, handlers.InventoryHandler.GetRecipe))
+	mux.HandleFunc("POST /inventory/{id}/produce", staff(models.PermInventoryManage, handlers.InventoryHandler.Produce))
+	mux.HandleFunc("GET /inventory/{id}/cost", staff(models.PermCatalog, handlers.InventoryHandler.GetIngredientCost))
+	mux.HandleFunc("GET /inventory/{id}/availability", staff(models.PermInventory, handlers.InventoryHandler.GetAvailability))
+
+	mux.HandleFunc("POST /menu", global(models.PermCatalog, handlers.MenuHandler.CreateMenuItem))
 	mux.HandleFunc("GET /menu", handlers.MenuHandler.GetAllMenu)
-	mux.HandleFunc("PUT /menu/{id}", handlers.MenuHandler.UpdateMenuItem)
-	mux.HandleFunc("DELETE /menu/{id}", handlers.MenuHandler.DeleteMenuItem)
+	mux.HandleFunc("PUT /menu/{id}", global(models.PermCatalog, handlers.MenuHandler.UpdateMenuItem))
+	mux.HandleFunc("DELETE /menu/{id}", global(models.PermCatalog, handlers.MenuHandler.DeleteMenuItem))
+	mux.HandleFunc("POST /menu/{id}/restore", global(models.PermCatalog, handlers.MenuHandler.RestoreMenuItem))
 	mux.HandleFunc("GET /menu/{id}", handlers.MenuHandler.GetIngredientByID)
-	mux.HandleFunc("POST /order", handlers.OrderHandler.CreateOrder)
-
-	mux.HandleFunc("GET /order", handlers.OrderHandler.Orders)
-	mux.HandleFunc("GET /order/{id}", handlers.OrderHandler.GetOrderByID)
-	mux.HandleFunc("PUT /order/{id}", handlers.OrderHandler.UpdateOrderItem)
-	mux.HandleFunc("DELETE /order/{id}", handlers.OrderHandler.DeleteOrderByID)
-	mux.HandleFunc("PUT /order/status/{id}", handlers.OrderHandler.UpdateStatusOrder)
-
-	mux.HandleFunc("POST /customer", handlers.CustomerHandler.CreateCustomer)
-	mux.HandleFunc("GET /customer", handlers.CustomerHandler.GetAllCustomers)
-	mux.HandleFunc("GET /customer/{id}", handlers.CustomerHandler.GetCustomerByID)
-	mux.HandleFunc("PUT /customer/{id}", handlers.CustomerHandler.UpdateCustomer)
-	mux.HandleFunc("DELETE /customer/{id}", handlers.CustomerHandler.DeleteCustomer)
-
-	mux.HandleFunc("GET /totalprice", handlers.AggregationHandler.TotalPrice)
-	mux.HandleFunc("GET /popularitems", handlers.AggregationHandler.PopularItems)
-	mux.HandleFunc("GET /search", handlers.AggregationHandler.Search)
-	mux.HandleFunc("GET /orderedItems", handlers.AggregationHandler.OrderedItemByPeriod)
+	mux.HandleFunc("GET /menu/{id}/cost", staff(models.PermCatalog, handlers.MenuHandler.GetItemCost))
+	mux.HandleFunc("GET /menu/{id}/availability", handlers.MenuHandler.GetItemAvailability)
+	mux.HandleFunc("GET /menu/{id}/nutrition", handlers.MenuHandler.GetItemNutrition)
+	mux.HandleFunc("POST /menu/{id}/images", global(models.PermCatalog, handlers.MenuHandler.UploadMenuItemImage))
+	mux.HandleFunc("DELETE /menu/{id}/images/{image_id}", global(models.PermCatalog, handlers.MenuHandler.DeleteMenuItemImage))
+	mux.HandleFunc("GET /menu/export", staff(models.PermCatalog, handlers.MenuHandler.ExportMenu))
+	mux.HandleFunc("POST /menu/import", global(models.PermCatalog, handlers.MenuHandler.ImportMenu))
+	mux.HandleFunc("POST /menu/price-adjustments", global(models.PermCatalog, handlers.PriceAdjustmentHandler.CreatePriceAdjustment))
+	mux.HandleFunc("GET /menu/price-adjustments", staff(models.PermCatalog, handlers.PriceAdjustmentHandler.GetPriceAdjustments))
+	mux.HandleFunc("POST /menu/price-adjustments/{id}/revert", global(models.PermCatalog, handlers.PriceAdjustmentHandler.RevertPriceAdjustment))
+	mux.HandleFunc("POST /order", staff(models.PermOrders, handlers.OrderHandler.CreateOrder))
+
+	mux.HandleFunc("GET /order", staff(models.PermOrders, handlers.OrderHandler.Orders))
+	mux.HandleFunc("GET /order/{id}", staff(models.PermOrders, handlers.OrderHandler.GetOrderByID))
+	mux.HandleFunc("PUT /order/{id}", staff(models.PermOrdersManage, handlers.OrderHandler.UpdateOrderItem))
+	mux.HandleFunc("DELETE /order/{id}", staff(models.PermOrdersManage, handlers.OrderHandler.DeleteOrderByID))
+	mux.HandleFunc("PUT /order/status/{id}", staff(models.PermOrders, handlers.OrderHandler.UpdateStatusOrder))
+
+	mux.HandleFunc("POST /customer", staff(models.PermCustomers, handlers.CustomerHandler.CreateCustomer))
+	mux.HandleFunc("GET /customer", staff(models.PermCustomers, handlers.CustomerHandler.GetAllCustomers))
+	mux.HandleFunc("GET /customer/duplicates", staff(models.PermCustomersManage, handlers.CustomerHandler.GetDuplicates))
+	mux.HandleFunc("POST /customer/merge", staff(models.PermCustomersManage, handlers.CustomerHandler.MergeCustomers))
+	mux.HandleFunc("GET /customer/erasures", staff(models.PermCustomersManage, handlers.CustomerHandler.GetErasures))
+	mux.HandleFunc("GET /customer/{id}", staff(models.PermCustomers, handlers.CustomerHandler.GetCustomerByID))
+	mux.HandleFunc("PUT /customer/{id}", staff(models.PermCustomers, handlers.CustomerHandler.UpdateCustomer))
+	mux.HandleFunc("DELETE /customer/{id}", staff(models.PermCustomersManage, handlers.CustomerHandler.DeleteCustomer))
+	mux.HandleFunc("POST /customer/{id}/restore", staff(models.PermCustomersManage, handlers.CustomerHandler.RestoreCustomer))
+	mux.HandleFunc("GET /customer/{id}/export", staff(models.PermCustomersManage, handlers.CustomerHandler.ExportCustomer))
+	mux.HandleFunc("POST /customer/{id}/erase", staff(models.PermCustomersManage, handlers.CustomerHandler.EraseCustomer))
+	mux.HandleFunc("GET /customer/{id}/orders", staff(models.PermOrders, handlers.OrderHandler.CustomerOrders))
+	mux.HandleFunc("GET /customer/{id}/orders/{orderId}", staff(models.PermOrders, handlers.OrderHandler.CustomerOrder))
+	mux.HandleFunc("POST /customer/{id}/orders/{orderId}/reorder", staff(models.PermOrders, handlers.OrderHandler.Reorder))
+
+	// Customer accounts for order-ahead. The /me endpoints serve the customer
+	// of the bearer token only; the /customer endpoints above are for staff.
+	customer := handlers.AccountHandler.Customer
+	mux.HandleFunc("POST /account/register", handlers.AccountHandler.Register)
+	mux.HandleFunc("POST /account/login", handlers.AccountHandler.Login)
+	mux.HandleFunc("POST /account/login/code", handlers.AccountHandler.SendLoginCode)
+	mux.HandleFunc("POST /account/login/code/verify", handlers.AccountHandler.LoginWithCode)
+	mux.HandleFunc("POST /account/logout", customer(handlers.AccountHandler.Logout))
+	mux.HandleFunc("PUT /account/password", customer(handlers.AccountHandler.SetPassword))
+	mux.HandleFunc("GET /me", customer(handlers.CustomerHandler.GetCustomerByID))
+	mux.HandleFunc("PUT /me", customer(handlers.CustomerHandler.UpdateCustomer))
+	mux.HandleFunc("GET /me/export", customer(handlers.CustomerHandler.ExportCustomer))
+	mux.HandleFunc("GET /me/orders", customer(handlers.OrderHandler.CustomerOrders))
+	mux.HandleFunc("POST /me/orders", customer(handlers.OrderHandler.CreateOrder))
+	mux.HandleFunc("GET /me/orders/{orderId}", customer(handlers.OrderHandler.CustomerOrder))
+	mux.HandleFunc("POST /me/orders/{orderId}/reorder", customer(handlers.OrderHandler.Reorder))
+
+	mux.HandleFunc("POST /stores", staff(models.PermStores, handlers.StoreHandler.CreateStore))
+	mux.HandleFunc("GET /stores", handlers.StoreHandler.GetAllStores)
+	mux.HandleFunc("GET /stores/{id}", handlers.StoreHandler.GetStoreByID)
+	mux.HandleFunc("PUT /stores/{id}", staff(models.PermStores, handlers.StoreHandler.UpdateStore))
+	mux.HandleFunc("DELETE /stores/{id}", staff(models.PermStores, handlers.StoreHandler.DeleteStore))
+	mux.HandleFunc("PUT /menu/{id}/store", staff(models.PermCatalog, handlers.StoreHandler.SetMenuItem))
+	mux.HandleFunc("DELETE /menu/{id}/store", staff(models.PermCatalog, handlers.StoreHandler.DeleteMenuItem))
+	mux.HandleFunc("POST /transfers", staff(models.PermInventoryManage, handlers.TransferHandler.CreateTransfer))
+	mux.HandleFunc("GET /transfers", staff(models.PermInventory, handlers.TransferHandler.GetTransfers))
+	mux.HandleFunc("GET /transfers/in-transit", staff(models.PermInventory, handlers.TransferHandler.InTransit))
+	mux.HandleFunc("GET /transfers/{id}", staff(models.PermInventory, handlers.TransferHandler.GetTransferByID))
+	mux.HandleFunc("POST /transfers/{id}/ship", staff(models.PermInventoryManage, handlers.TransferHandler.ShipTransfer))
+	mux.HandleFunc("POST /transfers/{id}/receive", staff(models.PermInventoryManage, handlers.TransferHandler.ReceiveTransfer))
+	mux.HandleFunc("POST /transfers/{id}/cancel", staff(models.PermInventoryManage, handlers.TransferHandler.CancelTransfer))
+	mux.HandleFunc("POST /modifier-groups", global(models.PermCatalog, handlers.ModifierHandler.CreateModifierGroup))
+	mux.HandleFunc("GET /modifier-groups", handlers.ModifierHandler.GetModifierGroups)
+	mux.HandleFunc("GET /modifier-groups/{id}", handlers.ModifierHandler.GetModifierGroupByID)
+	mux.HandleFunc("PUT /modifier-groups/{id}", global(models.PermCatalog, handlers.ModifierHandler.UpdateModifierGroup))
+	mux.HandleFunc("DELETE /modifier-groups/{id}", global(models.PermCatalog, handlers.ModifierHandler.DeleteModifierGroup))
+	mux.HandleFunc("PUT /menu/{id}/modifier-groups", global(models.PermCatalog, handlers.ModifierHandler.SetMenuItemGroups))
+	mux.HandleFunc("POST /menu-schedules", global(models.PermCatalog, handlers.ScheduleHandler.CreateSchedule))
+	mux.HandleFunc("GET /menu-schedules", staff(models.PermCatalog, handlers.ScheduleHandler.GetSchedules))
+	mux.HandleFunc("PUT /menu-schedules/{id}", global(models.PermCatalog, handlers.ScheduleHandler.UpdateSchedule))
+	mux.HandleFunc("DELETE /menu-schedules/{id}", global(models.PermCatalog, handlers.ScheduleHandler.DeleteSchedule))
+	mux.HandleFunc("POST /categories", global(models.PermCatalog, handlers.CategoryHandler.CreateCategory))
+	mux.HandleFunc("GET /categories", handlers.CategoryHandler.GetCategories)
+	mux.HandleFunc("GET /categories/{id}", handlers.CategoryHandler.GetCategoryByID)
+	mux.HandleFunc("PUT /categories/{id}", global(models.PermCatalog, handlers.CategoryHandler.UpdateCategory))
+	mux.HandleFunc("DELETE /categories/{id}", global(models.PermCatalog, handlers.CategoryHandler.DeleteCategory))
+	mux.HandleFunc("PUT /translations", global(models.PermCatalog, handlers.TranslationHandler.SetTranslation))
+	mux.HandleFunc("GET /translations", staff(models.PermCatalog, handlers.TranslationHandler.GetTranslations))
+	mux.HandleFunc("GET /translations/missing", staff(models.PermCatalog, handlers.TranslationHandler.GetMissingTranslations))
+	mux.HandleFunc("DELETE /translations/{id}", global(models.PermCatalog, handlers.TranslationHandler.DeleteTranslation))
+	mux.HandleFunc("POST /menu-versions", global(models.PermCatalog, handlers.MenuVersionHandler.CreateMenuVersion))
+	mux.HandleFunc("GET /menu-versions", staff(models.PermCatalog, handlers.MenuVersionHandler.GetMenuVersions))
+	mux.HandleFunc("GET /menu-versions/{id}", staff(models.PermCatalog, handlers.MenuVersionHandler.GetMenuVersionByID))
+	mux.HandleFunc("PUT /menu-versions/{id}", global(models.PermCatalog, handlers.MenuVersionHandler.UpdateMenuVersion))
+	mux.HandleFunc("DELETE /menu-versions/{id}", global(models.PermCatalog, handlers.MenuVersionHandler.DeleteMenuVersion))
+	mux.HandleFunc("POST /menu-versions/{id}/publish", global(models.PermCatalog, handlers.MenuVersionHandler.PublishMenuVersion))
+	mux.HandleFunc("POST /menu-versions/{id}/rollback", global(models.PermCatalog, handlers.MenuVersionHandler.RollbackMenuVersion))
+	mux.HandleFunc("GET /menu-versions/{id}/diff", staff(models.PermCatalog, handlers.MenuVersionHandler.GetMenuVersionDiff))
+
+	// Every endpoint is also served under /stores/{store_id}/ with that store as context;
+	// outside of it the store is taken from the X-Store-ID header.
+	mux.Handle("/stores/{store_id}/", handlers.StoreHandler.Scoped(mux))
+
+	mux.HandleFunc("GET /totalprice", staff(models.PermReports, handlers.AggregationHandler.TotalPrice))
+	mux.HandleFunc("GET /popularitems", staff(models.PermReports, handlers.AggregationHandler.PopularItems))
+	mux.HandleFunc("GET /salesbycategory", staff(models.PermReports, handlers.AggregationHandler.SalesByCategory))
+	mux.HandleFunc("GET /search", staff(models.PermReports, handlers.AggregationHandler.Search))
+	mux.HandleFunc("GET /orderedItems", staff(models.PermReports, handlers.AggregationHandler.OrderedItemByPeriod))
+	mux.HandleFunc("GET /customersegments", staff(models.PermReports, handlers.AggregationHandler.CustomerSegments))
+	mux.HandleFunc("GET /cohortretention", staff(models.PermReports, handlers.AggregationHandler.CohortRetention))
+	mux.HandleFunc("GET /lifetimevalue", staff(models.PermReports, handlers.AggregationHandler.LifetimeValue))
+
+	mux.HandleFunc("POST /segments", staff(models.PermReports, handlers.SegmentHandler.CreateSegment))
+	mux.HandleFunc("GET /segments", staff(models.PermReports, handlers.SegmentHandler.GetSegments))
+	mux.HandleFunc("PUT /segments/{id}", staff(models.PermReports, handlers.SegmentHandler.UpdateSegment))
+	mux.HandleFunc("DELETE /segments/{id}", staff(models.PermReports, handlers.SegmentHandler.DeleteSegment))
 	return mux
 }
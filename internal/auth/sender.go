@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// LoginCode is a one-time login code to deliver to a customer. Channel is
+// "email" or "sms", after what the customer logged in with, and To is their
+// address or E.164 phone number.
+type LoginCode struct {
+	CustomerId string
+	Channel    string
+	To         string
+	Code       string
+	ExpiresAt  time.Time
+}
+
+// CodeSender delivers login codes to customers, such as by email or SMS.
+type CodeSender interface {
+	SendLoginCode(ctx context.Context, code LoginCode) error
+}
+
+// Link returns the magic link of a code: linkURL with the recipient and the
+// code as query parameters.
+func (c LoginCode) Link(linkURL string) string {
+	param := "email"
+	if c.Channel == "sms" {
+		param = "phone_number"
+	}
+	query := url.Values{param: {c.To}, "code": {c.Code}}
+	return linkURL + "?" + query.Encode()
+}
+
+// WebhookSender delivers login codes by posting them as JSON to the URL of
+// an email or SMS gateway, with the magic link when a link URL is set.
+type WebhookSender struct {
+	url     string
+	linkURL string
+	client  *http.Client
+}
+
+func NewWebhookSender(url string, linkURL string) *WebhookSender {
+	return &WebhookSender{url: url, linkURL: linkURL, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *WebhookSender) SendLoginCode(ctx context.Context, code LoginCode) error {
+	message := map[string]string{
+		"customer_id": code.CustomerId,
+		"channel":     code.Channel,
+		"to":          code.To,
+		"code":        code.Code,
+		"expires_at":  code.ExpiresAt.Format(time.RFC3339),
+	}
+	if s.linkURL != "" {
+		message["link"] = code.Link(s.linkURL)
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode login code: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create login code request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post login code: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("login code webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// LogSender writes login codes to the log instead of delivering them, for
+// development only: anyone who can read the log can log in as any customer.
+// With a link URL it writes the magic link too.
+type LogSender struct {
+	linkURL string
+}
+
+func NewLogSender(linkURL string) *LogSender {
+	return &LogSender{linkURL: linkURL}
+}
+
+func (s *LogSender) SendLoginCode(ctx context.Context, code LoginCode) error {
+	log.Printf("Login code for customer [%s] by %s to %s: %s, valid until %s",
+		code.CustomerId, code.Channel, code.To, code.Code, code.ExpiresAt.Format(time.RFC3339))
+	if s.linkURL != "" {
+		log.Printf("Login link for customer [%s]: %s", code.CustomerId, code.Link(s.linkURL))
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"frappuccino/models"
+	"time"
+)
+
+type AccountRepo interface {
+	Register(ctx context.Context, customer *models.Customer, passwordHash string) error
+	FindCustomer(ctx context.Context, email, phoneNumber string) (models.Customer, error)
+	PasswordHash(ctx context.Context, CustomerId string) (string, time.Time, error)
+	FailLogin(ctx context.Context, CustomerId string, maxAttempts int, lockout time.Duration) error
+	ResetFailedLogins(ctx context.Context, CustomerId string) error
+	SetPassword(ctx context.Context, CustomerId string, passwordHash string) error
+	LastLoginCodeAt(ctx context.Context, CustomerId string) (time.Time, error)
+	CreateLoginCode(ctx context.Context, CustomerId string, codeHash string, expiresAt time.Time) error
+	UseLoginCode(ctx context.Context, CustomerId string, codeHash string, maxAttempts int) (bool, error)
+	CreateSession(ctx context.Context, CustomerId string, tokenHash string, expiresAt time.Time) error
+	SessionCustomer(ctx context.Context, tokenHash string) (string, error)
+	RevokeSession(ctx context.Context, tokenHash string) error
+	RevokeOtherSessions(ctx context.Context, CustomerId string, tokenHash string) error
+}
+
+type AccountRepository struct {
+	db *sql.DB
+}
+
+func NewAccountRepository(db *sql.DB) *AccountRepository {
+	return &AccountRepository{db: db}
+}
+
+// activeCustomer is the condition on customers c that can log in: not
+// archived, merged into another customer or erased.
+const activeCustomer = `c.archived_at IS NULL AND c.merged_into IS NULL AND c.erased_at IS NULL`
+
+// Register creates a customer with a password.
+func (r *AccountRepository) Register(ctx context.Context, customer *models.Customer, passwordHash string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if err := createCustomer(ctx, tx, customer); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO customer_credentials (customer_id, password_hash) VALUES ($1, $2)`, customer.CustomerId, passwordHash)
+	if err != nil {
+		return fmt.Errorf("failed to save password: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// FindCustomer returns the active customer with the email, or with the phone
+// number when email is empty. It fails with sql.ErrNoRows when there is none.
+func (r *AccountRepository) FindCustomer(ctx context.Context, email, phoneNumber string) (models.Customer, error) {
+	var customer models.Customer
+	err := scanCustomer(r.db.QueryRowContext(ctx, customerQuery+` c
+	WHERE `+activeCustomer+`
+	AND (CASE WHEN $1 <> '' THEN c.email = $1 ELSE c.phone_number = $2 AND $2 <> '' END)`, email, phoneNumber), &customer)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Customer{}, fmt.Errorf("customer not found: %w", err)
+		}
+		return models.Customer{}, fmt.Errorf("failed to find customer: %w", err)
+	}
+	return customer, nil
+}
+
+// PasswordHash returns the password hash of a customer and until when their
+// password logins are locked, or sql.ErrNoRows when they have no password.
+func (r *AccountRepository) PasswordHash(ctx context.Context, CustomerId string) (string, time.Time, error) {
+	var hash string
+	var lockedUntil sql.NullTime
+	err := r.db.QueryRowContext(ctx, `
+	SELECT password_hash, locked_until FROM customer_credentials WHERE customer_id::text = $1`, CustomerId).Scan(&hash, &lockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", time.Time{}, fmt.Errorf("customer has no password: %w", err)
+		}
+		return "", time.Time{}, fmt.Errorf("failed to get password: %w", err)
+	}
+	return hash, lockedUntil.Time, nil
+}
+
+// FailLogin counts a wrong password of a customer. The maxAttempts-th in a
+// row locks their password logins for lockout and starts the count again.
+func (r *AccountRepository) FailLogin(ctx context.Context, CustomerId string, maxAttempts int, lockout time.Duration) error {
+	_, err := r.db.ExecContext(ctx, `
+	UPDATE customer_credentials SET
+		failed_logins = CASE WHEN failed_logins + 1 >= $2 THEN 0 ELSE failed_logins + 1 END,
+		locked_until = CASE WHEN failed_logins + 1 >= $2 THEN now() + make_interval(secs => $3) ELSE locked_until END
+	WHERE customer_id::text = $1`, CustomerId, maxAttempts, lockout.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to count failed login: %w", err)
+	}
+	return nil
+}
+
+// ResetFailedLogins starts the count of wrong passwords of a customer again.
+func (r *AccountRepository) ResetFailedLogins(ctx context.Context, CustomerId string) error {
+	_, err := r.db.ExecContext(ctx, `
+	UPDATE customer_credentials SET failed_logins = 0
+	WHERE customer_id::text = $1 AND failed_logins > 0`, CustomerId)
+	if err != nil {
+		return fmt.Errorf("failed to reset failed logins: %w", err)
+	}
+	return nil
+}
+
+// SetPassword sets or replaces the password of a customer.
+func (r *AccountRepository) SetPassword(ctx context.Context, CustomerId string, passwordHash string) error {
+	_, err := r.db.ExecContext(ctx, `
+	INSERT INTO customer_credentials (customer_id, password_hash) VALUES ($1, $2)
+	ON CONFLICT (customer_id) DO UPDATE SET password_hash = EXCLUDED.password_hash, failed_logins = 0, locked_until = NULL`, CustomerId, passwordHash)
+	if err != nil {
+		return fmt.Errorf("failed to save password: %w", err)
+	}
+	return nil
+}
+
+// LastLoginCodeAt returns when the last login code of a customer was sent,
+// or the zero time when none was.
+func (r *AccountRepository) LastLoginCodeAt(ctx context.Context, CustomerId string) (time.Time, error) {
+	var last sql.NullTime
+	err := r.db.QueryRowContext(ctx, `
+	SELECT max(created_at) FROM customer_login_codes WHERE customer_id::text = $1`, CustomerId).Scan(&last)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get last login code: %w", err)
+	}
+	return last.Time, nil
+}
+
+// CreateLoginCode saves a new login code of a customer, using up the older
+// ones.
+func (r *AccountRepository) CreateLoginCode(ctx context.Context, CustomerId string, codeHash string, expiresAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, `
+	UPDATE customer_login_codes SET used_at = now() WHERE customer_id::text = $1 AND used_at IS NULL`, CustomerId)
+	if err != nil {
+		return fmt.Errorf("failed to expire login codes: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO customer_login_codes (customer_id, code_hash, expires_at) VALUES ($1, $2, $3)`,
+		CustomerId, codeHash, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to save login code: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// UseLoginCode checks a code against the current login code of a customer
+// and uses it up when it matches. A wrong code counts as an attempt; the
+// code is used up after maxAttempts of them.
+func (r *AccountRepository) UseLoginCode(ctx context.Context, CustomerId string, codeHash string, maxAttempts int) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	var codeId string
+	var matches bool
+	err = tx.QueryRowContext(ctx, `
+	SELECT code_id, code_hash = $2
+	FROM customer_login_codes
+	WHERE customer_id::text = $1 AND used_at IS NULL AND expires_at > now()
+	ORDER BY created_at DESC
+	LIMIT 1
+	FOR UPDATE`, CustomerId, codeHash).Scan(&codeId, &matches)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get login code: %w", err)
+	}
+	if matches {
+		_, err = tx.ExecContext(ctx, `
+		UPDATE customer_login_codes SET used_at = now() WHERE code_id = $1`, codeId)
+	} else {
+		_, err = tx.ExecContext(ctx, `
+		UPDATE customer_login_codes
+		SET attempts = attempts + 1, used_at = CASE WHEN attempts + 1 >= $2 THEN now() END
+		WHERE code_id = $1`, codeId, maxAttempts)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update login code: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return matches, nil
+}
+
+func (r *AccountRepository) CreateSession(ctx context.Context, CustomerId string, tokenHash string, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+	INSERT INTO customer_sessions (customer_id, token_hash, expires_at) VALUES ($1, $2, $3)`,
+		CustomerId, tokenHash, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+// SessionCustomer returns the id of the customer of a live session. It fails
+// with sql.ErrNoRows when the session is unknown, revoked or expired or the
+// customer can no longer log in.
+func (r *AccountRepository) SessionCustomer(ctx context.Context, tokenHash string) (string, error) {
+	var CustomerId string
+	err := r.db.QueryRowContext(ctx, `
+	SELECT c.customer_id::text
+	FROM customer_sessions s
+	JOIN customers c ON c.customer_id = s.customer_id
+	WHERE s.token_hash = $1 AND s.revoked_at IS NULL AND s.expires_at > now()
+	AND `+activeCustomer, tokenHash).Scan(&CustomerId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("session not found: %w", err)
+		}
+		return "", fmt.Errorf("failed to get session: %w", err)
+	}
+	return CustomerId, nil
+}
+
+func (r *AccountRepository) RevokeSession(ctx context.Context, tokenHash string) error {
+	_, err := r.db.ExecContext(ctx, `
+	UPDATE customer_sessions SET revoked_at = now() WHERE token_hash = $1 AND revoked_at IS NULL`, tokenHash)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokeOtherSessions logs a customer out everywhere but in the session of
+// tokenHash.
+func (r *AccountRepository) RevokeOtherSessions(ctx context.Context, CustomerId string, tokenHash string) error {
+	_, err := r.db.ExecContext(ctx, `
+	UPDATE customer_sessions SET revoked_at = now()
+	WHERE customer_id::text = $1 AND token_hash <> $2 AND revoked_at IS NULL`, CustomerId, tokenHash)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
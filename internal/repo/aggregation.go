@@ -5,13 +5,18 @@ import (
 	"database/sql"
 	"fmt"
 	"frappuccino/models"
+	"time"
 )
 
 type AggregationRepo interface {
-	TotalPrice() (float64, error)
-	PopularItems() (models.PopularItems, error)
+	TotalPrice(StoreId string) (float64, error)
+	PopularItems(StoreId string, groupBy string) (models.PopularItems, error)
+	SalesByCategory(StoreId string, groupBy string) (models.SalesByCategory, error)
 	Search(ctx context.Context, q string, filters []string, minPrice, maxPrice float64) (models.Search, error)
 	OrderedItemByPeriod(period string, month string, year string) (models.ListOrderedItemByPeriods, error)
+	CustomerRFM(ctx context.Context, StoreId string) ([]models.CustomerRFM, error)
+	CohortActivity(ctx context.Context, StoreId string, from time.Time, months int) ([]models.CohortActivity, error)
+	CustomerLTV(ctx context.Context, StoreId string) ([]models.CustomerLTV, error)
 	searchMenu(ctx context.Context, q string, minPrice, maxPrice float64) ([]models.SearchMenu, error)
 	searchOrder(ctx context.Context, q string, minPrice, maxPrice float64) ([]models.SearchOrder, error)
 }
@@ -24,14 +29,16 @@ func NewAggregationRepository(db *sql.DB) *AggregationRepository {
 	return &AggregationRepository{db: db}
 }
 
-func (r *AggregationRepository) TotalPrice() (float64, error) {
+// TotalPrice sums completed orders of a store, or of all stores when StoreId is empty.
+func (r *AggregationRepository) TotalPrice(StoreId string) (float64, error) {
 	var totalPrice float64
 
 	err := r.db.QueryRow(`
-		SELECT SUM(total_price)
+		SELECT COALESCE(SUM(total_price), 0)
 		FROM orders
 		WHERE order_status = 'COMPLETED'
-	`).Scan(&totalPrice)
+		AND ($1 = '' OR store_id::text = $1)
+	`, StoreId).Scan(&totalPrice)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -42,15 +49,23 @@ func (r *AggregationRepository) TotalPrice() (float64, error) {
 
 	return totalPrice, nil
 }
-func (r *AggregationRepository) PopularItems() (models.PopularItems, error) {
-	query := `SELECT item_name, count(item_name)
-			 FROM order_items
-			 GROUP BY item_name
-			 ORDER BY count(item_name) DESC
+
+// PopularItems ranks menu items ordered in a store, or in all stores when StoreId is empty.
+// Bundles count as their components, each with its allocated share of the revenue.
+// With groupBy "variant" each variant of an item is ranked separately.
+func (r *AggregationRepository) PopularItems(StoreId string, groupBy string) (models.PopularItems, error) {
+	query := `SELECT mi.item_name, COALESCE(CASE WHEN $2 = 'variant' THEN v.variant_name END, ''), SUM(s.quantity)::int, SUM(s.revenue)
+			 FROM order_item_sales s
+			 JOIN orders o USING(order_id)
+			 JOIN menu_items mi USING(menu_item_id)
+			 LEFT JOIN menu_item_variants v ON v.variant_id = s.variant_id
+			 WHERE $1 = '' OR o.store_id::text = $1
+			 GROUP BY mi.menu_item_id, mi.item_name, CASE WHEN $2 = 'variant' THEN v.variant_name END
+			 ORDER BY SUM(s.quantity) DESC
 			 LIMIT 10`
-	var popularItems models.PopularItems
+	popularItems := models.PopularItems{StoreId: StoreId}
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.Query(query, StoreId, groupBy)
 	if err != nil {
 		return models.PopularItems{}, err
 	}
@@ -58,7 +73,7 @@ func (r *AggregationRepository) PopularItems() (models.PopularItems, error) {
 	for rows.Next() {
 		var popularItem models.PopularItem
 
-		err = rows.Scan(&popularItem.ItemName, &popularItem.OrderedTimes)
+		err = rows.Scan(&popularItem.ItemName, &popularItem.VariantName, &popularItem.OrderedTimes, &popularItem.Revenue)
 		if err != nil {
 			return models.PopularItems{}, err
 		}
@@ -67,6 +82,172 @@ func (r *AggregationRepository) PopularItems() (models.PopularItems, error) {
 	return popularItems, nil
 }
 
+// SalesByCategory sums the quantity and revenue sold per category in a store,
+// or in all stores when StoreId is empty, leaving out cancelled orders.
+// Bundles count as their components. An item in several categories counts
+// in each. With groupBy "parent" subcategories roll up into their top-level
+// category, counting each sale once per top-level category.
+func (r *AggregationRepository) SalesByCategory(StoreId string, groupBy string) (models.SalesByCategory, error) {
+	query := `WITH RECURSIVE roots AS (
+				SELECT category_id, category_id AS root_id FROM categories WHERE parent_id IS NULL
+				UNION ALL
+				SELECT c.category_id, r.root_id FROM categories c JOIN roots r ON c.parent_id = r.category_id
+			 ),
+			 sales AS (
+				SELECT row_number() OVER () AS sale_id, s.*
+				FROM order_item_sales s
+				JOIN orders o USING(order_id)
+				WHERE o.order_status <> 'CANCELLED' AND ($1 = '' OR o.store_id::text = $1)
+			 ),
+			 grouped AS (
+				SELECT DISTINCT s.sale_id, s.quantity, s.revenue,
+					CASE WHEN $2 = 'parent' THEN r.root_id ELSE mc.category_id END AS category_id
+				FROM sales s
+				JOIN menu_item_categories mc USING(menu_item_id)
+				JOIN roots r ON r.category_id = mc.category_id
+			 )
+			 SELECT c.category_id, c.category_name, SUM(g.quantity)::int, SUM(g.revenue)
+			 FROM grouped g
+			 JOIN categories c USING(category_id)
+			 GROUP BY c.category_id, c.category_name
+			 ORDER BY SUM(g.revenue) DESC, c.category_name`
+	if groupBy == "" {
+		groupBy = "category"
+	}
+	sales := models.SalesByCategory{StoreId: StoreId, GroupBy: groupBy}
+
+	rows, err := r.db.Query(query, StoreId, groupBy)
+	if err != nil {
+		return models.SalesByCategory{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var category models.CategorySales
+
+		err = rows.Scan(&category.CategoryId, &category.CategoryName, &category.Quantity, &category.Revenue)
+		if err != nil {
+			return models.SalesByCategory{}, err
+		}
+		sales.Categories = append(sales.Categories, category)
+	}
+	return sales, rows.Err()
+}
+
+// CustomerRFM scores the active customers with orders in a store, or in all
+// stores when StoreId is empty, leaving out cancelled orders. Each score is
+// the quintile of the customer among the others, so ties score the same.
+func (r *AggregationRepository) CustomerRFM(ctx context.Context, StoreId string) ([]models.CustomerRFM, error) {
+	query := `WITH stats AS (
+				SELECT c.customer_id, c.full_name, c.email, c.phone_number,
+					MAX(o.created_at) AS last_order_at, COUNT(*) AS orders, SUM(o.total_price) AS spent
+				FROM orders o
+				JOIN customers c USING(customer_id)
+				WHERE o.order_status <> 'CANCELLED' AND c.archived_at IS NULL
+				AND ($1 = '' OR o.store_id::text = $1)
+				GROUP BY c.customer_id
+			 )
+			 SELECT customer_id, full_name, email, phone_number, last_order_at,
+				EXTRACT(DAY FROM now() - last_order_at)::int, orders, spent,
+				CEIL(CUME_DIST() OVER (ORDER BY last_order_at) * 5)::int,
+				CEIL(CUME_DIST() OVER (ORDER BY orders) * 5)::int,
+				CEIL(CUME_DIST() OVER (ORDER BY spent) * 5)::int
+			 FROM stats
+			 ORDER BY spent DESC, full_name`
+	rows, err := r.db.QueryContext(ctx, query, StoreId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query customer rfm: %w", err)
+	}
+	defer rows.Close()
+	customers := []models.CustomerRFM{}
+	for rows.Next() {
+		var c models.CustomerRFM
+		err = rows.Scan(&c.CustomerId, &c.FullName, &c.Email, &c.PhoneNumber, &c.LastOrderAt, &c.DaysSince,
+			&c.Orders, &c.Spent, &c.Recency, &c.Frequency, &c.Monetary)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan customer rfm: %w", err)
+		}
+		customers = append(customers, c)
+	}
+	return customers, rows.Err()
+}
+
+// CohortActivity groups the customers who signed up from the month of from
+// on into monthly cohorts and counts, for each of the months 1 to months
+// after the signup month, those who ordered in a store, or in any store when
+// StoreId is empty. Month 0 counts the whole cohort. Cancelled orders and
+// customers merged into others are left out.
+func (r *AggregationRepository) CohortActivity(ctx context.Context, StoreId string, from time.Time, months int) ([]models.CohortActivity, error) {
+	query := `WITH cohorts AS (
+				SELECT customer_id, date_trunc('month', created_at) AS cohort
+				FROM customers
+				WHERE merged_into IS NULL AND created_at >= date_trunc('month', $2::timestamptz)
+			 ),
+			 activity AS (
+				SELECT DISTINCT customer_id, date_trunc('month', created_at) AS month
+				FROM orders
+				WHERE order_status <> 'CANCELLED' AND ($1 = '' OR store_id::text = $1)
+			 )
+			 SELECT to_char(cohort, 'YYYY-MM'), 0, COUNT(*)::int
+			 FROM cohorts
+			 GROUP BY cohort
+			 UNION ALL
+			 SELECT to_char(c.cohort, 'YYYY-MM'),
+				((EXTRACT(YEAR FROM a.month) - EXTRACT(YEAR FROM c.cohort)) * 12
+					+ EXTRACT(MONTH FROM a.month) - EXTRACT(MONTH FROM c.cohort))::int,
+				COUNT(*)::int
+			 FROM cohorts c
+			 JOIN activity a ON a.customer_id = c.customer_id
+				AND a.month > c.cohort AND a.month <= c.cohort + make_interval(months => $3)
+			 GROUP BY 1, 2
+			 ORDER BY 1, 2`
+	rows, err := r.db.QueryContext(ctx, query, StoreId, from, months)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cohort activity: %w", err)
+	}
+	defer rows.Close()
+	var activity []models.CohortActivity
+	for rows.Next() {
+		var a models.CohortActivity
+		if err := rows.Scan(&a.Cohort, &a.Month, &a.Customers); err != nil {
+			return nil, fmt.Errorf("failed to scan cohort activity: %w", err)
+		}
+		activity = append(activity, a)
+	}
+	return activity, rows.Err()
+}
+
+// CustomerLTV sums the orders of each customer in a store, or in all stores
+// when StoreId is empty, leaving out cancelled orders and customers merged
+// into others. Customers without orders are included with nothing spent.
+func (r *AggregationRepository) CustomerLTV(ctx context.Context, StoreId string) ([]models.CustomerLTV, error) {
+	query := `SELECT c.customer_id, c.full_name, to_char(date_trunc('month', c.created_at), 'YYYY-MM'),
+				COUNT(o.order_id)::int, COALESCE(SUM(o.total_price), 0), MIN(o.created_at), MAX(o.created_at)
+			 FROM customers c
+			 LEFT JOIN orders o ON o.customer_id = c.customer_id
+				AND o.order_status <> 'CANCELLED' AND ($1 = '' OR o.store_id::text = $1)
+			 WHERE c.merged_into IS NULL
+			 GROUP BY c.customer_id, c.full_name, c.created_at
+			 ORDER BY 5 DESC, c.full_name`
+	rows, err := r.db.QueryContext(ctx, query, StoreId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query customer lifetime value: %w", err)
+	}
+	defer rows.Close()
+	customers := []models.CustomerLTV{}
+	for rows.Next() {
+		var c models.CustomerLTV
+		err := rows.Scan(&c.CustomerId, &c.FullName, &c.Cohort, &c.Orders, &c.Revenue, &c.FirstOrderAt, &c.LastOrderAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan customer lifetime value: %w", err)
+		}
+		if c.Orders > 0 {
+			c.AverageOrderValue = c.Revenue / float64(c.Orders)
+		}
+		customers = append(customers, c)
+	}
+	return customers, rows.Err()
+}
+
 func (r *AggregationRepository) Search(ctx context.Context, q string, filters []string, minPrice, maxPrice float64) (models.Search, error) {
 	var err error
 	var searchMenu []models.SearchMenu
@@ -0,0 +1,222 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"frappuccino/models"
+	"frappuccino/utils"
+	"math"
+
+	"github.com/lib/pq"
+)
+
+// setBundleSlots replaces the bundle slots of item. Options must be plain
+// menu items: bundles cannot contain bundles.
+func setBundleSlots(ctx context.Context, tx *sql.Tx, item *models.MenuItems) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM bundle_slots WHERE bundle_id = $1`, item.MenuItemId); err != nil {
+		return fmt.Errorf("failed to clear bundle slots: %w", err)
+	}
+	if len(item.BundleSlots) == 0 {
+		return nil
+	}
+	var isComponent bool
+	err := tx.QueryRowContext(ctx, `
+	SELECT EXISTS (SELECT 1 FROM bundle_slot_options WHERE menu_item_id = $1)`, item.MenuItemId).Scan(&isComponent)
+	if err != nil {
+		return fmt.Errorf("failed to check bundle components: %w", err)
+	}
+	if isComponent {
+		return fmt.Errorf("%s is a component of another bundle: %w", item.ItemName, models.ErrInvalidBundle)
+	}
+
+	for i := range item.BundleSlots {
+		slot := &item.BundleSlots[i]
+		slot.BundleId = item.MenuItemId
+		if slot.Quantity <= 0 {
+			slot.Quantity = 1
+		}
+		if len(slot.Options) == 0 {
+			return fmt.Errorf("slot %s has no options: %w", slot.SlotName, models.ErrInvalidBundle)
+		}
+		err := tx.QueryRowContext(ctx, `
+		INSERT INTO bundle_slots (bundle_id, slot_name, quantity, display_order)
+		VALUES ($1, $2, $3, $4)
+		RETURNING slot_id`, slot.BundleId, slot.SlotName, slot.Quantity, slot.DisplayOrder).Scan(&slot.SlotId)
+		if err != nil {
+			return fmt.Errorf("failed to save bundle slot %s: %w", slot.SlotName, err)
+		}
+		for _, option := range slot.Options {
+			res, err := tx.ExecContext(ctx, `
+			INSERT INTO bundle_slot_options (slot_id, menu_item_id)
+			SELECT $1, menu_item_id
+			FROM menu_items
+			WHERE menu_item_id = $2 AND menu_item_id <> $3 AND archived_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM bundle_slots WHERE bundle_id = $2)`, slot.SlotId, option, item.MenuItemId)
+			if err != nil {
+				return fmt.Errorf("failed to add bundle slot option: %w", err)
+			}
+			if n, err := res.RowsAffected(); err != nil {
+				return fmt.Errorf("failed to check rows affected: %w", err)
+			} else if n == 0 {
+				return fmt.Errorf("option %s of slot %s is missing, archived or a bundle: %w", option, slot.SlotName, models.ErrInvalidBundle)
+			}
+		}
+	}
+	return nil
+}
+
+// bundleSlots returns the slots of the given menu items keyed by bundle id.
+func bundleSlots(ctx context.Context, q querier, MenuItemIds []string) (map[utils.TEXT][]models.BundleSlot, error) {
+	rows, err := q.QueryContext(ctx, `
+	SELECT s.slot_id, s.bundle_id, s.slot_name, s.quantity, s.display_order,
+		array_agg(o.menu_item_id::text ORDER BY o.menu_item_id)
+	FROM bundle_slots s
+	JOIN bundle_slot_options o USING(slot_id)
+	WHERE s.bundle_id = ANY($1::uuid[])
+	GROUP BY s.slot_id
+	ORDER BY s.display_order, s.slot_name`, pq.Array(MenuItemIds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bundle slots: %w", err)
+	}
+	defer rows.Close()
+	byBundle := make(map[utils.TEXT][]models.BundleSlot)
+	for rows.Next() {
+		var slot models.BundleSlot
+		var options []string
+		err := rows.Scan(&slot.SlotId, &slot.BundleId, &slot.SlotName, &slot.Quantity, &slot.DisplayOrder, pq.Array(&options))
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan bundle slot: %w", err)
+		}
+		for _, option := range options {
+			slot.Options = append(slot.Options, utils.TEXT(option))
+		}
+		byBundle[slot.BundleId] = append(byBundle[slot.BundleId], slot)
+	}
+	return byBundle, rows.Err()
+}
+
+// bundleComponents resolves the components of a bundle order item from its
+// selections. Fixed slots need no selection. It returns nil for other items.
+func bundleComponents(ctx context.Context, q querier, item models.OrderItems) ([]models.OrderItemComponent, error) {
+	slots, err := bundleSlots(ctx, q, []string{string(item.MenuItemId)})
+	if err != nil {
+		return nil, err
+	}
+	selected := make(map[utils.TEXT]utils.TEXT, len(item.Selections))
+	for _, s := range item.Selections {
+		selected[s.SlotId] = s.MenuItemId
+	}
+
+	var components []models.OrderItemComponent
+	for _, slot := range slots[item.MenuItemId] {
+		choice, ok := selected[slot.SlotId]
+		delete(selected, slot.SlotId)
+		if !ok {
+			if len(slot.Options) != 1 {
+				return nil, fmt.Errorf("slot %s needs a selection: %w", slot.SlotName, models.ErrInvalidBundle)
+			}
+			choice = slot.Options[0]
+		}
+		valid := false
+		for _, option := range slot.Options {
+			valid = valid || option == choice
+		}
+		if !valid {
+			return nil, fmt.Errorf("%s is not an option of slot %s: %w", choice, slot.SlotName, models.ErrInvalidBundle)
+		}
+		components = append(components, models.OrderItemComponent{
+			MenuItemId: choice,
+			SlotName:   slot.SlotName,
+			Quantity:   slot.Quantity,
+		})
+	}
+	for slotId := range selected {
+		return nil, fmt.Errorf("slot %s is not part of menu item %s: %w", slotId, item.MenuItemId, models.ErrInvalidBundle)
+	}
+	return components, nil
+}
+
+// componentsRecipe adds the recipes of bundle components to the recipe of one bundle.
+func componentsRecipe(ctx context.Context, q querier, recipe []models.MenuItemsIngredients, components []models.OrderItemComponent) ([]models.MenuItemsIngredients, error) {
+	for _, c := range components {
+		lines, err := menuIngredients(ctx, q, string(c.MenuItemId))
+		if err != nil {
+			return nil, err
+		}
+	next:
+		for _, line := range lines {
+			line.Quantity *= c.Quantity
+			for i := range recipe {
+				if recipe[i].IngredientId == line.IngredientId {
+					recipe[i].Quantity += line.Quantity
+					continue next
+				}
+			}
+			recipe = append(recipe, line)
+		}
+	}
+	return recipe, nil
+}
+
+// allocateBundle splits the unit price of a bundle over its components in
+// proportion to their store prices, so component sales carry the revenue.
+// The last component takes the rounding remainder.
+func allocateBundle(ctx context.Context, q querier, StoreId utils.TEXT, unitPrice utils.DEC, components []models.OrderItemComponent) error {
+	weights := make([]utils.DEC, len(components))
+	var total utils.DEC
+	for i, c := range components {
+		var price utils.DEC
+		err := q.QueryRowContext(ctx, `
+		SELECT COALESCE(smi.price, mi.price)
+		FROM menu_items mi
+		LEFT JOIN store_menu_items smi ON smi.menu_item_id = mi.menu_item_id AND smi.store_id = $2
+		WHERE mi.menu_item_id = $1 AND mi.archived_at IS NULL AND COALESCE(smi.is_available, TRUE)`, c.MenuItemId, StoreId).Scan(&price)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("bundle component %s: %w", c.MenuItemId, models.ErrItemNotAvailable)
+			}
+			return err
+		}
+		weights[i] = price * c.Quantity
+		if weights[i] == 0 {
+			weights[i] = c.Quantity
+		}
+		total += weights[i]
+	}
+
+	remaining := unitPrice
+	for i := range components {
+		if i == len(components)-1 {
+			components[i].AllocatedPrice = remaining
+			break
+		}
+		share := utils.DEC(math.Round(float64(unitPrice*weights[i]/total)*100) / 100)
+		components[i].AllocatedPrice = share
+		remaining -= share
+	}
+	return nil
+}
+
+// orderItemComponents returns the components sold in a bundle order item.
+func orderItemComponents(ctx context.Context, q querier, OrderItemId utils.TEXT) ([]models.OrderItemComponent, error) {
+	rows, err := q.QueryContext(ctx, `
+	SELECT menu_item_id, slot_name, quantity, allocated_price
+	FROM order_item_components
+	WHERE order_item_id = $1
+	ORDER BY slot_name`, OrderItemId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order item components: %w", err)
+	}
+	defer rows.Close()
+	var components []models.OrderItemComponent
+	for rows.Next() {
+		var c models.OrderItemComponent
+		if err := rows.Scan(&c.MenuItemId, &c.SlotName, &c.Quantity, &c.AllocatedPrice); err != nil {
+			return nil, fmt.Errorf("failed to scan order item component: %w", err)
+		}
+		components = append(components, c)
+	}
+	return components, rows.Err()
+}
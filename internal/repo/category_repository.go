@@ -0,0 +1,192 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"frappuccino/models"
+	"frappuccino/utils"
+
+	"github.com/lib/pq"
+)
+
+type CategoryRepo interface {
+	Create(ctx context.Context, category *models.Category) error
+	GetAll(ctx context.Context) ([]models.Category, error)
+	GetCategoryByID(ctx context.Context, CategoryId string) (models.Category, error)
+	UpdateCategoryByID(ctx context.Context, category *models.Category) error
+	DeleteCategoryByID(ctx context.Context, CategoryId string) error
+}
+
+type CategoryRepository struct {
+	db *sql.DB
+}
+
+func NewCategoryRepository(db *sql.DB) *CategoryRepository {
+	return &CategoryRepository{db: db}
+}
+
+// categoryError turns constraint violations on categories into ErrInvalidCategory.
+func categoryError(action string, err error) error {
+	switch pqCode(err) {
+	case uniqueViolation:
+		return fmt.Errorf("category name is taken: %w", models.ErrInvalidCategory)
+	case foreignKeyViolation, invalidTextRepresentation:
+		return fmt.Errorf("parent category not found: %w", models.ErrInvalidCategory)
+	}
+	return fmt.Errorf("failed to %s category: %w", action, err)
+}
+
+func (r *CategoryRepository) Create(ctx context.Context, category *models.Category) error {
+	err := r.db.QueryRowContext(ctx, `
+	INSERT INTO categories (category_name, parent_id, description, display_order)
+	VALUES ($1, NULLIF($2, '')::uuid, $3, $4)
+	RETURNING category_id, created_at, updated_at`,
+		category.CategoryName, category.ParentId, category.Description, category.DisplayOrder).Scan(
+		&category.CategoryId, &category.CreatedAt, &category.UpdatedAt)
+	if err != nil {
+		return categoryError("create", err)
+	}
+	return nil
+}
+
+// categoryColumns lists the categories columns in the order scanCategory reads them.
+const categoryColumns = `category_id, category_name, COALESCE(parent_id::text, ''), description, display_order, created_at, updated_at`
+
+func scanCategory(row interface{ Scan(...any) error }, c *models.Category) error {
+	return row.Scan(&c.CategoryId, &c.CategoryName, &c.ParentId, &c.Description, &c.DisplayOrder, &c.CreatedAt, &c.UpdatedAt)
+}
+
+// GetAll returns every category in display order.
+func (r *CategoryRepository) GetAll(ctx context.Context) ([]models.Category, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+categoryColumns+` FROM categories ORDER BY display_order, category_name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categories: %w", err)
+	}
+	defer rows.Close()
+	var categories []models.Category
+	for rows.Next() {
+		var c models.Category
+		if err := scanCategory(rows, &c); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+func (r *CategoryRepository) GetCategoryByID(ctx context.Context, CategoryId string) (models.Category, error) {
+	var c models.Category
+	err := scanCategory(r.db.QueryRowContext(ctx, `SELECT `+categoryColumns+` FROM categories WHERE category_id::text = $1`, CategoryId), &c)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Category{}, fmt.Errorf("category not found: %w", err)
+		}
+		return models.Category{}, fmt.Errorf("failed to get category: %w", err)
+	}
+	return c, nil
+}
+
+// UpdateCategoryByID saves a category, refusing a parent that would make the tree a cycle.
+func (r *CategoryRepository) UpdateCategoryByID(ctx context.Context, category *models.Category) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if category.ParentId != "" {
+		var cycle bool
+		err := tx.QueryRowContext(ctx, `
+		WITH RECURSIVE subtree AS (
+			SELECT category_id FROM categories WHERE category_id::text = $1
+			UNION
+			SELECT c.category_id FROM categories c JOIN subtree s ON c.parent_id = s.category_id
+		)
+		SELECT EXISTS (SELECT 1 FROM subtree WHERE category_id::text = $2)`, category.CategoryId, category.ParentId).Scan(&cycle)
+		if err != nil {
+			return fmt.Errorf("failed to check category parent: %w", err)
+		}
+		if cycle {
+			return fmt.Errorf("category cannot be its own parent: %w", models.ErrInvalidCategory)
+		}
+	}
+	err = tx.QueryRowContext(ctx, `
+	UPDATE categories
+	SET category_name = $2, parent_id = NULLIF($3, '')::uuid, description = $4, display_order = $5
+	WHERE category_id::text = $1
+	RETURNING created_at, updated_at`,
+		category.CategoryId, category.CategoryName, category.ParentId, category.Description, category.DisplayOrder).Scan(
+		&category.CreatedAt, &category.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		return categoryError("update", err)
+	}
+	return tx.Commit()
+}
+
+// DeleteCategoryByID deletes a category without subcategories. Its items
+// and schedules lose the category.
+func (r *CategoryRepository) DeleteCategoryByID(ctx context.Context, CategoryId string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM categories WHERE category_id::text = $1`, CategoryId)
+	if err != nil {
+		if pqCode(err) == foreignKeyViolation {
+			return models.ErrCategoryInUse
+		}
+		return fmt.Errorf("failed to delete category: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// setMenuCategories replaces the categories of item when it lists category ids.
+func setMenuCategories(ctx context.Context, tx *sql.Tx, item *models.MenuItems) error {
+	if item.CategoryIds == nil {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM menu_item_categories WHERE menu_item_id = $1`, item.MenuItemId); err != nil {
+		return fmt.Errorf("failed to clear menu item categories: %w", err)
+	}
+	ids := make(map[string]bool, len(item.CategoryIds))
+	for _, id := range item.CategoryIds {
+		ids[id] = true
+	}
+	res, err := tx.ExecContext(ctx, `
+	INSERT INTO menu_item_categories (menu_item_id, category_id)
+	SELECT $1, category_id FROM categories WHERE category_id::text = ANY($2::text[])`, item.MenuItemId, pq.Array(item.CategoryIds))
+	if err != nil {
+		return fmt.Errorf("failed to add menu item categories: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	} else if int(n) != len(ids) {
+		return fmt.Errorf("menu item %s lists unknown categories: %w", item.ItemName, models.ErrInvalidCategory)
+	}
+	return nil
+}
+
+// categoryIdsByName finds categories by name, ignoring case.
+func categoryIdsByName(ctx context.Context, q querier, names []string) (utils.TEXTARR, error) {
+	ids := utils.TEXTARR{}
+	for _, name := range names {
+		var id string
+		err := q.QueryRowContext(ctx, `SELECT category_id FROM categories WHERE lower(category_name) = lower($1)`, name).Scan(&id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, fmt.Errorf("category %q not found", name)
+			}
+			return nil, fmt.Errorf("failed to look up category %q: %w", name, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,159 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"frappuccino/models"
+	"frappuccino/utils"
+
+	"github.com/lib/pq"
+)
+
+// CustomerOrders returns a page of the orders of a customer, newest first.
+// It fails with sql.ErrNoRows when the customer does not exist.
+func (r *OrderRepository) CustomerOrders(ctx context.Context, CustomerId string, filter models.OrderFilter) (models.OrderPage, error) {
+	page := models.OrderPage{Orders: []models.Order{}, Page: filter.Page, PageSize: filter.PageSize}
+	statuses := pq.Array(filter.Statuses)
+	err := r.db.QueryRowContext(ctx, `
+	SELECT count(o.order_id)
+	FROM customers c
+	LEFT JOIN orders o ON o.customer_id = c.customer_id
+		AND (cardinality($2::text[]) = 0 OR o.order_status::text = ANY($2::text[]))
+		AND ($3 = '' OR o.store_id::text = $3)
+	WHERE c.customer_id::text = $1
+	GROUP BY c.customer_id`, CustomerId, statuses, filter.StoreId).Scan(&page.Total)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return page, fmt.Errorf("customer not found: %w", err)
+		}
+		return page, fmt.Errorf("failed to count customer orders: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+orderColumns+`
+	FROM orders
+	WHERE customer_id::text = $1 AND (cardinality($2::text[]) = 0 OR order_status::text = ANY($2::text[]))
+	AND ($5 = '' OR store_id::text = $5)
+	ORDER BY created_at DESC, order_id
+	LIMIT $3 OFFSET $4`, CustomerId, statuses, filter.PageSize, (filter.Page-1)*filter.PageSize, filter.StoreId)
+	if err != nil {
+		return page, fmt.Errorf("failed to query customer orders: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var order models.Order
+		if err := scanOrder(rows, &order); err != nil {
+			return page, fmt.Errorf("failed to scan order: %w", err)
+		}
+		page.Orders = append(page.Orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return page, err
+	}
+	for i := range page.Orders {
+		page.Orders[i].OrderItems, err = r.orderItems(ctx, page.Orders[i].OrderId)
+		if err != nil {
+			return page, err
+		}
+	}
+	return page, nil
+}
+
+// Reorder places order, a copy of a past order, with those of its items that
+// can still be ordered in order.StoreId, at current prices. Bundle components
+// are matched to the current bundle slots by slot name. The items left out
+// are returned; when none is left nothing is placed and the error is
+// models.ErrNothingToReorder.
+func (r *OrderRepository) Reorder(ctx context.Context, order *models.Order) ([]models.UnavailableItem, error) {
+	if order.StoreId == "" {
+		return nil, models.ErrMissingStore
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	unavailable := []models.UnavailableItem{}
+	available := make([]models.OrderItems, 0, len(order.OrderItems))
+	for _, item := range order.OrderItems {
+		item, err := r.reorderItem(ctx, tx, order.StoreId, item)
+		if err != nil {
+			if !unavailableItem(err) {
+				return nil, err
+			}
+			unavailable = append(unavailable, models.UnavailableItem{
+				MenuItemId: item.MenuItemId,
+				ItemName:   item.ItemName,
+				VariantId:  item.VariantId,
+				Quantity:   item.Quantity,
+				Reason:     utils.TEXT(err.Error()),
+			})
+			continue
+		}
+		available = append(available, item)
+	}
+	if len(available) == 0 {
+		return unavailable, models.ErrNothingToReorder
+	}
+	order.OrderItems = available
+	if err := r.create(ctx, tx, order); err != nil {
+		return nil, err
+	}
+	return unavailable, tx.Commit()
+}
+
+// reorderItem turns an item of a past order into a new order item and checks
+// that it can be ordered in the store on its own.
+func (r *OrderRepository) reorderItem(ctx context.Context, tx *sql.Tx, storeId utils.TEXT, past models.OrderItems) (models.OrderItems, error) {
+	item := models.OrderItems{
+		MenuItemId:     past.MenuItemId,
+		ItemName:       past.ItemName,
+		VariantId:      past.VariantId,
+		Modifiers:      past.Modifiers,
+		Customizations: past.Customizations,
+		Quantity:       past.Quantity,
+	}
+	if len(past.Components) > 0 {
+		slots, err := bundleSlots(ctx, tx, []string{string(past.MenuItemId)})
+		if err != nil {
+			return item, err
+		}
+		bySlotName := make(map[utils.TEXT]utils.TEXT, len(slots[past.MenuItemId]))
+		for _, slot := range slots[past.MenuItemId] {
+			bySlotName[slot.SlotName] = slot.SlotId
+		}
+		for _, c := range past.Components {
+			slotId, ok := bySlotName[c.SlotName]
+			if !ok {
+				return item, fmt.Errorf("slot %s was removed: %w", c.SlotName, models.ErrInvalidBundle)
+			}
+			item.Selections = append(item.Selections, models.BundleSelection{SlotId: slotId, MenuItemId: c.MenuItemId})
+		}
+	}
+
+	usage, lines, err := orderUsage(ctx, tx, []models.OrderItems{item})
+	if err != nil {
+		return item, err
+	}
+	if err := r.checkIngregients(ctx, tx, storeId, usage); err != nil {
+		return item, err
+	}
+	if _, err := itemPrice(ctx, tx, storeId, item, lines[0].components); err != nil {
+		return item, err
+	}
+	return item, nil
+}
+
+// unavailableItem tells whether err means that an item cannot be ordered
+// as it was rather than that checking it failed.
+func unavailableItem(err error) bool {
+	for _, target := range []error{models.ErrItemNotAvailable, models.ErrOutsideSchedule, models.ErrInsufficientStock,
+		models.ErrInvalidVariant, models.ErrInvalidModifier, models.ErrInvalidBundle} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"frappuccino/models"
+	"frappuccino/utils"
+
+	"github.com/lib/pq"
+)
+
+// erasedFields are the fields Erase clears and the account tables it
+// deletes the rows of.
+var erasedFields = []string{
+	"customers.full_name", "customers.phone_number", "customers.email", "customers.preferences",
+	"orders.special_instructions", "order_items.customizations",
+	"customer_credentials", "customer_login_codes", "customer_sessions",
+}
+
+// erasedName replaces the name of erased customers.
+const erasedName = "Erased customer"
+
+// MergedCustomers returns the customers that were merged into a customer.
+func (r *CustomerRepository) MergedCustomers(ctx context.Context, CustomerId string) ([]models.Customer, error) {
+	rows, err := r.db.QueryContext(ctx, customerQuery+`
+	WHERE merged_into::text = $1
+	ORDER BY created_at`, CustomerId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query merged customers: %w", err)
+	}
+	defer rows.Close()
+	customers := []models.Customer{}
+	for rows.Next() {
+		var customer models.Customer
+		if err := scanCustomer(rows, &customer); err != nil {
+			return nil, fmt.Errorf("failed to scan Customer: %w", err)
+		}
+		customers = append(customers, customer)
+	}
+	return customers, rows.Err()
+}
+
+// Erase anonymises a customer and the customers merged into it, archives
+// them, deletes their accounts and records the erasure. Their orders are
+// kept for accounting with their free-text instructions and customizations
+// cleared. It fails with sql.ErrNoRows when the customer does not exist.
+func (r *CustomerRepository) Erase(ctx context.Context, erasure *models.CustomerErasure) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var erased bool
+	err = tx.QueryRowContext(ctx, `
+	SELECT erased_at IS NOT NULL FROM customers WHERE customer_id::text = $1 FOR UPDATE`, erasure.CustomerId).Scan(&erased)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("Customer not found: %w", err)
+		}
+		return fmt.Errorf("failed to get Customer: %w", err)
+	}
+	if erased {
+		return models.ErrCustomerErased
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	UPDATE customers
+	SET full_name = $2, phone_number = '', email = '', preferences = '{}',
+		archived_at = COALESCE(archived_at, now()), erased_at = now()
+	WHERE customer_id::text = $1 OR merged_into::text = $1`, erasure.CustomerId, erasedName)
+	if err != nil {
+		return fmt.Errorf("failed to erase Customer: %w", err)
+	}
+	for _, table := range []string{"customer_credentials", "customer_login_codes", "customer_sessions"} {
+		_, err = tx.ExecContext(ctx, `
+		DELETE FROM `+table+`
+		WHERE customer_id IN (SELECT customer_id FROM customers WHERE customer_id::text = $1 OR merged_into::text = $1)`, erasure.CustomerId)
+		if err != nil {
+			return fmt.Errorf("failed to delete %s: %w", table, err)
+		}
+	}
+	_, err = tx.ExecContext(ctx, `
+	UPDATE order_items SET customizations = '{}'
+	WHERE order_id IN (SELECT order_id FROM orders WHERE customer_id::text = $1)`, erasure.CustomerId)
+	if err != nil {
+		return fmt.Errorf("failed to erase order customizations: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, `
+	UPDATE orders SET special_instructions = '{}' WHERE customer_id::text = $1`, erasure.CustomerId)
+	if err != nil {
+		return fmt.Errorf("failed to erase order instructions: %w", err)
+	}
+	kept, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+
+	erasure.ErasedFields = erasedFields
+	erasure.OrdersKept = utils.INT(kept)
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO customer_erasures (customer_id, requested_by, reason, erased_fields, orders_kept)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING erasure_id, erased_at`, erasure.CustomerId, erasure.RequestedBy, erasure.Reason,
+		pq.Array(erasure.ErasedFields), erasure.OrdersKept).Scan(&erasure.ErasureId, &erasure.ErasedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record erasure: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Erasures returns the audit trail of erasures, newest first, of a customer
+// or of all customers when CustomerId is empty.
+func (r *CustomerRepository) Erasures(ctx context.Context, CustomerId string) ([]models.CustomerErasure, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT erasure_id, customer_id, requested_by, reason, erased_fields, orders_kept, erased_at
+	FROM customer_erasures
+	WHERE $1 = '' OR customer_id::text = $1
+	ORDER BY erased_at DESC`, CustomerId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query customer erasures: %w", err)
+	}
+	defer rows.Close()
+	erasures := []models.CustomerErasure{}
+	for rows.Next() {
+		var e models.CustomerErasure
+		err := rows.Scan(&e.ErasureId, &e.CustomerId, &e.RequestedBy, &e.Reason, pq.Array(&e.ErasedFields), &e.OrdersKept, &e.ErasedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan customer erasure: %w", err)
+		}
+		erasures = append(erasures, e)
+	}
+	return erasures, rows.Err()
+}
@@ -3,17 +3,29 @@ package repo
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"frappuccino/models"
+	"frappuccino/utils"
+	"strings"
+	"unicode"
+
+	"github.com/lib/pq"
 )
 
 type CustomerRepo interface {
 	Create(ctx context.Context, customer *models.Customer) error
-	GetAll(ctx context.Context) ([]models.Customer, error)
+	GetAll(ctx context.Context, filter models.CustomerFilter) ([]models.Customer, error)
 	GetCustomerByID(ctx context.Context, CustomerId string) (models.Customer, error)
 	UpdateCustomerByID(ctx context.Context, customer *models.Customer) error
 	DeleteCustomerByID(ctx context.Context, CustomerId string) error
+	RestoreCustomerByID(ctx context.Context, CustomerId string) error
+	Duplicates(ctx context.Context, minNameSimilarity float64) ([]models.DuplicateCustomers, error)
+	Merge(ctx context.Context, SurvivorId string, DuplicateIds []string) (int, error)
+	MergedCustomers(ctx context.Context, CustomerId string) ([]models.Customer, error)
+	Erase(ctx context.Context, erasure *models.CustomerErasure) error
+	Erasures(ctx context.Context, CustomerId string) ([]models.CustomerErasure, error)
 }
 
 type CustomerRepository struct {
@@ -25,19 +37,121 @@ func NewCustomerRepository(db *sql.DB) *CustomerRepository {
 }
 
 func (r *CustomerRepository) Create(ctx context.Context, customer *models.Customer) error {
-	err := r.db.QueryRowContext(ctx,
+	return createCustomer(ctx, r.db, customer)
+}
+
+func createCustomer(ctx context.Context, q querier, customer *models.Customer) error {
+	preferences, err := encodePreferences(ctx, q, customer.Preferences)
+	if err != nil {
+		return err
+	}
+	err = q.QueryRowContext(ctx,
 		`INSERT INTO customers (full_name,phone_number,email,preferences)
 	     VALUES ($1,$2,$3,$4)
-		 RETURNING customer_id,created_at,updated_at`, customer.FullName, customer.PhoneNumber, customer.Email, customer.Preferences).Scan(&customer.CustomerId, &customer.CreatedAt, &customer.UpdatedAt)
+		 RETURNING customer_id,created_at,updated_at`, customer.FullName, customer.PhoneNumber, customer.Email, preferences).Scan(&customer.CustomerId, &customer.CreatedAt, &customer.UpdatedAt)
 	if err != nil {
+		if taken := contactTaken(err); taken != nil {
+			return taken
+		}
 		return fmt.Errorf("failed to create Customer: %w", err)
 	}
 	return nil
 }
 
-func (r *CustomerRepository) GetAll(ctx context.Context) ([]models.Customer, error) {
-	rows, err := r.db.QueryContext(ctx, `
-		SELECT * FROM customers`)
+// contactIndexes are the unique indexes on customer contact details, by the
+// field they cover.
+var contactIndexes = map[string]string{
+	"idx_customers_email_unique":        "email",
+	"idx_customers_phone_number_unique": "phone_number",
+}
+
+// contactTaken turns the violation of a unique contact index into a
+// ValidationError telling which field is taken, or returns nil.
+func contactTaken(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != uniqueViolation {
+		return nil
+	}
+	field, ok := contactIndexes[pqErr.Constraint]
+	if !ok {
+		return nil
+	}
+	return &models.ValidationError{
+		Err:    models.ErrContactTaken,
+		Fields: map[string]string{field: "is used by another customer"},
+	}
+}
+
+// customerQuery selects customers, archived ones included.
+const customerQuery = `
+	SELECT customer_id, full_name, phone_number, email, preferences, archived_at, COALESCE(merged_into::text, ''), erased_at, created_at, updated_at
+	FROM customers`
+
+func scanCustomer(row interface{ Scan(...any) error }, customer *models.Customer) error {
+	var preferences []byte
+	err := row.Scan(&customer.CustomerId, &customer.FullName, &customer.PhoneNumber, &customer.Email, &preferences,
+		&customer.ArchivedAt, &customer.MergedInto, &customer.ErasedAt, &customer.CreatedAt, &customer.UpdatedAt)
+	if err != nil || preferences == nil {
+		return err
+	}
+	return json.Unmarshal(preferences, &customer.Preferences)
+}
+
+// encodePreferences checks that the favourite drink of preferences is a menu
+// item on sale and encodes them for the preferences column.
+func encodePreferences(ctx context.Context, q querier, preferences models.Preferences) ([]byte, error) {
+	if preferences.FavouriteDrink != "" {
+		var exists bool
+		err := q.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM menu_items WHERE menu_item_id::text = $1 AND archived_at IS NULL)`,
+			preferences.FavouriteDrink).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check favourite drink: %w", err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("favourite_drink %s not found: %w", preferences.FavouriteDrink, models.ErrInvalidPreferences)
+		}
+	}
+	data, err := json.Marshal(preferences)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode preferences: %w", err)
+	}
+	return data, nil
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// GetAll returns the customers with the preferences of the filter, or only
+// the archived ones when archived is set. With a query it returns those
+// whose name or email contains it or has a word similar to it, best matches
+// first, and those whose phone number contains its digits when it has at
+// least three.
+func (r *CustomerRepository) GetAll(ctx context.Context, filter models.CustomerFilter) ([]models.Customer, error) {
+	query := strings.TrimSpace(filter.Query)
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, query)
+	if len(digits) < 3 {
+		digits = ""
+	}
+	preferences, err := json.Marshal(filter.Preferences)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode preferences: %w", err)
+	}
+	rows, err := r.db.QueryContext(ctx, customerQuery+`
+	WHERE (archived_at IS NOT NULL) = $1
+	AND ($2 = ''
+		OR full_name ILIKE $3 OR email ILIKE $3
+		OR $2 <% full_name OR $2 <% email
+		OR ($4 <> '' AND phone_number LIKE '%' || $4 || '%'))
+	AND preferences @> $5::jsonb
+	ORDER BY CASE WHEN $2 = '' THEN 0
+		ELSE greatest(word_similarity($2, full_name), word_similarity($2, email)) END DESC,
+		full_name`, filter.Archived, query, "%"+likeEscaper.Replace(query)+"%", digits, preferences)
 	if err != nil {
 		return nil, fmt.Errorf("failer to query Customer: %w", err)
 	}
@@ -45,19 +159,19 @@ func (r *CustomerRepository) GetAll(ctx context.Context) ([]models.Customer, err
 	var customers []models.Customer
 	for rows.Next() {
 		var customer models.Customer
-		err := rows.Scan(&customer.CustomerId, &customer.FullName, &customer.PhoneNumber, &customer.Email, &customer.Preferences, &customer.CreatedAt, &customer.UpdatedAt)
+		err := scanCustomer(rows, &customer)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan Customer: %w", err)
 		}
 		customers = append(customers, customer)
 	}
-	return customers, nil
+	return customers, rows.Err()
 }
 
 func (r *CustomerRepository) GetCustomerByID(ctx context.Context, CustomerId string) (models.Customer, error) {
 	var customer models.Customer
-	err := r.db.QueryRowContext(ctx, `
-		SELECT * FROM customers WHERE customer_id = $1`, CustomerId).Scan(&customer.CustomerId, &customer.FullName, &customer.PhoneNumber, &customer.Email, &customer.Preferences, &customer.CreatedAt, &customer.UpdatedAt)
+	err := scanCustomer(r.db.QueryRowContext(ctx, customerQuery+`
+	WHERE customer_id = $1`, CustomerId), &customer)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.Customer{}, fmt.Errorf("Customer not found: %w", err)
@@ -73,6 +187,10 @@ func (r *CustomerRepository) UpdateCustomerByID(ctx context.Context, customer *m
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
+	preferences, err := encodePreferences(ctx, tx, customer.Preferences)
+	if err != nil {
+		return err
+	}
 	res, err := tx.ExecContext(ctx, `
 	UPDATE customers 
 	SET 
@@ -81,9 +199,12 @@ func (r *CustomerRepository) UpdateCustomerByID(ctx context.Context, customer *m
 		email =$3,
 		preferences =$4,
 		updated_at = NOW()
-	WHERE customer_id = $5
-	`, customer.FullName, customer.PhoneNumber, customer.Email, customer.Preferences, customer.CustomerId)
+	WHERE customer_id = $5 AND erased_at IS NULL
+	`, customer.FullName, customer.PhoneNumber, customer.Email, preferences, customer.CustomerId)
 	if err != nil {
+		if taken := contactTaken(err); taken != nil {
+			return taken
+		}
 		return err
 	}
 	rowsAffected, err := res.RowsAffected()
@@ -102,18 +223,37 @@ func (r *CustomerRepository) UpdateCustomerByID(ctx context.Context, customer *m
 	return nil
 }
 
+// DeleteCustomerByID archives a customer. Their orders are kept.
 func (r *CustomerRepository) DeleteCustomerByID(ctx context.Context, CustomerId string) error {
-	tx, err := r.db.BeginTx(ctx, nil)
+	res, err := r.db.ExecContext(ctx, `
+	UPDATE customers SET archived_at = now() WHERE customer_id = $1 AND archived_at IS NULL`, CustomerId)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return fmt.Errorf("failed to archive Customer: %w", err)
 	}
-	defer tx.Rollback()
-	res, err := tx.ExecContext(ctx, `DELETE FROM customer_id WHERE id= $1`, CustomerId)
+	rowsAffected, err := res.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to delete Customer: %w", err)
+		return fmt.Errorf("failed to check rows affected: %w", err)
 	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
 
-	// Verify exactly one row was deleted
+// RestoreCustomerByID brings an archived customer back, undoing its merge
+// into another customer if it was merged. The moved orders stay moved.
+// Erased customers cannot be restored, nor customers whose email or phone
+// number another active customer has taken since.
+func (r *CustomerRepository) RestoreCustomerByID(ctx context.Context, CustomerId string) error {
+	res, err := r.db.ExecContext(ctx, `
+	UPDATE customers SET archived_at = NULL, merged_into = NULL
+	WHERE customer_id = $1 AND archived_at IS NOT NULL AND erased_at IS NULL`, CustomerId)
+	if err != nil {
+		if taken := contactTaken(err); taken != nil {
+			return taken
+		}
+		return fmt.Errorf("failed to restore Customer: %w", err)
+	}
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("failed to check rows affected: %w", err)
@@ -121,11 +261,159 @@ func (r *CustomerRepository) DeleteCustomerByID(ctx context.Context, CustomerId
 	if rowsAffected == 0 {
 		return sql.ErrNoRows
 	}
+	return nil
+}
+
+// Duplicates pairs up active customers sharing an email or a phone number
+// or with names at least minNameSimilarity similar, most similar first.
+func (r *CustomerRepository) Duplicates(ctx context.Context, minNameSimilarity float64) ([]models.DuplicateCustomers, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT a.customer_id::text, b.customer_id::text,
+		a.email <> '' AND lower(a.email) = lower(b.email),
+		a.phone_number <> '' AND a.phone_number = b.phone_number,
+		similarity(a.full_name, b.full_name)
+	FROM customers a
+	JOIN customers b ON a.customer_id < b.customer_id
+	WHERE a.archived_at IS NULL AND b.archived_at IS NULL
+	AND ((a.email <> '' AND lower(a.email) = lower(b.email))
+		OR (a.phone_number <> '' AND a.phone_number = b.phone_number)
+		OR (a.full_name % b.full_name AND similarity(a.full_name, b.full_name) >= $1))
+	ORDER BY 5 DESC, a.full_name`, minNameSimilarity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query duplicate customers: %w", err)
+	}
+	defer rows.Close()
+	duplicates := []models.DuplicateCustomers{}
+	var ids []string
+	for rows.Next() {
+		var d models.DuplicateCustomers
+		var sameEmail, samePhone bool
+		err := rows.Scan(&d.Customer.CustomerId, &d.Duplicate.CustomerId, &sameEmail, &samePhone, &d.NameSimilarity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan duplicate customers: %w", err)
+		}
+		if sameEmail {
+			d.Reasons = append(d.Reasons, "email")
+		}
+		if samePhone {
+			d.Reasons = append(d.Reasons, "phone")
+		}
+		if float64(d.NameSimilarity) >= minNameSimilarity {
+			d.Reasons = append(d.Reasons, "name")
+		}
+		duplicates = append(duplicates, d)
+		ids = append(ids, string(d.Customer.CustomerId), string(d.Duplicate.CustomerId))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	// Commit transaction if everything succeeded
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	customers := make(map[utils.TEXT]models.Customer)
+	rows, err = r.db.QueryContext(ctx, customerQuery+` WHERE customer_id::text = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Customer: %w", err)
 	}
+	defer rows.Close()
+	for rows.Next() {
+		var customer models.Customer
+		if err := scanCustomer(rows, &customer); err != nil {
+			return nil, fmt.Errorf("failed to scan Customer: %w", err)
+		}
+		customers[customer.CustomerId] = customer
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range duplicates {
+		duplicates[i].Customer = customers[duplicates[i].Customer.CustomerId]
+		duplicates[i].Duplicate = customers[duplicates[i].Duplicate.CustomerId]
+	}
+	return duplicates, nil
+}
 
-	return nil
+// Merge moves the orders of the duplicates to the surviving customer, adds
+// their preferences it lacks and archives them as merged into it. It returns
+// how many orders were moved and fails with sql.ErrNoRows when a customer
+// does not exist. Customers have no loyalty points or balances, so there are
+// none to move.
+func (r *CustomerRepository) Merge(ctx context.Context, SurvivorId string, DuplicateIds []string) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+	SELECT customer_id::text, archived_at IS NOT NULL, merged_into IS NOT NULL
+	FROM customers
+	WHERE customer_id::text = ANY($1)
+	ORDER BY customer_id
+	FOR UPDATE`, pq.Array(append([]string{SurvivorId}, DuplicateIds...)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock customers: %w", err)
+	}
+	found := 0
+	for rows.Next() {
+		var id string
+		var archived, merged bool
+		if err := rows.Scan(&id, &archived, &merged); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan Customer: %w", err)
+		}
+		found++
+		if id == SurvivorId && archived {
+			rows.Close()
+			return 0, fmt.Errorf("customer %s is archived: %w", id, models.ErrInvalidMerge)
+		}
+		if merged {
+			rows.Close()
+			return 0, fmt.Errorf("customer %s is already merged: %w", id, models.ErrInvalidMerge)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if found != len(DuplicateIds)+1 {
+		return 0, fmt.Errorf("Customer not found: %w", sql.ErrNoRows)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+	UPDATE orders SET customer_id = $1::uuid WHERE customer_id::text = ANY($2)`, SurvivorId, pq.Array(DuplicateIds))
+	if err != nil {
+		return 0, fmt.Errorf("failed to move orders: %w", err)
+	}
+	moved, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	// the survivor keeps its own value of a preference the duplicates share,
+	// except allergies which add up
+	_, err = tx.ExecContext(ctx, `
+	UPDATE customers s
+	SET preferences = COALESCE((
+		SELECT jsonb_object_agg(e.key, e.value)
+		FROM customers d, jsonb_each(d.preferences) e
+		WHERE d.customer_id::text = ANY($2)
+	), '{}') || s.preferences || COALESCE((
+		SELECT jsonb_build_object('allergies', jsonb_agg(DISTINCT a.value))
+		FROM customers c, jsonb_array_elements_text(c.preferences -> 'allergies') a
+		WHERE c.customer_id::text = ANY($2) OR c.customer_id = s.customer_id
+		HAVING count(*) > 0
+	), '{}')
+	WHERE s.customer_id::text = $1`, SurvivorId, pq.Array(DuplicateIds))
+	if err != nil {
+		return 0, fmt.Errorf("failed to merge preferences: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+	UPDATE customers
+	SET archived_at = COALESCE(archived_at, now()), merged_into = $1::uuid
+	WHERE customer_id::text = ANY($2) OR merged_into::text = ANY($2)`, SurvivorId, pq.Array(DuplicateIds))
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive merged customers: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return int(moved), nil
 }
@@ -6,14 +6,22 @@ import (
 	"errors"
 	"fmt"
 	"frappuccino/models"
+	"frappuccino/utils"
+
+	"github.com/lib/pq"
 )
 
 type InventoryRepo interface {
 	Create(ctx context.Context, ingredient *models.Inventory) error
-	GetAll(ctx context.Context) ([]models.Inventory, error)
-	GetIngredientByID(ctx context.Context, IngredientId string) (models.Inventory, error)
+	GetAll(ctx context.Context, StoreId string) ([]models.Inventory, error)
+	GetIngredientByID(ctx context.Context, StoreId string, IngredientId string) (models.Inventory, error)
 	UpdateIngredientByID(ctx context.Context, ingredient *models.Inventory) error
 	DeleteIngredientByID(ctx context.Context, IngerdientID string) error
+	RestoreIngredientByID(ctx context.Context, IngredientId string) error
+	SetRecipe(ctx context.Context, recipe *models.Recipe) error
+	GetRecipe(ctx context.Context, IngredientId string) (models.Recipe, error)
+	GetRecipes(ctx context.Context) (map[utils.TEXT]models.Recipe, error)
+	Produce(ctx context.Context, production *models.Production) error
 }
 
 type InventoryRepository struct {
@@ -24,24 +32,59 @@ func NewInventoryRepository(db *sql.DB) *InventoryRepository {
 	return &InventoryRepository{db: db}
 }
 
+// inventoryQuery selects ingredients with their stock in store $1,
+// or summed over all stores when $1 is empty. Archived ingredients are
+// included, as recipes may still use them.
+const inventoryQuery = `
+	SELECT i.ingredient_id, i.ingredient_name, i.unit, i.unit_cost, i.is_prepared, i.batch_yield,
+		i.allergens, i.diets, i.kcal, i.sugar_g, i.fat_g, i.protein_g, i.caffeine_mg,
+		COALESCE(SUM(si.quantity), 0), COALESCE(SUM(si.reorder_level), 0),
+		i.archived_at, i.created_at, i.updated_at
+	FROM inventory i
+	LEFT JOIN store_inventory si ON si.ingredient_id = i.ingredient_id
+		AND ($1 = '' OR si.store_id::text = $1)`
+
+func scanIngredient(row interface{ Scan(...any) error }, ingredient *models.Inventory) error {
+	return row.Scan(&ingredient.IngredientId, &ingredient.IngredientName, &ingredient.Unit, &ingredient.UnitCost, &ingredient.IsPrepared, &ingredient.BatchYield,
+		pq.Array(&ingredient.Allergens), pq.Array(&ingredient.Diets),
+		&ingredient.Nutrition.Kcal, &ingredient.Nutrition.SugarG, &ingredient.Nutrition.FatG, &ingredient.Nutrition.ProteinG, &ingredient.Nutrition.CaffeineMg,
+		&ingredient.Quantity, &ingredient.ReorderLevel, &ingredient.ArchivedAt, &ingredient.CreatedAt, &ingredient.UpdatedAt)
+}
+
 func (r *InventoryRepository) Create(ctx context.Context, ingredient *models.Inventory) error {
 	if ingredient.IngredientName == "" {
 		return errors.New("ingredient_name cannot be empty")
 	}
 
-	_, err := r.db.ExecContext(ctx,
-		`INSERT INTO inventory (ingredient_name, unit, quantity, reorder_level)
-		 VALUES ($1, $2, $3, $4)`,
-		ingredient.IngredientName, ingredient.Unit, ingredient.Quantity, ingredient.ReorderLevel)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO inventory (ingredient_name, unit, unit_cost, allergens, diets, kcal, sugar_g, fat_g, protein_g, caffeine_mg)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 RETURNING ingredient_id, created_at, updated_at`,
+		ingredient.IngredientName, ingredient.Unit, ingredient.UnitCost, pq.Array(ingredient.Allergens), pq.Array(ingredient.Diets),
+		ingredient.Nutrition.Kcal, ingredient.Nutrition.SugarG, ingredient.Nutrition.FatG, ingredient.Nutrition.ProteinG, ingredient.Nutrition.CaffeineMg).Scan(&ingredient.IngredientId, &ingredient.CreatedAt, &ingredient.UpdatedAt)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if ingredient.StoreId != "" {
+		if err := setStoreStock(ctx, tx, ingredient); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-func (r *InventoryRepository) GetAll(ctx context.Context) ([]models.Inventory, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT * FROM inventory`)
+func (r *InventoryRepository) GetAll(ctx context.Context, StoreId string) ([]models.Inventory, error) {
+	rows, err := r.db.QueryContext(ctx, inventoryQuery+`
+	GROUP BY i.ingredient_id
+	ORDER BY i.ingredient_name`, StoreId)
 	if err != nil {
 		return nil, fmt.Errorf("failer to query inventory: %w", err)
 	}
@@ -49,28 +92,34 @@ func (r *InventoryRepository) GetAll(ctx context.Context) ([]models.Inventory, e
 	var inventory []models.Inventory
 	for rows.Next() {
 		var ingredient models.Inventory
-		err := rows.Scan(&ingredient.IngredientId, &ingredient.IngredientName, &ingredient.Unit, &ingredient.Quantity, &ingredient.ReorderLevel, &ingredient.CreatedAt, &ingredient.UpdatedAt)
+		err := scanIngredient(rows, &ingredient)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan ingredient: %w", err)
 		}
+		ingredient.StoreId = utils.TEXT(StoreId)
 		inventory = append(inventory, ingredient)
 	}
 	return inventory, nil
 }
 
-func (r *InventoryRepository) GetIngredientByID(ctx context.Context, IngredientId string) (models.Inventory, error) {
+func (r *InventoryRepository) GetIngredientByID(ctx context.Context, StoreId string, IngredientId string) (models.Inventory, error) {
 	var ingredient models.Inventory
-	err := r.db.QueryRowContext(ctx, `SELECT * FROM inventory WHERE ingredient_id=$1`, IngredientId).Scan(&ingredient.IngredientId, &ingredient.IngredientName, &ingredient.Unit, &ingredient.Quantity, &ingredient.ReorderLevel, &ingredient.CreatedAt, &ingredient.UpdatedAt)
+	err := scanIngredient(r.db.QueryRowContext(ctx, inventoryQuery+`
+	WHERE i.ingredient_id = $2
+	GROUP BY i.ingredient_id`, StoreId, IngredientId), &ingredient)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.Inventory{}, fmt.Errorf("ingredient not found: %w", err)
 		}
 		return models.Inventory{}, fmt.Errorf("failed to get ingredient: %w", err)
 	}
+	ingredient.StoreId = utils.TEXT(StoreId)
 
 	return ingredient, nil
 }
 
+// UpdateIngredientByID updates the ingredient and, when a store is given,
+// its stock in that store.
 func (r *InventoryRepository) UpdateIngredientByID(ctx context.Context, ingredient *models.Inventory) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -82,11 +131,18 @@ func (r *InventoryRepository) UpdateIngredientByID(ctx context.Context, ingredie
 	UPDATE inventory
 	SET ingredient_name = $1,
 	unit = $2,
-	quantity= $3,
-	reorder_level =$4,
+	unit_cost = $3,
+	allergens = $5,
+	diets = $6,
+	kcal = $7,
+	sugar_g = $8,
+	fat_g = $9,
+	protein_g = $10,
+	caffeine_mg = $11,
 	updated_at = NOW()
-	WHERE ingredient_id =$5
-	`, ingredient.IngredientName, ingredient.Unit, ingredient.Quantity, ingredient.ReorderLevel, ingredient.IngredientId)
+	WHERE ingredient_id =$4
+	`, ingredient.IngredientName, ingredient.Unit, ingredient.UnitCost, ingredient.IngredientId, pq.Array(ingredient.Allergens), pq.Array(ingredient.Diets),
+		ingredient.Nutrition.Kcal, ingredient.Nutrition.SugarG, ingredient.Nutrition.FatG, ingredient.Nutrition.ProteinG, ingredient.Nutrition.CaffeineMg)
 	if err != nil {
 		return err
 	}
@@ -99,6 +155,12 @@ func (r *InventoryRepository) UpdateIngredientByID(ctx context.Context, ingredie
 		return sql.ErrNoRows
 	}
 
+	if ingredient.StoreId != "" {
+		if err := setStoreStock(ctx, tx, ingredient); err != nil {
+			return err
+		}
+	}
+
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
@@ -107,18 +169,54 @@ func (r *InventoryRepository) UpdateIngredientByID(ctx context.Context, ingredie
 	return nil
 }
 
+// DeleteIngredientByID archives an ingredient. Recipes that use it keep
+// resolving it for costs and stock.
 func (r *InventoryRepository) DeleteIngredientByID(ctx context.Context, IngerdientID string) error {
+	res, err := r.db.ExecContext(ctx, `
+	UPDATE inventory SET archived_at = now() WHERE ingredient_id = $1 AND archived_at IS NULL`, IngerdientID)
+	if err != nil {
+		return fmt.Errorf("failed to archive ingredient: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *InventoryRepository) RestoreIngredientByID(ctx context.Context, IngredientId string) error {
+	res, err := r.db.ExecContext(ctx, `
+	UPDATE inventory SET archived_at = NULL WHERE ingredient_id = $1 AND archived_at IS NOT NULL`, IngredientId)
+	if err != nil {
+		return fmt.Errorf("failed to restore ingredient: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetRecipe replaces the recipe of an ingredient and marks it as prepared.
+// It fails with models.ErrRecipeCycle if the ingredient would end up in its own sub-recipes.
+func (r *InventoryRepository) SetRecipe(ctx context.Context, recipe *models.Recipe) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
-	res, err := tx.ExecContext(ctx, `DELETE FROM inventory WHERE ingredient_id= $1`, IngerdientID)
+
+	res, err := tx.ExecContext(ctx, `
+	UPDATE inventory SET is_prepared = TRUE, batch_yield = $2 WHERE ingredient_id = $1`, recipe.IngredientId, recipe.BatchYield)
 	if err != nil {
-		return fmt.Errorf("failed to delete ingredient: %w", err)
+		return fmt.Errorf("failed to update ingredient: %w", err)
 	}
-
-	// Verify exactly one row was deleted
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("failed to check rows affected: %w", err)
@@ -127,10 +225,218 @@ func (r *InventoryRepository) DeleteIngredientByID(ctx context.Context, Ingerdie
 		return sql.ErrNoRows
 	}
 
-	// Commit transaction if everything succeeded
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	_, err = tx.ExecContext(ctx, `DELETE FROM ingredient_recipes WHERE prepared_ingredient_id = $1`, recipe.IngredientId)
+	if err != nil {
+		return fmt.Errorf("failed to clear recipe: %w", err)
+	}
+	for _, c := range recipe.Components {
+		if c.IngredientId == recipe.IngredientId {
+			return models.ErrRecipeCycle
+		}
+		_, err = tx.ExecContext(ctx, `
+		INSERT INTO ingredient_recipes (prepared_ingredient_id, component_ingredient_id, quantity)
+		VALUES ($1, $2, $3)`, recipe.IngredientId, c.IngredientId, c.Quantity)
+		if err != nil {
+			return fmt.Errorf("failed to add recipe component: %w", err)
+		}
+	}
+
+	var cycle bool
+	err = tx.QueryRowContext(ctx, `
+	WITH RECURSIVE reach(ingredient_id) AS (
+		SELECT component_ingredient_id FROM ingredient_recipes WHERE prepared_ingredient_id = $1
+		UNION
+		SELECT ir.component_ingredient_id
+		FROM ingredient_recipes ir
+		JOIN reach ON ir.prepared_ingredient_id = reach.ingredient_id
+	)
+	SELECT EXISTS (SELECT 1 FROM reach WHERE ingredient_id = $1)`, recipe.IngredientId).Scan(&cycle)
+	if err != nil {
+		return fmt.Errorf("failed to check recipe cycle: %w", err)
+	}
+	if cycle {
+		return models.ErrRecipeCycle
+	}
+
+	return tx.Commit()
+}
+
+func (r *InventoryRepository) GetRecipe(ctx context.Context, IngredientId string) (models.Recipe, error) {
+	var recipe models.Recipe
+	var prepared bool
+	err := r.db.QueryRowContext(ctx, `
+	SELECT ingredient_id, is_prepared, batch_yield FROM inventory WHERE ingredient_id = $1`, IngredientId).Scan(&recipe.IngredientId, &prepared, &recipe.BatchYield)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Recipe{}, fmt.Errorf("ingredient not found: %w", err)
+		}
+		return models.Recipe{}, fmt.Errorf("failed to get ingredient: %w", err)
+	}
+	if !prepared {
+		return models.Recipe{}, models.ErrNotPrepared
+	}
+	recipes, err := r.recipes(ctx, r.db, IngredientId)
+	if err != nil {
+		return models.Recipe{}, err
+	}
+	recipe.Components = recipes[recipe.IngredientId].Components
+	return recipe, nil
+}
+
+// GetRecipes returns the recipes of all prepared ingredients keyed by ingredient id.
+func (r *InventoryRepository) GetRecipes(ctx context.Context) (map[utils.TEXT]models.Recipe, error) {
+	return r.recipes(ctx, r.db, "")
+}
+
+func (r *InventoryRepository) recipes(ctx context.Context, q querier, IngredientId string) (map[utils.TEXT]models.Recipe, error) {
+	rows, err := q.QueryContext(ctx, `
+	SELECT p.ingredient_id, p.batch_yield, c.ingredient_id, c.ingredient_name, ir.quantity
+	FROM inventory p
+	JOIN ingredient_recipes ir ON ir.prepared_ingredient_id = p.ingredient_id
+	JOIN inventory c ON c.ingredient_id = ir.component_ingredient_id
+	WHERE p.is_prepared AND ($1 = '' OR p.ingredient_id::text = $1)
+	ORDER BY c.ingredient_name`, IngredientId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recipes: %w", err)
+	}
+	defer rows.Close()
+	recipes := make(map[utils.TEXT]models.Recipe)
+	for rows.Next() {
+		var id utils.TEXT
+		var batchYield utils.DEC
+		var c models.RecipeComponent
+		if err := rows.Scan(&id, &batchYield, &c.IngredientId, &c.IngredientName, &c.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan recipe: %w", err)
+		}
+		recipe := recipes[id]
+		recipe.IngredientId = id
+		recipe.BatchYield = batchYield
+		recipe.Components = append(recipe.Components, c)
+		recipes[id] = recipe
+	}
+	return recipes, nil
+}
+
+// Produce makes production.Batches batches of a prepared ingredient in a store,
+// taking the components out of its stock and adding the yield.
+func (r *InventoryRepository) Produce(ctx context.Context, production *models.Production) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var prepared bool
+	var batchYield utils.DEC
+	err = tx.QueryRowContext(ctx, `
+	SELECT is_prepared, batch_yield FROM inventory WHERE ingredient_id = $1 FOR UPDATE`, production.IngredientId).Scan(&prepared, &batchYield)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("ingredient not found: %w", err)
+		}
+		return fmt.Errorf("failed to get ingredient: %w", err)
+	}
+	if !prepared {
+		return models.ErrNotPrepared
+	}
+	recipes, err := r.recipes(ctx, tx, string(production.IngredientId))
+	if err != nil {
+		return err
+	}
+
+	production.Quantity = batchYield * production.Batches
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO ingredient_productions (ingredient_id, store_id, batches, quantity)
+	VALUES ($1, $2, $3, $4)
+	RETURNING production_id, created_at`,
+		production.IngredientId, production.StoreId, production.Batches, production.Quantity).Scan(&production.ProductionId, &production.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record production: %w", err)
+	}
+
+	for _, c := range recipes[production.IngredientId].Components {
+		err = moveStock(ctx, tx, &models.InventoryTransactions{
+			IngredientId:               c.IngredientId,
+			StoreId:                    production.StoreId,
+			Quantity:                   -c.Quantity * production.Batches,
+			InventoryTransactionAction: "REMOVE",
+			ReferenceId:                production.ProductionId,
+			Notes:                      "Used by production " + production.ProductionId,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	err = moveStock(ctx, tx, &models.InventoryTransactions{
+		IngredientId:               production.IngredientId,
+		StoreId:                    production.StoreId,
+		Quantity:                   production.Quantity,
+		InventoryTransactionAction: "ADD",
+		ReferenceId:                production.ProductionId,
+		Notes:                      "Yield of production " + production.ProductionId,
+	})
+	if err != nil {
+		return err
 	}
 
+	return tx.Commit()
+}
+
+// setStoreStock overwrites the stock level and reorder level of an ingredient in a store.
+func setStoreStock(ctx context.Context, tx *sql.Tx, ingredient *models.Inventory) error {
+	_, err := tx.ExecContext(ctx, `
+	INSERT INTO store_inventory (store_id, ingredient_id, quantity, reorder_level)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (store_id, ingredient_id) DO UPDATE
+	SET quantity = EXCLUDED.quantity,
+		reorder_level = EXCLUDED.reorder_level`,
+		ingredient.StoreId, ingredient.IngredientId, ingredient.Quantity, ingredient.ReorderLevel)
+	if err != nil {
+		return fmt.Errorf("failed to set store stock: %w", err)
+	}
+	return nil
+}
+
+// moveStock changes the stock of an ingredient in a store by t.Quantity
+// and records the movement in inventory_transactions.
+func moveStock(ctx context.Context, tx *sql.Tx, t *models.InventoryTransactions) error {
+	err := tx.QueryRowContext(ctx, `
+	INSERT INTO inventory_transactions (ingredient_id, store_id, quantity, inventory_transaction_action, reference_id, notes)
+	VALUES ($1, $2, $3, $4, NULLIF($5, '')::uuid, $6)
+	RETURNING inventory_transactions_id, created_at`,
+		t.IngredientId, t.StoreId, t.Quantity, t.InventoryTransactionAction, t.ReferenceId, t.Notes).Scan(&t.InventoryTransactionId, &t.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record inventory transaction: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+	UPDATE store_inventory
+	SET quantity = quantity + $3
+	WHERE store_id = $1 AND ingredient_id = $2`,
+		t.StoreId, t.IngredientId, t.Quantity)
+	if err != nil {
+		if pqCode(err) == checkViolation {
+			return fmt.Errorf("ingredient %s: %w", t.IngredientId, models.ErrInsufficientStock)
+		}
+		return fmt.Errorf("failed to update store stock: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+	if t.Quantity < 0 {
+		return fmt.Errorf("ingredient %s: %w", t.IngredientId, models.ErrInsufficientStock)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO store_inventory (store_id, ingredient_id, quantity)
+	VALUES ($1, $2, $3)`,
+		t.StoreId, t.IngredientId, t.Quantity)
+	if err != nil {
+		return fmt.Errorf("failed to create store stock: %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,79 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"frappuccino/models"
+	"frappuccino/utils"
+
+	"github.com/lib/pq"
+)
+
+// AddImage records an uploaded image after the images the menu item already
+// has. It fails with sql.ErrNoRows when the item is missing or archived.
+func (r *MenuRepository) AddImage(ctx context.Context, image *models.MenuItemImage) error {
+	err := r.db.QueryRowContext(ctx, `
+	INSERT INTO menu_item_images (menu_item_id, image_key, thumbnail_key, content_type, width, height, size_bytes, display_order)
+	SELECT mi.menu_item_id, $2, $3, $4, $5, $6, $7,
+		(SELECT COALESCE(MAX(display_order) + 1, 0) FROM menu_item_images WHERE menu_item_id = mi.menu_item_id)
+	FROM menu_items mi
+	WHERE mi.menu_item_id::text = $1 AND mi.archived_at IS NULL
+	RETURNING image_id, display_order, created_at`,
+		image.MenuItemId, image.ImageKey, image.ThumbnailKey, image.ContentType, image.Width, image.Height, image.SizeBytes).Scan(
+		&image.ImageId, &image.DisplayOrder, &image.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("Item not found: %w", err)
+		}
+		return fmt.Errorf("failed to save menu item image: %w", err)
+	}
+	return nil
+}
+
+// DeleteImage removes an image of a menu item and returns it, so that its
+// files can be deleted from media storage.
+func (r *MenuRepository) DeleteImage(ctx context.Context, MenuItemId string, ImageId string) (models.MenuItemImage, error) {
+	var image models.MenuItemImage
+	err := scanMenuImage(r.db.QueryRowContext(ctx, `
+	DELETE FROM menu_item_images
+	WHERE menu_item_id::text = $1 AND image_id::text = $2
+	RETURNING `+menuImageColumns, MenuItemId, ImageId), &image)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return image, fmt.Errorf("image not found: %w", err)
+		}
+		return image, fmt.Errorf("failed to delete menu item image: %w", err)
+	}
+	return image, nil
+}
+
+const menuImageColumns = `image_id, menu_item_id, image_key, thumbnail_key, content_type, width, height, size_bytes, display_order, created_at`
+
+func scanMenuImage(row interface{ Scan(...any) error }, image *models.MenuItemImage) error {
+	return row.Scan(&image.ImageId, &image.MenuItemId, &image.ImageKey, &image.ThumbnailKey, &image.ContentType,
+		&image.Width, &image.Height, &image.SizeBytes, &image.DisplayOrder, &image.CreatedAt)
+}
+
+// menuImages returns the images of the given menu items keyed by item id.
+func menuImages(ctx context.Context, q querier, MenuItemIds []string) (map[utils.TEXT][]models.MenuItemImage, error) {
+	rows, err := q.QueryContext(ctx, `
+	SELECT `+menuImageColumns+`
+	FROM menu_item_images
+	WHERE menu_item_id = ANY($1::uuid[])
+	ORDER BY display_order, created_at`, pq.Array(MenuItemIds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query menu item images: %w", err)
+	}
+	defer rows.Close()
+	byItem := make(map[utils.TEXT][]models.MenuItemImage)
+	for rows.Next() {
+		var image models.MenuItemImage
+		if err := scanMenuImage(rows, &image); err != nil {
+			return nil, fmt.Errorf("failed to scan menu item image: %w", err)
+		}
+		byItem[image.MenuItemId] = append(byItem[image.MenuItemId], image)
+	}
+	return byItem, rows.Err()
+}
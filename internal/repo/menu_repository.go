@@ -6,16 +6,23 @@ import (
 	"errors"
 	"fmt"
 	"frappuccino/models"
+	"frappuccino/utils"
 
 	"github.com/lib/pq"
 )
 
 type MenuRepo interface {
 	Create(ctx context.Context, item *models.MenuItems) error
-	Get(ctx context.Context) ([]models.MenuItems, error)
-	GetItemByID(ctx context.Context, MenuItemId string) (models.MenuItems, error)
+	Get(ctx context.Context, StoreId string, archived bool) ([]models.MenuItems, error)
+	GetItemByID(ctx context.Context, StoreId string, MenuItemId string) (models.MenuItems, error)
 	UpdateItemByID(ctx context.Context, item *models.MenuItems) error
 	DeleteItemByID(ctx context.Context, MenuItemId string) error
+	RestoreItemByID(ctx context.Context, MenuItemId string) error
+	GetIngredients(ctx context.Context, MenuItemId string) ([]models.MenuItemsIngredients, error)
+	GetRecipes(ctx context.Context, MenuItemIds []string) (map[utils.TEXT][]models.MenuItemsIngredients, error)
+	Import(ctx context.Context, rows []models.MenuImportRow, dryRun bool) (models.MenuImportResult, error)
+	AddImage(ctx context.Context, image *models.MenuItemImage) error
+	DeleteImage(ctx context.Context, MenuItemId string, ImageId string) (models.MenuItemImage, error)
 }
 
 type MenuRepository struct {
@@ -27,19 +34,62 @@ func NewMenuRepository(db *sql.DB) *MenuRepository {
 }
 
 func (r *MenuRepository) Create(ctx context.Context, item *models.MenuItems) error {
-	err := r.db.QueryRowContext(ctx,
-		`INSERT INTO menu_item (item_name,item_description,price,categories)
-	     VALUES ($1,$2,$3,$4)
-		 RETURNING menu_item_id,created_at,updated_at`, item.ItemName, item.ItemDescription, item.Price, pq.Array(item.Categories)).Scan(&item.MenuItemId, &item.CreatedAt, &item.UpdatedAt)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO menu_items (item_name,item_description,price,tags)
+	     VALUES ($1,$2,$3,COALESCE($4::text[],'{}'))
+		 RETURNING menu_item_id,created_at,updated_at`, item.ItemName, item.ItemDescription, item.Price, pq.Array(item.Tags)).Scan(&item.MenuItemId, &item.CreatedAt, &item.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to create menu item: %w", err)
 	}
-	return nil
+	if err := setMenuCategories(ctx, tx, item); err != nil {
+		return err
+	}
+	if err := setMenuIngredients(ctx, tx, item); err != nil {
+		return err
+	}
+	if err := setMenuVariants(ctx, tx, item); err != nil {
+		return err
+	}
+	if err := setBundleSlots(ctx, tx, item); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *MenuRepository) Get(ctx context.Context) ([]models.MenuItems, error) {
-	rows, err := r.db.QueryContext(ctx, `
-		SELECT * FROM menu_item`)
+// menuQuery selects menu items with the price and availability of store $1,
+// orderable by the schedules read in the time zone of the store. An empty
+// store returns the base menu, with schedules read in UTC. Archived items
+// are included.
+const menuQuery = `
+	SELECT mi.menu_item_id, mi.item_name, mi.item_description,
+		COALESCE(smi.price, mi.price),
+		ARRAY(SELECT c.category_id::text FROM menu_item_categories mc JOIN categories c USING(category_id)
+			WHERE mc.menu_item_id = mi.menu_item_id ORDER BY c.display_order, c.category_name),
+		ARRAY(SELECT c.category_name FROM menu_item_categories mc JOIN categories c USING(category_id)
+			WHERE mc.menu_item_id = mi.menu_item_id ORDER BY c.display_order, c.category_name),
+		mi.tags,
+		menu_item_orderable(mi.menu_item_id, now(),
+			COALESCE((SELECT time_zone FROM stores WHERE store_id::text = $1), 'UTC')), mi.archived_at, mi.created_at, mi.updated_at
+	FROM menu_items mi
+	LEFT JOIN store_menu_items smi ON smi.menu_item_id = mi.menu_item_id
+		AND smi.store_id::text = $1
+	WHERE COALESCE(smi.is_available, TRUE)`
+
+func scanMenuItem(row interface{ Scan(...any) error }, item *models.MenuItems) error {
+	return row.Scan(&item.MenuItemId, &item.ItemName, &item.ItemDescription, &item.Price, pq.Array(&item.CategoryIds), pq.Array(&item.Categories), pq.Array(&item.Tags),
+		&item.Orderable, &item.ArchivedAt, &item.CreatedAt, &item.UpdatedAt)
+}
+
+// Get returns the menu of a store, or only its archived items when archived is set.
+func (r *MenuRepository) Get(ctx context.Context, StoreId string, archived bool) ([]models.MenuItems, error) {
+	rows, err := r.db.QueryContext(ctx, menuQuery+`
+	AND (mi.archived_at IS NOT NULL) = $2
+	ORDER BY mi.item_name`, StoreId, archived)
 	if err != nil {
 		return nil, fmt.Errorf("failer to query Menu: %w", err)
 	}
@@ -47,25 +97,83 @@ func (r *MenuRepository) Get(ctx context.Context) ([]models.MenuItems, error) {
 	var menu []models.MenuItems
 	for rows.Next() {
 		var item models.MenuItems
-		err := rows.Scan(&item.MenuItemId, &item.ItemName, &item.ItemDescription, &item.Price, pq.Array(&item.Categories), &item.CreatedAt, &item.UpdatedAt)
+		err := scanMenuItem(rows, &item)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan Menu: %w", err)
 		}
 		menu = append(menu, item)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(menu))
+	for i := range menu {
+		ids[i] = string(menu[i].MenuItemId)
+	}
+	variants, err := menuVariants(ctx, r.db, StoreId, ids)
+	if err != nil {
+		return nil, err
+	}
+	slots, err := bundleSlots(ctx, r.db, ids)
+	if err != nil {
+		return nil, err
+	}
+	recipes, err := menuRecipes(ctx, r.db, ids)
+	if err != nil {
+		return nil, err
+	}
+	images, err := menuImages(ctx, r.db, ids)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := menuModifierGroups(ctx, r.db, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range menu {
+		menu[i].Ingredients = recipes[menu[i].MenuItemId]
+		menu[i].Variants = variants[menu[i].MenuItemId]
+		menu[i].ModifierGroups = groups[menu[i].MenuItemId]
+		menu[i].BundleSlots = slots[menu[i].MenuItemId]
+		menu[i].Images = images[menu[i].MenuItemId]
+	}
 	return menu, nil
 }
 
-func (r *MenuRepository) GetItemByID(ctx context.Context, MenuItemId string) (models.MenuItems, error) {
+func (r *MenuRepository) GetItemByID(ctx context.Context, StoreId string, MenuItemId string) (models.MenuItems, error) {
 	var item models.MenuItems
-	err := r.db.QueryRowContext(ctx, `
-		SELECT * FROM menu_item WHERE menu_item_id = $1`, MenuItemId).Scan(&item.MenuItemId, &item.ItemName, &item.ItemDescription, &item.Price, pq.Array(&item.Categories), &item.CreatedAt, &item.UpdatedAt)
+	err := scanMenuItem(r.db.QueryRowContext(ctx, menuQuery+`
+	AND mi.menu_item_id = $2`, StoreId, MenuItemId), &item)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.MenuItems{}, fmt.Errorf("Item not found: %w", err)
 		}
 		return models.MenuItems{}, fmt.Errorf("failed to get Item: %w", err)
 	}
+	item.Ingredients, err = r.GetIngredients(ctx, MenuItemId)
+	if err != nil {
+		return models.MenuItems{}, err
+	}
+	variants, err := menuVariants(ctx, r.db, StoreId, []string{MenuItemId})
+	if err != nil {
+		return models.MenuItems{}, err
+	}
+	item.Variants = variants[item.MenuItemId]
+	groups, err := menuModifierGroups(ctx, r.db, []string{MenuItemId})
+	if err != nil {
+		return models.MenuItems{}, err
+	}
+	item.ModifierGroups = groups[item.MenuItemId]
+	slots, err := bundleSlots(ctx, r.db, []string{MenuItemId})
+	if err != nil {
+		return models.MenuItems{}, err
+	}
+	item.BundleSlots = slots[item.MenuItemId]
+	images, err := menuImages(ctx, r.db, []string{MenuItemId})
+	if err != nil {
+		return models.MenuItems{}, err
+	}
+	item.Images = images[item.MenuItemId]
 	return item, nil
 }
 
@@ -76,15 +184,15 @@ func (r *MenuRepository) UpdateItemByID(ctx context.Context, item *models.MenuIt
 	}
 	defer tx.Rollback()
 	res, err := tx.ExecContext(ctx, `
-	UPDATE menu_item 
+	UPDATE menu_items
 	SET 
 		item_name = $1,
 		item_description =$2,
 		price =$3,
-		categories =$4,
+		tags = COALESCE($5::text[], tags),
 		updated_at = NOW()
-	WHERE menu_item_id = $5
-	`, item.ItemName, item.ItemDescription, item.Price, item.Categories, item.MenuItemId)
+	WHERE menu_item_id = $4
+	`, item.ItemName, item.ItemDescription, item.Price, item.MenuItemId, pq.Array(item.Tags))
 	if err != nil {
 		return err
 	}
@@ -95,6 +203,27 @@ func (r *MenuRepository) UpdateItemByID(ctx context.Context, item *models.MenuIt
 	if rowsAffected == 0 {
 		return sql.ErrNoRows
 	}
+	if err := setMenuCategories(ctx, tx, item); err != nil {
+		return err
+	}
+	if item.Ingredients != nil {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM menu_item_ingredients WHERE menu_item_id = $1`, item.MenuItemId); err != nil {
+			return fmt.Errorf("failed to clear menu item ingredients: %w", err)
+		}
+		if err := setMenuIngredients(ctx, tx, item); err != nil {
+			return err
+		}
+	}
+	if item.Variants != nil {
+		if err := setMenuVariants(ctx, tx, item); err != nil {
+			return err
+		}
+	}
+	if item.BundleSlots != nil {
+		if err := setBundleSlots(ctx, tx, item); err != nil {
+			return err
+		}
+	}
 
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
@@ -104,18 +233,31 @@ func (r *MenuRepository) UpdateItemByID(ctx context.Context, item *models.MenuIt
 	return nil
 }
 
+// DeleteItemByID archives a menu item. It leaves the menu but past orders
+// keep their reference to it.
 func (r *MenuRepository) DeleteItemByID(ctx context.Context, MenuItemId string) error {
-	tx, err := r.db.BeginTx(ctx, nil)
+	res, err := r.db.ExecContext(ctx, `
+	UPDATE menu_items SET archived_at = now() WHERE menu_item_id = $1 AND archived_at IS NULL`, MenuItemId)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return fmt.Errorf("failed to archive menu item: %w", err)
 	}
-	defer tx.Rollback()
-	res, err := tx.ExecContext(ctx, `DELETE FROM menu_item_id WHERE id= $1`, MenuItemId)
+	rowsAffected, err := res.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to delete MenuItems: %w", err)
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
 	}
+	return nil
+}
 
-	// Verify exactly one row was deleted
+// RestoreItemByID puts an archived menu item back on the menu.
+func (r *MenuRepository) RestoreItemByID(ctx context.Context, MenuItemId string) error {
+	res, err := r.db.ExecContext(ctx, `
+	UPDATE menu_items SET archived_at = NULL WHERE menu_item_id = $1 AND archived_at IS NOT NULL`, MenuItemId)
+	if err != nil {
+		return fmt.Errorf("failed to restore menu item: %w", err)
+	}
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("failed to check rows affected: %w", err)
@@ -123,11 +265,292 @@ func (r *MenuRepository) DeleteItemByID(ctx context.Context, MenuItemId string)
 	if rowsAffected == 0 {
 		return sql.ErrNoRows
 	}
+	return nil
+}
 
-	// Commit transaction if everything succeeded
+// Import upserts menu items by name in one transaction. Each row runs in a
+// savepoint so that every failing row is reported; nothing is committed when
+// a row fails or in a dry run.
+func (r *MenuRepository) Import(ctx context.Context, rows []models.MenuImportRow, dryRun bool) (models.MenuImportResult, error) {
+	result := models.MenuImportResult{DryRun: dryRun, Errors: []models.MenuImportError{}}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i := range rows {
+		row := &rows[i]
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT import_row`); err != nil {
+			return result, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+		created, err := importMenuItem(ctx, tx, &row.Item)
+		if err != nil {
+			result.Errors = append(result.Errors, models.MenuImportError{Row: row.Row, ItemName: row.Item.ItemName, Error: err.Error()})
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT import_row`); err != nil {
+				return result, fmt.Errorf("failed to roll back savepoint: %w", err)
+			}
+			continue
+		}
+		if created {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+	}
+	if dryRun || len(result.Errors) > 0 {
+		return result, nil
+	}
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return result, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return result, nil
+}
+
+// importMenuItem updates the active menu item named like item, or creates
+// it, and replaces its recipe. Variants are replaced when item lists them.
+func importMenuItem(ctx context.Context, tx *sql.Tx, item *models.MenuItems) (bool, error) {
+	for i := range item.Ingredients {
+		line := &item.Ingredients[i]
+		if line.IngredientId != "" {
+			continue
+		}
+		err := tx.QueryRowContext(ctx, `SELECT ingredient_id FROM inventory WHERE ingredient_name = $1`, line.IngredientName).Scan(&line.IngredientId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return false, fmt.Errorf("ingredient %q not found", line.IngredientName)
+			}
+			return false, fmt.Errorf("failed to look up ingredient %q: %w", line.IngredientName, err)
+		}
+	}
+
+	if item.CategoryIds == nil && item.Categories != nil {
+		ids, err := categoryIdsByName(ctx, tx, item.Categories)
+		if err != nil {
+			return false, err
+		}
+		item.CategoryIds = ids
+	}
+
+	var ids []string
+	err := tx.QueryRowContext(ctx, `
+	SELECT COALESCE(array_agg(menu_item_id::text), '{}') FROM menu_items WHERE item_name = $1 AND archived_at IS NULL`, item.ItemName).Scan(pq.Array(&ids))
+	if err != nil {
+		return false, fmt.Errorf("failed to look up menu item: %w", err)
+	}
+	if len(ids) > 1 {
+		return false, fmt.Errorf("%d menu items are named %q", len(ids), item.ItemName)
+	}
+
+	created := len(ids) == 0
+	if created {
+		err = tx.QueryRowContext(ctx, `
+		INSERT INTO menu_items (item_name, item_description, price, tags)
+		VALUES ($1, $2, $3, COALESCE($4::text[], '{}'))
+		RETURNING menu_item_id, created_at, updated_at`, item.ItemName, item.ItemDescription, item.Price, pq.Array(item.Tags)).Scan(
+			&item.MenuItemId, &item.CreatedAt, &item.UpdatedAt)
+	} else {
+		err = tx.QueryRowContext(ctx, `
+		UPDATE menu_items
+		SET item_description = $2, price = $3, tags = COALESCE($4::text[], tags), updated_at = now()
+		WHERE menu_item_id = $1
+		RETURNING menu_item_id, created_at, updated_at`, ids[0], item.ItemDescription, item.Price, pq.Array(item.Tags)).Scan(
+			&item.MenuItemId, &item.CreatedAt, &item.UpdatedAt)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to save menu item: %w", err)
+	}
+	if err := setMenuCategories(ctx, tx, item); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM menu_item_ingredients WHERE menu_item_id = $1`, item.MenuItemId); err != nil {
+		return false, fmt.Errorf("failed to clear menu item ingredients: %w", err)
+	}
+	if err := setMenuIngredients(ctx, tx, item); err != nil {
+		return false, err
+	}
+	if item.Variants != nil {
+		if err := setMenuVariants(ctx, tx, item); err != nil {
+			return false, err
+		}
+	}
+	return created, nil
+}
+
+// GetIngredients returns the recipe lines of one serving of a menu item.
+func (r *MenuRepository) GetIngredients(ctx context.Context, MenuItemId string) ([]models.MenuItemsIngredients, error) {
+	return menuIngredients(ctx, r.db, MenuItemId)
+}
+
+// GetRecipes returns the recipe lines of the given menu items keyed by menu item id.
+func (r *MenuRepository) GetRecipes(ctx context.Context, MenuItemIds []string) (map[utils.TEXT][]models.MenuItemsIngredients, error) {
+	return menuRecipes(ctx, r.db, MenuItemIds)
+}
+
+func menuIngredients(ctx context.Context, q querier, MenuItemId string) ([]models.MenuItemsIngredients, error) {
+	recipes, err := menuRecipes(ctx, q, []string{MenuItemId})
+	if err != nil {
+		return nil, err
+	}
+	return recipes[utils.TEXT(MenuItemId)], nil
+}
+
+func menuRecipes(ctx context.Context, q querier, MenuItemIds []string) (map[utils.TEXT][]models.MenuItemsIngredients, error) {
+	rows, err := q.QueryContext(ctx, `
+	SELECT menu_item_ingredients_id, menu_item_id, ingredient_id, ingredient_name, quantity
+	FROM menu_item_ingredients
+	WHERE menu_item_id = ANY($1::uuid[])
+	ORDER BY ingredient_name`, pq.Array(MenuItemIds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query menu item ingredients: %w", err)
+	}
+	defer rows.Close()
+	recipes := make(map[utils.TEXT][]models.MenuItemsIngredients)
+	for rows.Next() {
+		var ingredient models.MenuItemsIngredients
+		err := rows.Scan(&ingredient.MenuItemIngredientId, &ingredient.MenuItemId, &ingredient.IngredientId, &ingredient.IngredientName, &ingredient.Quantity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan menu item ingredient: %w", err)
+		}
+		recipes[ingredient.MenuItemId] = append(recipes[ingredient.MenuItemId], ingredient)
 	}
+	return recipes, rows.Err()
+}
 
+// setMenuIngredients inserts the recipe lines of item.
+func setMenuIngredients(ctx context.Context, tx *sql.Tx, item *models.MenuItems) error {
+	for i := range item.Ingredients {
+		ingredient := &item.Ingredients[i]
+		ingredient.MenuItemId = item.MenuItemId
+		err := tx.QueryRowContext(ctx, `
+		INSERT INTO menu_item_ingredients (menu_item_id, ingredient_id, ingredient_name, quantity)
+		SELECT $1, ingredient_id, ingredient_name, $3
+		FROM inventory
+		WHERE ingredient_id = $2
+		RETURNING menu_item_ingredients_id, ingredient_name`, item.MenuItemId, ingredient.IngredientId, ingredient.Quantity).Scan(&ingredient.MenuItemIngredientId, &ingredient.IngredientName)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("ingredient %s not found: %w", ingredient.IngredientId, err)
+			}
+			return fmt.Errorf("failed to add menu item ingredient: %w", err)
+		}
+	}
 	return nil
 }
+
+// setMenuVariants creates or updates the variants of item by name and
+// archives the variants it no longer lists, which orders may still refer to.
+// Listing an archived variant again brings it back.
+func setMenuVariants(ctx context.Context, tx *sql.Tx, item *models.MenuItems) error {
+	names := make([]string, 0, len(item.Variants))
+	for i := range item.Variants {
+		v := &item.Variants[i]
+		v.MenuItemId = item.MenuItemId
+		if v.RecipeMultiplier <= 0 {
+			v.RecipeMultiplier = 1
+		}
+		err := tx.QueryRowContext(ctx, `
+		INSERT INTO menu_item_variants (menu_item_id, variant_name, price, recipe_multiplier, display_order)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (menu_item_id, variant_name) DO UPDATE
+		SET price = EXCLUDED.price,
+			recipe_multiplier = EXCLUDED.recipe_multiplier,
+			display_order = EXCLUDED.display_order,
+			archived_at = NULL
+		RETURNING variant_id`, v.MenuItemId, v.VariantName, v.Price, v.RecipeMultiplier, v.DisplayOrder).Scan(&v.VariantId)
+		if err != nil {
+			return fmt.Errorf("failed to save variant %s: %w", v.VariantName, err)
+		}
+		names = append(names, string(v.VariantName))
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM menu_item_variant_ingredients WHERE variant_id = $1`, v.VariantId); err != nil {
+			return fmt.Errorf("failed to clear variant ingredients: %w", err)
+		}
+		for j := range v.Ingredients {
+			c := &v.Ingredients[j]
+			err := tx.QueryRowContext(ctx, `
+			INSERT INTO menu_item_variant_ingredients (variant_id, ingredient_id, quantity)
+			SELECT $1, ingredient_id, $3 FROM inventory WHERE ingredient_id = $2
+			RETURNING (SELECT ingredient_name FROM inventory WHERE ingredient_id = $2)`, v.VariantId, c.IngredientId, c.Quantity).Scan(&c.IngredientName)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return fmt.Errorf("ingredient %s not found: %w", c.IngredientId, err)
+				}
+				return fmt.Errorf("failed to add variant ingredient: %w", err)
+			}
+		}
+	}
+	_, err := tx.ExecContext(ctx, `
+	UPDATE menu_item_variants SET archived_at = now()
+	WHERE menu_item_id = $1 AND NOT (variant_name = ANY($2)) AND archived_at IS NULL`, item.MenuItemId, pq.Array(names))
+	if err != nil {
+		return fmt.Errorf("failed to archive variants: %w", err)
+	}
+	return nil
+}
+
+// menuVariants returns the active variants of the given menu items keyed by
+// menu item id, priced for store StoreId: a store price of the menu item
+// moves the price of each variant by as much. An empty store returns the
+// base prices.
+func menuVariants(ctx context.Context, q querier, StoreId string, MenuItemIds []string) (map[utils.TEXT][]models.MenuItemVariant, error) {
+	rows, err := q.QueryContext(ctx, `
+	SELECT v.variant_id, v.menu_item_id, v.variant_name,
+		GREATEST(v.price + COALESCE(smi.price - mi.price, 0), 0),
+		v.recipe_multiplier, v.display_order
+	FROM menu_item_variants v
+	JOIN menu_items mi USING(menu_item_id)
+	LEFT JOIN store_menu_items smi ON smi.menu_item_id = v.menu_item_id AND smi.store_id::text = $2
+	WHERE v.menu_item_id = ANY($1::uuid[]) AND v.archived_at IS NULL
+	ORDER BY v.display_order, v.variant_name`, pq.Array(MenuItemIds), StoreId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query variants: %w", err)
+	}
+	defer rows.Close()
+	var variants []models.MenuItemVariant
+	for rows.Next() {
+		var v models.MenuItemVariant
+		if err := rows.Scan(&v.VariantId, &v.MenuItemId, &v.VariantName, &v.Price, &v.RecipeMultiplier, &v.DisplayOrder); err != nil {
+			return nil, fmt.Errorf("failed to scan variant: %w", err)
+		}
+		variants = append(variants, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ingredients, err := variantIngredients(ctx, q, MenuItemIds)
+	if err != nil {
+		return nil, err
+	}
+	byItem := make(map[utils.TEXT][]models.MenuItemVariant)
+	for _, v := range variants {
+		v.Ingredients = ingredients[v.VariantId]
+		byItem[v.MenuItemId] = append(byItem[v.MenuItemId], v)
+	}
+	return byItem, nil
+}
+
+func variantIngredients(ctx context.Context, q querier, MenuItemIds []string) (map[utils.TEXT][]models.RecipeComponent, error) {
+	rows, err := q.QueryContext(ctx, `
+	SELECT vi.variant_id, vi.ingredient_id, i.ingredient_name, vi.quantity
+	FROM menu_item_variant_ingredients vi
+	JOIN menu_item_variants v USING(variant_id)
+	JOIN inventory i USING(ingredient_id)
+	WHERE v.menu_item_id = ANY($1::uuid[]) AND v.archived_at IS NULL
+	ORDER BY i.ingredient_name`, pq.Array(MenuItemIds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query variant ingredients: %w", err)
+	}
+	defer rows.Close()
+	ingredients := make(map[utils.TEXT][]models.RecipeComponent)
+	for rows.Next() {
+		var variantId utils.TEXT
+		var c models.RecipeComponent
+		if err := rows.Scan(&variantId, &c.IngredientId, &c.IngredientName, &c.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan variant ingredient: %w", err)
+		}
+		ingredients[variantId] = append(ingredients[variantId], c)
+	}
+	return ingredients, nil
+}
@@ -0,0 +1,436 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"frappuccino/models"
+	"frappuccino/utils"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+type MenuVersionRepo interface {
+	Create(ctx context.Context, version *models.MenuVersion) error
+	GetAll(ctx context.Context, status string) ([]models.MenuVersion, error)
+	GetVersionByID(ctx context.Context, VersionId string) (models.MenuVersion, error)
+	GetPublished(ctx context.Context) (models.MenuVersion, error)
+	UpdateDraft(ctx context.Context, version *models.MenuVersion) error
+	DeleteDraft(ctx context.Context, VersionId string) error
+	Schedule(ctx context.Context, VersionId string, PublishAt utils.TIME) error
+	Publish(ctx context.Context, VersionId string) error
+	PublishDue(ctx context.Context) (int, []error, error)
+}
+
+type MenuVersionRepository struct {
+	db *sql.DB
+}
+
+func NewMenuVersionRepository(db *sql.DB) *MenuVersionRepository {
+	return &MenuVersionRepository{db: db}
+}
+
+// menuVersionColumns lists the menu_versions columns in the order scanMenuVersion reads them.
+const menuVersionColumns = `version_id,version_number,version_name,version_status,items,base_items,based_at,publish_at,published_at,publish_error,created_at,updated_at`
+
+func scanMenuVersion(row interface{ Scan(...any) error }, version *models.MenuVersion) error {
+	var items, base []byte
+	err := row.Scan(&version.VersionId, &version.VersionNumber, &version.VersionName, &version.VersionStatus, &items, &base,
+		&version.BasedAt, &version.PublishAt, &version.PublishedAt, &version.PublishError, &version.CreatedAt, &version.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(items, &version.Items); err != nil {
+		return err
+	}
+	return json.Unmarshal(base, &version.BaseItems)
+}
+
+// Create saves a draft version based on version.BaseItems. Items without an
+// id get the id they will have once the version is published.
+func (r *MenuVersionRepository) Create(ctx context.Context, version *models.MenuVersion) error {
+	if err := assignMenuItemIds(ctx, r.db, version.Items); err != nil {
+		return err
+	}
+	items, err := json.Marshal(version.Items)
+	if err != nil {
+		return fmt.Errorf("failed to encode menu version items: %w", err)
+	}
+	base, err := json.Marshal(version.BaseItems)
+	if err != nil {
+		return fmt.Errorf("failed to encode menu version base items: %w", err)
+	}
+	err = r.db.QueryRowContext(ctx, `
+	INSERT INTO menu_versions (version_name, items, base_items)
+	VALUES ($1, $2, $3)
+	RETURNING version_id, version_number, version_status, based_at, created_at, updated_at`, version.VersionName, items, base).Scan(
+		&version.VersionId, &version.VersionNumber, &version.VersionStatus, &version.BasedAt, &version.CreatedAt, &version.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create menu version: %w", err)
+	}
+	return nil
+}
+
+// GetAll lists menu versions, newest first, optionally filtered by status.
+func (r *MenuVersionRepository) GetAll(ctx context.Context, status string) ([]models.MenuVersion, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+menuVersionColumns+`
+	FROM menu_versions
+	WHERE $1 = '' OR version_status::text = $1
+	ORDER BY version_number DESC`, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query menu versions: %w", err)
+	}
+	defer rows.Close()
+	var versions []models.MenuVersion
+	for rows.Next() {
+		var version models.MenuVersion
+		if err := scanMenuVersion(rows, &version); err != nil {
+			return nil, fmt.Errorf("failed to scan menu version: %w", err)
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+func (r *MenuVersionRepository) GetVersionByID(ctx context.Context, VersionId string) (models.MenuVersion, error) {
+	var version models.MenuVersion
+	err := scanMenuVersion(r.db.QueryRowContext(ctx, `SELECT `+menuVersionColumns+` FROM menu_versions WHERE version_id = $1`, VersionId), &version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MenuVersion{}, fmt.Errorf("menu version not found: %w", err)
+		}
+		return models.MenuVersion{}, fmt.Errorf("failed to get menu version: %w", err)
+	}
+	return version, nil
+}
+
+// GetPublished returns the live menu version, or sql.ErrNoRows when no version has been published.
+func (r *MenuVersionRepository) GetPublished(ctx context.Context) (models.MenuVersion, error) {
+	var version models.MenuVersion
+	err := scanMenuVersion(r.db.QueryRowContext(ctx, `SELECT `+menuVersionColumns+` FROM menu_versions WHERE version_status = 'PUBLISHED'`), &version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MenuVersion{}, fmt.Errorf("no published menu version: %w", err)
+		}
+		return models.MenuVersion{}, fmt.Errorf("failed to get published menu version: %w", err)
+	}
+	return version, nil
+}
+
+func (r *MenuVersionRepository) UpdateDraft(ctx context.Context, version *models.MenuVersion) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := lockMenuVersion(ctx, tx, string(version.VersionId), models.MenuVersionDraft); err != nil {
+		return err
+	}
+	if err := assignMenuItemIds(ctx, tx, version.Items); err != nil {
+		return err
+	}
+	items, err := json.Marshal(version.Items)
+	if err != nil {
+		return fmt.Errorf("failed to encode menu version items: %w", err)
+	}
+	err = tx.QueryRowContext(ctx, `
+	UPDATE menu_versions SET version_name = $2, items = $3
+	WHERE version_id = $1
+	RETURNING version_number, version_status, created_at, updated_at`, version.VersionId, version.VersionName, items).Scan(
+		&version.VersionNumber, &version.VersionStatus, &version.CreatedAt, &version.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update menu version: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (r *MenuVersionRepository) DeleteDraft(ctx context.Context, VersionId string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := lockMenuVersion(ctx, tx, VersionId, models.MenuVersionDraft); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM menu_versions WHERE version_id = $1`, VersionId); err != nil {
+		return fmt.Errorf("failed to delete menu version: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Schedule marks a draft, or reschedules a scheduled version, to be published at PublishAt.
+func (r *MenuVersionRepository) Schedule(ctx context.Context, VersionId string, PublishAt utils.TIME) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := lockMenuVersion(ctx, tx, VersionId, models.MenuVersionDraft, models.MenuVersionScheduled); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+	UPDATE menu_versions SET version_status = 'SCHEDULED', publish_at = $2, publish_error = '' WHERE version_id = $1`, VersionId, PublishAt)
+	if err != nil {
+		return fmt.Errorf("failed to schedule menu version: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Publish makes a draft or scheduled version the live menu in one transaction.
+func (r *MenuVersionRepository) Publish(ctx context.Context, VersionId string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	version, err := lockMenuVersion(ctx, tx, VersionId, models.MenuVersionDraft, models.MenuVersionScheduled)
+	if err != nil {
+		return err
+	}
+	if err := publishMenuVersion(ctx, tx, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PublishDue publishes the scheduled versions whose time has come, oldest
+// first and each in its own transaction, and returns how many it published.
+// A version that fails to publish, for ErrMenuVersionConflict or any other
+// reason, goes back to draft with the reason in publish_error while the
+// others are still published; its error is returned apart.
+func (r *MenuVersionRepository) PublishDue(ctx context.Context) (int, []error, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT version_id FROM menu_versions
+	WHERE version_status = 'SCHEDULED' AND publish_at <= now()
+	ORDER BY publish_at`)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to query due menu versions: %w", err)
+	}
+	var due []string
+	for rows.Next() {
+		var VersionId string
+		if err := rows.Scan(&VersionId); err != nil {
+			rows.Close()
+			return 0, nil, fmt.Errorf("failed to scan menu version: %w", err)
+		}
+		due = append(due, VersionId)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	published := 0
+	var failed []error
+	for _, VersionId := range due {
+		ok, err := r.publishScheduled(ctx, VersionId)
+		if err == nil {
+			if ok {
+				published++
+			}
+			continue
+		}
+		if ctx.Err() != nil {
+			return published, failed, ctx.Err()
+		}
+		_, uerr := r.db.ExecContext(ctx, `
+		UPDATE menu_versions SET version_status = 'DRAFT', publish_at = NULL, publish_error = $2
+		WHERE version_id = $1 AND version_status = 'SCHEDULED'`, VersionId, err.Error())
+		if uerr != nil {
+			return published, failed, fmt.Errorf("failed to unschedule menu version: %w", uerr)
+		}
+		failed = append(failed, fmt.Errorf("menu version %s: %w", VersionId, err))
+	}
+	return published, failed, nil
+}
+
+// publishScheduled publishes a scheduled version whose time has come in one
+// transaction. It reports false, without error, when the version is no
+// longer due or another publisher holds it.
+func (r *MenuVersionRepository) publishScheduled(ctx context.Context, VersionId string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var version models.MenuVersion
+	err = scanMenuVersion(tx.QueryRowContext(ctx, `SELECT `+menuVersionColumns+`
+	FROM menu_versions
+	WHERE version_id = $1 AND version_status = 'SCHEDULED' AND publish_at <= now()
+	FOR UPDATE SKIP LOCKED`, VersionId), &version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to lock menu version: %w", err)
+	}
+	if err := publishMenuVersion(ctx, tx, version); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// lockMenuVersion locks the version for update and checks it is in one of the given statuses.
+func lockMenuVersion(ctx context.Context, tx *sql.Tx, VersionId string, statuses ...string) (models.MenuVersion, error) {
+	var version models.MenuVersion
+	err := scanMenuVersion(tx.QueryRowContext(ctx, `SELECT `+menuVersionColumns+`
+	FROM menu_versions WHERE version_id = $1 FOR UPDATE`, VersionId), &version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MenuVersion{}, fmt.Errorf("menu version not found: %w", err)
+		}
+		return models.MenuVersion{}, fmt.Errorf("failed to lock menu version: %w", err)
+	}
+	for _, status := range statuses {
+		if string(version.VersionStatus) == status {
+			return version, nil
+		}
+	}
+	return models.MenuVersion{}, fmt.Errorf("menu version is %s: %w", version.VersionStatus, models.ErrMenuVersionStatus)
+}
+
+// publishMenuVersion archives the live version and applies to menu_items
+// what version changes from the menu it was drafted from: items it adds or
+// changes are saved and items it drops are archived. Live items it does not
+// change are left alone. It fails with ErrMenuVersionConflict, before
+// writing anything, when an item it changes was edited since it was drafted.
+func publishMenuVersion(ctx context.Context, tx *sql.Tx, version models.MenuVersion) error {
+	diff := models.DiffMenus(version.BaseItems, version.Items)
+	changed := make(map[utils.TEXT]bool)
+	var touched []string
+	for _, item := range diff.Added {
+		changed[item.MenuItemId] = true
+		touched = append(touched, string(item.MenuItemId))
+	}
+	for _, change := range diff.Changed {
+		changed[change.MenuItemId] = true
+		touched = append(touched, string(change.MenuItemId))
+	}
+	removed := make([]string, len(diff.Removed))
+	for i, item := range diff.Removed {
+		removed[i] = string(item.MenuItemId)
+	}
+	if err := checkMenuConflicts(ctx, tx, append(touched, removed...), version.BasedAt); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `
+	UPDATE menu_versions SET version_status = 'ARCHIVED' WHERE version_status = 'PUBLISHED'`)
+	if err != nil {
+		return fmt.Errorf("failed to archive published menu version: %w", err)
+	}
+
+	for i := range version.Items {
+		item := &version.Items[i]
+		if !changed[item.MenuItemId] {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, `
+		INSERT INTO menu_items (menu_item_id, item_name, item_description, price, tags)
+		VALUES ($1, $2, $3, $4, COALESCE($5::text[], '{}'))
+		ON CONFLICT (menu_item_id) DO UPDATE
+		SET item_name = EXCLUDED.item_name,
+			item_description = EXCLUDED.item_description,
+			price = EXCLUDED.price,
+			tags = EXCLUDED.tags,
+			archived_at = NULL,
+			updated_at = now()`, item.MenuItemId, item.ItemName, item.ItemDescription, item.Price, pq.Array(item.Tags))
+		if err != nil {
+			return fmt.Errorf("failed to publish menu item %s: %w", item.ItemName, err)
+		}
+		if item.CategoryIds == nil {
+			item.CategoryIds = utils.TEXTARR{}
+		}
+		if err := setMenuCategories(ctx, tx, item); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM menu_item_ingredients WHERE menu_item_id = $1`, item.MenuItemId); err != nil {
+			return fmt.Errorf("failed to clear menu item ingredients: %w", err)
+		}
+		if err := setMenuIngredients(ctx, tx, item); err != nil {
+			return err
+		}
+		if err := setMenuVariants(ctx, tx, item); err != nil {
+			return err
+		}
+	}
+	// bundles go last so that their options exist
+	for i := range version.Items {
+		if changed[version.Items[i].MenuItemId] {
+			if err := setBundleSlots(ctx, tx, &version.Items[i]); err != nil {
+				return err
+			}
+		}
+	}
+	_, err = tx.ExecContext(ctx, `
+	UPDATE menu_items SET archived_at = now()
+	WHERE archived_at IS NULL AND menu_item_id = ANY($1::uuid[])`, pq.Array(removed))
+	if err != nil {
+		return fmt.Errorf("failed to archive menu items: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	UPDATE menu_versions SET version_status = 'PUBLISHED', published_at = now(), publish_error = '' WHERE version_id = $1`, version.VersionId)
+	if err != nil {
+		return fmt.Errorf("failed to publish menu version: %w", err)
+	}
+	return nil
+}
+
+// checkMenuConflicts locks the given live menu items and fails with
+// ErrMenuVersionConflict, naming them, when any was updated after basedAt.
+func checkMenuConflicts(ctx context.Context, tx *sql.Tx, MenuItemIds []string, basedAt utils.TIME) error {
+	rows, err := tx.QueryContext(ctx, `
+	SELECT item_name, updated_at > $2
+	FROM menu_items
+	WHERE menu_item_id = ANY($1::uuid[])
+	ORDER BY menu_item_id
+	FOR UPDATE`, pq.Array(MenuItemIds), basedAt)
+	if err != nil {
+		return fmt.Errorf("failed to lock menu items: %w", err)
+	}
+	defer rows.Close()
+	var edited []string
+	for rows.Next() {
+		var name string
+		var conflict bool
+		if err := rows.Scan(&name, &conflict); err != nil {
+			return fmt.Errorf("failed to scan menu item: %w", err)
+		}
+		if conflict {
+			edited = append(edited, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(edited) > 0 {
+		return fmt.Errorf("%s: %w", strings.Join(edited, ", "), models.ErrMenuVersionConflict)
+	}
+	return nil
+}
+
+// assignMenuItemIds gives new items of a version the id they will be published with.
+func assignMenuItemIds(ctx context.Context, q querier, items []models.MenuItems) error {
+	for i := range items {
+		if items[i].MenuItemId != "" {
+			continue
+		}
+		if err := q.QueryRowContext(ctx, `SELECT uuid_generate_v4()`).Scan(&items[i].MenuItemId); err != nil {
+			return fmt.Errorf("failed to assign menu item id: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"frappuccino/models"
+	"frappuccino/utils"
+	"slices"
+
+	"github.com/lib/pq"
+)
+
+type ModifierRepo interface {
+	Create(ctx context.Context, group *models.ModifierGroup) error
+	GetAll(ctx context.Context) ([]models.ModifierGroup, error)
+	GetGroupByID(ctx context.Context, ModifierGroupId string) (models.ModifierGroup, error)
+	UpdateGroupByID(ctx context.Context, group *models.ModifierGroup) error
+	DeleteGroupByID(ctx context.Context, ModifierGroupId string) error
+	SetMenuItemGroups(ctx context.Context, MenuItemId string, ModifierGroupIds []string) error
+}
+
+type ModifierRepository struct {
+	db *sql.DB
+}
+
+func NewModifierRepository(db *sql.DB) *ModifierRepository {
+	return &ModifierRepository{db: db}
+}
+
+func (r *ModifierRepository) Create(ctx context.Context, group *models.ModifierGroup) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO modifier_groups (group_name, min_selections, max_selections)
+	VALUES ($1, $2, $3)
+	RETURNING modifier_group_id, created_at, updated_at`,
+		group.GroupName, group.MinSelections, group.MaxSelections).Scan(&group.ModifierGroupId, &group.CreatedAt, &group.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create modifier group: %w", err)
+	}
+	if err := setModifiers(ctx, tx, group); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *ModifierRepository) GetAll(ctx context.Context) ([]models.ModifierGroup, error) {
+	return modifierGroups(ctx, r.db, `TRUE`)
+}
+
+func (r *ModifierRepository) GetGroupByID(ctx context.Context, ModifierGroupId string) (models.ModifierGroup, error) {
+	groups, err := modifierGroups(ctx, r.db, `g.modifier_group_id = $1`, ModifierGroupId)
+	if err != nil {
+		return models.ModifierGroup{}, err
+	}
+	if len(groups) == 0 {
+		return models.ModifierGroup{}, fmt.Errorf("modifier group not found: %w", sql.ErrNoRows)
+	}
+	return groups[0], nil
+}
+
+// UpdateGroupByID updates the group and its modifiers by name, removing the
+// modifiers it no longer lists.
+func (r *ModifierRepository) UpdateGroupByID(ctx context.Context, group *models.ModifierGroup) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+	UPDATE modifier_groups
+	SET group_name = $1,
+		min_selections = $2,
+		max_selections = $3
+	WHERE modifier_group_id = $4
+	RETURNING created_at, updated_at`,
+		group.GroupName, group.MinSelections, group.MaxSelections, group.ModifierGroupId).Scan(&group.CreatedAt, &group.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update modifier group: %w", err)
+	}
+	if err := setModifiers(ctx, tx, group); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *ModifierRepository) DeleteGroupByID(ctx context.Context, ModifierGroupId string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM modifier_groups WHERE modifier_group_id = $1`, ModifierGroupId)
+	if err != nil {
+		return fmt.Errorf("failed to delete modifier group: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetMenuItemGroups replaces the modifier groups offered on a menu item.
+func (r *ModifierRepository) SetMenuItemGroups(ctx context.Context, MenuItemId string, ModifierGroupIds []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM menu_item_modifier_groups WHERE menu_item_id = $1`, MenuItemId); err != nil {
+		return fmt.Errorf("failed to clear menu item modifier groups: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO menu_item_modifier_groups (menu_item_id, modifier_group_id)
+	SELECT $1, unnest($2::uuid[])`, MenuItemId, pq.Array(ModifierGroupIds))
+	if err != nil {
+		return fmt.Errorf("failed to set menu item modifier groups: %w", err)
+	}
+	return tx.Commit()
+}
+
+// setModifiers saves the modifiers of group by name and removes the ones it no longer lists.
+func setModifiers(ctx context.Context, tx *sql.Tx, group *models.ModifierGroup) error {
+	names := make([]string, 0, len(group.Modifiers))
+	for i := range group.Modifiers {
+		m := &group.Modifiers[i]
+		m.ModifierGroupId = group.ModifierGroupId
+		err := tx.QueryRowContext(ctx, `
+		INSERT INTO modifiers (modifier_group_id, modifier_name, price_delta)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (modifier_group_id, modifier_name) DO UPDATE
+		SET price_delta = EXCLUDED.price_delta
+		RETURNING modifier_id`, m.ModifierGroupId, m.ModifierName, m.PriceDelta).Scan(&m.ModifierId)
+		if err != nil {
+			return fmt.Errorf("failed to save modifier %s: %w", m.ModifierName, err)
+		}
+		names = append(names, string(m.ModifierName))
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM modifier_ingredients WHERE modifier_id = $1`, m.ModifierId); err != nil {
+			return fmt.Errorf("failed to clear modifier ingredients: %w", err)
+		}
+		for _, change := range m.Ingredients {
+			_, err := tx.ExecContext(ctx, `
+			INSERT INTO modifier_ingredients (modifier_id, modifier_action, ingredient_id, replaces_ingredient_id, quantity)
+			VALUES ($1, $2, NULLIF($3, '')::uuid, NULLIF($4, '')::uuid, $5)`,
+				m.ModifierId, change.Action, change.IngredientId, change.ReplacesIngredientId, change.Quantity)
+			if err != nil {
+				return fmt.Errorf("failed to add modifier ingredient: %w", err)
+			}
+		}
+	}
+	_, err := tx.ExecContext(ctx, `
+	DELETE FROM modifiers
+	WHERE modifier_group_id = $1 AND NOT (modifier_name = ANY($2))`, group.ModifierGroupId, pq.Array(names))
+	if err != nil {
+		return fmt.Errorf("failed to remove modifiers: %w", err)
+	}
+	return nil
+}
+
+// menuModifierGroups returns the modifier groups offered on the given menu
+// items keyed by menu item id. Each item gets its own copy of the modifiers.
+func menuModifierGroups(ctx context.Context, q querier, MenuItemIds []string) (map[utils.TEXT][]models.ModifierGroup, error) {
+	rows, err := q.QueryContext(ctx, `
+	SELECT menu_item_id, modifier_group_id FROM menu_item_modifier_groups
+	WHERE menu_item_id = ANY($1::uuid[])`, pq.Array(MenuItemIds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query menu item modifier groups: %w", err)
+	}
+	defer rows.Close()
+	itemsOf := make(map[utils.TEXT][]utils.TEXT)
+	for rows.Next() {
+		var MenuItemId, ModifierGroupId utils.TEXT
+		if err := rows.Scan(&MenuItemId, &ModifierGroupId); err != nil {
+			return nil, fmt.Errorf("failed to scan menu item modifier group: %w", err)
+		}
+		itemsOf[ModifierGroupId] = append(itemsOf[ModifierGroupId], MenuItemId)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(itemsOf) == 0 {
+		return map[utils.TEXT][]models.ModifierGroup{}, nil
+	}
+
+	groups, err := modifierGroups(ctx, q, `g.modifier_group_id IN (
+		SELECT modifier_group_id FROM menu_item_modifier_groups WHERE menu_item_id = ANY($1::uuid[]))`, pq.Array(MenuItemIds))
+	if err != nil {
+		return nil, err
+	}
+	byItem := make(map[utils.TEXT][]models.ModifierGroup)
+	for _, g := range groups {
+		for _, MenuItemId := range itemsOf[g.ModifierGroupId] {
+			group := g
+			group.Modifiers = slices.Clone(g.Modifiers)
+			byItem[MenuItemId] = append(byItem[MenuItemId], group)
+		}
+	}
+	return byItem, nil
+}
+
+// modifierGroups loads the groups matching where, with their modifiers and recipe changes.
+func modifierGroups(ctx context.Context, q querier, where string, args ...any) ([]models.ModifierGroup, error) {
+	rows, err := q.QueryContext(ctx, `
+	SELECT g.modifier_group_id, g.group_name, g.min_selections, g.max_selections, g.created_at, g.updated_at
+	FROM modifier_groups g
+	WHERE `+where+`
+	ORDER BY g.group_name`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query modifier groups: %w", err)
+	}
+	defer rows.Close()
+	var groups []models.ModifierGroup
+	var ids []string
+	for rows.Next() {
+		var g models.ModifierGroup
+		if err := rows.Scan(&g.ModifierGroupId, &g.GroupName, &g.MinSelections, &g.MaxSelections, &g.CreatedAt, &g.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan modifier group: %w", err)
+		}
+		groups = append(groups, g)
+		ids = append(ids, string(g.ModifierGroupId))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return groups, nil
+	}
+
+	modifiers, err := groupModifiers(ctx, q, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range groups {
+		groups[i].Modifiers = modifiers[groups[i].ModifierGroupId]
+	}
+	return groups, nil
+}
+
+func groupModifiers(ctx context.Context, q querier, ModifierGroupIds []string) (map[utils.TEXT][]models.Modifier, error) {
+	rows, err := q.QueryContext(ctx, `
+	SELECT m.modifier_id, m.modifier_group_id, m.modifier_name, m.price_delta,
+		COALESCE(mi.modifier_action::text, ''), COALESCE(mi.ingredient_id::text, ''),
+		COALESCE(mi.replaces_ingredient_id::text, ''), COALESCE(mi.quantity, 0)
+	FROM modifiers m
+	LEFT JOIN modifier_ingredients mi USING(modifier_id)
+	WHERE m.modifier_group_id = ANY($1::uuid[])
+	ORDER BY m.modifier_name`, pq.Array(ModifierGroupIds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query modifiers: %w", err)
+	}
+	defer rows.Close()
+	byGroup := make(map[utils.TEXT][]models.Modifier)
+	index := make(map[utils.TEXT]int)
+	for rows.Next() {
+		var m models.Modifier
+		var change models.ModifierIngredient
+		err := rows.Scan(&m.ModifierId, &m.ModifierGroupId, &m.ModifierName, &m.PriceDelta,
+			&change.Action, &change.IngredientId, &change.ReplacesIngredientId, &change.Quantity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan modifier: %w", err)
+		}
+		i, ok := index[m.ModifierId]
+		if !ok {
+			i = len(byGroup[m.ModifierGroupId])
+			index[m.ModifierId] = i
+			byGroup[m.ModifierGroupId] = append(byGroup[m.ModifierGroupId], m)
+		}
+		if change.Action != "" {
+			byGroup[m.ModifierGroupId][i].Ingredients = append(byGroup[m.ModifierGroupId][i].Ingredients, change)
+		}
+	}
+	return byGroup, nil
+}
+
+// selectModifiers checks the modifiers chosen for an order item against the
+// groups offered on its menu item and returns them.
+func selectModifiers(ctx context.Context, q querier, item models.OrderItems) ([]models.Modifier, error) {
+	offered, err := menuModifierGroups(ctx, q, []string{string(item.MenuItemId)})
+	if err != nil {
+		return nil, err
+	}
+	groups := offered[item.MenuItemId]
+	chosen := make(map[utils.TEXT]bool, len(item.Modifiers))
+	for _, id := range item.Modifiers {
+		if chosen[id] {
+			return nil, fmt.Errorf("modifier %s chosen twice: %w", id, models.ErrInvalidModifier)
+		}
+		chosen[id] = true
+	}
+
+	var selected []models.Modifier
+	for _, g := range groups {
+		var count utils.INT
+		for _, m := range g.Modifiers {
+			if chosen[m.ModifierId] {
+				selected = append(selected, m)
+				delete(chosen, m.ModifierId)
+				count++
+			}
+		}
+		if count < g.MinSelections || count > g.MaxSelections {
+			return nil, fmt.Errorf("%s needs %d to %d choices: %w", g.GroupName, g.MinSelections, g.MaxSelections, models.ErrInvalidModifier)
+		}
+	}
+	for id := range chosen {
+		return nil, fmt.Errorf("modifier %s is not offered on menu item %s: %w", id, item.MenuItemId, models.ErrInvalidModifier)
+	}
+	return selected, nil
+}
+
+// orderItemModifiers returns the ids of the modifiers chosen for an order item.
+func orderItemModifiers(ctx context.Context, q querier, OrderItemId utils.TEXT) ([]utils.TEXT, error) {
+	rows, err := q.QueryContext(ctx, `SELECT modifier_id FROM order_item_modifiers WHERE order_item_id = $1`, OrderItemId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order item modifiers: %w", err)
+	}
+	defer rows.Close()
+	var ids []utils.TEXT
+	for rows.Next() {
+		var id utils.TEXT
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan order item modifier: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -7,20 +7,21 @@ import (
 	"fmt"
 	"frappuccino/models"
 	"frappuccino/utils"
-
-	"github.com/lib/pq"
+	"slices"
 )
 
 type OrderRepo interface {
 	Create(ctx context.Context, order *models.Order) error
-	Orders(ctx context.Context) ([]models.Order, error)
+	Orders(ctx context.Context, StoreId string) ([]models.Order, error)
 	GetOrderByID(ctx context.Context, orderId string) (models.Order, error)
 	UpdateOrderItemByID(ctx context.Context, orderItems *models.OrderItems) error
 	DeleteOrderByID(ctx context.Context, orderId string) error
 	UpdateStatusOrder(ctx context.Context, orderId string, status string) error
+	CustomerOrders(ctx context.Context, CustomerId string, filter models.OrderFilter) (models.OrderPage, error)
+	Reorder(ctx context.Context, order *models.Order) ([]models.UnavailableItem, error)
 	NumberOfOrderItems(ctx context.Context) error // need to add
-	checkIngregients(tx *sql.Tx, orderItems []models.OrderItems) error
-	minusInventory(tx *sql.Tx, orderItems []models.OrderItems) error
+	checkIngregients(ctx context.Context, tx *sql.Tx, storeId utils.TEXT, usage map[utils.TEXT]utils.DEC) error
+	minusInventory(ctx context.Context, tx *sql.Tx, order *models.Order, usage map[utils.TEXT]utils.DEC) error
 }
 
 type OrderRepository struct {
@@ -31,76 +32,220 @@ func NewOrderRepository(db *sql.DB) *OrderRepository {
 	return &OrderRepository{db: db}
 }
 
+// Create places the order in order.StoreId, pricing its items with the
+// store menu and deducting their ingredients from the store inventory.
 func (r *OrderRepository) Create(ctx context.Context, order *models.Order) error {
+	if order.StoreId == "" {
+		return models.ErrMissingStore
+	}
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
+	if err := r.create(ctx, tx, order); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// create places the order within tx.
+func (r *OrderRepository) create(ctx context.Context, tx *sql.Tx, order *models.Order) error {
 	// check inventory
-	err = r.checkIngregients(tx, order.OrderItems)
+	usage, lines, err := orderUsage(ctx, tx, order.OrderItems)
+	if err != nil {
+		return err
+	}
+	err = r.checkIngregients(ctx, tx, order.StoreId, usage)
 	if err != nil {
 		return err
 	}
-	err = r.db.QueryRowContext(ctx,
-		`INSERT INTO orders (customer_id,special_instructions,order_payment_method)
-		VALUES ($1,$2,$3)
-		RETURNING order_id,created_at,updated_at;`, order.CustomerId, order.SpecialInstructions, order.PaymentMethod).Scan(&order.OrderId, &order.CreatedAt, &order.UpdatedAt)
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO orders (customer_id,store_id,special_instructions,order_payment_method,total_price)
+		VALUES ($1,$2,COALESCE(NULLIF($3,'')::jsonb,'{}'),$4,0)
+		RETURNING order_id,created_at,updated_at;`, order.CustomerId, order.StoreId, order.SpecialInstructions, order.PaymentMethod).Scan(&order.OrderId, &order.CreatedAt, &order.UpdatedAt)
 	if err != nil {
 		return err
 	}
 
 	var totalPrice utils.DEC
 
-	for _, items := range order.OrderItems {
-		err = tx.QueryRow(`SELECT mi.price FROM menu_items mi WHERE menu_item_id = $1`, items.MenuItemId).Scan(&items.UnitPrice)
+	for i := range order.OrderItems {
+		items := &order.OrderItems[i]
+		items.UnitPrice, err = itemPrice(ctx, tx, order.StoreId, *items, lines[i].components)
 		if err != nil {
 			return err
 		}
 
+		for _, m := range lines[i].modifiers {
+			items.UnitPrice += m.PriceDelta
+		}
+		if len(lines[i].components) > 0 {
+			err = allocateBundle(ctx, tx, order.StoreId, items.UnitPrice, lines[i].components)
+			if err != nil {
+				return err
+			}
+			items.Components = lines[i].components
+		}
+
+		items.OrderId = order.OrderId
+		err = tx.QueryRowContext(ctx, `
+		INSERT INTO order_items (order_id,menu_item_id,variant_id,customizations,quantity,unit_price)
+		VALUES ($1,$2,NULLIF($3,'')::uuid,COALESCE(NULLIF($4,'')::jsonb,'{}'),$5,$6)
+		RETURNING order_item_id`, items.OrderId, items.MenuItemId, items.VariantId, items.Customizations, items.Quantity, items.UnitPrice).Scan(&items.OrderItemId)
+		if err != nil {
+			return fmt.Errorf("failed to add order item: %w", err)
+		}
+		for _, m := range lines[i].modifiers {
+			_, err = tx.ExecContext(ctx, `
+			INSERT INTO order_item_modifiers (order_item_id,modifier_id,price_delta)
+			VALUES ($1,$2,$3)`, items.OrderItemId, m.ModifierId, m.PriceDelta)
+			if err != nil {
+				return fmt.Errorf("failed to add order item modifier: %w", err)
+			}
+		}
+		for _, c := range items.Components {
+			_, err = tx.ExecContext(ctx, `
+			INSERT INTO order_item_components (order_item_id,menu_item_id,slot_name,quantity,allocated_price)
+			VALUES ($1,$2,$3,$4,$5)`, items.OrderItemId, c.MenuItemId, c.SlotName, c.Quantity, c.AllocatedPrice)
+			if err != nil {
+				return fmt.Errorf("failed to add order item component: %w", err)
+			}
+		}
+
 		totalPrice += items.Quantity * items.UnitPrice // add unit_price from menu Items
 	}
 	order.TotalPrice = totalPrice
-	err = r.minusInventory(tx, order.OrderItems)
+	err = r.minusInventory(ctx, tx, order, usage)
+	if err != nil {
+		return err
+	}
 
 	order.OrderStatus = "PENDING"
+	return nil
+}
 
-	return tx.Commit()
+// itemPrice returns the store price of one unit of an order item before
+// modifiers, failing when the item or one of its bundle components cannot
+// be ordered in the store right now. A variant costs its price moved by the
+// difference between the store and base prices of its menu item, as
+// menuVariants lists it.
+func itemPrice(ctx context.Context, q querier, storeId utils.TEXT, item models.OrderItems, components []models.OrderItemComponent) (utils.DEC, error) {
+	var price utils.DEC
+	var variantFound bool
+	err := q.QueryRowContext(ctx, `
+	SELECT CASE WHEN v.variant_id IS NULL THEN COALESCE(smi.price, mi.price)
+		ELSE GREATEST(v.price + COALESCE(smi.price - mi.price, 0), 0) END,
+		v.variant_id IS NOT NULL
+	FROM menu_items mi
+	LEFT JOIN store_menu_items smi ON smi.menu_item_id = mi.menu_item_id AND smi.store_id = $2
+	LEFT JOIN menu_item_variants v ON v.menu_item_id = mi.menu_item_id AND v.variant_id::text = $3
+		AND v.archived_at IS NULL
+	WHERE mi.menu_item_id = $1 AND mi.archived_at IS NULL AND COALESCE(smi.is_available, TRUE)`, item.MenuItemId, storeId, item.VariantId).Scan(&price, &variantFound)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("menu item %s: %w", item.MenuItemId, models.ErrItemNotAvailable)
+		}
+		return 0, err
+	}
+	if item.VariantId != "" && !variantFound {
+		return 0, fmt.Errorf("variant %s: %w", item.VariantId, models.ErrInvalidVariant)
+	}
+	if err := checkOrderable(ctx, q, storeId, item.MenuItemId); err != nil {
+		return 0, err
+	}
+	for _, c := range components {
+		if err := checkOrderable(ctx, q, storeId, c.MenuItemId); err != nil {
+			return 0, err
+		}
+	}
+	return price, nil
+}
+
+// orderLine is what orderUsage resolved for one order item.
+type orderLine struct {
+	modifiers  []models.Modifier
+	components []models.OrderItemComponent
+}
+
+// orderUsage sums the ingredients consumed by the order items, bundle
+// components included, and returns the modifiers and components of each.
+func orderUsage(ctx context.Context, q querier, orderItems []models.OrderItems) (map[utils.TEXT]utils.DEC, []orderLine, error) {
+	usage := make(map[utils.TEXT]utils.DEC)
+	lines := make([]orderLine, len(orderItems))
+	for i, item := range orderItems {
+		recipe, err := itemRecipe(ctx, q, item)
+		if err != nil {
+			return nil, nil, err
+		}
+		lines[i].components, err = bundleComponents(ctx, q, item)
+		if err != nil {
+			return nil, nil, err
+		}
+		recipe, err = componentsRecipe(ctx, q, recipe, lines[i].components)
+		if err != nil {
+			return nil, nil, err
+		}
+		lines[i].modifiers, err = selectModifiers(ctx, q, item)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, ingredient := range models.ApplyModifiers(recipe, lines[i].modifiers) {
+			usage[ingredient.IngredientId] += ingredient.Quantity * item.Quantity
+		}
+	}
+	return usage, lines, nil
 }
 
-func (r *OrderRepository) checkIngregients(tx *sql.Tx, orderItems []models.OrderItems) error {
+// itemRecipe returns the recipe lines of one unit of an order item.
+func itemRecipe(ctx context.Context, q querier, item models.OrderItems) ([]models.MenuItemsIngredients, error) {
+	recipe, err := menuIngredients(ctx, q, string(item.MenuItemId))
+	if err != nil {
+		return nil, err
+	}
+	if item.VariantId == "" {
+		return recipe, nil
+	}
+	variants, err := menuVariants(ctx, q, "", []string{string(item.MenuItemId)})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range variants[item.MenuItemId] {
+		if v.VariantId == item.VariantId {
+			return v.Recipe(recipe), nil
+		}
+	}
+	return nil, fmt.Errorf("variant %s: %w", item.VariantId, models.ErrInvalidVariant)
+}
+
+func (r *OrderRepository) checkIngregients(ctx context.Context, tx *sql.Tx, storeId utils.TEXT, usage map[utils.TEXT]utils.DEC) error {
 	query1 := `
-	SELECT i.quantity>= mii.quantity * $1
-	FROM inventory i
-	JOIN menu_item_ingredients mii USING(ingredient_id)
-	WHERE mii.menu_item_id = $2`
+	SELECT COALESCE((SELECT quantity FROM store_inventory WHERE store_id = $1 AND ingredient_id = $2), 0) >= $3`
 
-	for _, item := range orderItems {
+	for ingredientId, quantity := range usage {
 		var have bool
-		err := tx.QueryRow(query1, item.Quantity, item.MenuItemId).Scan(&have)
-
-		if err != nil || have == false {
-			return fmt.Errorf("Doesn't have inventory for menu item %d: %w", item.MenuItemId, err)
+		err := tx.QueryRowContext(ctx, query1, storeId, ingredientId, quantity).Scan(&have)
+		if err != nil {
+			return fmt.Errorf("failed to check inventory for ingredient %s: %w", ingredientId, err)
+		}
+		if !have {
+			return fmt.Errorf("ingredient %s: %w", ingredientId, models.ErrInsufficientStock)
 		}
 	}
 
 	return nil
 }
 
-func (r *OrderRepository) minusInventory(tx *sql.Tx, orderItems []models.OrderItems) error {
-	for _, item := range orderItems {
-		_, err := tx.Exec(`
-            WITH ingredients AS (
-                SELECT ingredient_id, quantity 
-                FROM menu_item_ingredients 
-                WHERE menu_item_id = $1
-            )
-            UPDATE inventory i
-            SET quantity = i.quantity - (ing.quantity * $2)
-            FROM ingredients ing
-            WHERE i.ingredient_id = ing.ingredient_id`,
-			item.MenuItemId, item.Quantity,
-		)
+func (r *OrderRepository) minusInventory(ctx context.Context, tx *sql.Tx, order *models.Order, usage map[utils.TEXT]utils.DEC) error {
+	for ingredientId, quantity := range usage {
+		err := moveStock(ctx, tx, &models.InventoryTransactions{
+			IngredientId:               ingredientId,
+			StoreId:                    order.StoreId,
+			Quantity:                   -quantity,
+			InventoryTransactionAction: "REMOVE",
+			ReferenceId:                order.OrderId,
+			Notes:                      "Deduction for order " + order.OrderId,
+		})
 		if err != nil {
 			return fmt.Errorf("failed to deduct ingredient from inventory: %w", err)
 		}
@@ -108,8 +253,58 @@ func (r *OrderRepository) minusInventory(tx *sql.Tx, orderItems []models.OrderIt
 	return nil
 }
 
-func (r *OrderRepository) Orders(ctx context.Context) ([]models.Order, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT * FROM orders`)
+// orderColumns lists the orders columns in the order scanOrder reads them.
+const orderColumns = `order_id,customer_id,store_id,special_instructions::text,total_price,order_status,order_payment_method,created_at,updated_at`
+
+func scanOrder(row interface{ Scan(...any) error }, order *models.Order) error {
+	return row.Scan(&order.OrderId, &order.CustomerId, &order.StoreId, &order.SpecialInstructions, &order.TotalPrice, &order.OrderStatus, &order.PaymentMethod, &order.CreatedAt, &order.UpdatedAt)
+}
+
+// orderItems returns the items of an order with the names of their menu
+// items, archived ones included, and the price they were sold at.
+func (r *OrderRepository) orderItems(ctx context.Context, orderId utils.TEXT) ([]models.OrderItems, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT oi.order_item_id, oi.menu_item_id, mi.item_name, COALESCE(oi.variant_id::text,''), COALESCE(v.variant_name,''),
+		oi.order_id, oi.customizations, oi.quantity, oi.unit_price
+	FROM order_items oi
+	JOIN menu_items mi USING(menu_item_id)
+	LEFT JOIN menu_item_variants v USING(variant_id)
+	WHERE oi.order_id = $1`, orderId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order items: %w", err)
+	}
+	defer rows.Close()
+	var items []models.OrderItems
+	for rows.Next() {
+		var item models.OrderItems
+		err = rows.Scan(&item.OrderItemId, &item.MenuItemId, &item.ItemName, &item.VariantId, &item.VariantName, &item.OrderId, &item.Customizations, &item.Quantity, &item.UnitPrice)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order item: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Modifiers, err = orderItemModifiers(ctx, r.db, items[i].OrderItemId)
+		if err != nil {
+			return nil, err
+		}
+		items[i].Components, err = orderItemComponents(ctx, r.db, items[i].OrderItemId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// Orders returns the orders of a store, or of all stores when StoreId is empty.
+func (r *OrderRepository) Orders(ctx context.Context, StoreId string) ([]models.Order, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+orderColumns+`
+	FROM orders
+	WHERE $1 = '' OR store_id::text = $1
+	ORDER BY created_at DESC`, StoreId)
 	if err != nil {
 		return []models.Order{}, err
 	}
@@ -117,67 +312,175 @@ func (r *OrderRepository) Orders(ctx context.Context) ([]models.Order, error) {
 	var orders []models.Order
 	for rows.Next() {
 		var order models.Order
-		err = rows.Scan(&order.OrderId, &order.CustomerId, pq.Array(&order.OrderItems), &order.SpecialInstructions, &order.TotalPrice, &order.OrderStatus, &order.PaymentMethod, &order.CreatedAt, &order.UpdatedAt)
+		err = scanOrder(rows, &order)
 		if err != nil {
 			return nil, err
 		}
 		orders = append(orders, order)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range orders {
+		orders[i].OrderItems, err = r.orderItems(ctx, orders[i].OrderId)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return orders, nil
 }
 
 func (r *OrderRepository) GetOrderByID(ctx context.Context, orderId string) (models.Order, error) {
 	var order models.Order
-	err := r.db.QueryRowContext(ctx, `SELECT * FROM orders WHERE order_id = $1`, orderId).Scan(&order.OrderId, &order.CustomerId, pq.Array(&order.OrderItems), &order.SpecialInstructions, &order.TotalPrice, &order.OrderStatus, &order.PaymentMethod, &order.CreatedAt, &order.UpdatedAt)
+	err := scanOrder(r.db.QueryRowContext(ctx, `SELECT `+orderColumns+` FROM orders WHERE order_id::text = $1`, orderId), &order)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.Order{}, fmt.Errorf("Item not found: %w", err)
 		}
 		return models.Order{}, fmt.Errorf("failed to get Item: %w", err)
 	}
+	order.OrderItems, err = r.orderItems(ctx, order.OrderId)
+	if err != nil {
+		return models.Order{}, err
+	}
 	return order, nil
 }
 
+// UpdateOrderItemByID changes the quantity and customizations of an order
+// item. What was ordered stays as it is: another menu item, variant,
+// modifiers or bundle selections fail with ErrInvalidOrderItemChange. Stock
+// moves by the change in quantity; the item keeps the unit price it was
+// ordered at.
 func (r *OrderRepository) UpdateOrderItemByID(ctx context.Context, orderItems *models.OrderItems) error {
+	if orderItems.Quantity <= 0 {
+		return models.ErrInvalidQuantity
+	}
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
-	res, err := tx.ExecContext(ctx, `
-	UPDATE order_item
-	SET 
-	menu_item_id = $3,
-	customizations = $4,
-	quantity = $5,
-	WHERE order_id = $1 AND order_item_id= $2
-	`, orderItems.OrderId, orderItems.OrderItemId, orderItems.MenuItemId, orderItems.Customizations, orderItems.Quantity)
+
+	current := models.OrderItems{OrderId: orderItems.OrderId, OrderItemId: orderItems.OrderItemId}
+	var storeId utils.TEXT
+	err = tx.QueryRowContext(ctx, `
+	SELECT oi.menu_item_id, COALESCE(oi.variant_id::text, ''), oi.quantity, o.store_id
+	FROM order_items oi
+	JOIN orders o USING(order_id)
+	WHERE oi.order_id::text = $1 AND oi.order_item_id::text = $2
+	FOR UPDATE OF oi`, orderItems.OrderId, orderItems.OrderItemId).Scan(&current.MenuItemId, &current.VariantId, &current.Quantity, &storeId)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("order item not found: %w", err)
+		}
+		return fmt.Errorf("failed to get order item: %w", err)
+	}
+	if current.Modifiers, err = orderItemModifiers(ctx, tx, current.OrderItemId); err != nil {
 		return err
 	}
-	rowsAffected, err := res.RowsAffected()
+	if err := checkOrderItemChange(current, *orderItems); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	UPDATE order_items SET customizations = $2, quantity = $3 WHERE order_item_id = $1`,
+		current.OrderItemId, orderItems.Customizations, orderItems.Quantity)
 	if err != nil {
-		return fmt.Errorf("failed to check rows affected: %w", err)
+		return fmt.Errorf("failed to update order item: %w", err)
 	}
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+
+	if change := orderItems.Quantity - current.Quantity; change != 0 {
+		recipe, err := orderItemRecipe(ctx, tx, current)
+		if err != nil {
+			return err
+		}
+		for _, line := range recipe {
+			t := &models.InventoryTransactions{
+				IngredientId:               line.IngredientId,
+				StoreId:                    storeId,
+				Quantity:                   -line.Quantity * change,
+				InventoryTransactionAction: "REMOVE",
+				ReferenceId:                current.OrderId,
+				Notes:                      "Deduction for order " + current.OrderId,
+			}
+			if change < 0 {
+				t.InventoryTransactionAction = "ADD"
+				t.Notes = "Returned from order " + current.OrderId
+			}
+			if err := moveStock(ctx, tx, t); err != nil {
+				return fmt.Errorf("failed to move stock for order item: %w", err)
+			}
+		}
 	}
 
-	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	return nil
+}
 
+// checkOrderItemChange fails with ErrInvalidOrderItemChange when an update
+// names another menu item, variant or modifiers than the item has, or bundle
+// selections. Fields left out keep what the item has.
+func checkOrderItemChange(current, update models.OrderItems) error {
+	if update.MenuItemId != "" && update.MenuItemId != current.MenuItemId {
+		return fmt.Errorf("menu item %s: %w", update.MenuItemId, models.ErrInvalidOrderItemChange)
+	}
+	if update.VariantId != "" && update.VariantId != current.VariantId {
+		return fmt.Errorf("variant %s: %w", update.VariantId, models.ErrInvalidOrderItemChange)
+	}
+	if len(update.Selections) > 0 {
+		return fmt.Errorf("bundle selections: %w", models.ErrInvalidOrderItemChange)
+	}
+	if len(update.Modifiers) > 0 {
+		chosen := slices.Clone(update.Modifiers)
+		had := slices.Clone(current.Modifiers)
+		slices.Sort(chosen)
+		slices.Sort(had)
+		if !slices.Equal(chosen, had) {
+			return fmt.Errorf("modifiers: %w", models.ErrInvalidOrderItemChange)
+		}
+	}
 	return nil
 }
 
+// orderItemRecipe returns the ingredients one unit of an order item uses as
+// it was ordered: the recipe of its menu item or variant, those of its
+// bundle components and the changes of its modifiers.
+func orderItemRecipe(ctx context.Context, q querier, item models.OrderItems) ([]models.MenuItemsIngredients, error) {
+	recipe, err := itemRecipe(ctx, q, item)
+	if err != nil {
+		return nil, err
+	}
+	components, err := orderItemComponents(ctx, q, item.OrderItemId)
+	if err != nil {
+		return nil, err
+	}
+	if recipe, err = componentsRecipe(ctx, q, recipe, components); err != nil {
+		return nil, err
+	}
+	offered, err := menuModifierGroups(ctx, q, []string{string(item.MenuItemId)})
+	if err != nil {
+		return nil, err
+	}
+	var modifiers []models.Modifier
+	for _, g := range offered[item.MenuItemId] {
+		for _, m := range g.Modifiers {
+			if slices.Contains(item.Modifiers, m.ModifierId) {
+				modifiers = append(modifiers, m)
+			}
+		}
+	}
+	return models.ApplyModifiers(recipe, modifiers), nil
+}
+
 func (r *OrderRepository) DeleteOrderByID(ctx context.Context, orderId string) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
-	res, err := tx.ExecContext(ctx, `DELETE FROM orders WHERE id = $1`, orderId)
+	res, err := tx.ExecContext(ctx, `DELETE FROM orders WHERE order_id = $1`, orderId)
 	if err != nil {
 		return fmt.Errorf("failed to delete Orders: %w", err)
 	}
@@ -205,7 +508,7 @@ func (r *OrderRepository) UpdateStatusOrder(ctx context.Context, orderId string,
 	}
 	defer tx.Rollback()
 	res, err := tx.ExecContext(ctx, `
-	UPDATE TABLE order
+	UPDATE orders
 	SET order_status = $2
 	WHERE order_id = $1
 	`, orderId, status)
@@ -0,0 +1,234 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"frappuccino/models"
+	"frappuccino/utils"
+
+	"github.com/lib/pq"
+)
+
+type PriceAdjustmentRepo interface {
+	Targets(ctx context.Context, adjustment models.PriceAdjustment) ([]models.PriceChange, error)
+	Create(ctx context.Context, adjustment *models.PriceAdjustment) error
+	GetAll(ctx context.Context) ([]models.PriceAdjustment, error)
+	Revert(ctx context.Context, AdjustmentId string) (models.PriceAdjustment, error)
+}
+
+type PriceAdjustmentRepository struct {
+	db *sql.DB
+}
+
+func NewPriceAdjustmentRepository(db *sql.DB) *PriceAdjustmentRepository {
+	return &PriceAdjustmentRepository{db: db}
+}
+
+// Targets returns the prices an adjustment applies to: those of the active
+// menu items it targets, of their variants and of their store overrides.
+func (r *PriceAdjustmentRepository) Targets(ctx context.Context, adjustment models.PriceAdjustment) ([]models.PriceChange, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	WITH targets AS (
+		SELECT menu_item_id, item_name, price
+		FROM menu_items
+		WHERE archived_at IS NULL
+		AND ($1 = '' OR menu_item_id IN (
+			WITH RECURSIVE subtree AS (
+				SELECT category_id FROM categories WHERE category_id::text = $1
+				UNION
+				SELECT c.category_id FROM categories c JOIN subtree s ON c.parent_id = s.category_id
+			)
+			SELECT menu_item_id FROM menu_item_categories JOIN subtree USING(category_id)))
+		AND ($2 = '' OR $2 = ANY(tags))
+		AND (cardinality($3::uuid[]) = 0 OR menu_item_id = ANY($3::uuid[]))
+	)
+	SELECT menu_item_id, item_name, '' AS variant_id, '' AS variant_name, '' AS store_id, '' AS store_name, price
+	FROM targets
+	UNION ALL
+	SELECT t.menu_item_id, t.item_name, v.variant_id::text, v.variant_name, '', '', v.price
+	FROM targets t
+	JOIN menu_item_variants v ON v.menu_item_id = t.menu_item_id AND v.archived_at IS NULL
+	UNION ALL
+	SELECT t.menu_item_id, t.item_name, '', '', s.store_id::text, s.store_name, smi.price
+	FROM targets t
+	JOIN store_menu_items smi ON smi.menu_item_id = t.menu_item_id AND smi.price IS NOT NULL
+	JOIN stores s ON s.store_id = smi.store_id
+	ORDER BY item_name, menu_item_id, store_name, variant_name`, adjustment.CategoryId, adjustment.Tag, pq.Array(adjustment.MenuItemIds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price adjustment targets: %w", err)
+	}
+	defer rows.Close()
+	var changes []models.PriceChange
+	for rows.Next() {
+		var c models.PriceChange
+		if err := rows.Scan(&c.MenuItemId, &c.ItemName, &c.VariantId, &c.VariantName, &c.StoreId, &c.StoreName, &c.OldPrice); err != nil {
+			return nil, fmt.Errorf("failed to scan price adjustment target: %w", err)
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// Create saves the adjustment and applies its changes in one transaction.
+// The price triggers record every change in price_history under the batch.
+func (r *PriceAdjustmentRepository) Create(ctx context.Context, adjustment *models.PriceAdjustment) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO price_adjustments (reason, category_id, tag, menu_item_ids, change_type, change_value, round_to, rounding)
+	VALUES ($1, NULLIF($2, '')::uuid, NULLIF($3, ''), COALESCE($4::uuid[], '{}'), $5, $6, $7, $8)
+	RETURNING adjustment_id, created_at`, adjustment.Reason, adjustment.CategoryId, adjustment.Tag, pq.Array(adjustment.MenuItemIds),
+		adjustment.ChangeType, adjustment.ChangeValue, adjustment.RoundTo, adjustment.Rounding).Scan(&adjustment.AdjustmentId, &adjustment.CreatedAt)
+	if err != nil {
+		switch pqCode(err) {
+		case foreignKeyViolation, invalidTextRepresentation:
+			return fmt.Errorf("category or menu item not found: %w", models.ErrInvalidPriceAdjustment)
+		}
+		return fmt.Errorf("failed to create price adjustment: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT set_config('app.price_adjustment_id', $1, true)`, adjustment.AdjustmentId); err != nil {
+		return fmt.Errorf("failed to tag price history: %w", err)
+	}
+	for _, c := range adjustment.Changes {
+		var err error
+		switch {
+		case c.VariantId != "":
+			_, err = tx.ExecContext(ctx, `
+			UPDATE menu_item_variants SET price = $2 WHERE variant_id = $1`, c.VariantId, c.NewPrice)
+		case c.StoreId != "":
+			_, err = tx.ExecContext(ctx, `
+			UPDATE store_menu_items SET price = $3 WHERE store_id = $1 AND menu_item_id = $2`, c.StoreId, c.MenuItemId, c.NewPrice)
+		default:
+			_, err = tx.ExecContext(ctx, `
+			UPDATE menu_items SET price = $2, updated_at = now() WHERE menu_item_id = $1`, c.MenuItemId, c.NewPrice)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update price of %s: %w", c.ItemName, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetAll lists price adjustments, newest first, with the changes recorded in price_history.
+func (r *PriceAdjustmentRepository) GetAll(ctx context.Context) ([]models.PriceAdjustment, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+priceAdjustmentColumns+` FROM price_adjustments ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price adjustments: %w", err)
+	}
+	defer rows.Close()
+	var adjustments []models.PriceAdjustment
+	for rows.Next() {
+		var a models.PriceAdjustment
+		if err := scanPriceAdjustment(rows, &a); err != nil {
+			return nil, fmt.Errorf("failed to scan price adjustment: %w", err)
+		}
+		adjustments = append(adjustments, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range adjustments {
+		if adjustments[i].Changes, err = priceAdjustmentChanges(ctx, r.db, adjustments[i].AdjustmentId); err != nil {
+			return nil, err
+		}
+	}
+	return adjustments, nil
+}
+
+// Revert restores the previous prices of a batch, of menu items, variants
+// and store overrides alike. Prices that were changed again since are left alone.
+func (r *PriceAdjustmentRepository) Revert(ctx context.Context, AdjustmentId string) (models.PriceAdjustment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.PriceAdjustment{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var adjustment models.PriceAdjustment
+	err = scanPriceAdjustment(tx.QueryRowContext(ctx, `SELECT `+priceAdjustmentColumns+`
+	FROM price_adjustments WHERE adjustment_id = $1 FOR UPDATE`, AdjustmentId), &adjustment)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.PriceAdjustment{}, fmt.Errorf("price adjustment not found: %w", err)
+		}
+		return models.PriceAdjustment{}, fmt.Errorf("failed to lock price adjustment: %w", err)
+	}
+	if adjustment.RevertedAt != nil {
+		return models.PriceAdjustment{}, models.ErrPriceAdjustmentReverted
+	}
+
+	for _, query := range []string{`
+	UPDATE menu_items mi
+	SET price = ph.previous_price, updated_at = now()
+	FROM price_history ph
+	WHERE ph.adjustment_id = $1 AND mi.menu_item_id = ph.menu_item_id
+	AND ph.variant_id IS NULL AND ph.store_id IS NULL
+	AND ph.effective_to IS NULL AND mi.price = ph.price`, `
+	UPDATE menu_item_variants v
+	SET price = ph.previous_price
+	FROM price_history ph
+	WHERE ph.adjustment_id = $1 AND v.variant_id = ph.variant_id
+	AND ph.effective_to IS NULL AND v.price = ph.price`, `
+	UPDATE store_menu_items smi
+	SET price = ph.previous_price
+	FROM price_history ph
+	WHERE ph.adjustment_id = $1 AND smi.store_id = ph.store_id AND smi.menu_item_id = ph.menu_item_id
+	AND ph.effective_to IS NULL AND smi.price = ph.price`} {
+		if _, err := tx.ExecContext(ctx, query, AdjustmentId); err != nil {
+			return models.PriceAdjustment{}, fmt.Errorf("failed to revert prices: %w", err)
+		}
+	}
+	err = tx.QueryRowContext(ctx, `
+	UPDATE price_adjustments SET reverted_at = now() WHERE adjustment_id = $1
+	RETURNING reverted_at`, AdjustmentId).Scan(&adjustment.RevertedAt)
+	if err != nil {
+		return models.PriceAdjustment{}, fmt.Errorf("failed to mark price adjustment reverted: %w", err)
+	}
+	if adjustment.Changes, err = priceAdjustmentChanges(ctx, tx, adjustment.AdjustmentId); err != nil {
+		return models.PriceAdjustment{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.PriceAdjustment{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return adjustment, nil
+}
+
+// priceAdjustmentColumns lists the price_adjustments columns in the order scanPriceAdjustment reads them.
+const priceAdjustmentColumns = `adjustment_id, reason, COALESCE(category_id::text, ''), COALESCE(tag, ''), menu_item_ids::text[],
+	change_type, change_value, round_to, rounding, reverted_at, created_at`
+
+func scanPriceAdjustment(row interface{ Scan(...any) error }, a *models.PriceAdjustment) error {
+	return row.Scan(&a.AdjustmentId, &a.Reason, &a.CategoryId, &a.Tag, pq.Array(&a.MenuItemIds),
+		&a.ChangeType, &a.ChangeValue, &a.RoundTo, &a.Rounding, &a.RevertedAt, &a.CreatedAt)
+}
+
+func priceAdjustmentChanges(ctx context.Context, q querier, AdjustmentId utils.TEXT) ([]models.PriceChange, error) {
+	rows, err := q.QueryContext(ctx, `
+	SELECT ph.menu_item_id, mi.item_name, COALESCE(ph.variant_id::text, ''), COALESCE(v.variant_name, ''),
+		COALESCE(ph.store_id::text, ''), COALESCE(s.store_name, ''), ph.previous_price, ph.price
+	FROM price_history ph
+	JOIN menu_items mi USING(menu_item_id)
+	LEFT JOIN menu_item_variants v ON v.variant_id = ph.variant_id
+	LEFT JOIN stores s ON s.store_id = ph.store_id
+	WHERE ph.adjustment_id = $1
+	ORDER BY mi.item_name, ph.menu_item_id, s.store_name NULLS FIRST, v.variant_name NULLS FIRST`, AdjustmentId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price adjustment changes: %w", err)
+	}
+	defer rows.Close()
+	var changes []models.PriceChange
+	for rows.Next() {
+		var c models.PriceChange
+		if err := rows.Scan(&c.MenuItemId, &c.ItemName, &c.VariantId, &c.VariantName, &c.StoreId, &c.StoreName, &c.OldPrice, &c.NewPrice); err != nil {
+			return nil, fmt.Errorf("failed to scan price adjustment change: %w", err)
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
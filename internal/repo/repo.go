@@ -1,21 +1,73 @@
 package repo
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// pqCode returns the SQLSTATE of a postgres error, or "" for other errors.
+func pqCode(err error) pq.ErrorCode {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code
+	}
+	return ""
+}
+
+// SQLSTATEs the repositories turn into domain errors.
+const (
+	invalidTextRepresentation pq.ErrorCode = "22P02"
+	foreignKeyViolation       pq.ErrorCode = "23503"
+	uniqueViolation           pq.ErrorCode = "23505"
+	checkViolation            pq.ErrorCode = "23514"
+)
 
 type Repository struct {
-	CustomerRepo    CustomerRepo
-	InventoryRepo   InventoryRepo
-	MenuRepo        MenuRepo
-	OrderRepo       OrderRepo
-	AggregationRepo AggregationRepo
+	CustomerRepo        CustomerRepo
+	InventoryRepo       InventoryRepo
+	MenuRepo            MenuRepo
+	OrderRepo           OrderRepo
+	AggregationRepo     AggregationRepo
+	StoreRepo           StoreRepo
+	TransferRepo        TransferRepo
+	ModifierRepo        ModifierRepo
+	ScheduleRepo        ScheduleRepo
+	MenuVersionRepo     MenuVersionRepo
+	PriceAdjustmentRepo PriceAdjustmentRepo
+	CategoryRepo        CategoryRepo
+	TranslationRepo     TranslationRepo
+	SegmentRepo         SegmentRepo
+	AccountRepo         AccountRepo
+	StaffRepo           StaffRepo
 }
 
 func New(db *sql.DB) *Repository {
 	return &Repository{
-		CustomerRepo:    NewCustomerRepository(db),
-		InventoryRepo:   NewInventoryRepository(db),
-		MenuRepo:        NewMenuRepository(db),
-		OrderRepo:       NewOrderRepository(db),
-		AggregationRepo: NewAggregationRepository(db),
+		CustomerRepo:        NewCustomerRepository(db),
+		InventoryRepo:       NewInventoryRepository(db),
+		MenuRepo:            NewMenuRepository(db),
+		OrderRepo:           NewOrderRepository(db),
+		AggregationRepo:     NewAggregationRepository(db),
+		StoreRepo:           NewStoreRepository(db),
+		TransferRepo:        NewTransferRepository(db),
+		ModifierRepo:        NewModifierRepository(db),
+		ScheduleRepo:        NewScheduleRepository(db),
+		MenuVersionRepo:     NewMenuVersionRepository(db),
+		PriceAdjustmentRepo: NewPriceAdjustmentRepository(db),
+		CategoryRepo:        NewCategoryRepository(db),
+		TranslationRepo:     NewTranslationRepository(db),
+		SegmentRepo:         NewSegmentRepository(db),
+		AccountRepo:         NewAccountRepository(db),
+		StaffRepo:           NewStaffRepository(db),
 	}
 }
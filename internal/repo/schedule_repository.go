@@ -0,0 +1,131 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"frappuccino/models"
+	"frappuccino/utils"
+
+	"github.com/lib/pq"
+)
+
+type ScheduleRepo interface {
+	Create(ctx context.Context, schedule *models.MenuSchedule) error
+	GetAll(ctx context.Context, MenuItemId string, CategoryId string) ([]models.MenuSchedule, error)
+	UpdateScheduleByID(ctx context.Context, schedule *models.MenuSchedule) error
+	DeleteScheduleByID(ctx context.Context, ScheduleId string) error
+}
+
+type ScheduleRepository struct {
+	db *sql.DB
+}
+
+func NewScheduleRepository(db *sql.DB) *ScheduleRepository {
+	return &ScheduleRepository{db: db}
+}
+
+func (r *ScheduleRepository) Create(ctx context.Context, schedule *models.MenuSchedule) error {
+	err := r.db.QueryRowContext(ctx, `
+	INSERT INTO menu_schedules (menu_item_id, category_id, start_time, end_time, days_of_week, start_date, end_date)
+	VALUES (NULLIF($1, '')::uuid, NULLIF($2, '')::uuid, NULLIF($3, '')::time, NULLIF($4, '')::time, $5, NULLIF($6, '')::date, NULLIF($7, '')::date)
+	RETURNING schedule_id`,
+		schedule.MenuItemId, schedule.CategoryId, schedule.StartTime, schedule.EndTime,
+		pq.Array(schedule.DaysOfWeek), schedule.StartDate, schedule.EndDate).Scan(&schedule.ScheduleId)
+	if err != nil {
+		return scheduleError("create", err)
+	}
+	return nil
+}
+
+// scheduleError reports an unknown menu item or category as ErrInvalidSchedule.
+func scheduleError(action string, err error) error {
+	switch pqCode(err) {
+	case foreignKeyViolation, invalidTextRepresentation:
+		return fmt.Errorf("menu item or category not found: %w", models.ErrInvalidSchedule)
+	}
+	return fmt.Errorf("failed to %s schedule: %w", action, err)
+}
+
+// GetAll returns the schedules, optionally only those of a menu item or a category.
+func (r *ScheduleRepository) GetAll(ctx context.Context, MenuItemId string, CategoryId string) ([]models.MenuSchedule, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT schedule_id, COALESCE(menu_item_id::text, ''), COALESCE(category_id::text, ''),
+		COALESCE(to_char(start_time, 'HH24:MI'), ''), COALESCE(to_char(end_time, 'HH24:MI'), ''),
+		days_of_week, COALESCE(start_date::text, ''), COALESCE(end_date::text, '')
+	FROM menu_schedules
+	WHERE ($1 = '' OR menu_item_id::text = $1)
+	AND ($2 = '' OR category_id::text = $2)
+	ORDER BY category_id, menu_item_id, start_time`, MenuItemId, CategoryId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schedules: %w", err)
+	}
+	defer rows.Close()
+	var schedules []models.MenuSchedule
+	for rows.Next() {
+		var s models.MenuSchedule
+		err := rows.Scan(&s.ScheduleId, &s.MenuItemId, &s.CategoryId, &s.StartTime, &s.EndTime,
+			pq.Array(&s.DaysOfWeek), &s.StartDate, &s.EndDate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+func (r *ScheduleRepository) UpdateScheduleByID(ctx context.Context, schedule *models.MenuSchedule) error {
+	res, err := r.db.ExecContext(ctx, `
+	UPDATE menu_schedules
+	SET menu_item_id = NULLIF($1, '')::uuid,
+		category_id = NULLIF($2, '')::uuid,
+		start_time = NULLIF($3, '')::time,
+		end_time = NULLIF($4, '')::time,
+		days_of_week = $5,
+		start_date = NULLIF($6, '')::date,
+		end_date = NULLIF($7, '')::date
+	WHERE schedule_id = $8`,
+		schedule.MenuItemId, schedule.CategoryId, schedule.StartTime, schedule.EndTime,
+		pq.Array(schedule.DaysOfWeek), schedule.StartDate, schedule.EndDate, schedule.ScheduleId)
+	if err != nil {
+		return scheduleError("update", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *ScheduleRepository) DeleteScheduleByID(ctx context.Context, ScheduleId string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM menu_schedules WHERE schedule_id = $1`, ScheduleId)
+	if err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// checkOrderable fails with models.ErrOutsideSchedule when a menu item
+// cannot be sold in a store right now.
+func checkOrderable(ctx context.Context, q querier, StoreId, MenuItemId utils.TEXT) error {
+	var orderable bool
+	err := q.QueryRowContext(ctx, `
+	SELECT menu_item_orderable($1, now(), COALESCE((SELECT time_zone FROM stores WHERE store_id::text = $2), 'UTC'))`, MenuItemId, StoreId).Scan(&orderable)
+	if err != nil {
+		return fmt.Errorf("failed to check menu item schedule: %w", err)
+	}
+	if !orderable {
+		return fmt.Errorf("menu item %s: %w", MenuItemId, models.ErrOutsideSchedule)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"frappuccino/models"
+)
+
+type SegmentRepo interface {
+	Create(ctx context.Context, segment *models.SegmentDefinition) error
+	GetAll(ctx context.Context) ([]models.SegmentDefinition, error)
+	UpdateSegmentByID(ctx context.Context, segment *models.SegmentDefinition) error
+	DeleteSegmentByID(ctx context.Context, SegmentId string) error
+}
+
+type SegmentRepository struct {
+	db *sql.DB
+}
+
+func NewSegmentRepository(db *sql.DB) *SegmentRepository {
+	return &SegmentRepository{db: db}
+}
+
+// segmentError turns constraint violations on segments into ErrInvalidSegment.
+func segmentError(action string, err error) error {
+	switch pqCode(err) {
+	case uniqueViolation:
+		return fmt.Errorf("segment name is taken: %w", models.ErrInvalidSegment)
+	case checkViolation:
+		return fmt.Errorf("score ranges must lie within 1 to 5: %w", models.ErrInvalidSegment)
+	}
+	return fmt.Errorf("failed to %s segment: %w", action, err)
+}
+
+func (r *SegmentRepository) Create(ctx context.Context, segment *models.SegmentDefinition) error {
+	err := r.db.QueryRowContext(ctx, `
+	INSERT INTO customer_segments (segment_name, description, min_recency, max_recency,
+		min_frequency, max_frequency, min_monetary, max_monetary, priority)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	RETURNING segment_id, created_at, updated_at`,
+		segment.SegmentName, segment.Description, segment.MinRecency, segment.MaxRecency,
+		segment.MinFrequency, segment.MaxFrequency, segment.MinMonetary, segment.MaxMonetary, segment.Priority).Scan(
+		&segment.SegmentId, &segment.CreatedAt, &segment.UpdatedAt)
+	if err != nil {
+		return segmentError("create", err)
+	}
+	return nil
+}
+
+// GetAll returns the saved segments in the order they are tried.
+func (r *SegmentRepository) GetAll(ctx context.Context) ([]models.SegmentDefinition, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT segment_id, segment_name, description, min_recency, max_recency,
+		min_frequency, max_frequency, min_monetary, max_monetary, priority, created_at, updated_at
+	FROM customer_segments
+	ORDER BY priority, segment_name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query segments: %w", err)
+	}
+	defer rows.Close()
+	segments := []models.SegmentDefinition{}
+	for rows.Next() {
+		var d models.SegmentDefinition
+		err := rows.Scan(&d.SegmentId, &d.SegmentName, &d.Description, &d.MinRecency, &d.MaxRecency,
+			&d.MinFrequency, &d.MaxFrequency, &d.MinMonetary, &d.MaxMonetary, &d.Priority, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan segment: %w", err)
+		}
+		segments = append(segments, d)
+	}
+	return segments, rows.Err()
+}
+
+func (r *SegmentRepository) UpdateSegmentByID(ctx context.Context, segment *models.SegmentDefinition) error {
+	err := r.db.QueryRowContext(ctx, `
+	UPDATE customer_segments
+	SET segment_name = $2, description = $3, min_recency = $4, max_recency = $5,
+		min_frequency = $6, max_frequency = $7, min_monetary = $8, max_monetary = $9, priority = $10
+	WHERE segment_id::text = $1
+	RETURNING created_at, updated_at`,
+		segment.SegmentId, segment.SegmentName, segment.Description, segment.MinRecency, segment.MaxRecency,
+		segment.MinFrequency, segment.MaxFrequency, segment.MinMonetary, segment.MaxMonetary, segment.Priority).Scan(
+		&segment.CreatedAt, &segment.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("segment not found: %w", err)
+		}
+		return segmentError("update", err)
+	}
+	return nil
+}
+
+func (r *SegmentRepository) DeleteSegmentByID(ctx context.Context, SegmentId string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM customer_segments WHERE segment_id::text = $1`, SegmentId)
+	if err != nil {
+		return fmt.Errorf("failed to delete segment: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
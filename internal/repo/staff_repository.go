@@ -0,0 +1,304 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"frappuccino/models"
+	"time"
+)
+
+type StaffRepo interface {
+	Create(ctx context.Context, staff *models.StaffUser, passwordHash string) error
+	GetAll(ctx context.Context, archived bool) ([]models.StaffUser, error)
+	GetStaffByID(ctx context.Context, StaffId string) (models.StaffUser, error)
+	GetByUsername(ctx context.Context, username string) (models.StaffUser, string, error)
+	UpdateStaffByID(ctx context.Context, staff *models.StaffUser) error
+	PasswordHash(ctx context.Context, StaffId string) (string, error)
+	SetPassword(ctx context.Context, StaffId string, passwordHash string) error
+	FailLogin(ctx context.Context, StaffId string, maxAttempts int, lockout time.Duration) error
+	ResetFailedLogins(ctx context.Context, StaffId string) error
+	DeleteStaffByID(ctx context.Context, StaffId string) error
+	Count(ctx context.Context) (int, error)
+	RecordDenial(ctx context.Context, denial *models.AccessDenial) error
+	Denials(ctx context.Context, limit int) ([]models.AccessDenial, error)
+}
+
+type StaffRepository struct {
+	db *sql.DB
+}
+
+func NewStaffRepository(db *sql.DB) *StaffRepository {
+	return &StaffRepository{db: db}
+}
+
+// staffError turns constraint violations on staff users into ErrInvalidStaff.
+func staffError(action string, err error) error {
+	switch pqCode(err) {
+	case uniqueViolation:
+		return fmt.Errorf("username is taken: %w", models.ErrInvalidStaff)
+	case foreignKeyViolation, invalidTextRepresentation, checkViolation:
+		return fmt.Errorf("%v: %w", err, models.ErrInvalidStaff)
+	}
+	return fmt.Errorf("failed to %s staff user: %w", action, err)
+}
+
+func (r *StaffRepository) Create(ctx context.Context, staff *models.StaffUser, passwordHash string) error {
+	err := r.db.QueryRowContext(ctx, `
+	INSERT INTO staff_users (username, full_name, staff_role, store_id, password_hash)
+	VALUES ($1, $2, $3, NULLIF($4, '')::uuid, $5)
+	RETURNING staff_id, created_at, updated_at`,
+		staff.Username, staff.FullName, staff.Role, staff.StoreId, passwordHash).Scan(&staff.StaffId, &staff.CreatedAt, &staff.UpdatedAt)
+	if err != nil {
+		return staffError("create", err)
+	}
+	return nil
+}
+
+// staffQuery selects staff users, archived ones included.
+const staffQuery = `
+	SELECT staff_id, username, full_name, staff_role, COALESCE(store_id::text, ''), locked_until, password_changed_at,
+		archived_at, created_at, updated_at, password_hash
+	FROM staff_users`
+
+func scanStaff(row interface{ Scan(...any) error }, staff *models.StaffUser, passwordHash *string) error {
+	return row.Scan(&staff.StaffId, &staff.Username, &staff.FullName, &staff.Role, &staff.StoreId,
+		&staff.LockedUntil, &staff.PasswordChangedAt, &staff.ArchivedAt, &staff.CreatedAt, &staff.UpdatedAt, passwordHash)
+}
+
+// GetAll returns the active staff users, or the archived ones.
+func (r *StaffRepository) GetAll(ctx context.Context, archived bool) ([]models.StaffUser, error) {
+	rows, err := r.db.QueryContext(ctx, staffQuery+`
+	WHERE (archived_at IS NOT NULL) = $1
+	ORDER BY username`, archived)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query staff users: %w", err)
+	}
+	defer rows.Close()
+	users := []models.StaffUser{}
+	for rows.Next() {
+		var staff models.StaffUser
+		var hash string
+		if err := scanStaff(rows, &staff, &hash); err != nil {
+			return nil, fmt.Errorf("failed to scan staff user: %w", err)
+		}
+		users = append(users, staff)
+	}
+	return users, rows.Err()
+}
+
+func (r *StaffRepository) GetStaffByID(ctx context.Context, StaffId string) (models.StaffUser, error) {
+	var staff models.StaffUser
+	var hash string
+	err := scanStaff(r.db.QueryRowContext(ctx, staffQuery+`
+	WHERE staff_id::text = $1`, StaffId), &staff, &hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.StaffUser{}, fmt.Errorf("staff user not found: %w", err)
+		}
+		return models.StaffUser{}, fmt.Errorf("failed to get staff user: %w", err)
+	}
+	return staff, nil
+}
+
+// GetByUsername returns an active staff user and their password hash.
+func (r *StaffRepository) GetByUsername(ctx context.Context, username string) (models.StaffUser, string, error) {
+	var staff models.StaffUser
+	var hash string
+	err := scanStaff(r.db.QueryRowContext(ctx, staffQuery+`
+	WHERE username = $1 AND archived_at IS NULL`, username), &staff, &hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.StaffUser{}, "", fmt.Errorf("staff user not found: %w", err)
+		}
+		return models.StaffUser{}, "", fmt.Errorf("failed to get staff user: %w", err)
+	}
+	return staff, hash, nil
+}
+
+// UpdateStaffByID changes the name, role and store of an active staff user.
+// Demoting the last active admin fails with ErrLastAdmin.
+func (r *StaffRepository) UpdateStaffByID(ctx context.Context, staff *models.StaffUser) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, `
+	UPDATE staff_users
+	SET full_name = $2, staff_role = $3, store_id = NULLIF($4, '')::uuid
+	WHERE staff_id::text = $1 AND archived_at IS NULL`, staff.StaffId, staff.FullName, staff.Role, staff.StoreId)
+	if err != nil {
+		return staffError("update", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	if err := checkAdminLeft(ctx, tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// checkAdminLeft fails with ErrLastAdmin when no active admin is left.
+func checkAdminLeft(ctx context.Context, tx *sql.Tx) error {
+	// lock the admins so that two concurrent demotions cannot both pass
+	rows, err := tx.QueryContext(ctx, `
+	SELECT staff_id FROM staff_users WHERE staff_role = 'ADMIN' AND archived_at IS NULL FOR UPDATE`)
+	if err != nil {
+		return fmt.Errorf("failed to lock admins: %w", err)
+	}
+	admins := 0
+	for rows.Next() {
+		admins++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if admins == 0 {
+		return models.ErrLastAdmin
+	}
+	return nil
+}
+
+// PasswordHash returns the password hash of an active staff user.
+func (r *StaffRepository) PasswordHash(ctx context.Context, StaffId string) (string, error) {
+	var hash string
+	err := r.db.QueryRowContext(ctx, `
+	SELECT password_hash FROM staff_users WHERE staff_id::text = $1 AND archived_at IS NULL`, StaffId).Scan(&hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("staff user not found: %w", err)
+		}
+		return "", fmt.Errorf("failed to get staff password: %w", err)
+	}
+	return hash, nil
+}
+
+// SetPassword replaces the password of an active staff user, which ends
+// their tokens and lifts a login lock.
+func (r *StaffRepository) SetPassword(ctx context.Context, StaffId string, passwordHash string) error {
+	res, err := r.db.ExecContext(ctx, `
+	UPDATE staff_users SET password_hash = $2, password_changed_at = now(), failed_logins = 0, locked_until = NULL
+	WHERE staff_id::text = $1 AND archived_at IS NULL`, StaffId, passwordHash)
+	if err != nil {
+		return fmt.Errorf("failed to set staff password: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// FailLogin counts a wrong password of a staff user. The maxAttempts-th in
+// a row locks their logins for lockout and starts the count again.
+func (r *StaffRepository) FailLogin(ctx context.Context, StaffId string, maxAttempts int, lockout time.Duration) error {
+	_, err := r.db.ExecContext(ctx, `
+	UPDATE staff_users SET
+		failed_logins = CASE WHEN failed_logins + 1 >= $2 THEN 0 ELSE failed_logins + 1 END,
+		locked_until = CASE WHEN failed_logins + 1 >= $2 THEN now() + make_interval(secs => $3) ELSE locked_until END
+	WHERE staff_id::text = $1`, StaffId, maxAttempts, lockout.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to count failed login: %w", err)
+	}
+	return nil
+}
+
+// ResetFailedLogins starts the count of wrong passwords of a staff user again.
+func (r *StaffRepository) ResetFailedLogins(ctx context.Context, StaffId string) error {
+	_, err := r.db.ExecContext(ctx, `
+	UPDATE staff_users SET failed_logins = 0
+	WHERE staff_id::text = $1 AND failed_logins > 0`, StaffId)
+	if err != nil {
+		return fmt.Errorf("failed to reset failed logins: %w", err)
+	}
+	return nil
+}
+
+// DeleteStaffByID archives a staff user, who can no longer log in. Archiving
+// the last active admin fails with ErrLastAdmin.
+func (r *StaffRepository) DeleteStaffByID(ctx context.Context, StaffId string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, `
+	UPDATE staff_users SET archived_at = now() WHERE staff_id::text = $1 AND archived_at IS NULL`, StaffId)
+	if err != nil {
+		return fmt.Errorf("failed to archive staff user: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	if err := checkAdminLeft(ctx, tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Count returns how many staff users there are, archived ones included.
+func (r *StaffRepository) Count(ctx context.Context) (int, error) {
+	var n int
+	if err := r.db.QueryRowContext(ctx, `SELECT count(*) FROM staff_users`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count staff users: %w", err)
+	}
+	return n, nil
+}
+
+func (r *StaffRepository) RecordDenial(ctx context.Context, denial *models.AccessDenial) error {
+	err := r.db.QueryRowContext(ctx, `
+	INSERT INTO access_denials (staff_id, method, path, permission, reason, remote_addr)
+	VALUES (NULLIF($1, '')::uuid, $2, $3, $4, $5, $6)
+	RETURNING denial_id, created_at`, denial.StaffId, denial.Method, denial.Path, denial.Permission,
+		denial.Reason, denial.RemoteAddr).Scan(&denial.DenialId, &denial.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record access denial: %w", err)
+	}
+	return nil
+}
+
+// Denials returns the latest denied requests, newest first.
+func (r *StaffRepository) Denials(ctx context.Context, limit int) ([]models.AccessDenial, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT d.denial_id, COALESCE(d.staff_id::text, ''), COALESCE(s.username, ''), d.method, d.path,
+		d.permission, d.reason, d.remote_addr, d.created_at
+	FROM access_denials d
+	LEFT JOIN staff_users s ON s.staff_id = d.staff_id
+	ORDER BY d.created_at DESC
+	LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query access denials: %w", err)
+	}
+	defer rows.Close()
+	denials := []models.AccessDenial{}
+	for rows.Next() {
+		var d models.AccessDenial
+		err := rows.Scan(&d.DenialId, &d.StaffId, &d.Username, &d.Method, &d.Path, &d.Permission, &d.Reason, &d.RemoteAddr, &d.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan access denial: %w", err)
+		}
+		denials = append(denials, d)
+	}
+	return denials, rows.Err()
+}
@@ -0,0 +1,142 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"frappuccino/models"
+)
+
+type StoreRepo interface {
+	Create(ctx context.Context, store *models.Store) error
+	GetAll(ctx context.Context) ([]models.Store, error)
+	GetStoreByID(ctx context.Context, StoreId string) (models.Store, error)
+	UpdateStoreByID(ctx context.Context, store *models.Store) error
+	DeleteStoreByID(ctx context.Context, StoreId string) error
+	SetMenuItem(ctx context.Context, item *models.StoreMenuItem) error
+	DeleteMenuItem(ctx context.Context, StoreId string, MenuItemId string) error
+}
+
+type StoreRepository struct {
+	db *sql.DB
+}
+
+func NewStoreRepository(db *sql.DB) *StoreRepository {
+	return &StoreRepository{db: db}
+}
+
+func (r *StoreRepository) Create(ctx context.Context, store *models.Store) error {
+	err := r.db.QueryRowContext(ctx,
+		`INSERT INTO stores (store_name,address,time_zone)
+	     VALUES ($1,$2,$3)
+		 RETURNING store_id,created_at,updated_at`, store.StoreName, store.Address, store.TimeZone).Scan(&store.StoreId, &store.CreatedAt, &store.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create store: %w", err)
+	}
+	return nil
+}
+
+func (r *StoreRepository) GetAll(ctx context.Context) ([]models.Store, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT store_id,store_name,address,time_zone,created_at,updated_at
+		FROM stores
+		ORDER BY store_name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stores: %w", err)
+	}
+	defer rows.Close()
+	var stores []models.Store
+	for rows.Next() {
+		var store models.Store
+		err := rows.Scan(&store.StoreId, &store.StoreName, &store.Address, &store.TimeZone, &store.CreatedAt, &store.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan store: %w", err)
+		}
+		stores = append(stores, store)
+	}
+	return stores, nil
+}
+
+func (r *StoreRepository) GetStoreByID(ctx context.Context, StoreId string) (models.Store, error) {
+	var store models.Store
+	err := r.db.QueryRowContext(ctx, `
+		SELECT store_id,store_name,address,time_zone,created_at,updated_at
+		FROM stores WHERE store_id = $1`, StoreId).Scan(&store.StoreId, &store.StoreName, &store.Address, &store.TimeZone, &store.CreatedAt, &store.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Store{}, fmt.Errorf("store not found: %w", err)
+		}
+		return models.Store{}, fmt.Errorf("failed to get store: %w", err)
+	}
+	return store, nil
+}
+
+// UpdateStoreByID keeps the time zone of the store when none is given.
+func (r *StoreRepository) UpdateStoreByID(ctx context.Context, store *models.Store) error {
+	res, err := r.db.ExecContext(ctx, `
+	UPDATE stores
+	SET
+		store_name = $1,
+		address = $2,
+		time_zone = COALESCE(NULLIF($4, ''), time_zone)
+	WHERE store_id = $3
+	`, store.StoreName, store.Address, store.StoreId, store.TimeZone)
+	if err != nil {
+		return fmt.Errorf("failed to update store: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *StoreRepository) DeleteStoreByID(ctx context.Context, StoreId string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM stores WHERE store_id = $1`, StoreId)
+	if err != nil {
+		return fmt.Errorf("failed to delete store: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetMenuItem creates or replaces the store override of a menu item.
+func (r *StoreRepository) SetMenuItem(ctx context.Context, item *models.StoreMenuItem) error {
+	_, err := r.db.ExecContext(ctx, `
+	INSERT INTO store_menu_items (store_id,menu_item_id,price,is_available)
+	VALUES ($1,$2,$3,$4)
+	ON CONFLICT (store_id,menu_item_id) DO UPDATE
+	SET price = EXCLUDED.price,
+		is_available = EXCLUDED.is_available
+	`, item.StoreId, item.MenuItemId, item.Price, item.IsAvailable)
+	if err != nil {
+		return fmt.Errorf("failed to set store menu item: %w", err)
+	}
+	return nil
+}
+
+func (r *StoreRepository) DeleteMenuItem(ctx context.Context, StoreId string, MenuItemId string) error {
+	res, err := r.db.ExecContext(ctx, `
+	DELETE FROM store_menu_items WHERE store_id = $1 AND menu_item_id = $2`, StoreId, MenuItemId)
+	if err != nil {
+		return fmt.Errorf("failed to delete store menu item: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,331 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"frappuccino/models"
+	"frappuccino/utils"
+)
+
+type TransferRepo interface {
+	Create(ctx context.Context, transfer *models.Transfer) error
+	GetAll(ctx context.Context, StoreId string, status string) ([]models.Transfer, error)
+	GetTransferByID(ctx context.Context, TransferId string) (models.Transfer, error)
+	Ship(ctx context.Context, TransferId string, items []models.TransferItem) error
+	Receive(ctx context.Context, TransferId string, items []models.TransferItem) error
+	Cancel(ctx context.Context, TransferId string) error
+	InTransit(ctx context.Context, StoreId string) ([]models.InTransitStock, error)
+}
+
+type TransferRepository struct {
+	db *sql.DB
+}
+
+func NewTransferRepository(db *sql.DB) *TransferRepository {
+	return &TransferRepository{db: db}
+}
+
+const transferColumns = `transfer_id,from_store_id,to_store_id,transfer_status,notes,shipped_at,received_at,created_at,updated_at`
+
+func scanTransfer(row interface{ Scan(...any) error }, t *models.Transfer) error {
+	return row.Scan(&t.TransferId, &t.FromStoreId, &t.ToStoreId, &t.TransferStatus, &t.Notes, &t.ShippedAt, &t.ReceivedAt, &t.CreatedAt, &t.UpdatedAt)
+}
+
+func (r *TransferRepository) Create(ctx context.Context, transfer *models.Transfer) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+	INSERT INTO stock_transfers (from_store_id,to_store_id,notes)
+	VALUES ($1,$2,$3)
+	RETURNING transfer_id,transfer_status,created_at,updated_at`,
+		transfer.FromStoreId, transfer.ToStoreId, transfer.Notes).Scan(&transfer.TransferId, &transfer.TransferStatus, &transfer.CreatedAt, &transfer.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create transfer: %w", err)
+	}
+
+	for i := range transfer.Items {
+		item := &transfer.Items[i]
+		err = tx.QueryRowContext(ctx, `
+		INSERT INTO stock_transfer_items (transfer_id,ingredient_id,requested_quantity)
+		VALUES ($1,$2,$3)
+		RETURNING transfer_item_id`, transfer.TransferId, item.IngredientId, item.RequestedQuantity).Scan(&item.TransferItemId)
+		if err != nil {
+			return fmt.Errorf("failed to add transfer item: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetAll returns transfers from or to a store (all stores when StoreId is empty),
+// optionally filtered by status.
+func (r *TransferRepository) GetAll(ctx context.Context, StoreId string, status string) ([]models.Transfer, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+transferColumns+`
+	FROM stock_transfers
+	WHERE ($1 = '' OR from_store_id::text = $1 OR to_store_id::text = $1)
+	AND ($2 = '' OR transfer_status::text = $2)
+	ORDER BY created_at DESC`, StoreId, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transfers: %w", err)
+	}
+	defer rows.Close()
+	var transfers []models.Transfer
+	for rows.Next() {
+		var transfer models.Transfer
+		if err := scanTransfer(rows, &transfer); err != nil {
+			return nil, fmt.Errorf("failed to scan transfer: %w", err)
+		}
+		transfers = append(transfers, transfer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range transfers {
+		transfers[i].Items, err = transferItems(ctx, r.db, transfers[i].TransferId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return transfers, nil
+}
+
+func (r *TransferRepository) GetTransferByID(ctx context.Context, TransferId string) (models.Transfer, error) {
+	var transfer models.Transfer
+	err := scanTransfer(r.db.QueryRowContext(ctx, `SELECT `+transferColumns+` FROM stock_transfers WHERE transfer_id = $1`, TransferId), &transfer)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Transfer{}, fmt.Errorf("transfer not found: %w", err)
+		}
+		return models.Transfer{}, fmt.Errorf("failed to get transfer: %w", err)
+	}
+	transfer.Items, err = transferItems(ctx, r.db, transfer.TransferId)
+	if err != nil {
+		return models.Transfer{}, err
+	}
+	return transfer, nil
+}
+
+// Ship takes the shipped quantities out of the source store. Items missing
+// from the request ship their requested quantity; items of ingredients the
+// transfer does not have fail with ErrInvalidTransfer.
+func (r *TransferRepository) Ship(ctx context.Context, TransferId string, items []models.TransferItem) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	transfer, err := lockTransfer(ctx, tx, TransferId, models.TransferStatusRequested)
+	if err != nil {
+		return err
+	}
+	shipped, err := transferItemsByIngredient(transfer, items)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range transfer.Items {
+		quantity := item.RequestedQuantity
+		if s, ok := shipped[item.IngredientId]; ok && s.ShippedQuantity != nil {
+			quantity = *s.ShippedQuantity
+		}
+		_, err = tx.ExecContext(ctx, `
+		UPDATE stock_transfer_items SET shipped_quantity = $2 WHERE transfer_item_id = $1`, item.TransferItemId, quantity)
+		if err != nil {
+			return fmt.Errorf("failed to ship transfer item: %w", err)
+		}
+		if quantity == 0 {
+			continue
+		}
+		err = moveStock(ctx, tx, &models.InventoryTransactions{
+			IngredientId:               item.IngredientId,
+			StoreId:                    transfer.FromStoreId,
+			Quantity:                   -quantity,
+			InventoryTransactionAction: "REMOVE",
+			ReferenceId:                transfer.TransferId,
+			Notes:                      "Shipped to store " + transfer.ToStoreId + " by transfer " + transfer.TransferId,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	UPDATE stock_transfers SET transfer_status = 'SHIPPED', shipped_at = now() WHERE transfer_id = $1`, TransferId)
+	if err != nil {
+		return fmt.Errorf("failed to ship transfer: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Receive adds the received quantities to the destination store and records
+// any difference to the shipped quantity. Items missing from the request are
+// received as shipped; items of ingredients the transfer does not have fail
+// with ErrInvalidTransfer.
+func (r *TransferRepository) Receive(ctx context.Context, TransferId string, items []models.TransferItem) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	transfer, err := lockTransfer(ctx, tx, TransferId, models.TransferStatusShipped)
+	if err != nil {
+		return err
+	}
+	received, err := transferItemsByIngredient(transfer, items)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range transfer.Items {
+		var quantity utils.DEC
+		if item.ShippedQuantity != nil {
+			quantity = *item.ShippedQuantity
+		}
+		notes := item.DiscrepancyNotes
+		if rcv, ok := received[item.IngredientId]; ok {
+			if rcv.ReceivedQuantity != nil {
+				quantity = *rcv.ReceivedQuantity
+			}
+			notes = rcv.DiscrepancyNotes
+		}
+		_, err = tx.ExecContext(ctx, `
+		UPDATE stock_transfer_items
+		SET received_quantity = $2, discrepancy_notes = $3
+		WHERE transfer_item_id = $1`, item.TransferItemId, quantity, notes)
+		if err != nil {
+			return fmt.Errorf("failed to receive transfer item: %w", err)
+		}
+		if quantity == 0 {
+			continue
+		}
+		err = moveStock(ctx, tx, &models.InventoryTransactions{
+			IngredientId:               item.IngredientId,
+			StoreId:                    transfer.ToStoreId,
+			Quantity:                   quantity,
+			InventoryTransactionAction: "ADD",
+			ReferenceId:                transfer.TransferId,
+			Notes:                      "Received from store " + transfer.FromStoreId + " by transfer " + transfer.TransferId,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	UPDATE stock_transfers SET transfer_status = 'RECEIVED', received_at = now() WHERE transfer_id = $1`, TransferId)
+	if err != nil {
+		return fmt.Errorf("failed to receive transfer: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Cancel cancels a transfer that has not been shipped yet.
+func (r *TransferRepository) Cancel(ctx context.Context, TransferId string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := lockTransfer(ctx, tx, TransferId, models.TransferStatusRequested); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+	UPDATE stock_transfers SET transfer_status = 'CANCELLED' WHERE transfer_id = $1`, TransferId)
+	if err != nil {
+		return fmt.Errorf("failed to cancel transfer: %w", err)
+	}
+	return tx.Commit()
+}
+
+// InTransit sums shipped but not yet received quantities per destination store.
+func (r *TransferRepository) InTransit(ctx context.Context, StoreId string) ([]models.InTransitStock, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT t.to_store_id, ti.ingredient_id, i.ingredient_name, SUM(ti.shipped_quantity)
+	FROM stock_transfers t
+	JOIN stock_transfer_items ti USING(transfer_id)
+	JOIN inventory i USING(ingredient_id)
+	WHERE t.transfer_status = 'SHIPPED'
+	AND ($1 = '' OR t.to_store_id::text = $1)
+	GROUP BY t.to_store_id, ti.ingredient_id, i.ingredient_name
+	ORDER BY t.to_store_id, i.ingredient_name`, StoreId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stock in transit: %w", err)
+	}
+	defer rows.Close()
+	var stock []models.InTransitStock
+	for rows.Next() {
+		var s models.InTransitStock
+		if err := rows.Scan(&s.StoreId, &s.IngredientId, &s.IngredientName, &s.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan stock in transit: %w", err)
+		}
+		stock = append(stock, s)
+	}
+	return stock, rows.Err()
+}
+
+// transferItemsByIngredient maps the items of a ship or receive request to
+// their ingredient, checking that each is one of the transfer.
+func transferItemsByIngredient(transfer models.Transfer, items []models.TransferItem) (map[utils.TEXT]models.TransferItem, error) {
+	onTransfer := make(map[utils.TEXT]bool, len(transfer.Items))
+	for _, item := range transfer.Items {
+		onTransfer[item.IngredientId] = true
+	}
+	byIngredient := make(map[utils.TEXT]models.TransferItem, len(items))
+	for _, item := range items {
+		if !onTransfer[item.IngredientId] {
+			return nil, fmt.Errorf("ingredient %s is not on the transfer: %w", item.IngredientId, models.ErrInvalidTransfer)
+		}
+		byIngredient[item.IngredientId] = item
+	}
+	return byIngredient, nil
+}
+
+// lockTransfer locks the transfer for update and checks it is in the expected status.
+func lockTransfer(ctx context.Context, tx *sql.Tx, TransferId string, status string) (models.Transfer, error) {
+	var transfer models.Transfer
+	err := scanTransfer(tx.QueryRowContext(ctx, `SELECT `+transferColumns+` FROM stock_transfers WHERE transfer_id = $1 FOR UPDATE`, TransferId), &transfer)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Transfer{}, fmt.Errorf("transfer not found: %w", err)
+		}
+		return models.Transfer{}, fmt.Errorf("failed to get transfer: %w", err)
+	}
+	if string(transfer.TransferStatus) != status {
+		return models.Transfer{}, fmt.Errorf("transfer is %s: %w", transfer.TransferStatus, models.ErrTransferStatus)
+	}
+	transfer.Items, err = transferItems(ctx, tx, transfer.TransferId)
+	if err != nil {
+		return models.Transfer{}, err
+	}
+	return transfer, nil
+}
+
+func transferItems(ctx context.Context, q querier, TransferId utils.TEXT) ([]models.TransferItem, error) {
+	rows, err := q.QueryContext(ctx, `
+	SELECT transfer_item_id,ingredient_id,requested_quantity,shipped_quantity,received_quantity,discrepancy,discrepancy_notes
+	FROM stock_transfer_items
+	WHERE transfer_id = $1`, TransferId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transfer items: %w", err)
+	}
+	defer rows.Close()
+	var items []models.TransferItem
+	for rows.Next() {
+		var item models.TransferItem
+		err := rows.Scan(&item.TransferItemId, &item.IngredientId, &item.RequestedQuantity, &item.ShippedQuantity, &item.ReceivedQuantity, &item.Discrepancy, &item.DiscrepancyNotes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transfer item: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,155 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"frappuccino/models"
+	"frappuccino/utils"
+
+	"github.com/lib/pq"
+)
+
+type TranslationRepo interface {
+	Upsert(ctx context.Context, translation *models.Translation) error
+	GetAll(ctx context.Context, locales []string) ([]models.Translation, error)
+	Delete(ctx context.Context, TranslationId string) error
+	Missing(ctx context.Context, locales []string) ([]models.MissingTranslations, error)
+}
+
+type TranslationRepository struct {
+	db *sql.DB
+}
+
+func NewTranslationRepository(db *sql.DB) *TranslationRepository {
+	return &TranslationRepository{db: db}
+}
+
+// Upsert saves the translation of its target in its locale, replacing the
+// one it already has.
+func (r *TranslationRepository) Upsert(ctx context.Context, translation *models.Translation) error {
+	var target string
+	switch {
+	case translation.MenuItemId != "":
+		target = "menu_item_id"
+	case translation.CategoryId != "":
+		target = "category_id"
+	case translation.ModifierGroupId != "":
+		target = "modifier_group_id"
+	default:
+		target = "modifier_id"
+	}
+	err := r.db.QueryRowContext(ctx, `
+	INSERT INTO translations (locale, menu_item_id, category_id, modifier_group_id, modifier_id, name, description)
+	VALUES ($1, NULLIF($2, '')::uuid, NULLIF($3, '')::uuid, NULLIF($4, '')::uuid, NULLIF($5, '')::uuid, $6, $7)
+	ON CONFLICT (`+target+`, locale) WHERE `+target+` IS NOT NULL DO UPDATE
+	SET name = EXCLUDED.name, description = EXCLUDED.description
+	RETURNING translation_id, created_at, updated_at`,
+		translation.Locale, translation.MenuItemId, translation.CategoryId, translation.ModifierGroupId, translation.ModifierId,
+		translation.Name, translation.Description).Scan(&translation.TranslationId, &translation.CreatedAt, &translation.UpdatedAt)
+	if err != nil {
+		switch pqCode(err) {
+		case foreignKeyViolation, invalidTextRepresentation:
+			return fmt.Errorf("translated %s not found: %w", target, models.ErrInvalidTranslation)
+		}
+		return fmt.Errorf("failed to save translation: %w", err)
+	}
+	return nil
+}
+
+// GetAll returns the translations in the given locales, or in every locale
+// when none are given.
+func (r *TranslationRepository) GetAll(ctx context.Context, locales []string) ([]models.Translation, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT translation_id, locale, COALESCE(menu_item_id::text, ''), COALESCE(category_id::text, ''),
+		COALESCE(modifier_group_id::text, ''), COALESCE(modifier_id::text, ''), name, description, created_at, updated_at
+	FROM translations
+	WHERE cardinality($1::text[]) = 0 OR locale = ANY($1::text[])
+	ORDER BY locale, name`, pq.Array(locales))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query translations: %w", err)
+	}
+	defer rows.Close()
+	translations := []models.Translation{}
+	for rows.Next() {
+		var t models.Translation
+		err := rows.Scan(&t.TranslationId, &t.Locale, &t.MenuItemId, &t.CategoryId, &t.ModifierGroupId, &t.ModifierId,
+			&t.Name, &t.Description, &t.CreatedAt, &t.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan translation: %w", err)
+		}
+		translations = append(translations, t)
+	}
+	return translations, rows.Err()
+}
+
+func (r *TranslationRepository) Delete(ctx context.Context, TranslationId string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM translations WHERE translation_id::text = $1`, TranslationId)
+	if err != nil {
+		return fmt.Errorf("failed to delete translation: %w", err)
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Missing lists per locale the menu items, categories, modifier groups and
+// modifiers without a translation. Without locales it checks every locale
+// that has translations. Archived menu items are left out.
+func (r *TranslationRepository) Missing(ctx context.Context, locales []string) ([]models.MissingTranslations, error) {
+	rows, err := r.db.QueryContext(ctx, `
+	WITH locales AS (
+		SELECT unnest($1::text[]) AS locale
+		UNION
+		SELECT locale FROM translations WHERE cardinality($1::text[]) = 0
+	),
+	targets AS (
+		SELECT 'menu_item' AS type, menu_item_id AS id, item_name::text AS name FROM menu_items WHERE archived_at IS NULL
+		UNION ALL
+		SELECT 'category', category_id, category_name FROM categories
+		UNION ALL
+		SELECT 'modifier_group', modifier_group_id, group_name FROM modifier_groups
+		UNION ALL
+		SELECT 'modifier', modifier_id, modifier_name FROM modifiers
+	)
+	SELECT l.locale, COALESCE(m.type, ''), COALESCE(m.id::text, ''), COALESCE(m.name, '')
+	FROM locales l
+	LEFT JOIN LATERAL (
+		SELECT t.* FROM targets t
+		WHERE NOT EXISTS (
+			SELECT 1 FROM translations tr
+			WHERE tr.locale = l.locale
+			AND t.id IN (tr.menu_item_id, tr.category_id, tr.modifier_group_id, tr.modifier_id)
+		)
+	) m ON TRUE
+	ORDER BY l.locale, m.type, m.name`, pq.Array(locales))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query missing translations: %w", err)
+	}
+	defer rows.Close()
+	report := []models.MissingTranslations{}
+	for rows.Next() {
+		var locale utils.TEXT
+		var target models.UntranslatedTarget
+		if err := rows.Scan(&locale, &target.Type, &target.Id, &target.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan missing translation: %w", err)
+		}
+		if len(report) == 0 || report[len(report)-1].Locale != locale {
+			report = append(report, models.MissingTranslations{Locale: locale, Missing: []models.UntranslatedTarget{}})
+		}
+		if target.Id != "" {
+			last := &report[len(report)-1]
+			last.Missing = append(last.Missing, target)
+			last.Count++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"frappuccino/internal/auth"
+	"frappuccino/internal/repo"
+	"frappuccino/models"
+	"frappuccino/utils"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type AccountServiceInf interface {
+	Register(ctx context.Context, registration *models.AccountRegistration) (models.CustomerSession, error)
+	Login(ctx context.Context, login models.Login) (models.CustomerSession, error)
+	SendLoginCode(ctx context.Context, login models.Login) error
+	LoginWithCode(ctx context.Context, login models.Login) (models.CustomerSession, error)
+	Authenticate(ctx context.Context, token string) (string, error)
+	Logout(ctx context.Context, token string) error
+	SetPassword(ctx context.Context, CustomerId string, token string, change models.PasswordChange) error
+}
+
+// Customer session and login code lifetimes.
+const (
+	sessionTTL        = 30 * 24 * time.Hour
+	loginCodeTTL      = 15 * time.Minute
+	loginCodeInterval = time.Minute
+	loginCodeAttempts = 5
+	loginAttempts     = 5
+	loginLockout      = 15 * time.Minute
+)
+
+// dummyPasswordHash is checked against when a customer has no password, so
+// that failed logins take as long whether or not the account exists.
+var dummyPasswordHash, _ = auth.HashPassword("frappuccino")
+
+type AccountService struct {
+	accountRepo repo.AccountRepo
+	sender      auth.CodeSender
+}
+
+func NewAccountService(accountRepo repo.AccountRepo, sender auth.CodeSender) *AccountService {
+	return &AccountService{accountRepo: accountRepo, sender: sender}
+}
+
+// Register opens an account for a new customer and logs them in. Existing
+// customers, such as those added by staff, log in with a code instead and
+// may then set a password.
+func (s *AccountService) Register(ctx context.Context, registration *models.AccountRegistration) (models.CustomerSession, error) {
+	err := normalizeContact(&registration.Customer)
+	var invalid *models.ValidationError
+	if err != nil && !errors.As(err, &invalid) {
+		return models.CustomerSession{}, err
+	}
+	if problem := passwordProblem(registration.Password); problem != "" {
+		if invalid == nil {
+			invalid = &models.ValidationError{Err: models.ErrInvalidPassword, Fields: map[string]string{}}
+		}
+		invalid.Fields["password"] = problem
+	}
+	if registration.Email == "" && registration.PhoneNumber == "" && invalid == nil {
+		return models.CustomerSession{}, models.ErrInvalidLogin
+	}
+	if invalid != nil {
+		return models.CustomerSession{}, invalid
+	}
+	if err := normalizePreferences(&registration.Preferences); err != nil {
+		return models.CustomerSession{}, err
+	}
+	hash, err := auth.HashPassword(registration.Password)
+	if err != nil {
+		return models.CustomerSession{}, err
+	}
+
+	log.Println("Registering customer account:", registration.FullName)
+	if err := s.accountRepo.Register(ctx, &registration.Customer, hash); err != nil {
+		log.Printf("Failed to register customer account '%s': %v", registration.FullName, err)
+		return models.CustomerSession{}, fmt.Errorf("could not register customer account: %w", err)
+	}
+	log.Println("Customer account registered successfully:", registration.CustomerId)
+	return s.startSession(ctx, string(registration.CustomerId))
+}
+
+// passwordProblem tells what is wrong with a new password, or returns "".
+func passwordProblem(password string) string {
+	switch n := utf8.RuneCountInString(password); {
+	case n < 8:
+		return "needs at least 8 characters"
+	case n > 128:
+		return "is longer than 128 characters"
+	}
+	return ""
+}
+
+// Login logs a customer in with their password. After loginAttempts wrong
+// passwords in a row, password logins of the customer are locked for
+// loginLockout.
+func (s *AccountService) Login(ctx context.Context, login models.Login) (models.CustomerSession, error) {
+	customer, err := s.findCustomer(ctx, login)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			auth.CheckPassword(dummyPasswordHash, login.Password)
+			return models.CustomerSession{}, models.ErrInvalidCredentials
+		}
+		return models.CustomerSession{}, err
+	}
+	hash, lockedUntil, err := s.accountRepo.PasswordHash(ctx, string(customer.CustomerId))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.CustomerSession{}, err
+	}
+	if err != nil {
+		hash = dummyPasswordHash
+	}
+	if time.Now().Before(lockedUntil) {
+		log.Printf("Password login of customer [%s] is locked until %s", customer.CustomerId, lockedUntil.Format(time.RFC3339))
+		return models.CustomerSession{}, models.ErrLoginLocked
+	}
+	if !auth.CheckPassword(hash, login.Password) || hash == dummyPasswordHash {
+		log.Printf("Failed login of customer [%s]", customer.CustomerId)
+		if err := s.accountRepo.FailLogin(ctx, string(customer.CustomerId), loginAttempts, loginLockout); err != nil {
+			return models.CustomerSession{}, err
+		}
+		return models.CustomerSession{}, models.ErrInvalidCredentials
+	}
+	if err := s.accountRepo.ResetFailedLogins(ctx, string(customer.CustomerId)); err != nil {
+		return models.CustomerSession{}, err
+	}
+	return s.startSession(ctx, string(customer.CustomerId))
+}
+
+// SendLoginCode sends a one-time login code to the email or phone number of
+// the login. Unknown customers get nothing and the caller is not told, nor
+// when a code was already sent within the last minute. Without a sender it
+// fails with ErrLoginCodesUnavailable.
+func (s *AccountService) SendLoginCode(ctx context.Context, login models.Login) error {
+	if s.sender == nil {
+		return models.ErrLoginCodesUnavailable
+	}
+	customer, err := s.findCustomer(ctx, login)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	last, err := s.accountRepo.LastLoginCodeAt(ctx, string(customer.CustomerId))
+	if err != nil {
+		return err
+	}
+	if time.Since(last) < loginCodeInterval {
+		log.Printf("Login code for customer [%s] sent less than %s ago, not sending another", customer.CustomerId, loginCodeInterval)
+		return nil
+	}
+	code, err := auth.NewCode()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(loginCodeTTL)
+	if err := s.accountRepo.CreateLoginCode(ctx, string(customer.CustomerId), auth.HashToken(code), expiresAt); err != nil {
+		return err
+	}
+	message := auth.LoginCode{CustomerId: string(customer.CustomerId), Channel: "email", To: string(customer.Email), Code: code, ExpiresAt: expiresAt}
+	if login.Email == "" {
+		message.Channel, message.To = "sms", string(customer.PhoneNumber)
+	}
+	if err := s.sender.SendLoginCode(ctx, message); err != nil {
+		log.Printf("Failed to send login code to customer [%s]: %v", customer.CustomerId, err)
+		return fmt.Errorf("could not send login code: %w", err)
+	}
+	log.Printf("Login code sent to customer [%s] by %s", customer.CustomerId, message.Channel)
+	return nil
+}
+
+// LoginWithCode logs a customer in with a code of SendLoginCode.
+func (s *AccountService) LoginWithCode(ctx context.Context, login models.Login) (models.CustomerSession, error) {
+	customer, err := s.findCustomer(ctx, login)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.CustomerSession{}, models.ErrInvalidCredentials
+		}
+		return models.CustomerSession{}, err
+	}
+	code := strings.TrimSpace(login.Code)
+	ok, err := s.accountRepo.UseLoginCode(ctx, string(customer.CustomerId), auth.HashToken(code), loginCodeAttempts)
+	if err != nil {
+		return models.CustomerSession{}, err
+	}
+	if !ok {
+		log.Printf("Failed code login of customer [%s]", customer.CustomerId)
+		return models.CustomerSession{}, models.ErrInvalidCredentials
+	}
+	return s.startSession(ctx, string(customer.CustomerId))
+}
+
+// findCustomer returns the active customer a login names. It fails with
+// ErrInvalidLogin when the login names none and with sql.ErrNoRows when
+// there is no such customer.
+func (s *AccountService) findCustomer(ctx context.Context, login models.Login) (models.Customer, error) {
+	var email, phone string
+	var err error
+	switch {
+	case strings.TrimSpace(login.Email) != "":
+		if email, err = models.NormalizeEmail(login.Email); err != nil {
+			return models.Customer{}, fmt.Errorf("customer not found: %w", sql.ErrNoRows)
+		}
+	case strings.TrimSpace(login.PhoneNumber) != "":
+		if phone, err = models.NormalizePhone(login.PhoneNumber, login.Country); err != nil {
+			return models.Customer{}, fmt.Errorf("customer not found: %w", sql.ErrNoRows)
+		}
+	default:
+		return models.Customer{}, models.ErrInvalidLogin
+	}
+	return s.accountRepo.FindCustomer(ctx, email, phone)
+}
+
+func (s *AccountService) startSession(ctx context.Context, CustomerId string) (models.CustomerSession, error) {
+	token, err := auth.NewToken()
+	if err != nil {
+		return models.CustomerSession{}, err
+	}
+	expiresAt := time.Now().Add(sessionTTL)
+	if err := s.accountRepo.CreateSession(ctx, CustomerId, auth.HashToken(token), expiresAt); err != nil {
+		log.Printf("Failed to start session of customer [%s]: %v", CustomerId, err)
+		return models.CustomerSession{}, fmt.Errorf("could not start session: %w", err)
+	}
+	log.Printf("Customer [%s] logged in", CustomerId)
+	return models.CustomerSession{Token: token, CustomerId: utils.TEXT(CustomerId), ExpiresAt: utils.TIME(expiresAt)}, nil
+}
+
+// Authenticate returns the id of the customer of a session token, or fails
+// with ErrUnauthenticated.
+func (s *AccountService) Authenticate(ctx context.Context, token string) (string, error) {
+	if token == "" {
+		return "", models.ErrUnauthenticated
+	}
+	CustomerId, err := s.accountRepo.SessionCustomer(ctx, auth.HashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", models.ErrUnauthenticated
+		}
+		return "", err
+	}
+	return CustomerId, nil
+}
+
+func (s *AccountService) Logout(ctx context.Context, token string) error {
+	if err := s.accountRepo.RevokeSession(ctx, auth.HashToken(token)); err != nil {
+		log.Printf("Failed to log out: %v", err)
+		return fmt.Errorf("could not log out: %w", err)
+	}
+	return nil
+}
+
+// SetPassword sets the password of a customer and ends their other sessions.
+// A customer who has a password must give it as the current one; a wrong
+// one counts as a failed login, so it locks like one.
+func (s *AccountService) SetPassword(ctx context.Context, CustomerId string, token string, change models.PasswordChange) error {
+	if problem := passwordProblem(change.Password); problem != "" {
+		return &models.ValidationError{Err: models.ErrInvalidPassword, Fields: map[string]string{"password": problem}}
+	}
+	current, lockedUntil, err := s.accountRepo.PasswordHash(ctx, CustomerId)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// a customer who logs in with codes sets a first password
+	case err != nil:
+		return err
+	case time.Now().Before(lockedUntil):
+		return models.ErrLoginLocked
+	case !auth.CheckPassword(current, change.CurrentPassword):
+		log.Printf("Wrong current password of customer [%s]", CustomerId)
+		if err := s.accountRepo.FailLogin(ctx, CustomerId, loginAttempts, loginLockout); err != nil {
+			return err
+		}
+		return &models.ValidationError{Err: models.ErrWrongPassword, Fields: map[string]string{"current_password": "is not the current password"}}
+	}
+	hash, err := auth.HashPassword(change.Password)
+	if err != nil {
+		return err
+	}
+	log.Printf("Setting password of customer [%s]", CustomerId)
+	if err := s.accountRepo.SetPassword(ctx, CustomerId, hash); err != nil {
+		log.Printf("Failed to set password of customer [%s]: %v", CustomerId, err)
+		return fmt.Errorf("could not set password: %w", err)
+	}
+	if err := s.accountRepo.RevokeOtherSessions(ctx, CustomerId, auth.HashToken(token)); err != nil {
+		return fmt.Errorf("could not end other sessions: %w", err)
+	}
+	log.Printf("Password of customer [%s] set successfully", CustomerId)
+	return nil
+}
@@ -2,29 +2,39 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"frappuccino/internal/repo"
 	"frappuccino/models"
 	"log"
+	"math"
+	"slices"
+	"strings"
+	"time"
 )
 
 type AggregationServiceInf interface {
-	TotalPrice() (float64, error)
-	PopularItems() (models.PopularItems, error)
+	TotalPrice(StoreId string) (float64, error)
+	PopularItems(StoreId string, groupBy string) (models.PopularItems, error)
+	SalesByCategory(StoreId string, groupBy string) (models.SalesByCategory, error)
 	Search(ctx context.Context, q string, filters []string, minPrice, maxPrice float64) (models.Search, error)
 	OrderedItemByPeriod(period string, month string, year string) (models.ListOrderedItemByPeriods, error)
+	CustomerSegments(ctx context.Context, StoreId string, segment string) (models.CustomerSegments, error)
+	CohortRetention(ctx context.Context, StoreId string, from string, months int) (models.CohortRetention, error)
+	LifetimeValue(ctx context.Context, StoreId string, groupBy string) (models.LifetimeValue, error)
 }
 
 type AggregationService struct {
 	aggregationRepo repo.AggregationRepo
+	segmentRepo     repo.SegmentRepo
 }
 
-func NewAggregationService(aggregationRepo repo.AggregationRepo) *AggregationService {
-	return &AggregationService{aggregationRepo: aggregationRepo}
+func NewAggregationService(aggregationRepo repo.AggregationRepo, segmentRepo repo.SegmentRepo) *AggregationService {
+	return &AggregationService{aggregationRepo: aggregationRepo, segmentRepo: segmentRepo}
 }
 
-func (s *AggregationService) TotalPrice() (float64, error) {
+func (s *AggregationService) TotalPrice(StoreId string) (float64, error) {
 	log.Println("Count TotalPrice")
-	res, err := s.aggregationRepo.TotalPrice()
+	res, err := s.aggregationRepo.TotalPrice(StoreId)
 	if err != nil {
 		log.Printf("Failed to get TotalPrice: %v", err)
 		return 0, err
@@ -32,9 +42,12 @@ func (s *AggregationService) TotalPrice() (float64, error) {
 	log.Printf("TotalPrice: %v", res)
 	return res, nil
 }
-func (s *AggregationService) PopularItems() (models.PopularItems, error) {
+func (s *AggregationService) PopularItems(StoreId string, groupBy string) (models.PopularItems, error) {
 	log.Println("Get PopulatItems")
-	res, err := s.aggregationRepo.PopularItems()
+	if groupBy != "" && groupBy != "item" && groupBy != "variant" {
+		return models.PopularItems{}, models.ErrInvalidGroupBy
+	}
+	res, err := s.aggregationRepo.PopularItems(StoreId, groupBy)
 	if err != nil {
 		log.Printf("Failed to get PoplarItem: %v", err)
 		return models.PopularItems{}, err
@@ -43,6 +56,20 @@ func (s *AggregationService) PopularItems() (models.PopularItems, error) {
 	return res, nil
 }
 
+func (s *AggregationService) SalesByCategory(StoreId string, groupBy string) (models.SalesByCategory, error) {
+	log.Println("Get sales by category")
+	if groupBy != "" && groupBy != "category" && groupBy != "parent" {
+		return models.SalesByCategory{}, models.ErrInvalidGroupBy
+	}
+	res, err := s.aggregationRepo.SalesByCategory(StoreId, groupBy)
+	if err != nil {
+		log.Printf("Failed to get sales by category: %v", err)
+		return models.SalesByCategory{}, err
+	}
+	log.Println("Success to get sales by category")
+	return res, nil
+}
+
 func (s *AggregationService) Search(ctx context.Context, q string, filters []string, minPrice, maxPrice float64) (models.Search, error) {
 	log.Printf("Search by filer: %v", filters)
 	res, err := s.aggregationRepo.Search(ctx, q, filters, minPrice, maxPrice)
@@ -63,3 +90,150 @@ func (s *AggregationService) OrderedItemByPeriod(period string, month string, ye
 	log.Println("success to get ordered item by period")
 	return res, nil
 }
+
+// CustomerSegments scores the customers of a store, or of all stores when
+// StoreId is empty, and puts each in the first segment matching its scores.
+// With segment only the customers in that segment are listed; the counts
+// always cover every segment.
+func (s *AggregationService) CustomerSegments(ctx context.Context, StoreId string, segment string) (models.CustomerSegments, error) {
+	log.Println("Get customer segments")
+	definitions, err := segmentDefinitions(ctx, s.segmentRepo)
+	if err != nil {
+		log.Printf("Failed to get segments: %v", err)
+		return models.CustomerSegments{}, err
+	}
+	known := segment == ""
+	for _, d := range definitions {
+		known = known || string(d.SegmentName) == segment
+	}
+	if !known {
+		return models.CustomerSegments{}, fmt.Errorf("unknown segment %q: %w", segment, models.ErrInvalidSegment)
+	}
+	customers, err := s.aggregationRepo.CustomerRFM(ctx, StoreId)
+	if err != nil {
+		log.Printf("Failed to get customer rfm: %v", err)
+		return models.CustomerSegments{}, err
+	}
+
+	res := models.CustomerSegments{StoreId: StoreId, Segment: segment, Counts: map[string]int{}, Customers: []models.CustomerRFM{}}
+	for _, c := range customers {
+		for _, d := range definitions {
+			if d.Matches(c) {
+				c.Segment = string(d.SegmentName)
+				break
+			}
+		}
+		res.Counts[c.Segment]++
+		if segment == "" || c.Segment == segment {
+			res.Customers = append(res.Customers, c)
+		}
+	}
+	log.Println("Success to get customer segments")
+	return res, nil
+}
+
+const (
+	defaultCohortMonths = 12
+	maxCohortMonths     = 36
+)
+
+// CohortRetention builds the retention table of the monthly cohorts from
+// the month from (YYYY-MM) on, by default the last months ones, over the
+// months after signing up.
+func (s *AggregationService) CohortRetention(ctx context.Context, StoreId string, from string, months int) (models.CohortRetention, error) {
+	log.Println("Get cohort retention")
+	if months == 0 {
+		months = defaultCohortMonths
+	}
+	if months < 1 || months > maxCohortMonths {
+		return models.CohortRetention{}, models.ErrInvalidCohortRange
+	}
+	now := time.Now()
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	start := thisMonth.AddDate(0, -months, 0)
+	if from != "" {
+		parsed, err := time.ParseInLocation("2006-01", from, now.Location())
+		if err != nil {
+			return models.CohortRetention{}, fmt.Errorf("from %q: %w", from, models.ErrInvalidCohortRange)
+		}
+		start = parsed
+	}
+	activity, err := s.aggregationRepo.CohortActivity(ctx, StoreId, start, months)
+	if err != nil {
+		log.Printf("Failed to get cohort activity: %v", err)
+		return models.CohortRetention{}, err
+	}
+
+	res := models.CohortRetention{StoreId: StoreId, Months: months, Cohorts: []models.Cohort{}}
+	for _, a := range activity {
+		if a.Month == 0 {
+			cohort := models.Cohort{Cohort: a.Cohort, Customers: a.Customers}
+			// only the months after signing up that have ended
+			if month, err := time.ParseInLocation("2006-01", a.Cohort, now.Location()); err == nil {
+				ended := (thisMonth.Year()-month.Year())*12 + int(thisMonth.Month()-month.Month()) - 1
+				cohort.Active = make([]int, max(0, min(months, ended)))
+				cohort.Retention = make([]float64, len(cohort.Active))
+			}
+			res.Cohorts = append(res.Cohorts, cohort)
+			continue
+		}
+		cohort := &res.Cohorts[len(res.Cohorts)-1]
+		if a.Month <= len(cohort.Active) {
+			cohort.Active[a.Month-1] = a.Customers
+			cohort.Retention[a.Month-1] = math.Round(float64(a.Customers)*10000/float64(cohort.Customers)) / 100
+		}
+	}
+	log.Println("Success to get cohort retention")
+	return res, nil
+}
+
+// LifetimeValue reports what customers have spent in a store, or in all
+// stores when StoreId is empty, per customer or with groupBy "cohort" per
+// monthly signup cohort.
+func (s *AggregationService) LifetimeValue(ctx context.Context, StoreId string, groupBy string) (models.LifetimeValue, error) {
+	log.Println("Get lifetime value")
+	if groupBy == "" {
+		groupBy = "customer"
+	}
+	if groupBy != "customer" && groupBy != "cohort" {
+		return models.LifetimeValue{}, models.ErrInvalidGroupBy
+	}
+	customers, err := s.aggregationRepo.CustomerLTV(ctx, StoreId)
+	if err != nil {
+		log.Printf("Failed to get lifetime value: %v", err)
+		return models.LifetimeValue{}, err
+	}
+	res := models.LifetimeValue{StoreId: StoreId, GroupBy: groupBy}
+	if groupBy == "customer" {
+		res.Customers = customers
+		return res, nil
+	}
+
+	res.Cohorts = []models.CohortLTV{}
+	index := make(map[string]int)
+	for _, c := range customers {
+		i, ok := index[c.Cohort]
+		if !ok {
+			i = len(res.Cohorts)
+			index[c.Cohort] = i
+			res.Cohorts = append(res.Cohorts, models.CohortLTV{Cohort: c.Cohort})
+		}
+		cohort := &res.Cohorts[i]
+		cohort.Customers++
+		if c.Orders > 0 {
+			cohort.PayingCustomers++
+		}
+		cohort.Orders += c.Orders
+		cohort.Revenue += c.Revenue
+	}
+	for i := range res.Cohorts {
+		cohort := &res.Cohorts[i]
+		cohort.LTV = cohort.Revenue / float64(cohort.Customers)
+		if cohort.Orders > 0 {
+			cohort.AverageOrderValue = cohort.Revenue / float64(cohort.Orders)
+		}
+	}
+	slices.SortFunc(res.Cohorts, func(a, b models.CohortLTV) int { return strings.Compare(a.Cohort, b.Cohort) })
+	log.Println("Success to get lifetime value")
+	return res, nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"frappuccino/internal/repo"
+	"frappuccino/models"
+	"frappuccino/utils"
+	"log"
+	"strings"
+)
+
+type CategoryServiceInf interface {
+	Create(ctx context.Context, category *models.Category) error
+	GetAll(ctx context.Context, locales []string) ([]models.Category, error)
+	GetCategoryByID(ctx context.Context, CategoryId string) (models.Category, error)
+	UpdateCategoryByID(ctx context.Context, category *models.Category) error
+	DeleteCategoryByID(ctx context.Context, CategoryId string) error
+}
+
+type CategoryService struct {
+	categoryRepo    repo.CategoryRepo
+	translationRepo repo.TranslationRepo
+}
+
+func NewCategoryService(categoryRepo repo.CategoryRepo, translationRepo repo.TranslationRepo) *CategoryService {
+	return &CategoryService{categoryRepo: categoryRepo, translationRepo: translationRepo}
+}
+
+func validateCategory(category *models.Category) error {
+	category.CategoryName = utils.TEXT(strings.TrimSpace(string(category.CategoryName)))
+	if category.CategoryName == "" {
+		return fmt.Errorf("category_name is required: %w", models.ErrInvalidCategory)
+	}
+	if category.ParentId != "" && category.ParentId == category.CategoryId {
+		return fmt.Errorf("category cannot be its own parent: %w", models.ErrInvalidCategory)
+	}
+	return nil
+}
+
+func (s *CategoryService) Create(ctx context.Context, category *models.Category) error {
+	if err := validateCategory(category); err != nil {
+		return err
+	}
+	log.Println("Creating new category:", category.CategoryName)
+	err := s.categoryRepo.Create(ctx, category)
+	if err != nil {
+		log.Printf("Failed to create category '%s': %v", category.CategoryName, err)
+		return fmt.Errorf("could not create category: %w", err)
+	}
+	log.Println("Category created successfully:", category.CategoryId)
+	return nil
+}
+
+// GetAll returns the category tree: top-level categories with their
+// subcategories nested, each level in display order, translated into the
+// first of locales they have a translation in.
+func (s *CategoryService) GetAll(ctx context.Context, locales []string) ([]models.Category, error) {
+	categories, err := s.categoryRepo.GetAll(ctx)
+	if err != nil {
+		log.Printf("Failed to fetch categories: %v", err)
+		return nil, fmt.Errorf("could not retrieve categories: %w", err)
+	}
+	texts, err := loadLocalizer(ctx, s.translationRepo, locales)
+	if err != nil {
+		log.Printf("Failed to fetch translations of categories: %v", err)
+		return nil, fmt.Errorf("could not retrieve categories: %w", err)
+	}
+	tree := categoryTree(categories, "")
+	texts.categories(tree)
+	return tree, nil
+}
+
+// categoryTree nests the children of parent, keeping the order of categories.
+func categoryTree(categories []models.Category, parent utils.TEXT) []models.Category {
+	var level []models.Category
+	for _, c := range categories {
+		if c.ParentId == parent {
+			c.Children = categoryTree(categories, c.CategoryId)
+			level = append(level, c)
+		}
+	}
+	return level
+}
+
+// GetCategoryByID returns a category with its subcategories nested.
+func (s *CategoryService) GetCategoryByID(ctx context.Context, CategoryId string) (models.Category, error) {
+	category, err := s.categoryRepo.GetCategoryByID(ctx, CategoryId)
+	if err != nil {
+		log.Printf("Failed to fetch category [%s]: %v", CategoryId, err)
+		return models.Category{}, fmt.Errorf("could not get category: %w", err)
+	}
+	categories, err := s.categoryRepo.GetAll(ctx)
+	if err != nil {
+		return models.Category{}, fmt.Errorf("could not get subcategories: %w", err)
+	}
+	category.Children = categoryTree(categories, category.CategoryId)
+	return category, nil
+}
+
+func (s *CategoryService) UpdateCategoryByID(ctx context.Context, category *models.Category) error {
+	if err := validateCategory(category); err != nil {
+		return err
+	}
+	log.Printf("Updating category [%s]", category.CategoryId)
+	err := s.categoryRepo.UpdateCategoryByID(ctx, category)
+	if err != nil {
+		log.Printf("Failed to update category [%s]: %v", category.CategoryId, err)
+		return fmt.Errorf("could not update category: %w", err)
+	}
+	log.Printf("Category [%s] updated successfully", category.CategoryId)
+	return nil
+}
+
+func (s *CategoryService) DeleteCategoryByID(ctx context.Context, CategoryId string) error {
+	log.Printf("Deleting category [%s]", CategoryId)
+	err := s.categoryRepo.DeleteCategoryByID(ctx, CategoryId)
+	if err != nil {
+		log.Printf("Failed to delete category [%s]: %v", CategoryId, err)
+		return fmt.Errorf("could not delete category: %w", err)
+	}
+	log.Printf("Category [%s] deleted successfully", CategoryId)
+	return nil
+}
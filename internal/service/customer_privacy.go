@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"frappuccino/models"
+	"frappuccino/utils"
+	"log"
+	"time"
+)
+
+// Export gathers all the data kept about a customer. There is no loyalty
+// history to export, as customers have no loyalty points or rewards.
+func (s *CustomerService) Export(ctx context.Context, CustomerId string) (models.CustomerExport, error) {
+	log.Printf("Exporting data of customer [%s]", CustomerId)
+	customer, err := s.customerRepo.GetCustomerByID(ctx, CustomerId)
+	if err != nil {
+		return models.CustomerExport{}, fmt.Errorf("could not get customer: %w", err)
+	}
+	export := models.CustomerExport{ExportedAt: utils.TIME(time.Now()), Customer: customer, Orders: []models.Order{}}
+	export.MergedCustomers, err = s.customerRepo.MergedCustomers(ctx, CustomerId)
+	if err != nil {
+		log.Printf("Failed to export customer [%s]: %v", CustomerId, err)
+		return models.CustomerExport{}, fmt.Errorf("could not export customer: %w", err)
+	}
+	for filter := (models.OrderFilter{Page: 1, PageSize: maxOrderPageSize}); ; filter.Page++ {
+		page, err := s.orderRepo.CustomerOrders(ctx, CustomerId, filter)
+		if err != nil {
+			log.Printf("Failed to export orders of customer [%s]: %v", CustomerId, err)
+			return models.CustomerExport{}, fmt.Errorf("could not export customer: %w", err)
+		}
+		export.Orders = append(export.Orders, page.Orders...)
+		if len(page.Orders) < filter.PageSize {
+			break
+		}
+	}
+	export.Erasures, err = s.customerRepo.Erasures(ctx, CustomerId)
+	if err != nil {
+		log.Printf("Failed to export customer [%s]: %v", CustomerId, err)
+		return models.CustomerExport{}, fmt.Errorf("could not export customer: %w", err)
+	}
+	log.Printf("Customer [%s] exported successfully with %d orders", CustomerId, len(export.Orders))
+	return export, nil
+}
+
+// Erase anonymises the personal data of a customer, keeping their orders
+// for accounting, and records who asked for it and why.
+func (s *CustomerService) Erase(ctx context.Context, erasure *models.CustomerErasure) error {
+	log.Printf("Erasing data of customer [%s] requested by %q", erasure.CustomerId, erasure.RequestedBy)
+	if err := s.customerRepo.Erase(ctx, erasure); err != nil {
+		log.Printf("Failed to erase customer [%s]: %v", erasure.CustomerId, err)
+		return fmt.Errorf("could not erase customer: %w", err)
+	}
+	log.Printf("Customer [%s] erased successfully as erasure [%s]", erasure.CustomerId, erasure.ErasureId)
+	return nil
+}
+
+// Erasures returns the erasure audit trail of a customer, or of all
+// customers when CustomerId is empty.
+func (s *CustomerService) Erasures(ctx context.Context, CustomerId string) ([]models.CustomerErasure, error) {
+	erasures, err := s.customerRepo.Erasures(ctx, CustomerId)
+	if err != nil {
+		log.Printf("Failed to fetch customer erasures: %v", err)
+		return nil, fmt.Errorf("could not retrieve customer erasures: %w", err)
+	}
+	return erasures, nil
+}
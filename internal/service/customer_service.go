@@ -5,26 +5,42 @@ import (
 	"fmt"
 	"frappuccino/internal/repo"
 	"frappuccino/models"
+	"frappuccino/utils"
 	"log"
+	"strings"
+	"unicode/utf8"
 )
 
 type CustomerServiceInf interface {
 	Create(ctx context.Context, customer *models.Customer) error
-	GetAll(ctx context.Context) ([]models.Customer, error)
+	GetAll(ctx context.Context, filter models.CustomerFilter) ([]models.Customer, error)
 	GetCustomerByID(ctx context.Context, CustomerId string) (models.Customer, error)
 	UpdateCustomerByID(ctx context.Context, customer *models.Customer) error
 	DeleteCustomerByID(ctx context.Context, CustomerId string) error
+	RestoreCustomerByID(ctx context.Context, CustomerId string) error
+	Duplicates(ctx context.Context) ([]models.DuplicateCustomers, error)
+	Merge(ctx context.Context, merge models.CustomerMerge) (models.CustomerMergeResult, error)
+	Export(ctx context.Context, CustomerId string) (models.CustomerExport, error)
+	Erase(ctx context.Context, erasure *models.CustomerErasure) error
+	Erasures(ctx context.Context, CustomerId string) ([]models.CustomerErasure, error)
 }
 
 type CustomerService struct {
 	customerRepo repo.CustomerRepo
+	orderRepo    repo.OrderRepo
 }
 
-func NewCustomerService(customerRepo repo.CustomerRepo) *CustomerService {
-	return &CustomerService{customerRepo: customerRepo}
+func NewCustomerService(customerRepo repo.CustomerRepo, orderRepo repo.OrderRepo) *CustomerService {
+	return &CustomerService{customerRepo: customerRepo, orderRepo: orderRepo}
 }
 
 func (s *CustomerService) Create(ctx context.Context, customer *models.Customer) error {
+	if err := normalizeContact(customer); err != nil {
+		return err
+	}
+	if err := normalizePreferences(&customer.Preferences); err != nil {
+		return err
+	}
 	log.Println("Creating new Customer :", customer.FullName)
 	err := s.customerRepo.Create(ctx, customer)
 	if err != nil {
@@ -35,9 +51,12 @@ func (s *CustomerService) Create(ctx context.Context, customer *models.Customer)
 	return nil
 }
 
-func (s *CustomerService) GetAll(ctx context.Context) ([]models.Customer, error) {
+func (s *CustomerService) GetAll(ctx context.Context, filter models.CustomerFilter) ([]models.Customer, error) {
+	if err := normalizePreferences(&filter.Preferences); err != nil {
+		return nil, err
+	}
 	log.Println("Fetching all Customers")
-	customers, err := s.customerRepo.GetAll(ctx)
+	customers, err := s.customerRepo.GetAll(ctx, filter)
 	if err != nil {
 		log.Printf("Failed to fetch Customer: %v", err)
 		return nil, fmt.Errorf("could not retrieve menu: %w", err)
@@ -58,6 +77,12 @@ func (s *CustomerService) GetCustomerByID(ctx context.Context, CustomerId string
 }
 
 func (s *CustomerService) UpdateCustomerByID(ctx context.Context, customer *models.Customer) error {
+	if err := normalizeContact(customer); err != nil {
+		return err
+	}
+	if err := normalizePreferences(&customer.Preferences); err != nil {
+		return err
+	}
 	log.Printf("Updating  Customer [%s]", customer.CustomerId)
 	err := s.customerRepo.UpdateCustomerByID(ctx, customer)
 	if err != nil {
@@ -78,3 +103,105 @@ func (s *CustomerService) DeleteCustomerByID(ctx context.Context, CustomerId str
 	log.Printf("Customer [%s] deleted successfully", CustomerId)
 	return nil
 }
+
+func (s *CustomerService) RestoreCustomerByID(ctx context.Context, CustomerId string) error {
+	log.Printf("Restoring Customer [%s]", CustomerId)
+	err := s.customerRepo.RestoreCustomerByID(ctx, CustomerId)
+	if err != nil {
+		log.Printf("Failed to restore Customer [%s]: %v", CustomerId, err)
+		return fmt.Errorf("could not restore Customer: %w", err)
+	}
+	log.Printf("Customer [%s] restored successfully", CustomerId)
+	return nil
+}
+
+// normalizeContact trims the name of customer, puts its phone number in
+// E.164 form and lowercases its email. Phone number and email may be left
+// empty. It returns a ValidationError listing every invalid field.
+func normalizeContact(customer *models.Customer) error {
+	fields := map[string]string{}
+	customer.FullName = utils.TEXT(strings.TrimSpace(string(customer.FullName)))
+	if customer.FullName == "" {
+		fields["full_name"] = "is required"
+	} else if utf8.RuneCountInString(string(customer.FullName)) > 255 {
+		fields["full_name"] = "is longer than 255 characters"
+	}
+	if phone := strings.TrimSpace(string(customer.PhoneNumber)); phone != "" {
+		phone, err := models.NormalizePhone(phone, string(customer.Country))
+		if err != nil {
+			fields["phone_number"] = err.Error()
+		}
+		customer.PhoneNumber = utils.TEXT(phone)
+	} else {
+		customer.PhoneNumber = ""
+	}
+	if email := strings.TrimSpace(string(customer.Email)); email != "" {
+		email, err := models.NormalizeEmail(email)
+		if err != nil {
+			fields["email"] = err.Error()
+		}
+		customer.Email = utils.TEXT(email)
+	} else {
+		customer.Email = ""
+	}
+	if len(fields) > 0 {
+		return &models.ValidationError{Err: models.ErrInvalidContact, Fields: fields}
+	}
+	return nil
+}
+
+// normalizePreferences lowercases the values of preferences and validates them.
+func normalizePreferences(p *models.Preferences) error {
+	p.FavouriteDrink = utils.TEXT(strings.TrimSpace(string(p.FavouriteDrink)))
+	p.MilkPreference = utils.TEXT(strings.ToLower(strings.TrimSpace(string(p.MilkPreference))))
+	p.SugarLevel = utils.TEXT(strings.ToLower(strings.TrimSpace(string(p.SugarLevel))))
+	for i := range p.Allergies {
+		p.Allergies[i] = strings.ToLower(strings.TrimSpace(p.Allergies[i]))
+	}
+	return p.Validate()
+}
+
+// duplicateNameSimilarity is how similar two names must be for the customers
+// to be reported as duplicates on their names alone.
+const duplicateNameSimilarity = 0.6
+
+// Duplicates reports pairs of active customers that are likely the same person.
+func (s *CustomerService) Duplicates(ctx context.Context) ([]models.DuplicateCustomers, error) {
+	duplicates, err := s.customerRepo.Duplicates(ctx, duplicateNameSimilarity)
+	if err != nil {
+		log.Printf("Failed to find duplicate customers: %v", err)
+		return nil, fmt.Errorf("could not find duplicate customers: %w", err)
+	}
+	log.Printf("Found %d possible duplicate customers", len(duplicates))
+	return duplicates, nil
+}
+
+// Merge folds duplicate customers into the surviving one, which gets their
+// orders and the preferences it lacks. The duplicates are archived.
+func (s *CustomerService) Merge(ctx context.Context, merge models.CustomerMerge) (models.CustomerMergeResult, error) {
+	if merge.SurvivorId == "" || len(merge.DuplicateIds) == 0 {
+		return models.CustomerMergeResult{}, models.ErrInvalidMerge
+	}
+	seen := map[utils.TEXT]bool{merge.SurvivorId: true}
+	ids := make([]string, 0, len(merge.DuplicateIds))
+	for _, id := range merge.DuplicateIds {
+		if seen[id] {
+			return models.CustomerMergeResult{}, fmt.Errorf("customer %s given twice: %w", id, models.ErrInvalidMerge)
+		}
+		seen[id] = true
+		ids = append(ids, string(id))
+	}
+
+	log.Printf("Merging customers %v into [%s]", ids, merge.SurvivorId)
+	moved, err := s.customerRepo.Merge(ctx, string(merge.SurvivorId), ids)
+	if err != nil {
+		log.Printf("Failed to merge customers into [%s]: %v", merge.SurvivorId, err)
+		return models.CustomerMergeResult{}, fmt.Errorf("could not merge customers: %w", err)
+	}
+	customer, err := s.customerRepo.GetCustomerByID(ctx, string(merge.SurvivorId))
+	if err != nil {
+		return models.CustomerMergeResult{}, fmt.Errorf("could not get customer: %w", err)
+	}
+	log.Printf("Customers merged successfully into [%s], %d orders moved", merge.SurvivorId, moved)
+	return models.CustomerMergeResult{Customer: customer, Merged: merge.DuplicateIds, OrdersMoved: utils.INT(moved)}, nil
+}
@@ -2,16 +2,25 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"frappuccino/internal/repo"
 	"frappuccino/models"
+	"frappuccino/utils"
+	"log"
 )
 
 type InventoryServiceInf interface {
 	Create(ctx context.Context, ingredient *models.Inventory) error
-	GetAll(ctx context.Context) ([]models.Inventory, error)
-	GetIngredientByID(ctx context.Context, IngredientId string) (models.Inventory, error)
+	GetAll(ctx context.Context, StoreId string, archived bool) ([]models.Inventory, error)
+	GetIngredientByID(ctx context.Context, StoreId string, IngredientId string) (models.Inventory, error)
 	UpdateIngredientByID(ctx context.Context, ingredient *models.Inventory) error
 	DeleteIngredientByID(ctx context.Context, IngerdientID string) error
+	RestoreIngredientByID(ctx context.Context, IngredientId string) error
+	SetRecipe(ctx context.Context, recipe *models.Recipe) error
+	GetRecipe(ctx context.Context, IngredientId string) (models.Recipe, error)
+	Produce(ctx context.Context, production *models.Production) error
+	GetIngredientCost(ctx context.Context, IngredientId string) (models.IngredientCost, error)
+	GetAvailability(ctx context.Context, StoreId string, IngredientId string) (models.Availability, error)
 }
 
 type InventoryService struct {
@@ -29,19 +38,39 @@ func (s *InventoryService) Create(ctx context.Context, ingredient *models.Invent
 	if ingredient.ReorderLevel < 0 {
 		return models.ErrInvalidReorderLevel
 	}
+	if ingredient.UnitCost < 0 {
+		return models.ErrInvalidUnitCost
+	}
+	if !ingredient.Nutrition.Valid() {
+		return models.ErrInvalidNutrition
+	}
+	ingredient.Allergens = normalizeFlags(ingredient.Allergens)
+	ingredient.Diets = normalizeFlags(ingredient.Diets)
 
 	return s.inventoryRepo.Create(ctx, ingredient)
 }
 
-func (s *InventoryService) GetAll(ctx context.Context) ([]models.Inventory, error) {
-	return s.inventoryRepo.GetAll(ctx)
+// GetAll lists the ingredients in stock, or only the archived ones when
+// archived is set.
+func (s *InventoryService) GetAll(ctx context.Context, StoreId string, archived bool) ([]models.Inventory, error) {
+	inventory, err := s.inventoryRepo.GetAll(ctx, StoreId)
+	if err != nil {
+		return nil, err
+	}
+	listed := inventory[:0]
+	for _, ingredient := range inventory {
+		if (ingredient.ArchivedAt != nil) == archived {
+			listed = append(listed, ingredient)
+		}
+	}
+	return listed, nil
 }
 
-func (s *InventoryService) GetIngredientByID(ctx context.Context, IngredientId string) (models.Inventory, error) {
+func (s *InventoryService) GetIngredientByID(ctx context.Context, StoreId string, IngredientId string) (models.Inventory, error) {
 	if len(IngredientId) <= 0 {
 		return models.Inventory{}, models.ErrInvalidIngredientId
 	}
-	return s.inventoryRepo.GetIngredientByID(ctx, IngredientId)
+	return s.inventoryRepo.GetIngredientByID(ctx, StoreId, IngredientId)
 }
 
 func (s *InventoryService) UpdateIngredientByID(ctx context.Context, ingredient *models.Inventory) error {
@@ -57,6 +86,14 @@ func (s *InventoryService) UpdateIngredientByID(ctx context.Context, ingredient
 	if ingredient.ReorderLevel < 0 {
 		return models.ErrInvalidReorderLevel
 	}
+	if ingredient.UnitCost < 0 {
+		return models.ErrInvalidUnitCost
+	}
+	if !ingredient.Nutrition.Valid() {
+		return models.ErrInvalidNutrition
+	}
+	ingredient.Allergens = normalizeFlags(ingredient.Allergens)
+	ingredient.Diets = normalizeFlags(ingredient.Diets)
 	return s.inventoryRepo.UpdateIngredientByID(ctx, ingredient)
 }
 
@@ -66,3 +103,99 @@ func (s *InventoryService) DeleteIngredientByID(ctx context.Context, IngredientI
 	}
 	return s.inventoryRepo.DeleteIngredientByID(ctx, IngredientId)
 }
+
+func (s *InventoryService) RestoreIngredientByID(ctx context.Context, IngredientId string) error {
+	if IngredientId == "" {
+		return models.ErrInvalidIngredientId
+	}
+	return s.inventoryRepo.RestoreIngredientByID(ctx, IngredientId)
+}
+
+func (s *InventoryService) SetRecipe(ctx context.Context, recipe *models.Recipe) error {
+	if recipe.IngredientId == "" {
+		return models.ErrInvalidIngredientId
+	}
+	if recipe.BatchYield <= 0 || len(recipe.Components) == 0 {
+		return models.ErrInvalidRecipe
+	}
+	for _, c := range recipe.Components {
+		if c.IngredientId == "" {
+			return models.ErrInvalidIngredientId
+		}
+		if c.Quantity <= 0 {
+			return models.ErrInvalidQuantity
+		}
+	}
+	log.Printf("Setting recipe of ingredient [%s]", recipe.IngredientId)
+	return s.inventoryRepo.SetRecipe(ctx, recipe)
+}
+
+func (s *InventoryService) GetRecipe(ctx context.Context, IngredientId string) (models.Recipe, error) {
+	if IngredientId == "" {
+		return models.Recipe{}, models.ErrInvalidIngredientId
+	}
+	return s.inventoryRepo.GetRecipe(ctx, IngredientId)
+}
+
+func (s *InventoryService) Produce(ctx context.Context, production *models.Production) error {
+	if production.StoreId == "" {
+		return models.ErrMissingStore
+	}
+	if production.Batches <= 0 {
+		return models.ErrInvalidQuantity
+	}
+	log.Printf("Producing %v batches of ingredient [%s] in store [%s]", production.Batches, production.IngredientId, production.StoreId)
+	err := s.inventoryRepo.Produce(ctx, production)
+	if err != nil {
+		log.Printf("Failed to produce ingredient [%s]: %v", production.IngredientId, err)
+		return fmt.Errorf("could not produce ingredient: %w", err)
+	}
+	log.Printf("Produced %v of ingredient [%s]", production.Quantity, production.IngredientId)
+	return nil
+}
+
+func (s *InventoryService) GetIngredientCost(ctx context.Context, IngredientId string) (models.IngredientCost, error) {
+	book, err := loadRecipeBook(ctx, s.inventoryRepo, "")
+	if err != nil {
+		return models.IngredientCost{}, err
+	}
+	if _, ok := book.ingredients[utils.TEXT(IngredientId)]; !ok {
+		return models.IngredientCost{}, models.ErrInvalidIngredientId
+	}
+	cost, err := book.unitCost(utils.TEXT(IngredientId))
+	if err != nil {
+		return models.IngredientCost{}, err
+	}
+	return models.IngredientCost{IngredientId: utils.TEXT(IngredientId), UnitCost: cost}, nil
+}
+
+func (s *InventoryService) GetAvailability(ctx context.Context, StoreId string, IngredientId string) (models.Availability, error) {
+	if StoreId == "" {
+		return models.Availability{}, models.ErrMissingStore
+	}
+	book, err := loadRecipeBook(ctx, s.inventoryRepo, StoreId)
+	if err != nil {
+		return models.Availability{}, err
+	}
+	if _, ok := book.ingredients[utils.TEXT(IngredientId)]; !ok {
+		return models.Availability{}, models.ErrInvalidIngredientId
+	}
+	quantity, err := book.available(utils.TEXT(IngredientId))
+	if err != nil {
+		return models.Availability{}, err
+	}
+	return models.Availability{Id: utils.TEXT(IngredientId), StoreId: utils.TEXT(StoreId), Quantity: quantity}, nil
+}
+
+// loadRecipeBook loads all recipes with the ingredient stock of a store.
+func loadRecipeBook(ctx context.Context, inventoryRepo repo.InventoryRepo, StoreId string) (*recipeBook, error) {
+	recipes, err := inventoryRepo.GetRecipes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inventory, err := inventoryRepo.GetAll(ctx, StoreId)
+	if err != nil {
+		return nil, err
+	}
+	return newRecipeBook(recipes, inventory), nil
+}
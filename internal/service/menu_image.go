@@ -0,0 +1,180 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"frappuccino/models"
+	"frappuccino/utils"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"net/http"
+)
+
+const (
+	// MaxImageBytes is the largest image upload accepted.
+	MaxImageBytes = 5 << 20
+	// maxImageSide and maxImagePixels keep decoding of uploads within
+	// bounds: a decoded image takes up to 8 bytes a pixel whatever the size
+	// of the upload.
+	maxImageSide   = 8000
+	maxImagePixels = 25_000_000
+	// thumbnailSide is the longest side of generated thumbnails.
+	thumbnailSide = 320
+)
+
+// imageFormats maps the accepted content types to the image format names of
+// the image package and to file extensions.
+var imageFormats = map[string]struct{ format, ext string }{
+	"image/jpeg": {"jpeg", "jpg"},
+	"image/png":  {"png", "png"},
+	"image/gif":  {"gif", "gif"},
+}
+
+// UploadImage validates an image of a menu item, stores it with a thumbnail
+// in media storage and records both.
+func (s *MenuService) UploadImage(ctx context.Context, MenuItemId string, data []byte) (models.MenuItemImage, error) {
+	log.Printf("Uploading image of menu item [%s]", MenuItemId)
+	if len(data) > MaxImageBytes {
+		return models.MenuItemImage{}, models.ErrImageTooLarge
+	}
+	contentType := http.DetectContentType(data)
+	kind, ok := imageFormats[contentType]
+	if !ok {
+		return models.MenuItemImage{}, fmt.Errorf("%s is not accepted: %w", contentType, models.ErrInvalidImage)
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || format != kind.format {
+		return models.MenuItemImage{}, fmt.Errorf("could not read image: %w", models.ErrInvalidImage)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width > maxImageSide || config.Height > maxImageSide ||
+		config.Width*config.Height > maxImagePixels {
+		return models.MenuItemImage{}, fmt.Errorf("image is %dx%d: %w", config.Width, config.Height, models.ErrInvalidImage)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return models.MenuItemImage{}, fmt.Errorf("could not read image: %w", models.ErrInvalidImage)
+	}
+
+	// JPEG thumbnails of photos, PNG thumbnails of the rest to keep transparency
+	var thumb bytes.Buffer
+	thumbExt := "png"
+	if kind.format == "jpeg" {
+		thumbExt = "jpg"
+		err = jpeg.Encode(&thumb, thumbnail(img, thumbnailSide), &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&thumb, thumbnail(img, thumbnailSide))
+	}
+	if err != nil {
+		return models.MenuItemImage{}, fmt.Errorf("could not create thumbnail: %w", err)
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return models.MenuItemImage{}, err
+	}
+	upload := models.MenuItemImage{
+		MenuItemId:   utils.TEXT(MenuItemId),
+		ImageKey:     utils.TEXT("menu/" + name + "." + kind.ext),
+		ThumbnailKey: utils.TEXT("menu/" + name + "_thumb." + thumbExt),
+		ContentType:  utils.TEXT(contentType),
+		Width:        utils.INT(config.Width),
+		Height:       utils.INT(config.Height),
+		SizeBytes:    utils.INT(len(data)),
+	}
+	if err := s.media.Put(ctx, string(upload.ImageKey), data); err != nil {
+		log.Printf("Failed to store image of menu item [%s]: %v", MenuItemId, err)
+		return models.MenuItemImage{}, fmt.Errorf("could not store image: %w", err)
+	}
+	if err := s.media.Put(ctx, string(upload.ThumbnailKey), thumb.Bytes()); err != nil {
+		s.deleteImageFiles(ctx, upload)
+		log.Printf("Failed to store thumbnail of menu item [%s]: %v", MenuItemId, err)
+		return models.MenuItemImage{}, fmt.Errorf("could not store image: %w", err)
+	}
+	if err := s.menuRepo.AddImage(ctx, &upload); err != nil {
+		s.deleteImageFiles(ctx, upload)
+		log.Printf("Failed to save image of menu item [%s]: %v", MenuItemId, err)
+		return models.MenuItemImage{}, fmt.Errorf("could not save image: %w", err)
+	}
+	upload.URL = utils.TEXT(s.media.URL(string(upload.ImageKey)))
+	upload.ThumbnailURL = utils.TEXT(s.media.URL(string(upload.ThumbnailKey)))
+	log.Printf("Image [%s] of menu item [%s] uploaded successfully", upload.ImageId, MenuItemId)
+	return upload, nil
+}
+
+// DeleteImage removes an image of a menu item and its files.
+func (s *MenuService) DeleteImage(ctx context.Context, MenuItemId string, ImageId string) error {
+	log.Printf("Deleting image [%s] of menu item [%s]", ImageId, MenuItemId)
+	deleted, err := s.menuRepo.DeleteImage(ctx, MenuItemId, ImageId)
+	if err != nil {
+		log.Printf("Failed to delete image [%s]: %v", ImageId, err)
+		return fmt.Errorf("could not delete image: %w", err)
+	}
+	s.deleteImageFiles(ctx, deleted)
+	log.Printf("Image [%s] deleted successfully", ImageId)
+	return nil
+}
+
+// deleteImageFiles removes the files of an image. Failures are only logged:
+// a leftover file does no harm once nothing refers to it.
+func (s *MenuService) deleteImageFiles(ctx context.Context, img models.MenuItemImage) {
+	for _, key := range []utils.TEXT{img.ImageKey, img.ThumbnailKey} {
+		if err := s.media.Delete(ctx, string(key)); err != nil {
+			log.Printf("Failed to delete media file %s: %v", key, err)
+		}
+	}
+}
+
+// setImageURLs resolves the media storage URLs of images.
+func (s *MenuService) setImageURLs(images []models.MenuItemImage) {
+	for i := range images {
+		images[i].URL = utils.TEXT(s.media.URL(string(images[i].ImageKey)))
+		images[i].ThumbnailURL = utils.TEXT(s.media.URL(string(images[i].ThumbnailKey)))
+	}
+}
+
+// thumbnail scales img down to fit in a side x side square, averaging the
+// pixels each thumbnail pixel covers. Smaller images keep their size.
+func thumbnail(img image.Image, side int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tw, th := w, h
+	if w > side || h > side {
+		if w >= h {
+			tw, th = side, max(1, h*side/w)
+		} else {
+			tw, th = max(1, w*side/h), side
+		}
+	}
+	dst := image.NewRGBA64(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+max((y+1)*h/th, y*h/th+1)
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+max((x+1)*w/tw, x*w/tw+1)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(bl / n), uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// randomName returns a random file name for an upload.
+func randomName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not name image: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
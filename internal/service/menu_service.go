@@ -4,24 +4,36 @@ import (
 	"context"
 	"fmt"
 	"frappuccino/internal/repo"
+	"frappuccino/internal/storage"
 	"frappuccino/models"
+	"frappuccino/utils"
 	"log"
 )
 
 type MenuServiceInf interface {
 	Create(ctx context.Context, item *models.MenuItems) error
-	GetAll(ctx context.Context) ([]models.MenuItems, error)
-	GetItemByID(ctx context.Context, MenuItemId string) (models.MenuItems, error)
+	GetAll(ctx context.Context, StoreId string, filter models.MenuFilter) ([]models.MenuItems, error)
+	GetItemByID(ctx context.Context, StoreId string, MenuItemId string, locales []string) (models.MenuItems, error)
 	UpdateItemByID(ctx context.Context, item *models.MenuItems) error
 	DeleteItemByID(ctx context.Context, MenuItemId string) error
+	RestoreItemByID(ctx context.Context, MenuItemId string) error
+	GetItemCost(ctx context.Context, MenuItemId string, VariantId string) (models.MenuItemCost, error)
+	GetItemAvailability(ctx context.Context, StoreId string, MenuItemId string, VariantId string) (models.Availability, error)
+	GetItemNutrition(ctx context.Context, MenuItemId string) (models.MenuItemNutrition, error)
+	Import(ctx context.Context, rows []models.MenuImportRow, dryRun bool) (models.MenuImportResult, error)
+	UploadImage(ctx context.Context, MenuItemId string, data []byte) (models.MenuItemImage, error)
+	DeleteImage(ctx context.Context, MenuItemId string, ImageId string) error
 }
 
 type MenuService struct {
-	menuRepo repo.MenuRepo
+	menuRepo        repo.MenuRepo
+	inventoryRepo   repo.InventoryRepo
+	translationRepo repo.TranslationRepo
+	media           storage.Storage
 }
 
-func NewMenuService(menuRepo repo.MenuRepo) *MenuService {
-	return &MenuService{menuRepo: menuRepo}
+func NewMenuService(menuRepo repo.MenuRepo, inventoryRepo repo.InventoryRepo, translationRepo repo.TranslationRepo, media storage.Storage) *MenuService {
+	return &MenuService{menuRepo: menuRepo, inventoryRepo: inventoryRepo, translationRepo: translationRepo, media: media}
 }
 
 func (s *MenuService) Create(ctx context.Context, item *models.MenuItems) error {
@@ -35,24 +47,54 @@ func (s *MenuService) Create(ctx context.Context, item *models.MenuItems) error
 	return nil
 }
 
-func (s *MenuService) GetAll(ctx context.Context) ([]models.MenuItems, error) {
+func (s *MenuService) GetAll(ctx context.Context, StoreId string, filter models.MenuFilter) ([]models.MenuItems, error) {
 	log.Println("Fetching all menu items")
-	menu, err := s.menuRepo.Get(ctx)
+	menu, err := s.menuRepo.Get(ctx, StoreId, filter.Archived)
 	if err != nil {
 		log.Printf("Failed to fetch menu items: %v", err)
 		return nil, fmt.Errorf("could not retrieve menu: %w", err)
 	}
-	log.Printf("Retrieved %d menu items", len(menu))
-	return menu, nil
+	if err := s.annotateRecipes(ctx, menu); err != nil {
+		log.Printf("Failed to derive allergens of menu items: %v", err)
+		return nil, fmt.Errorf("could not retrieve menu: %w", err)
+	}
+	texts, err := loadLocalizer(ctx, s.translationRepo, filter.Locales)
+	if err != nil {
+		log.Printf("Failed to fetch translations of menu items: %v", err)
+		return nil, fmt.Errorf("could not retrieve menu: %w", err)
+	}
+	filtered := menu[:0]
+	for _, item := range menu {
+		if matchesFilter(&item, filter) {
+			texts.menuItem(&item)
+			s.setImageURLs(item.Images)
+			filtered = append(filtered, item)
+		}
+	}
+	log.Printf("Retrieved %d menu items", len(filtered))
+	return filtered, nil
 }
 
-func (s *MenuService) GetItemByID(ctx context.Context, MenuItemId string) (models.MenuItems, error) {
+func (s *MenuService) GetItemByID(ctx context.Context, StoreId string, MenuItemId string, locales []string) (models.MenuItems, error) {
 	log.Printf("Fetching menu item by ID: %s", MenuItemId)
-	item, err := s.menuRepo.GetItemByID(ctx, MenuItemId)
+	item, err := s.menuRepo.GetItemByID(ctx, StoreId, MenuItemId)
 	if err != nil {
 		log.Printf("Failed to fetch menu item [%s]: %v", MenuItemId, err)
 		return models.MenuItems{}, fmt.Errorf("could not get menu item: %w", err)
 	}
+	items := []models.MenuItems{item}
+	if err := s.annotateRecipes(ctx, items); err != nil {
+		log.Printf("Failed to derive allergens of menu item [%s]: %v", MenuItemId, err)
+		return models.MenuItems{}, fmt.Errorf("could not get menu item: %w", err)
+	}
+	item = items[0]
+	texts, err := loadLocalizer(ctx, s.translationRepo, locales)
+	if err != nil {
+		log.Printf("Failed to fetch translations of menu item [%s]: %v", MenuItemId, err)
+		return models.MenuItems{}, fmt.Errorf("could not get menu item: %w", err)
+	}
+	texts.menuItem(&item)
+	s.setImageURLs(item.Images)
 	log.Printf("Retrieved menu item [%s]: %s", item.MenuItemId, item.ItemName)
 	return item, nil
 }
@@ -78,3 +120,307 @@ func (s *MenuService) DeleteItemByID(ctx context.Context, MenuItemId string) err
 	log.Printf("Menu item [%s] deleted successfully", MenuItemId)
 	return nil
 }
+
+func (s *MenuService) RestoreItemByID(ctx context.Context, MenuItemId string) error {
+	log.Printf("Restoring menu item [%s]", MenuItemId)
+	err := s.menuRepo.RestoreItemByID(ctx, MenuItemId)
+	if err != nil {
+		log.Printf("Failed to restore menu item [%s]: %v", MenuItemId, err)
+		return fmt.Errorf("could not restore menu item: %w", err)
+	}
+	log.Printf("Menu item [%s] restored successfully", MenuItemId)
+	return nil
+}
+
+// Import validates the rows of an import file and upserts them by item
+// name. When a row is invalid the rest are still checked but nothing is saved.
+func (s *MenuService) Import(ctx context.Context, rows []models.MenuImportRow, dryRun bool) (models.MenuImportResult, error) {
+	var invalid []models.MenuImportError
+	valid := make([]models.MenuImportRow, 0, len(rows))
+	seen := make(map[utils.TEXT]int)
+	for _, row := range rows {
+		err := validateImportItem(row.Item)
+		if first, ok := seen[row.Item.ItemName]; ok && err == nil {
+			err = fmt.Errorf("item already imported at row %d", first)
+		}
+		if err != nil {
+			invalid = append(invalid, models.MenuImportError{Row: row.Row, ItemName: row.Item.ItemName, Error: err.Error()})
+			continue
+		}
+		seen[row.Item.ItemName] = row.Row
+		valid = append(valid, row)
+	}
+
+	log.Printf("Importing %d menu items (dry run: %t)", len(rows), dryRun)
+	result, err := s.menuRepo.Import(ctx, valid, dryRun || len(invalid) > 0)
+	if err != nil {
+		log.Printf("Failed to import menu: %v", err)
+		return models.MenuImportResult{}, fmt.Errorf("could not import menu: %w", err)
+	}
+	result.DryRun = dryRun
+	result.Errors = append(invalid, result.Errors...)
+	if len(result.Errors) > 0 {
+		log.Printf("Menu import has %d invalid rows", len(result.Errors))
+		return result, nil
+	}
+	log.Printf("Menu imported: %d created, %d updated", result.Created, result.Updated)
+	return result, nil
+}
+
+func validateImportItem(item models.MenuItems) error {
+	if item.ItemName == "" {
+		return fmt.Errorf("item_name is required")
+	}
+	if item.Price < 0 {
+		return fmt.Errorf("price cannot be negative")
+	}
+	for _, line := range item.Ingredients {
+		if line.IngredientId == "" && line.IngredientName == "" {
+			return fmt.Errorf("recipe line needs an ingredient_id or ingredient_name")
+		}
+		if line.Quantity <= 0 {
+			return fmt.Errorf("quantity of %s must be positive", line.IngredientName+line.IngredientId)
+		}
+	}
+	return nil
+}
+
+// GetItemCost costs one serving of a menu item, or of one of its variants,
+// through its recipe and sub-recipes.
+func (s *MenuService) GetItemCost(ctx context.Context, MenuItemId string, VariantId string) (models.MenuItemCost, error) {
+	item, err := s.menuRepo.GetItemByID(ctx, "", MenuItemId)
+	if err != nil {
+		return models.MenuItemCost{}, fmt.Errorf("could not get menu item: %w", err)
+	}
+	price, recipe, err := variantOf(item, VariantId)
+	if err != nil {
+		return models.MenuItemCost{}, err
+	}
+	book, err := loadRecipeBook(ctx, s.inventoryRepo, "")
+	if err != nil {
+		return models.MenuItemCost{}, err
+	}
+	cost, err := book.cost(recipe)
+	if err != nil {
+		return models.MenuItemCost{}, err
+	}
+	return models.MenuItemCost{
+		MenuItemId: item.MenuItemId,
+		VariantId:  utils.TEXT(VariantId),
+		Price:      price,
+		Cost:       cost,
+		Margin:     price - cost,
+	}, nil
+}
+
+// GetItemAvailability returns how many servings of a menu item a store can
+// make from its stock, including prepared ingredients it can still produce.
+func (s *MenuService) GetItemAvailability(ctx context.Context, StoreId string, MenuItemId string, VariantId string) (models.Availability, error) {
+	if StoreId == "" {
+		return models.Availability{}, models.ErrMissingStore
+	}
+	item, err := s.menuRepo.GetItemByID(ctx, StoreId, MenuItemId)
+	if err != nil {
+		return models.Availability{}, fmt.Errorf("could not get menu item: %w", err)
+	}
+	_, recipe, err := variantOf(item, VariantId)
+	if err != nil {
+		return models.Availability{}, err
+	}
+	book, err := loadRecipeBook(ctx, s.inventoryRepo, StoreId)
+	if err != nil {
+		return models.Availability{}, err
+	}
+	servings, err := book.servings(recipe)
+	if err != nil {
+		return models.Availability{}, err
+	}
+	return models.Availability{Id: item.MenuItemId, StoreId: utils.TEXT(StoreId), Quantity: servings}, nil
+}
+
+// GetItemNutrition returns the nutrition of one serving of a menu item and
+// of each of its variants.
+func (s *MenuService) GetItemNutrition(ctx context.Context, MenuItemId string) (models.MenuItemNutrition, error) {
+	item, err := s.menuRepo.GetItemByID(ctx, "", MenuItemId)
+	if err != nil {
+		return models.MenuItemNutrition{}, fmt.Errorf("could not get menu item: %w", err)
+	}
+	var options []string
+	for _, slot := range item.BundleSlots {
+		options = append(options, string(slot.Options[0]))
+	}
+	optionRecipes, err := s.menuRepo.GetRecipes(ctx, options)
+	if err != nil {
+		return models.MenuItemNutrition{}, err
+	}
+	book, err := loadRecipeBook(ctx, s.inventoryRepo, "")
+	if err != nil {
+		return models.MenuItemNutrition{}, err
+	}
+
+	result := models.MenuItemNutrition{MenuItemId: item.MenuItemId, ItemName: item.ItemName}
+	result.Nutrition, err = book.nutrition(servingRecipe(item, optionRecipes))
+	if err != nil {
+		return models.MenuItemNutrition{}, err
+	}
+	for _, v := range item.Variants {
+		n, err := book.nutrition(v.Recipe(item.Ingredients))
+		if err != nil {
+			return models.MenuItemNutrition{}, err
+		}
+		result.Variants = append(result.Variants, models.VariantNutrition{
+			VariantId:   v.VariantId,
+			VariantName: v.VariantName,
+			Nutrition:   n,
+		})
+	}
+	return result, nil
+}
+
+// servingRecipe returns the recipe of one serving of item, adding the fixed
+// components of a bundle. Choice slots depend on the order and are left out.
+func servingRecipe(item models.MenuItems, optionRecipes map[utils.TEXT][]models.MenuItemsIngredients) []models.MenuItemsIngredients {
+	recipe := item.Ingredients
+	for _, slot := range item.BundleSlots {
+		if len(slot.Options) != 1 {
+			continue
+		}
+		for _, line := range optionRecipes[slot.Options[0]] {
+			line.Quantity *= slot.Quantity
+			recipe = append(recipe[:len(recipe):len(recipe)], line)
+		}
+	}
+	return recipe
+}
+
+// variantOf returns the price and recipe of a variant of item, or of the
+// item itself when VariantId is empty.
+func variantOf(item models.MenuItems, VariantId string) (utils.DEC, []models.MenuItemsIngredients, error) {
+	if VariantId == "" {
+		return item.Price, item.Ingredients, nil
+	}
+	for _, v := range item.Variants {
+		if string(v.VariantId) == VariantId {
+			return v.Price, v.Recipe(item.Ingredients), nil
+		}
+	}
+	return 0, nil, models.ErrInvalidVariant
+}
+
+// annotateRecipes sets what menu items inherit from their recipes: the
+// allergens and diets of their recipe, variants and bundle options, the
+// allergens each of their modifiers adds, and their nutrition per serving.
+func (s *MenuService) annotateRecipes(ctx context.Context, items []models.MenuItems) error {
+	book, err := loadRecipeBook(ctx, s.inventoryRepo, "")
+	if err != nil {
+		return err
+	}
+	var options []string
+	for _, item := range items {
+		for _, slot := range item.BundleSlots {
+			for _, option := range slot.Options {
+				options = append(options, string(option))
+			}
+		}
+	}
+	optionRecipes, err := s.menuRepo.GetRecipes(ctx, options)
+	if err != nil {
+		return err
+	}
+
+	for i := range items {
+		item := &items[i]
+		recipe := append([]models.MenuItemsIngredients{}, item.Ingredients...)
+		for _, v := range item.Variants {
+			recipe = append(recipe, v.Recipe(item.Ingredients)...)
+		}
+		for _, slot := range item.BundleSlots {
+			for _, option := range slot.Options {
+				recipe = append(recipe, optionRecipes[option]...)
+			}
+		}
+		allergens, diets, err := book.recipeDietary(recipe)
+		if err != nil {
+			return err
+		}
+		item.Allergens = sortedFlags(allergens)
+		item.Diets = sortedFlags(diets)
+		nutrition, err := book.nutrition(servingRecipe(*item, optionRecipes))
+		if err != nil {
+			return err
+		}
+		item.Nutrition = &nutrition
+
+		for g := range item.ModifierGroups {
+			for m := range item.ModifierGroups[g].Modifiers {
+				modifier := &item.ModifierGroups[g].Modifiers[m]
+				added := make(map[string]bool)
+				for _, change := range modifier.Ingredients {
+					if change.IngredientId == "" {
+						continue
+					}
+					a, _, err := book.dietary(change.IngredientId)
+					if err != nil {
+						return err
+					}
+					for flag := range a {
+						added[flag] = true
+					}
+				}
+				modifier.Allergens = sortedFlags(added)
+			}
+		}
+	}
+	return nil
+}
+
+// matchesFilter reports whether item is orderable now, unless the filter
+// asks for all items, contains none of the excluded allergens and suits
+// every requested diet. Modifiers adding an excluded allergen are dropped
+// from item, which is left out when one of its groups is then left with
+// fewer modifiers than an order has to pick.
+func matchesFilter(item *models.MenuItems, filter models.MenuFilter) bool {
+	if !item.Orderable && !filter.All {
+		return false
+	}
+	if containsAny(item.Allergens, filter.ExcludeAllergens) {
+		return false
+	}
+	if len(filter.ExcludeAllergens) > 0 {
+		for g := range item.ModifierGroups {
+			group := &item.ModifierGroups[g]
+			kept := group.Modifiers[:0]
+			for _, m := range group.Modifiers {
+				if !containsAny(m.Allergens, filter.ExcludeAllergens) {
+					kept = append(kept, m)
+				}
+			}
+			group.Modifiers = kept
+			if utils.INT(len(kept)) < group.MinSelections {
+				return false
+			}
+		}
+	}
+	for _, diet := range filter.Diets {
+		suits := false
+		for _, d := range item.Diets {
+			suits = suits || d == diet
+		}
+		if !suits {
+			return false
+		}
+	}
+	return true
+}
+
+// containsAny reports whether flags hold any of wanted.
+func containsAny(flags utils.TEXTARR, wanted []string) bool {
+	for _, w := range wanted {
+		for _, flag := range flags {
+			if flag == w {
+				return true
+			}
+		}
+	}
+	return false
+}
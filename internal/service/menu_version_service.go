@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"frappuccino/internal/repo"
+	"frappuccino/models"
+	"frappuccino/utils"
+	"log"
+	"time"
+)
+
+type MenuVersionServiceInf interface {
+	Create(ctx context.Context, version *models.MenuVersion) error
+	GetAll(ctx context.Context, status string) ([]models.MenuVersion, error)
+	GetVersionByID(ctx context.Context, VersionId string) (models.MenuVersion, error)
+	UpdateVersionByID(ctx context.Context, version *models.MenuVersion) error
+	DeleteVersionByID(ctx context.Context, VersionId string) error
+	Publish(ctx context.Context, VersionId string, PublishAt *utils.TIME) error
+	PublishDue(ctx context.Context) error
+	Rollback(ctx context.Context, VersionId string) (models.MenuVersion, error)
+	Diff(ctx context.Context, VersionId string, AgainstId string) (models.MenuVersionDiff, error)
+}
+
+type MenuVersionService struct {
+	menuRepo    repo.MenuRepo
+	versionRepo repo.MenuVersionRepo
+}
+
+func NewMenuVersionService(menuRepo repo.MenuRepo, versionRepo repo.MenuVersionRepo) *MenuVersionService {
+	return &MenuVersionService{menuRepo: menuRepo, versionRepo: versionRepo}
+}
+
+// Create saves a draft based on the live menu. A draft without items starts
+// as a copy of it.
+func (s *MenuVersionService) Create(ctx context.Context, version *models.MenuVersion) error {
+	menu, err := s.menuRepo.Get(ctx, "", false)
+	if err != nil {
+		return fmt.Errorf("could not copy live menu: %w", err)
+	}
+	version.BaseItems = snapshotItems(menu)
+	if version.Items == nil {
+		version.Items = version.BaseItems
+	}
+	log.Println("Creating new menu version:", version.VersionName)
+	err = s.versionRepo.Create(ctx, version)
+	if err != nil {
+		log.Printf("Failed to create menu version '%s': %v", version.VersionName, err)
+		return fmt.Errorf("could not create menu version: %w", err)
+	}
+	log.Println("Menu version created successfully:", version.VersionId)
+	return nil
+}
+
+func (s *MenuVersionService) GetAll(ctx context.Context, status string) ([]models.MenuVersion, error) {
+	versions, err := s.versionRepo.GetAll(ctx, status)
+	if err != nil {
+		log.Printf("Failed to fetch menu versions: %v", err)
+		return nil, fmt.Errorf("could not retrieve menu versions: %w", err)
+	}
+	return versions, nil
+}
+
+func (s *MenuVersionService) GetVersionByID(ctx context.Context, VersionId string) (models.MenuVersion, error) {
+	version, err := s.versionRepo.GetVersionByID(ctx, VersionId)
+	if err != nil {
+		log.Printf("Failed to fetch menu version [%s]: %v", VersionId, err)
+		return models.MenuVersion{}, fmt.Errorf("could not get menu version: %w", err)
+	}
+	return version, nil
+}
+
+func (s *MenuVersionService) UpdateVersionByID(ctx context.Context, version *models.MenuVersion) error {
+	log.Printf("Updating menu version [%s]", version.VersionId)
+	version.Items = snapshotItems(version.Items)
+	err := s.versionRepo.UpdateDraft(ctx, version)
+	if err != nil {
+		log.Printf("Failed to update menu version [%s]: %v", version.VersionId, err)
+		return fmt.Errorf("could not update menu version: %w", err)
+	}
+	log.Printf("Menu version [%s] updated successfully", version.VersionId)
+	return nil
+}
+
+func (s *MenuVersionService) DeleteVersionByID(ctx context.Context, VersionId string) error {
+	log.Printf("Deleting menu version [%s]", VersionId)
+	err := s.versionRepo.DeleteDraft(ctx, VersionId)
+	if err != nil {
+		log.Printf("Failed to delete menu version [%s]: %v", VersionId, err)
+		return fmt.Errorf("could not delete menu version: %w", err)
+	}
+	log.Printf("Menu version [%s] deleted successfully", VersionId)
+	return nil
+}
+
+// Publish publishes a version now, or schedules it when PublishAt is in the future.
+func (s *MenuVersionService) Publish(ctx context.Context, VersionId string, PublishAt *utils.TIME) error {
+	if PublishAt != nil && time.Time(*PublishAt).After(time.Now()) {
+		log.Printf("Scheduling menu version [%s] for %s", VersionId, time.Time(*PublishAt).Format(time.RFC3339))
+		if err := s.versionRepo.Schedule(ctx, VersionId, *PublishAt); err != nil {
+			log.Printf("Failed to schedule menu version [%s]: %v", VersionId, err)
+			return fmt.Errorf("could not schedule menu version: %w", err)
+		}
+		return nil
+	}
+	log.Printf("Publishing menu version [%s]", VersionId)
+	if err := s.versionRepo.Publish(ctx, VersionId); err != nil {
+		log.Printf("Failed to publish menu version [%s]: %v", VersionId, err)
+		return fmt.Errorf("could not publish menu version: %w", err)
+	}
+	log.Printf("Menu version [%s] published successfully", VersionId)
+	return nil
+}
+
+// PublishDue publishes the scheduled versions whose time has come. Those
+// that fail to publish, such as when their items were edited live since
+// they were drafted, go back to draft without holding up the others.
+func (s *MenuVersionService) PublishDue(ctx context.Context) error {
+	n, failed, err := s.versionRepo.PublishDue(ctx)
+	for _, f := range failed {
+		log.Printf("Scheduled menu version not published, back to draft: %v", f)
+	}
+	if n > 0 {
+		log.Printf("Published %d scheduled menu versions", n)
+	}
+	if err != nil {
+		return fmt.Errorf("could not publish scheduled menu versions: %w", err)
+	}
+	return nil
+}
+
+// Rollback publishes a copy of an earlier version, based on the live menu,
+// so the history keeps every menu that was live.
+func (s *MenuVersionService) Rollback(ctx context.Context, VersionId string) (models.MenuVersion, error) {
+	old, err := s.versionRepo.GetVersionByID(ctx, VersionId)
+	if err != nil {
+		return models.MenuVersion{}, fmt.Errorf("could not get menu version: %w", err)
+	}
+	if old.VersionStatus != models.MenuVersionPublished && old.VersionStatus != models.MenuVersionArchived {
+		return models.MenuVersion{}, fmt.Errorf("only published versions can be rolled back to: %w", models.ErrMenuVersionStatus)
+	}
+	version := models.MenuVersion{
+		VersionName: utils.TEXT(fmt.Sprintf("Rollback to v%d", old.VersionNumber)),
+		Items:       old.Items,
+	}
+	if err := s.Create(ctx, &version); err != nil {
+		return models.MenuVersion{}, err
+	}
+	if err := s.Publish(ctx, string(version.VersionId), nil); err != nil {
+		return models.MenuVersion{}, err
+	}
+	return s.versionRepo.GetVersionByID(ctx, string(version.VersionId))
+}
+
+// Diff compares a version with another one, by default with the published version.
+func (s *MenuVersionService) Diff(ctx context.Context, VersionId string, AgainstId string) (models.MenuVersionDiff, error) {
+	to, err := s.versionRepo.GetVersionByID(ctx, VersionId)
+	if err != nil {
+		return models.MenuVersionDiff{}, fmt.Errorf("could not get menu version: %w", err)
+	}
+	var from models.MenuVersion
+	if AgainstId == "" {
+		from, err = s.versionRepo.GetPublished(ctx)
+	} else {
+		from, err = s.versionRepo.GetVersionByID(ctx, AgainstId)
+	}
+	if err != nil {
+		return models.MenuVersionDiff{}, fmt.Errorf("could not get menu version to compare with: %w", err)
+	}
+	return diffVersions(from, to), nil
+}
+
+func diffVersions(from, to models.MenuVersion) models.MenuVersionDiff {
+	diff := models.DiffMenus(from.Items, to.Items)
+	diff.FromVersionId, diff.ToVersionId = from.VersionId, to.VersionId
+	return diff
+}
+
+// snapshotItems keeps what a version defines of menu items and drops what
+// is derived from recipes, stores and schedules.
+func snapshotItems(menu []models.MenuItems) []models.MenuItems {
+	items := make([]models.MenuItems, len(menu))
+	for i, item := range menu {
+		items[i] = models.MenuItems{
+			MenuItemId:      item.MenuItemId,
+			ItemName:        item.ItemName,
+			ItemDescription: item.ItemDescription,
+			Price:           item.Price,
+			CategoryIds:     item.CategoryIds,
+			Tags:            item.Tags,
+			Ingredients:     item.Ingredients,
+			Variants:        item.Variants,
+			BundleSlots:     item.BundleSlots,
+		}
+	}
+	return items
+}
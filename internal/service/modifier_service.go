@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"frappuccino/internal/repo"
+	"frappuccino/models"
+	"log"
+)
+
+type ModifierServiceInf interface {
+	Create(ctx context.Context, group *models.ModifierGroup) error
+	GetAll(ctx context.Context) ([]models.ModifierGroup, error)
+	GetGroupByID(ctx context.Context, ModifierGroupId string) (models.ModifierGroup, error)
+	UpdateGroupByID(ctx context.Context, group *models.ModifierGroup) error
+	DeleteGroupByID(ctx context.Context, ModifierGroupId string) error
+	SetMenuItemGroups(ctx context.Context, MenuItemId string, ModifierGroupIds []string) error
+}
+
+type ModifierService struct {
+	modifierRepo repo.ModifierRepo
+}
+
+func NewModifierService(modifierRepo repo.ModifierRepo) *ModifierService {
+	return &ModifierService{modifierRepo: modifierRepo}
+}
+
+// validateGroup checks the selection bounds of a group and the recipe changes of its modifiers.
+func validateGroup(group *models.ModifierGroup) error {
+	if group.GroupName == "" || group.MinSelections < 0 || group.MaxSelections < group.MinSelections {
+		return models.ErrInvalidModifierGroup
+	}
+	for _, m := range group.Modifiers {
+		if m.ModifierName == "" {
+			return fmt.Errorf("modifier needs a name: %w", models.ErrInvalidModifier)
+		}
+		for _, change := range m.Ingredients {
+			switch change.Action {
+			case models.ModifierActionAdd:
+				if change.IngredientId == "" || change.Quantity <= 0 {
+					return fmt.Errorf("%s: ADD needs ingredient_id and a positive quantity: %w", m.ModifierName, models.ErrInvalidModifier)
+				}
+			case models.ModifierActionRemove:
+				if change.ReplacesIngredientId == "" {
+					return fmt.Errorf("%s: REMOVE needs replaces_ingredient_id: %w", m.ModifierName, models.ErrInvalidModifier)
+				}
+			case models.ModifierActionSubstitute:
+				if change.IngredientId == "" || change.ReplacesIngredientId == "" || change.Quantity < 0 {
+					return fmt.Errorf("%s: SUBSTITUTE needs ingredient_id and replaces_ingredient_id: %w", m.ModifierName, models.ErrInvalidModifier)
+				}
+			default:
+				return fmt.Errorf("%s: unknown action %s: %w", m.ModifierName, change.Action, models.ErrInvalidModifier)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *ModifierService) Create(ctx context.Context, group *models.ModifierGroup) error {
+	if err := validateGroup(group); err != nil {
+		return err
+	}
+	log.Println("Creating new modifier group:", group.GroupName)
+	err := s.modifierRepo.Create(ctx, group)
+	if err != nil {
+		log.Printf("Failed to create modifier group '%s': %v", group.GroupName, err)
+		return fmt.Errorf("could not create modifier group: %w", err)
+	}
+	log.Println("Modifier group created successfully:", group.ModifierGroupId)
+	return nil
+}
+
+func (s *ModifierService) GetAll(ctx context.Context) ([]models.ModifierGroup, error) {
+	log.Println("Fetching all modifier groups")
+	groups, err := s.modifierRepo.GetAll(ctx)
+	if err != nil {
+		log.Printf("Failed to fetch modifier groups: %v", err)
+		return nil, fmt.Errorf("could not retrieve modifier groups: %w", err)
+	}
+	log.Printf("Retrieved %d modifier groups", len(groups))
+	return groups, nil
+}
+
+func (s *ModifierService) GetGroupByID(ctx context.Context, ModifierGroupId string) (models.ModifierGroup, error) {
+	group, err := s.modifierRepo.GetGroupByID(ctx, ModifierGroupId)
+	if err != nil {
+		log.Printf("Failed to fetch modifier group [%s]: %v", ModifierGroupId, err)
+		return models.ModifierGroup{}, fmt.Errorf("could not get modifier group: %w", err)
+	}
+	return group, nil
+}
+
+func (s *ModifierService) UpdateGroupByID(ctx context.Context, group *models.ModifierGroup) error {
+	if err := validateGroup(group); err != nil {
+		return err
+	}
+	log.Printf("Updating modifier group [%s]", group.ModifierGroupId)
+	err := s.modifierRepo.UpdateGroupByID(ctx, group)
+	if err != nil {
+		log.Printf("Failed to update modifier group [%s]: %v", group.ModifierGroupId, err)
+		return fmt.Errorf("could not update modifier group: %w", err)
+	}
+	log.Printf("Modifier group [%s] updated successfully", group.ModifierGroupId)
+	return nil
+}
+
+func (s *ModifierService) DeleteGroupByID(ctx context.Context, ModifierGroupId string) error {
+	log.Printf("Deleting modifier group [%s]", ModifierGroupId)
+	err := s.modifierRepo.DeleteGroupByID(ctx, ModifierGroupId)
+	if err != nil {
+		log.Printf("Failed to delete modifier group [%s]: %v", ModifierGroupId, err)
+		return fmt.Errorf("could not delete modifier group: %w", err)
+	}
+	log.Printf("Modifier group [%s] deleted successfully", ModifierGroupId)
+	return nil
+}
+
+func (s *ModifierService) SetMenuItemGroups(ctx context.Context, MenuItemId string, ModifierGroupIds []string) error {
+	log.Printf("Setting %d modifier groups on menu item [%s]", len(ModifierGroupIds), MenuItemId)
+	err := s.modifierRepo.SetMenuItemGroups(ctx, MenuItemId, ModifierGroupIds)
+	if err != nil {
+		log.Printf("Failed to set modifier groups on menu item [%s]: %v", MenuItemId, err)
+		return fmt.Errorf("could not set menu item modifier groups: %w", err)
+	}
+	return nil
+}
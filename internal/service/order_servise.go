@@ -2,42 +2,82 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"frappuccino/internal/repo"
 	"frappuccino/models"
+	"frappuccino/utils"
 	"log"
+	"slices"
+	"strings"
 )
 
 type OrderServiseInf interface {
 	Create(ctx context.Context, order *models.Order) error
-	Orders(ctx context.Context) ([]models.Order, error)
-	GetOrderByID(ctx context.Context, orderId string) (models.Order, error)
-	UpdateOrderItemByID(ctx context.Context, orderItems *models.OrderItems) error
-	DeleteOrderByID(ctx context.Context, orderId string) error
-	UpdateStatusOrder(ctx context.Context, orderId string, status string) error
+	Orders(ctx context.Context, StoreId string) ([]models.Order, error)
+	GetOrderByID(ctx context.Context, orderId string, StoreId string) (models.Order, error)
+	UpdateOrderItemByID(ctx context.Context, orderItems *models.OrderItems, StoreId string) error
+	DeleteOrderByID(ctx context.Context, orderId string, StoreId string) error
+	UpdateStatusOrder(ctx context.Context, orderId string, status string, StoreId string) error
+	CustomerOrders(ctx context.Context, CustomerId string, filter models.OrderFilter) (models.OrderPage, error)
+	CustomerOrder(ctx context.Context, CustomerId string, orderId string, StoreId string) (models.Order, error)
+	Reorder(ctx context.Context, CustomerId string, orderId string, StoreId string, PaymentMethod string) (models.Reorder, error)
 }
 
 type OrderServise struct {
-	orderRepo repo.OrderRepo
+	orderRepo    repo.OrderRepo
+	customerRepo repo.CustomerRepo
 }
 
-func NewOrderService(orderRepo repo.OrderRepo) *OrderServise {
-	return &OrderServise{orderRepo: orderRepo}
+func NewOrderService(orderRepo repo.OrderRepo, customerRepo repo.CustomerRepo) *OrderServise {
+	return &OrderServise{orderRepo: orderRepo, customerRepo: customerRepo}
 }
 
 func (s *OrderServise) Create(ctx context.Context, order *models.Order) error {
-	log.Println("Create new order", order.OrderId)
+	if order.StoreId == "" {
+		return models.ErrMissingStore
+	}
+	if err := s.prefillCustomizations(ctx, order); err != nil {
+		return err
+	}
+	log.Println("Create new order in store", order.StoreId)
 	err := s.orderRepo.Create(ctx, order)
 	if err != nil {
-		log.Println("Failed to create order")
+		log.Printf("Failed to create order: %v", err)
 		return err
 	}
 	log.Println("Order created successfully", order.OrderId)
 	return nil
 }
 
-func (s *OrderServise) Orders(ctx context.Context) ([]models.Order, error) {
+// prefillCustomizations gives the items of the order that have no
+// customizations those of the preferences of its customer. An unknown
+// customer is left for the order itself to fail on.
+func (s *OrderServise) prefillCustomizations(ctx context.Context, order *models.Order) error {
+	if order.CustomerId == "" {
+		return nil
+	}
+	customer, err := s.customerRepo.GetCustomerByID(ctx, string(order.CustomerId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	customizations := customer.Preferences.Customizations()
+	for i := range order.OrderItems {
+		item := &order.OrderItems[i]
+		if c := strings.TrimSpace(string(item.Customizations)); c == "" || c == "{}" || c == "null" {
+			item.Customizations = customizations
+		}
+	}
+	return nil
+}
+
+func (s *OrderServise) Orders(ctx context.Context, StoreId string) ([]models.Order, error) {
 	log.Println("Get orders ")
-	orders, err := s.Orders(ctx)
+	orders, err := s.orderRepo.Orders(ctx, StoreId)
 	if err != nil {
 		log.Println("Failed to get orders")
 		return nil, err
@@ -46,9 +86,23 @@ func (s *OrderServise) Orders(ctx context.Context) ([]models.Order, error) {
 	return orders, nil
 }
 
-func (s *OrderServise) GetOrderByID(ctx context.Context, orderId string) (models.Order, error) {
+// orderInStore fails with sql.ErrNoRows unless the order is one of the
+// store. With no store every order is found.
+func (s *OrderServise) orderInStore(ctx context.Context, orderId string, StoreId string) (models.Order, error) {
+	order, err := s.orderRepo.GetOrderByID(ctx, orderId)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if StoreId != "" && string(order.StoreId) != StoreId {
+		return models.Order{}, fmt.Errorf("order not found in store: %w", sql.ErrNoRows)
+	}
+	return order, nil
+}
+
+// GetOrderByID returns an order of StoreId, or of any store when it is empty.
+func (s *OrderServise) GetOrderByID(ctx context.Context, orderId string, StoreId string) (models.Order, error) {
 	log.Println("Get order BY id")
-	order, err := s.GetOrderByID(ctx, orderId)
+	order, err := s.orderInStore(ctx, orderId, StoreId)
 	if err != nil {
 		log.Println("Failed to get order")
 		return models.Order{}, err
@@ -57,9 +111,13 @@ func (s *OrderServise) GetOrderByID(ctx context.Context, orderId string) (models
 	return order, nil
 }
 
-func (s *OrderServise) UpdateOrderItemByID(ctx context.Context, orderItems *models.OrderItems) error {
+func (s *OrderServise) UpdateOrderItemByID(ctx context.Context, orderItems *models.OrderItems, StoreId string) error {
 	log.Println("updateing order items")
-	err := s.UpdateOrderItemByID(ctx, orderItems)
+	if _, err := s.orderInStore(ctx, string(orderItems.OrderId), StoreId); err != nil {
+		log.Println("Failed update order item ")
+		return err
+	}
+	err := s.orderRepo.UpdateOrderItemByID(ctx, orderItems)
 	if err != nil {
 		log.Println("Failed update order item ")
 		return err
@@ -68,21 +126,108 @@ func (s *OrderServise) UpdateOrderItemByID(ctx context.Context, orderItems *mode
 	return nil
 }
 
-func (s *OrderServise) DeleteOrderByID(ctx context.Context, orderId string) error {
+func (s *OrderServise) DeleteOrderByID(ctx context.Context, orderId string, StoreId string) error {
 	log.Println("Deleting order")
-	err := s.DeleteOrderByID(ctx, orderId)
+	if _, err := s.orderInStore(ctx, orderId, StoreId); err != nil {
+		log.Println("Failed to delete order")
+		return err
+	}
+	err := s.orderRepo.DeleteOrderByID(ctx, orderId)
 	if err != nil {
 		log.Println("Failed to delete order")
 		return err
 	}
 	return nil
 }
-func (s *OrderServise) UpdateStatusOrder(ctx context.Context, orderId string, status string) error {
+func (s *OrderServise) UpdateStatusOrder(ctx context.Context, orderId string, status string, StoreId string) error {
 	log.Println("Updateing Order")
-
-	err := s.UpdateStatusOrder(ctx, orderId, status)
+	if _, err := s.orderInStore(ctx, orderId, StoreId); err != nil {
+		return err
+	}
+	err := s.orderRepo.UpdateStatusOrder(ctx, orderId, status)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+const (
+	defaultOrderPageSize = 20
+	maxOrderPageSize     = 100
+)
+
+// CustomerOrders returns a page of the order history of a customer. A zero
+// page or page size means the first page of defaultOrderPageSize orders.
+func (s *OrderServise) CustomerOrders(ctx context.Context, CustomerId string, filter models.OrderFilter) (models.OrderPage, error) {
+	if filter.Page == 0 {
+		filter.Page = 1
+	}
+	if filter.PageSize == 0 {
+		filter.PageSize = defaultOrderPageSize
+	}
+	if filter.Page < 0 || filter.PageSize < 0 || filter.PageSize > maxOrderPageSize {
+		return models.OrderPage{}, fmt.Errorf("page %d of %d orders: %w", filter.Page, filter.PageSize, models.ErrInvalidOrderFilter)
+	}
+	for _, status := range filter.Statuses {
+		if !slices.Contains(models.OrderStatuses, status) {
+			return models.OrderPage{}, fmt.Errorf("status %q: %w", status, models.ErrInvalidOrderFilter)
+		}
+	}
+	page, err := s.orderRepo.CustomerOrders(ctx, CustomerId, filter)
+	if err != nil {
+		log.Printf("Failed to get orders of customer [%s]: %v", CustomerId, err)
+		return models.OrderPage{}, fmt.Errorf("could not retrieve customer orders: %w", err)
+	}
+	return page, nil
+}
+
+// CustomerOrder returns an order of a customer. Orders of other customers,
+// and of other stores than StoreId when it is set, are not found.
+func (s *OrderServise) CustomerOrder(ctx context.Context, CustomerId string, orderId string, StoreId string) (models.Order, error) {
+	order, err := s.orderInStore(ctx, orderId, StoreId)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if string(order.CustomerId) != CustomerId {
+		return models.Order{}, fmt.Errorf("order not found: %w", sql.ErrNoRows)
+	}
+	return order, nil
+}
+
+// Reorder places a past order of a customer again at current prices, in
+// StoreId or else the store of the past order, paid with PaymentMethod or
+// else as the past order was. Items that can no longer be ordered are left
+// out and reported.
+func (s *OrderServise) Reorder(ctx context.Context, CustomerId string, orderId string, StoreId string, PaymentMethod string) (models.Reorder, error) {
+	log.Printf("Reordering order [%s] of customer [%s]", orderId, CustomerId)
+	past, err := s.orderRepo.GetOrderByID(ctx, orderId)
+	if err != nil {
+		return models.Reorder{}, err
+	}
+	if string(past.CustomerId) != CustomerId {
+		return models.Reorder{}, fmt.Errorf("order not found: %w", sql.ErrNoRows)
+	}
+	order := models.Order{
+		CustomerId:          past.CustomerId,
+		StoreId:             past.StoreId,
+		OrderItems:          past.OrderItems,
+		SpecialInstructions: past.SpecialInstructions,
+		PaymentMethod:       past.PaymentMethod,
+	}
+	if StoreId != "" {
+		order.StoreId = utils.TEXT(StoreId)
+	}
+	if PaymentMethod != "" {
+		order.PaymentMethod = utils.TEXT(PaymentMethod)
+	}
+	unavailable, err := s.orderRepo.Reorder(ctx, &order)
+	if err != nil {
+		if errors.Is(err, models.ErrNothingToReorder) {
+			return models.Reorder{Unavailable: unavailable}, err
+		}
+		log.Printf("Failed to reorder order [%s]: %v", orderId, err)
+		return models.Reorder{}, err
+	}
+	log.Printf("Order [%s] reordered successfully as [%s], %d items left out", orderId, order.OrderId, len(unavailable))
+	return models.Reorder{Order: &order, Unavailable: unavailable}, nil
+}
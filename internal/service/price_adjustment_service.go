@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"frappuccino/internal/repo"
+	"frappuccino/models"
+	"frappuccino/utils"
+	"log"
+	"math"
+	"strings"
+)
+
+type PriceAdjustmentServiceInf interface {
+	Create(ctx context.Context, adjustment *models.PriceAdjustment, preview bool) error
+	GetAll(ctx context.Context) ([]models.PriceAdjustment, error)
+	Revert(ctx context.Context, AdjustmentId string) (models.PriceAdjustment, error)
+}
+
+type PriceAdjustmentService struct {
+	adjustmentRepo repo.PriceAdjustmentRepo
+}
+
+func NewPriceAdjustmentService(adjustmentRepo repo.PriceAdjustmentRepo) *PriceAdjustmentService {
+	return &PriceAdjustmentService{adjustmentRepo: adjustmentRepo}
+}
+
+// validateAdjustment checks an adjustment and defaults it to rounding to the nearest cent.
+func validateAdjustment(adjustment *models.PriceAdjustment) error {
+	if adjustment.CategoryId == "" && adjustment.Tag == "" && len(adjustment.MenuItemIds) == 0 {
+		return fmt.Errorf("no category_id, tag or menu_item_ids: %w", models.ErrInvalidPriceAdjustment)
+	}
+	adjustment.ChangeType = utils.TEXT(strings.ToUpper(string(adjustment.ChangeType)))
+	if adjustment.ChangeType != models.PriceChangePercent && adjustment.ChangeType != models.PriceChangeAbsolute {
+		return fmt.Errorf("change_type %q: %w", adjustment.ChangeType, models.ErrInvalidPriceAdjustment)
+	}
+	if adjustment.RoundTo == 0 {
+		adjustment.RoundTo = 0.01
+	}
+	if adjustment.RoundTo < 0 {
+		return fmt.Errorf("round_to %v: %w", adjustment.RoundTo, models.ErrInvalidPriceAdjustment)
+	}
+	adjustment.Rounding = utils.TEXT(strings.ToUpper(string(adjustment.Rounding)))
+	switch adjustment.Rounding {
+	case "":
+		adjustment.Rounding = models.RoundingNearest
+	case models.RoundingNearest, models.RoundingUp, models.RoundingDown:
+	default:
+		return fmt.Errorf("rounding %q: %w", adjustment.Rounding, models.ErrInvalidPriceAdjustment)
+	}
+	return nil
+}
+
+// adjustedPrice applies the change of adjustment to price, rounds it to a
+// multiple of RoundTo and never goes below zero.
+func adjustedPrice(adjustment models.PriceAdjustment, price utils.DEC) utils.DEC {
+	p := float64(price)
+	if adjustment.ChangeType == models.PriceChangePercent {
+		p *= 1 + float64(adjustment.ChangeValue)/100
+	} else {
+		p += float64(adjustment.ChangeValue)
+	}
+	step := float64(adjustment.RoundTo)
+	// the epsilon keeps float noise from pushing exact multiples up or down
+	switch adjustment.Rounding {
+	case models.RoundingUp:
+		p = math.Ceil(p/step-1e-9) * step
+	case models.RoundingDown:
+		p = math.Floor(p/step+1e-9) * step
+	default:
+		p = math.Round(p/step) * step
+	}
+	return utils.DEC(math.Max(0, math.Round(p*100)/100))
+}
+
+// Create works out the new price of every targeted item and, unless
+// previewing, applies them as one batch. Items whose price would not change
+// are left out.
+func (s *PriceAdjustmentService) Create(ctx context.Context, adjustment *models.PriceAdjustment, preview bool) error {
+	if err := validateAdjustment(adjustment); err != nil {
+		return err
+	}
+	targets, err := s.adjustmentRepo.Targets(ctx, *adjustment)
+	if err != nil {
+		return fmt.Errorf("could not find menu items to adjust: %w", err)
+	}
+	adjustment.Changes = []models.PriceChange{}
+	for _, c := range targets {
+		c.NewPrice = adjustedPrice(*adjustment, c.OldPrice)
+		if c.NewPrice != c.OldPrice {
+			adjustment.Changes = append(adjustment.Changes, c)
+		}
+	}
+	if preview {
+		return nil
+	}
+
+	log.Printf("Adjusting %d prices", len(adjustment.Changes))
+	if err := s.adjustmentRepo.Create(ctx, adjustment); err != nil {
+		log.Printf("Failed to adjust prices: %v", err)
+		return fmt.Errorf("could not adjust prices: %w", err)
+	}
+	log.Println("Price adjustment applied successfully:", adjustment.AdjustmentId)
+	return nil
+}
+
+func (s *PriceAdjustmentService) GetAll(ctx context.Context) ([]models.PriceAdjustment, error) {
+	adjustments, err := s.adjustmentRepo.GetAll(ctx)
+	if err != nil {
+		log.Printf("Failed to fetch price adjustments: %v", err)
+		return nil, fmt.Errorf("could not retrieve price adjustments: %w", err)
+	}
+	return adjustments, nil
+}
+
+func (s *PriceAdjustmentService) Revert(ctx context.Context, AdjustmentId string) (models.PriceAdjustment, error) {
+	log.Printf("Reverting price adjustment [%s]", AdjustmentId)
+	adjustment, err := s.adjustmentRepo.Revert(ctx, AdjustmentId)
+	if err != nil {
+		log.Printf("Failed to revert price adjustment [%s]: %v", AdjustmentId, err)
+		return models.PriceAdjustment{}, fmt.Errorf("could not revert price adjustment: %w", err)
+	}
+	log.Printf("Price adjustment [%s] reverted successfully", AdjustmentId)
+	return adjustment, nil
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"frappuccino/models"
+	"frappuccino/utils"
+	"math"
+	"sort"
+	"strings"
+)
+
+// recipeBook resolves ingredient costs and availability through the
+// recipes of prepared ingredients.
+type recipeBook struct {
+	recipes map[utils.TEXT]models.Recipe
+	// ingredients by id, carrying the unit cost and the stock of one store
+	ingredients map[utils.TEXT]models.Inventory
+}
+
+func newRecipeBook(recipes map[utils.TEXT]models.Recipe, inventory []models.Inventory) *recipeBook {
+	b := &recipeBook{
+		recipes:     recipes,
+		ingredients: make(map[utils.TEXT]models.Inventory, len(inventory)),
+	}
+	for _, ingredient := range inventory {
+		b.ingredients[ingredient.IngredientId] = ingredient
+	}
+	return b
+}
+
+// unitCost returns the cost of one unit of an ingredient. Prepared
+// ingredients cost their components divided by the batch yield.
+func (b *recipeBook) unitCost(id utils.TEXT) (utils.DEC, error) {
+	return b.unitCostVisiting(id, map[utils.TEXT]bool{})
+}
+
+func (b *recipeBook) unitCostVisiting(id utils.TEXT, visiting map[utils.TEXT]bool) (utils.DEC, error) {
+	recipe, ok := b.recipes[id]
+	if !ok || recipe.BatchYield <= 0 {
+		return b.ingredients[id].UnitCost, nil
+	}
+	if visiting[id] {
+		return 0, models.ErrRecipeCycle
+	}
+	visiting[id] = true
+	defer delete(visiting, id)
+
+	var batchCost utils.DEC
+	for _, c := range recipe.Components {
+		cost, err := b.unitCostVisiting(c.IngredientId, visiting)
+		if err != nil {
+			return 0, err
+		}
+		batchCost += cost * c.Quantity
+	}
+	return batchCost / recipe.BatchYield, nil
+}
+
+// available returns the stock of an ingredient plus what can still be
+// produced from the stock of its components. Components shared between
+// several sub-recipes are counted for each of them.
+func (b *recipeBook) available(id utils.TEXT) (utils.DEC, error) {
+	return b.availableVisiting(id, map[utils.TEXT]bool{})
+}
+
+func (b *recipeBook) availableVisiting(id utils.TEXT, visiting map[utils.TEXT]bool) (utils.DEC, error) {
+	stock := b.ingredients[id].Quantity
+	recipe, ok := b.recipes[id]
+	if !ok || recipe.BatchYield <= 0 || len(recipe.Components) == 0 {
+		return stock, nil
+	}
+	if visiting[id] {
+		return 0, models.ErrRecipeCycle
+	}
+	visiting[id] = true
+	defer delete(visiting, id)
+
+	batches := utils.DEC(math.Inf(1))
+	for _, c := range recipe.Components {
+		have, err := b.availableVisiting(c.IngredientId, visiting)
+		if err != nil {
+			return 0, err
+		}
+		batches = min(batches, have/c.Quantity)
+	}
+	return stock + utils.DEC(math.Floor(float64(batches)))*recipe.BatchYield, nil
+}
+
+// unitNutrition returns the nutrition of one unit of an ingredient. Prepared
+// ingredients carry the nutrition of their components divided by the batch yield.
+func (b *recipeBook) unitNutrition(id utils.TEXT) (models.Nutrition, error) {
+	return b.unitNutritionVisiting(id, map[utils.TEXT]bool{})
+}
+
+func (b *recipeBook) unitNutritionVisiting(id utils.TEXT, visiting map[utils.TEXT]bool) (models.Nutrition, error) {
+	recipe, ok := b.recipes[id]
+	if !ok || recipe.BatchYield <= 0 {
+		return b.ingredients[id].Nutrition, nil
+	}
+	if visiting[id] {
+		return models.Nutrition{}, models.ErrRecipeCycle
+	}
+	visiting[id] = true
+	defer delete(visiting, id)
+
+	var batch models.Nutrition
+	for _, c := range recipe.Components {
+		n, err := b.unitNutritionVisiting(c.IngredientId, visiting)
+		if err != nil {
+			return models.Nutrition{}, err
+		}
+		batch = batch.Add(n, c.Quantity)
+	}
+	return models.Nutrition{}.Add(batch, 1/recipe.BatchYield), nil
+}
+
+// nutrition returns the nutrition of one serving of a recipe.
+func (b *recipeBook) nutrition(ingredients []models.MenuItemsIngredients) (models.Nutrition, error) {
+	var total models.Nutrition
+	for _, ingredient := range ingredients {
+		n, err := b.unitNutrition(ingredient.IngredientId)
+		if err != nil {
+			return models.Nutrition{}, err
+		}
+		total = total.Add(n, ingredient.Quantity)
+	}
+	return total, nil
+}
+
+// servings returns how many servings of a recipe can be made.
+func (b *recipeBook) servings(ingredients []models.MenuItemsIngredients) (utils.DEC, error) {
+	if len(ingredients) == 0 {
+		return 0, nil
+	}
+	servings := utils.DEC(math.Inf(1))
+	for _, ingredient := range ingredients {
+		have, err := b.available(ingredient.IngredientId)
+		if err != nil {
+			return 0, err
+		}
+		servings = min(servings, have/ingredient.Quantity)
+	}
+	return utils.DEC(math.Floor(float64(servings))), nil
+}
+
+// cost returns the ingredient cost of one serving of a recipe.
+func (b *recipeBook) cost(ingredients []models.MenuItemsIngredients) (utils.DEC, error) {
+	var total utils.DEC
+	for _, ingredient := range ingredients {
+		cost, err := b.unitCost(ingredient.IngredientId)
+		if err != nil {
+			return 0, err
+		}
+		total += cost * ingredient.Quantity
+	}
+	return total, nil
+}
+
+// dietary returns the allergens of an ingredient, including those of its
+// sub-recipes, and the diets it suits. A prepared ingredient suits only the
+// diets every one of its components suits.
+func (b *recipeBook) dietary(id utils.TEXT) (map[string]bool, map[string]bool, error) {
+	return b.dietaryVisiting(id, map[utils.TEXT]bool{})
+}
+
+func (b *recipeBook) dietaryVisiting(id utils.TEXT, visiting map[utils.TEXT]bool) (map[string]bool, map[string]bool, error) {
+	ingredient := b.ingredients[id]
+	allergens := make(map[string]bool)
+	for _, a := range ingredient.Allergens {
+		allergens[a] = true
+	}
+	recipe, ok := b.recipes[id]
+	if !ok || len(recipe.Components) == 0 {
+		diets := make(map[string]bool)
+		for _, d := range ingredient.Diets {
+			diets[d] = true
+		}
+		return allergens, diets, nil
+	}
+	if visiting[id] {
+		return nil, nil, models.ErrRecipeCycle
+	}
+	visiting[id] = true
+	defer delete(visiting, id)
+
+	var diets map[string]bool
+	for _, c := range recipe.Components {
+		a, d, err := b.dietaryVisiting(c.IngredientId, visiting)
+		if err != nil {
+			return nil, nil, err
+		}
+		for flag := range a {
+			allergens[flag] = true
+		}
+		diets = intersectFlags(diets, d)
+	}
+	return allergens, diets, nil
+}
+
+// recipeDietary returns the allergens and diets of a recipe: the union of
+// the allergens and the intersection of the diets of its ingredients.
+func (b *recipeBook) recipeDietary(ingredients []models.MenuItemsIngredients) (map[string]bool, map[string]bool, error) {
+	allergens := make(map[string]bool)
+	var diets map[string]bool
+	for _, ingredient := range ingredients {
+		a, d, err := b.dietary(ingredient.IngredientId)
+		if err != nil {
+			return nil, nil, err
+		}
+		for flag := range a {
+			allergens[flag] = true
+		}
+		diets = intersectFlags(diets, d)
+	}
+	if diets == nil {
+		diets = make(map[string]bool)
+	}
+	return allergens, diets, nil
+}
+
+// intersectFlags returns the flags in both sets; a nil acc stands for "any flag".
+func intersectFlags(acc, flags map[string]bool) map[string]bool {
+	if acc == nil {
+		result := make(map[string]bool, len(flags))
+		for flag := range flags {
+			result[flag] = true
+		}
+		return result
+	}
+	for flag := range acc {
+		if !flags[flag] {
+			delete(acc, flag)
+		}
+	}
+	return acc
+}
+
+// sortedFlags lists a flag set in a stable order.
+func sortedFlags(flags map[string]bool) utils.TEXTARR {
+	list := make(utils.TEXTARR, 0, len(flags))
+	for flag := range flags {
+		list = append(list, flag)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// normalizeFlags lowercases and deduplicates allergen or diet flags.
+func normalizeFlags(flags utils.TEXTARR) utils.TEXTARR {
+	set := make(map[string]bool, len(flags))
+	for _, flag := range flags {
+		if flag = strings.ToLower(strings.TrimSpace(flag)); flag != "" {
+			set[flag] = true
+		}
+	}
+	return sortedFlags(set)
+}
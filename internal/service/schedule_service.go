@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"frappuccino/internal/repo"
+	"frappuccino/models"
+	"log"
+	"time"
+)
+
+type ScheduleServiceInf interface {
+	Create(ctx context.Context, schedule *models.MenuSchedule) error
+	GetAll(ctx context.Context, MenuItemId string, CategoryId string) ([]models.MenuSchedule, error)
+	UpdateScheduleByID(ctx context.Context, schedule *models.MenuSchedule) error
+	DeleteScheduleByID(ctx context.Context, ScheduleId string) error
+}
+
+type ScheduleService struct {
+	scheduleRepo repo.ScheduleRepo
+}
+
+func NewScheduleService(scheduleRepo repo.ScheduleRepo) *ScheduleService {
+	return &ScheduleService{scheduleRepo: scheduleRepo}
+}
+
+// validateSchedule checks a schedule and defaults it to every day of the week.
+func validateSchedule(schedule *models.MenuSchedule) error {
+	if (schedule.MenuItemId == "") == (schedule.CategoryId == "") {
+		return models.ErrInvalidSchedule
+	}
+	for _, t := range []string{string(schedule.StartTime), string(schedule.EndTime)} {
+		if _, err := time.Parse("15:04", t); t != "" && err != nil {
+			return fmt.Errorf("time %q: %w", t, models.ErrInvalidSchedule)
+		}
+	}
+	var dates []time.Time
+	for _, d := range []string{string(schedule.StartDate), string(schedule.EndDate)} {
+		if d == "" {
+			continue
+		}
+		date, err := time.Parse(time.DateOnly, d)
+		if err != nil {
+			return fmt.Errorf("date %q: %w", d, models.ErrInvalidSchedule)
+		}
+		dates = append(dates, date)
+	}
+	if len(dates) == 2 && dates[1].Before(dates[0]) {
+		return fmt.Errorf("end_date before start_date: %w", models.ErrInvalidSchedule)
+	}
+	if len(schedule.DaysOfWeek) == 0 {
+		schedule.DaysOfWeek = []int64{1, 2, 3, 4, 5, 6, 7}
+	}
+	for _, day := range schedule.DaysOfWeek {
+		if day < 1 || day > 7 {
+			return fmt.Errorf("day %d: %w", day, models.ErrInvalidSchedule)
+		}
+	}
+	return nil
+}
+
+func (s *ScheduleService) Create(ctx context.Context, schedule *models.MenuSchedule) error {
+	if err := validateSchedule(schedule); err != nil {
+		return err
+	}
+	log.Println("Creating new menu schedule")
+	err := s.scheduleRepo.Create(ctx, schedule)
+	if err != nil {
+		log.Printf("Failed to create menu schedule: %v", err)
+		return fmt.Errorf("could not create schedule: %w", err)
+	}
+	log.Println("Menu schedule created successfully:", schedule.ScheduleId)
+	return nil
+}
+
+func (s *ScheduleService) GetAll(ctx context.Context, MenuItemId string, CategoryId string) ([]models.MenuSchedule, error) {
+	schedules, err := s.scheduleRepo.GetAll(ctx, MenuItemId, CategoryId)
+	if err != nil {
+		log.Printf("Failed to fetch menu schedules: %v", err)
+		return nil, fmt.Errorf("could not retrieve schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+func (s *ScheduleService) UpdateScheduleByID(ctx context.Context, schedule *models.MenuSchedule) error {
+	if err := validateSchedule(schedule); err != nil {
+		return err
+	}
+	log.Printf("Updating menu schedule [%s]", schedule.ScheduleId)
+	err := s.scheduleRepo.UpdateScheduleByID(ctx, schedule)
+	if err != nil {
+		log.Printf("Failed to update menu schedule [%s]: %v", schedule.ScheduleId, err)
+		return fmt.Errorf("could not update schedule: %w", err)
+	}
+	return nil
+}
+
+func (s *ScheduleService) DeleteScheduleByID(ctx context.Context, ScheduleId string) error {
+	log.Printf("Deleting menu schedule [%s]", ScheduleId)
+	err := s.scheduleRepo.DeleteScheduleByID(ctx, ScheduleId)
+	if err != nil {
+		log.Printf("Failed to delete menu schedule [%s]: %v", ScheduleId, err)
+		return fmt.Errorf("could not delete schedule: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"frappuccino/internal/repo"
+	"frappuccino/models"
+	"frappuccino/utils"
+	"log"
+	"strings"
+)
+
+type SegmentServiceInf interface {
+	Create(ctx context.Context, segment *models.SegmentDefinition) error
+	GetAll(ctx context.Context) ([]models.SegmentDefinition, error)
+	UpdateSegmentByID(ctx context.Context, segment *models.SegmentDefinition) error
+	DeleteSegmentByID(ctx context.Context, SegmentId string) error
+}
+
+type SegmentService struct {
+	segmentRepo repo.SegmentRepo
+}
+
+func NewSegmentService(segmentRepo repo.SegmentRepo) *SegmentService {
+	return &SegmentService{segmentRepo: segmentRepo}
+}
+
+// validateSegment trims the name and checks the score ranges. A range left
+// at zero spans every score.
+func validateSegment(segment *models.SegmentDefinition) error {
+	segment.SegmentName = utils.TEXT(strings.TrimSpace(string(segment.SegmentName)))
+	if segment.SegmentName == "" {
+		return fmt.Errorf("segment name is required: %w", models.ErrInvalidSegment)
+	}
+	for _, r := range []struct {
+		name     string
+		min, max *utils.INT
+	}{
+		{"recency", &segment.MinRecency, &segment.MaxRecency},
+		{"frequency", &segment.MinFrequency, &segment.MaxFrequency},
+		{"monetary", &segment.MinMonetary, &segment.MaxMonetary},
+	} {
+		if *r.min == 0 {
+			*r.min = 1
+		}
+		if *r.max == 0 {
+			*r.max = 5
+		}
+		if *r.min < 1 || *r.min > *r.max || *r.max > 5 {
+			return fmt.Errorf("%s range %d-%d: %w", r.name, *r.min, *r.max, models.ErrInvalidSegment)
+		}
+	}
+	return nil
+}
+
+func (s *SegmentService) Create(ctx context.Context, segment *models.SegmentDefinition) error {
+	if err := validateSegment(segment); err != nil {
+		return err
+	}
+	log.Printf("Creating segment %q", segment.SegmentName)
+	if err := s.segmentRepo.Create(ctx, segment); err != nil {
+		log.Printf("Failed to create segment %q: %v", segment.SegmentName, err)
+		return fmt.Errorf("could not create segment: %w", err)
+	}
+	log.Printf("Segment [%s] created successfully", segment.SegmentId)
+	return nil
+}
+
+// GetAll returns the saved segments followed by the built-in ones they do
+// not replace, in the order customers are matched against them.
+func (s *SegmentService) GetAll(ctx context.Context) ([]models.SegmentDefinition, error) {
+	segments, err := segmentDefinitions(ctx, s.segmentRepo)
+	if err != nil {
+		log.Printf("Failed to fetch segments: %v", err)
+		return nil, fmt.Errorf("could not retrieve segments: %w", err)
+	}
+	return segments, nil
+}
+
+func (s *SegmentService) UpdateSegmentByID(ctx context.Context, segment *models.SegmentDefinition) error {
+	if err := validateSegment(segment); err != nil {
+		return err
+	}
+	log.Printf("Updating segment [%s]", segment.SegmentId)
+	if err := s.segmentRepo.UpdateSegmentByID(ctx, segment); err != nil {
+		log.Printf("Failed to update segment [%s]: %v", segment.SegmentId, err)
+		return fmt.Errorf("could not update segment: %w", err)
+	}
+	log.Printf("Segment [%s] updated successfully", segment.SegmentId)
+	return nil
+}
+
+func (s *SegmentService) DeleteSegmentByID(ctx context.Context, SegmentId string) error {
+	log.Printf("Deleting segment [%s]", SegmentId)
+	if err := s.segmentRepo.DeleteSegmentByID(ctx, SegmentId); err != nil {
+		log.Printf("Failed to delete segment [%s]: %v", SegmentId, err)
+		return fmt.Errorf("could not delete segment: %w", err)
+	}
+	log.Printf("Segment [%s] deleted successfully", SegmentId)
+	return nil
+}
+
+// segmentDefinitions returns the saved segments, then the built-in segments
+// no saved one has the name of.
+func segmentDefinitions(ctx context.Context, segmentRepo repo.SegmentRepo) ([]models.SegmentDefinition, error) {
+	segments, err := segmentRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	saved := make(map[utils.TEXT]bool, len(segments))
+	for _, d := range segments {
+		saved[d.SegmentName] = true
+	}
+	for _, d := range models.DefaultSegments {
+		if !saved[d.SegmentName] {
+			segments = append(segments, d)
+		}
+	}
+	return segments, nil
+}
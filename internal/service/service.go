@@ -1,19 +1,47 @@
 package service
 
-import "frappuccino/internal/repo"
+import (
+	"frappuccino/internal/auth"
+	"frappuccino/internal/repo"
+	"frappuccino/internal/storage"
+)
 
 type Service struct {
-	CustomerService  CustomerServiceInf
-	InventoryService InventoryServiceInf
-	MenuService      MenuServiceInf
-	OrderService     OrderServiseInf
+	CustomerService        CustomerServiceInf
+	InventoryService       InventoryServiceInf
+	MenuService            MenuServiceInf
+	OrderService           OrderServiseInf
+	AggregationService     AggregationServiceInf
+	StoreService           StoreServiceInf
+	TransferService        TransferServiceInf
+	ModifierService        ModifierServiceInf
+	ScheduleService        ScheduleServiceInf
+	MenuVersionService     MenuVersionServiceInf
+	PriceAdjustmentService PriceAdjustmentServiceInf
+	CategoryService        CategoryServiceInf
+	TranslationService     TranslationServiceInf
+	SegmentService         SegmentServiceInf
+	AccountService         AccountServiceInf
+	StaffService           StaffServiceInf
 }
 
-func New(repo *repo.Repository) *Service {
+func New(repo *repo.Repository, media storage.Storage, sender auth.CodeSender, signer *auth.Signer) *Service {
 	var service Service
-	service.CustomerService = NewCustomerService(repo.CustomerRepo)
+	service.CustomerService = NewCustomerService(repo.CustomerRepo, repo.OrderRepo)
 	service.InventoryService = NewInventoryService(repo.InventoryRepo)
-	service.MenuService = NewMenuService(repo.MenuRepo)
-	service.OrderService = NewOrderService(repo.OrderRepo)
+	service.MenuService = NewMenuService(repo.MenuRepo, repo.InventoryRepo, repo.TranslationRepo, media)
+	service.OrderService = NewOrderService(repo.OrderRepo, repo.CustomerRepo)
+	service.AggregationService = NewAggregationService(repo.AggregationRepo, repo.SegmentRepo)
+	service.StoreService = NewStoreService(repo.StoreRepo)
+	service.TransferService = NewTransferService(repo.TransferRepo)
+	service.ModifierService = NewModifierService(repo.ModifierRepo)
+	service.ScheduleService = NewScheduleService(repo.ScheduleRepo)
+	service.MenuVersionService = NewMenuVersionService(repo.MenuRepo, repo.MenuVersionRepo)
+	service.PriceAdjustmentService = NewPriceAdjustmentService(repo.PriceAdjustmentRepo)
+	service.CategoryService = NewCategoryService(repo.CategoryRepo, repo.TranslationRepo)
+	service.TranslationService = NewTranslationService(repo.TranslationRepo)
+	service.SegmentService = NewSegmentService(repo.SegmentRepo)
+	service.AccountService = NewAccountService(repo.AccountRepo, sender)
+	service.StaffService = NewStaffService(repo.StaffRepo, signer)
 	return &service
 }
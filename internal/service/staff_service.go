@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"frappuccino/internal/auth"
+	"frappuccino/internal/repo"
+	"frappuccino/models"
+	"frappuccino/utils"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+type StaffServiceInf interface {
+	Login(ctx context.Context, login models.StaffLogin) (models.StaffSession, error)
+	Authenticate(ctx context.Context, token string) (models.StaffUser, error)
+	Create(ctx context.Context, staff *models.StaffUser) error
+	GetAll(ctx context.Context, archived bool) ([]models.StaffUser, error)
+	GetStaffByID(ctx context.Context, StaffId string) (models.StaffUser, error)
+	UpdateStaffByID(ctx context.Context, staff *models.StaffUser) error
+	SetPassword(ctx context.Context, StaffId string, password string) error
+	ChangeOwnPassword(ctx context.Context, StaffId string, change models.PasswordChange) error
+	DeleteStaffByID(ctx context.Context, StaffId string) error
+	Bootstrap(ctx context.Context, username string, password string) error
+	RecordDenial(ctx context.Context, denial *models.AccessDenial) error
+	Denials(ctx context.Context, limit int) ([]models.AccessDenial, error)
+}
+
+// staffTokenTTL is how long staff tokens last: about a shift.
+const staffTokenTTL = 12 * time.Hour
+
+var usernamePattern = regexp.MustCompile(`^[a-z0-9._-]{3,50}$`)
+
+type StaffService struct {
+	staffRepo repo.StaffRepo
+	signer    *auth.Signer
+}
+
+func NewStaffService(staffRepo repo.StaffRepo, signer *auth.Signer) *StaffService {
+	return &StaffService{staffRepo: staffRepo, signer: signer}
+}
+
+// Login checks the password of an active staff user and returns a signed
+// token for them. Too many wrong passwords in a row lock their logins for a
+// while, as for customers, and fail with ErrLoginLocked.
+func (s *StaffService) Login(ctx context.Context, login models.StaffLogin) (models.StaffSession, error) {
+	staff, hash, err := s.staffRepo.GetByUsername(ctx, strings.ToLower(strings.TrimSpace(login.Username)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			auth.CheckPassword(dummyPasswordHash, login.Password)
+			log.Printf("Failed staff login as '%s': no such user", login.Username)
+			return models.StaffSession{}, models.ErrInvalidCredentials
+		}
+		return models.StaffSession{}, err
+	}
+	if staff.LockedUntil != nil && time.Now().Before(time.Time(*staff.LockedUntil)) {
+		log.Printf("Staff login as '%s' is locked until %s", login.Username, time.Time(*staff.LockedUntil).Format(time.RFC3339))
+		return models.StaffSession{}, models.ErrLoginLocked
+	}
+	if !auth.CheckPassword(hash, login.Password) {
+		log.Printf("Failed staff login as '%s': wrong password", login.Username)
+		if err := s.staffRepo.FailLogin(ctx, string(staff.StaffId), loginAttempts, loginLockout); err != nil {
+			return models.StaffSession{}, err
+		}
+		return models.StaffSession{}, models.ErrInvalidCredentials
+	}
+	if err := s.staffRepo.ResetFailedLogins(ctx, string(staff.StaffId)); err != nil {
+		return models.StaffSession{}, err
+	}
+	now := time.Now()
+	expiresAt := now.Add(staffTokenTTL)
+	token, err := s.signer.Sign(auth.Claims{
+		Subject:   string(staff.StaffId),
+		Role:      string(staff.Role),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return models.StaffSession{}, err
+	}
+	log.Printf("Staff user [%s] %s logged in", staff.StaffId, staff.Username)
+	return models.StaffSession{Token: token, Staff: staff, ExpiresAt: utils.TIME(expiresAt)}, nil
+}
+
+// Authenticate returns the staff user of a signed token. The user is read
+// afresh, so that archiving them, changing their role or changing their
+// password, which ends the tokens issued before, takes effect at once. It
+// fails with ErrUnauthenticated.
+func (s *StaffService) Authenticate(ctx context.Context, token string) (models.StaffUser, error) {
+	claims, err := s.signer.Verify(token, time.Now())
+	if err != nil {
+		return models.StaffUser{}, models.ErrUnauthenticated
+	}
+	staff, err := s.staffRepo.GetStaffByID(ctx, claims.Subject)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.StaffUser{}, models.ErrUnauthenticated
+		}
+		return models.StaffUser{}, err
+	}
+	if staff.ArchivedAt != nil || claims.IssuedAt < time.Time(staff.PasswordChangedAt).Unix() {
+		return models.StaffUser{}, models.ErrUnauthenticated
+	}
+	return staff, nil
+}
+
+// normalizeStaff trims the name and store of staff, uppercases its role and
+// checks it.
+func normalizeStaff(staff *models.StaffUser) error {
+	staff.FullName = utils.TEXT(strings.TrimSpace(string(staff.FullName)))
+	staff.Role = models.Role(strings.ToUpper(strings.TrimSpace(string(staff.Role))))
+	staff.StoreId = utils.TEXT(strings.TrimSpace(string(staff.StoreId)))
+	if !slices.Contains(models.Roles, staff.Role) {
+		return fmt.Errorf("role %q: %w", staff.Role, models.ErrInvalidStaff)
+	}
+	return nil
+}
+
+func (s *StaffService) Create(ctx context.Context, staff *models.StaffUser) error {
+	staff.Username = utils.TEXT(strings.ToLower(strings.TrimSpace(string(staff.Username))))
+	if !usernamePattern.MatchString(string(staff.Username)) {
+		return fmt.Errorf("username %q: %w", staff.Username, models.ErrInvalidStaff)
+	}
+	if err := normalizeStaff(staff); err != nil {
+		return err
+	}
+	if problem := passwordProblem(staff.Password); problem != "" {
+		return &models.ValidationError{Err: models.ErrInvalidPassword, Fields: map[string]string{"password": problem}}
+	}
+	hash, err := auth.HashPassword(staff.Password)
+	if err != nil {
+		return err
+	}
+	staff.Password = ""
+	log.Printf("Creating staff user %s as %s", staff.Username, staff.Role)
+	if err := s.staffRepo.Create(ctx, staff, hash); err != nil {
+		log.Printf("Failed to create staff user %s: %v", staff.Username, err)
+		return fmt.Errorf("could not create staff user: %w", err)
+	}
+	log.Printf("Staff user created successfully: %s", staff.StaffId)
+	return nil
+}
+
+func (s *StaffService) GetAll(ctx context.Context, archived bool) ([]models.StaffUser, error) {
+	users, err := s.staffRepo.GetAll(ctx, archived)
+	if err != nil {
+		log.Printf("Failed to fetch staff users: %v", err)
+		return nil, fmt.Errorf("could not get staff users: %w", err)
+	}
+	return users, nil
+}
+
+func (s *StaffService) GetStaffByID(ctx context.Context, StaffId string) (models.StaffUser, error) {
+	staff, err := s.staffRepo.GetStaffByID(ctx, StaffId)
+	if err != nil {
+		return models.StaffUser{}, fmt.Errorf("could not get staff user: %w", err)
+	}
+	return staff, nil
+}
+
+// UpdateStaffByID changes the name, role and store of a staff user. The
+// username stays as it is.
+func (s *StaffService) UpdateStaffByID(ctx context.Context, staff *models.StaffUser) error {
+	if err := normalizeStaff(staff); err != nil {
+		return err
+	}
+	log.Printf("Updating staff user [%s]", staff.StaffId)
+	if err := s.staffRepo.UpdateStaffByID(ctx, staff); err != nil {
+		log.Printf("Failed to update staff user [%s]: %v", staff.StaffId, err)
+		return fmt.Errorf("could not update staff user: %w", err)
+	}
+	log.Printf("Staff user [%s] updated successfully", staff.StaffId)
+	return nil
+}
+
+// SetPassword replaces the password of a staff user, which ends their tokens.
+func (s *StaffService) SetPassword(ctx context.Context, StaffId string, password string) error {
+	if problem := passwordProblem(password); problem != "" {
+		return &models.ValidationError{Err: models.ErrInvalidPassword, Fields: map[string]string{"password": problem}}
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := s.staffRepo.SetPassword(ctx, StaffId, hash); err != nil {
+		log.Printf("Failed to set password of staff user [%s]: %v", StaffId, err)
+		return fmt.Errorf("could not set staff password: %w", err)
+	}
+	log.Printf("Password of staff user [%s] set successfully", StaffId)
+	return nil
+}
+
+// ChangeOwnPassword replaces the password of staff changing their own,
+// who must give the current one. A wrong one counts as a failed login, so
+// it locks like one. All their tokens end, the one in use included.
+func (s *StaffService) ChangeOwnPassword(ctx context.Context, StaffId string, change models.PasswordChange) error {
+	staff, err := s.staffRepo.GetStaffByID(ctx, StaffId)
+	if err != nil {
+		return fmt.Errorf("could not get staff user: %w", err)
+	}
+	if staff.LockedUntil != nil && time.Now().Before(time.Time(*staff.LockedUntil)) {
+		return models.ErrLoginLocked
+	}
+	current, err := s.staffRepo.PasswordHash(ctx, StaffId)
+	if err != nil {
+		return fmt.Errorf("could not get staff user: %w", err)
+	}
+	if !auth.CheckPassword(current, change.CurrentPassword) {
+		log.Printf("Wrong current password of staff user [%s]", StaffId)
+		if err := s.staffRepo.FailLogin(ctx, StaffId, loginAttempts, loginLockout); err != nil {
+			return err
+		}
+		return &models.ValidationError{Err: models.ErrWrongPassword, Fields: map[string]string{"current_password": "is not the current password"}}
+	}
+	return s.SetPassword(ctx, StaffId, change.Password)
+}
+
+func (s *StaffService) DeleteStaffByID(ctx context.Context, StaffId string) error {
+	log.Printf("Archiving staff user [%s]", StaffId)
+	if err := s.staffRepo.DeleteStaffByID(ctx, StaffId); err != nil {
+		log.Printf("Failed to archive staff user [%s]: %v", StaffId, err)
+		return fmt.Errorf("could not delete staff user: %w", err)
+	}
+	log.Printf("Staff user [%s] archived successfully", StaffId)
+	return nil
+}
+
+// Bootstrap creates the first admin when there are no staff users yet, so
+// that the API can be used at all. It does nothing once there are some.
+func (s *StaffService) Bootstrap(ctx context.Context, username string, password string) error {
+	n, err := s.staffRepo.Count(ctx)
+	if err != nil || n > 0 {
+		return err
+	}
+	if username == "" || password == "" {
+		log.Println("No staff users yet: set STAFF_ADMIN_USERNAME and STAFF_ADMIN_PASSWORD to create the first admin")
+		return nil
+	}
+	return s.Create(ctx, &models.StaffUser{Username: utils.TEXT(username), FullName: "Administrator", Role: models.RoleAdmin, Password: password})
+}
+
+// RecordDenial logs a denied request and, when it came from a logged-in
+// member of staff, keeps it for GET /staff/denials. Anonymous requests are
+// only logged, so that they cannot fill the table.
+func (s *StaffService) RecordDenial(ctx context.Context, denial *models.AccessDenial) error {
+	if denial.StaffId == "" {
+		log.Printf("Access denied: %s %s by anonymous from %s, needs %s: %s",
+			denial.Method, denial.Path, denial.RemoteAddr, denial.Permission, denial.Reason)
+		return nil
+	}
+	log.Printf("Access denied: %s %s by staff user [%s] %s from %s, needs %s: %s",
+		denial.Method, denial.Path, denial.StaffId, denial.Username, denial.RemoteAddr, denial.Permission, denial.Reason)
+	if err := s.staffRepo.RecordDenial(ctx, denial); err != nil {
+		log.Printf("Failed to record access denial: %v", err)
+		return err
+	}
+	return nil
+}
+
+// maxDenials caps how many denied requests Denials returns.
+const maxDenials = 500
+
+// Denials returns the latest denied requests, 100 unless limit says otherwise.
+func (s *StaffService) Denials(ctx context.Context, limit int) ([]models.AccessDenial, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	limit = min(limit, maxDenials)
+	denials, err := s.staffRepo.Denials(ctx, limit)
+	if err != nil {
+		log.Printf("Failed to fetch access denials: %v", err)
+		return nil, fmt.Errorf("could not get access denials: %w", err)
+	}
+	return denials, nil
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"frappuccino/internal/repo"
+	"frappuccino/models"
+	"log"
+	"time"
+)
+
+type StoreServiceInf interface {
+	Create(ctx context.Context, store *models.Store) error
+	GetAll(ctx context.Context) ([]models.Store, error)
+	GetStoreByID(ctx context.Context, StoreId string) (models.Store, error)
+	UpdateStoreByID(ctx context.Context, store *models.Store) error
+	DeleteStoreByID(ctx context.Context, StoreId string) error
+	SetMenuItem(ctx context.Context, item *models.StoreMenuItem) error
+	DeleteMenuItem(ctx context.Context, StoreId string, MenuItemId string) error
+}
+
+type StoreService struct {
+	storeRepo repo.StoreRepo
+}
+
+func NewStoreService(storeRepo repo.StoreRepo) *StoreService {
+	return &StoreService{storeRepo: storeRepo}
+}
+
+func (s *StoreService) Create(ctx context.Context, store *models.Store) error {
+	if store.StoreName == "" {
+		return models.ErrInvalidStoreName
+	}
+	if store.TimeZone == "" {
+		store.TimeZone = "UTC"
+	}
+	if err := checkTimeZone(string(store.TimeZone)); err != nil {
+		return err
+	}
+	log.Println("Creating new store:", store.StoreName)
+	err := s.storeRepo.Create(ctx, store)
+	if err != nil {
+		log.Printf("Failed to create store '%s': %v", store.StoreName, err)
+		return fmt.Errorf("could not create store: %w", err)
+	}
+	log.Println("Store created successfully:", store.StoreId)
+	return nil
+}
+
+func (s *StoreService) GetAll(ctx context.Context) ([]models.Store, error) {
+	log.Println("Fetching all stores")
+	stores, err := s.storeRepo.GetAll(ctx)
+	if err != nil {
+		log.Printf("Failed to fetch stores: %v", err)
+		return nil, fmt.Errorf("could not retrieve stores: %w", err)
+	}
+	log.Printf("Retrieved %d stores", len(stores))
+	return stores, nil
+}
+
+func (s *StoreService) GetStoreByID(ctx context.Context, StoreId string) (models.Store, error) {
+	store, err := s.storeRepo.GetStoreByID(ctx, StoreId)
+	if err != nil {
+		log.Printf("Failed to fetch store [%s]: %v", StoreId, err)
+		return models.Store{}, fmt.Errorf("could not get store: %w", err)
+	}
+	return store, nil
+}
+
+func (s *StoreService) UpdateStoreByID(ctx context.Context, store *models.Store) error {
+	if store.StoreName == "" {
+		return models.ErrInvalidStoreName
+	}
+	if store.TimeZone != "" {
+		if err := checkTimeZone(string(store.TimeZone)); err != nil {
+			return err
+		}
+	}
+	log.Printf("Updating store [%s]", store.StoreId)
+	err := s.storeRepo.UpdateStoreByID(ctx, store)
+	if err != nil {
+		log.Printf("Failed to update store [%s]: %v", store.StoreId, err)
+		return fmt.Errorf("could not update store: %w", err)
+	}
+	log.Printf("Store [%s] updated successfully", store.StoreId)
+	return nil
+}
+
+func (s *StoreService) DeleteStoreByID(ctx context.Context, StoreId string) error {
+	log.Printf("Deleting store [%s]", StoreId)
+	err := s.storeRepo.DeleteStoreByID(ctx, StoreId)
+	if err != nil {
+		log.Printf("Failed to delete store [%s]: %v", StoreId, err)
+		return fmt.Errorf("could not delete store: %w", err)
+	}
+	log.Printf("Store [%s] deleted successfully", StoreId)
+	return nil
+}
+
+func (s *StoreService) SetMenuItem(ctx context.Context, item *models.StoreMenuItem) error {
+	if item.StoreId == "" {
+		return models.ErrMissingStore
+	}
+	if item.Price != nil && *item.Price < 0 {
+		return fmt.Errorf("price cannot be negative")
+	}
+	log.Printf("Setting menu item [%s] for store [%s]", item.MenuItemId, item.StoreId)
+	err := s.storeRepo.SetMenuItem(ctx, item)
+	if err != nil {
+		log.Printf("Failed to set menu item [%s] for store [%s]: %v", item.MenuItemId, item.StoreId, err)
+		return fmt.Errorf("could not set store menu item: %w", err)
+	}
+	return nil
+}
+
+func (s *StoreService) DeleteMenuItem(ctx context.Context, StoreId string, MenuItemId string) error {
+	if StoreId == "" {
+		return models.ErrMissingStore
+	}
+	log.Printf("Removing menu item [%s] override for store [%s]", MenuItemId, StoreId)
+	err := s.storeRepo.DeleteMenuItem(ctx, StoreId, MenuItemId)
+	if err != nil {
+		log.Printf("Failed to remove menu item [%s] override for store [%s]: %v", MenuItemId, StoreId, err)
+		return fmt.Errorf("could not delete store menu item: %w", err)
+	}
+	return nil
+}
+
+// checkTimeZone fails with models.ErrInvalidTimeZone unless zone is an IANA
+// time zone name.
+func checkTimeZone(zone string) error {
+	if zone == "Local" {
+		return fmt.Errorf("%s: %w", zone, models.ErrInvalidTimeZone)
+	}
+	if _, err := time.LoadLocation(zone); err != nil {
+		return fmt.Errorf("%s: %w", zone, models.ErrInvalidTimeZone)
+	}
+	return nil
+}
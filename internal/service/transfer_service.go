@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"frappuccino/internal/repo"
+	"frappuccino/models"
+	"log"
+)
+
+type TransferServiceInf interface {
+	Create(ctx context.Context, transfer *models.Transfer, StoreId string) error
+	GetAll(ctx context.Context, StoreId string, status string) ([]models.Transfer, error)
+	GetTransferByID(ctx context.Context, TransferId string, StoreId string) (models.Transfer, error)
+	Ship(ctx context.Context, TransferId string, StoreId string, items []models.TransferItem) error
+	Receive(ctx context.Context, TransferId string, StoreId string, items []models.TransferItem) error
+	Cancel(ctx context.Context, TransferId string, StoreId string) error
+	InTransit(ctx context.Context, StoreId string) ([]models.InTransitStock, error)
+}
+
+type TransferService struct {
+	transferRepo repo.TransferRepo
+}
+
+func NewTransferService(transferRepo repo.TransferRepo) *TransferService {
+	return &TransferService{transferRepo: transferRepo}
+}
+
+// transferInStore returns a transfer from StoreId, when from is set, or to
+// it, when to is set. Other transfers fail with sql.ErrNoRows; with no
+// StoreId every transfer is found.
+func (s *TransferService) transferInStore(ctx context.Context, TransferId string, StoreId string, from, to bool) (models.Transfer, error) {
+	transfer, err := s.transferRepo.GetTransferByID(ctx, TransferId)
+	if err != nil {
+		return models.Transfer{}, err
+	}
+	if StoreId != "" && !(from && string(transfer.FromStoreId) == StoreId) && !(to && string(transfer.ToStoreId) == StoreId) {
+		return models.Transfer{}, fmt.Errorf("transfer not found in store: %w", sql.ErrNoRows)
+	}
+	return transfer, nil
+}
+
+// Create requests a transfer. With a StoreId, only stock of that store can be requested.
+func (s *TransferService) Create(ctx context.Context, transfer *models.Transfer, StoreId string) error {
+	if transfer.FromStoreId == "" || transfer.ToStoreId == "" || transfer.FromStoreId == transfer.ToStoreId || len(transfer.Items) == 0 {
+		return models.ErrInvalidTransfer
+	}
+	if StoreId != "" && string(transfer.FromStoreId) != StoreId {
+		return fmt.Errorf("source store not found: %w", sql.ErrNoRows)
+	}
+	for _, item := range transfer.Items {
+		if item.IngredientId == "" {
+			return models.ErrInvalidIngredientId
+		}
+		if item.RequestedQuantity <= 0 {
+			return models.ErrInvalidQuantity
+		}
+	}
+	log.Printf("Requesting transfer from store [%s] to store [%s]", transfer.FromStoreId, transfer.ToStoreId)
+	err := s.transferRepo.Create(ctx, transfer)
+	if err != nil {
+		log.Printf("Failed to request transfer: %v", err)
+		return fmt.Errorf("could not create transfer: %w", err)
+	}
+	log.Println("Transfer requested successfully:", transfer.TransferId)
+	return nil
+}
+
+func (s *TransferService) GetAll(ctx context.Context, StoreId string, status string) ([]models.Transfer, error) {
+	log.Println("Fetching transfers")
+	transfers, err := s.transferRepo.GetAll(ctx, StoreId, status)
+	if err != nil {
+		log.Printf("Failed to fetch transfers: %v", err)
+		return nil, fmt.Errorf("could not retrieve transfers: %w", err)
+	}
+	log.Printf("Retrieved %d transfers", len(transfers))
+	return transfers, nil
+}
+
+// GetTransferByID returns a transfer from or to StoreId, or any transfer
+// when it is empty.
+func (s *TransferService) GetTransferByID(ctx context.Context, TransferId string, StoreId string) (models.Transfer, error) {
+	transfer, err := s.transferInStore(ctx, TransferId, StoreId, true, true)
+	if err != nil {
+		log.Printf("Failed to fetch transfer [%s]: %v", TransferId, err)
+		return models.Transfer{}, fmt.Errorf("could not get transfer: %w", err)
+	}
+	return transfer, nil
+}
+
+// Ship ships a transfer from StoreId, or from any store when it is empty.
+func (s *TransferService) Ship(ctx context.Context, TransferId string, StoreId string, items []models.TransferItem) error {
+	for _, item := range items {
+		if item.ShippedQuantity != nil && *item.ShippedQuantity < 0 {
+			return models.ErrInvalidQuantity
+		}
+	}
+	log.Printf("Shipping transfer [%s]", TransferId)
+	if _, err := s.transferInStore(ctx, TransferId, StoreId, true, false); err != nil {
+		log.Printf("Failed to ship transfer [%s]: %v", TransferId, err)
+		return fmt.Errorf("could not ship transfer: %w", err)
+	}
+	err := s.transferRepo.Ship(ctx, TransferId, items)
+	if err != nil {
+		log.Printf("Failed to ship transfer [%s]: %v", TransferId, err)
+		return fmt.Errorf("could not ship transfer: %w", err)
+	}
+	log.Printf("Transfer [%s] shipped successfully", TransferId)
+	return nil
+}
+
+// Receive receives a transfer to StoreId, or to any store when it is empty.
+func (s *TransferService) Receive(ctx context.Context, TransferId string, StoreId string, items []models.TransferItem) error {
+	for _, item := range items {
+		if item.ReceivedQuantity != nil && *item.ReceivedQuantity < 0 {
+			return models.ErrInvalidQuantity
+		}
+	}
+	log.Printf("Receiving transfer [%s]", TransferId)
+	if _, err := s.transferInStore(ctx, TransferId, StoreId, false, true); err != nil {
+		log.Printf("Failed to receive transfer [%s]: %v", TransferId, err)
+		return fmt.Errorf("could not receive transfer: %w", err)
+	}
+	err := s.transferRepo.Receive(ctx, TransferId, items)
+	if err != nil {
+		log.Printf("Failed to receive transfer [%s]: %v", TransferId, err)
+		return fmt.Errorf("could not receive transfer: %w", err)
+	}
+	log.Printf("Transfer [%s] received successfully", TransferId)
+	return nil
+}
+
+// Cancel cancels a transfer from StoreId, or from any store when it is empty.
+func (s *TransferService) Cancel(ctx context.Context, TransferId string, StoreId string) error {
+	log.Printf("Cancelling transfer [%s]", TransferId)
+	if _, err := s.transferInStore(ctx, TransferId, StoreId, true, false); err != nil {
+		log.Printf("Failed to cancel transfer [%s]: %v", TransferId, err)
+		return fmt.Errorf("could not cancel transfer: %w", err)
+	}
+	err := s.transferRepo.Cancel(ctx, TransferId)
+	if err != nil {
+		log.Printf("Failed to cancel transfer [%s]: %v", TransferId, err)
+		return fmt.Errorf("could not cancel transfer: %w", err)
+	}
+	log.Printf("Transfer [%s] cancelled successfully", TransferId)
+	return nil
+}
+
+func (s *TransferService) InTransit(ctx context.Context, StoreId string) ([]models.InTransitStock, error) {
+	stock, err := s.transferRepo.InTransit(ctx, StoreId)
+	if err != nil {
+		log.Printf("Failed to fetch stock in transit: %v", err)
+		return nil, fmt.Errorf("could not retrieve stock in transit: %w", err)
+	}
+	return stock, nil
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"frappuccino/internal/repo"
+	"frappuccino/models"
+	"frappuccino/utils"
+	"log"
+	"regexp"
+	"strings"
+)
+
+type TranslationServiceInf interface {
+	Set(ctx context.Context, translation *models.Translation) error
+	GetAll(ctx context.Context, locale string) ([]models.Translation, error)
+	Delete(ctx context.Context, TranslationId string) error
+	Missing(ctx context.Context, locales []string) ([]models.MissingTranslations, error)
+}
+
+type TranslationService struct {
+	translationRepo repo.TranslationRepo
+}
+
+func NewTranslationService(translationRepo repo.TranslationRepo) *TranslationService {
+	return &TranslationService{translationRepo: translationRepo}
+}
+
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// normalizeLocale lowercases a language tag, accepting "pt_BR" for "pt-br".
+func normalizeLocale(locale string) (string, error) {
+	locale = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	if !localePattern.MatchString(locale) {
+		return "", fmt.Errorf("%q: %w", locale, models.ErrInvalidLocale)
+	}
+	return locale, nil
+}
+
+// Set adds or replaces the translation of a menu item, category, modifier
+// group or modifier in one locale.
+func (s *TranslationService) Set(ctx context.Context, translation *models.Translation) error {
+	locale, err := normalizeLocale(string(translation.Locale))
+	if err != nil {
+		return fmt.Errorf("%w: %w", models.ErrInvalidTranslation, err)
+	}
+	if locale == models.DefaultLocale {
+		return fmt.Errorf("%s is the default locale, edit the content itself: %w", locale, models.ErrInvalidTranslation)
+	}
+	translation.Locale = utils.TEXT(locale)
+	targets := 0
+	for _, id := range []utils.TEXT{translation.MenuItemId, translation.CategoryId, translation.ModifierGroupId, translation.ModifierId} {
+		if id != "" {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return fmt.Errorf("%d targets given: %w", targets, models.ErrInvalidTranslation)
+	}
+	translation.Name = utils.TEXT(strings.TrimSpace(string(translation.Name)))
+	if translation.Name == "" {
+		return fmt.Errorf("name is required: %w", models.ErrInvalidTranslation)
+	}
+
+	log.Printf("Saving %s translation of [%s]", translation.Locale, translation.TargetId())
+	if err := s.translationRepo.Upsert(ctx, translation); err != nil {
+		log.Printf("Failed to save translation of [%s]: %v", translation.TargetId(), err)
+		return fmt.Errorf("could not save translation: %w", err)
+	}
+	log.Printf("Translation [%s] saved successfully", translation.TranslationId)
+	return nil
+}
+
+// GetAll returns the translations in a locale, or all of them when locale is empty.
+func (s *TranslationService) GetAll(ctx context.Context, locale string) ([]models.Translation, error) {
+	var locales []string
+	if locale != "" {
+		normalized, err := normalizeLocale(locale)
+		if err != nil {
+			return nil, err
+		}
+		locales = []string{normalized}
+	}
+	translations, err := s.translationRepo.GetAll(ctx, locales)
+	if err != nil {
+		log.Printf("Failed to fetch translations: %v", err)
+		return nil, fmt.Errorf("could not retrieve translations: %w", err)
+	}
+	return translations, nil
+}
+
+func (s *TranslationService) Delete(ctx context.Context, TranslationId string) error {
+	log.Printf("Deleting translation [%s]", TranslationId)
+	if err := s.translationRepo.Delete(ctx, TranslationId); err != nil {
+		log.Printf("Failed to delete translation [%s]: %v", TranslationId, err)
+		return fmt.Errorf("could not delete translation: %w", err)
+	}
+	log.Printf("Translation [%s] deleted successfully", TranslationId)
+	return nil
+}
+
+// Missing reports per locale what lacks a translation, for the given
+// locales or every locale translated so far.
+func (s *TranslationService) Missing(ctx context.Context, locales []string) ([]models.MissingTranslations, error) {
+	normalized := make([]string, 0, len(locales))
+	for _, locale := range locales {
+		locale, err := normalizeLocale(locale)
+		if err != nil {
+			return nil, err
+		}
+		if locale != models.DefaultLocale {
+			normalized = append(normalized, locale)
+		}
+	}
+	report, err := s.translationRepo.Missing(ctx, normalized)
+	if err != nil {
+		log.Printf("Failed to report missing translations: %v", err)
+		return nil, fmt.Errorf("could not report missing translations: %w", err)
+	}
+	return report, nil
+}
+
+// localizer looks up the text of menu content in the preferred locales.
+type localizer map[utils.TEXT]models.Translation
+
+// loadLocalizer loads the translations in locales, keeping for each target
+// the one in the most preferred locale. No locales means no translations.
+func loadLocalizer(ctx context.Context, translationRepo repo.TranslationRepo, locales []string) (localizer, error) {
+	if len(locales) == 0 {
+		return localizer{}, nil
+	}
+	translations, err := translationRepo.GetAll(ctx, locales)
+	if err != nil {
+		return nil, err
+	}
+	rank := make(map[utils.TEXT]int, len(locales))
+	for i := len(locales) - 1; i >= 0; i-- {
+		rank[utils.TEXT(locales[i])] = i
+	}
+	l := make(localizer, len(translations))
+	for _, t := range translations {
+		id := t.TargetId()
+		if current, ok := l[id]; !ok || rank[t.Locale] < rank[current.Locale] {
+			l[id] = t
+		}
+	}
+	return l, nil
+}
+
+// name returns the translated name of id, or name when there is none.
+func (l localizer) name(id utils.TEXT, name utils.TEXT) utils.TEXT {
+	if t, ok := l[id]; ok {
+		return t.Name
+	}
+	return name
+}
+
+// description returns the translated description of id, or description when
+// there is none or it is empty.
+func (l localizer) description(id utils.TEXT, description utils.TEXT) utils.TEXT {
+	if t, ok := l[id]; ok && t.Description != "" {
+		return t.Description
+	}
+	return description
+}
+
+// menuItem translates a menu item with its categories and modifiers.
+func (l localizer) menuItem(item *models.MenuItems) {
+	item.ItemName, item.ItemDescription = l.name(item.MenuItemId, item.ItemName), l.description(item.MenuItemId, item.ItemDescription)
+	for i := range item.Categories {
+		if i < len(item.CategoryIds) {
+			item.Categories[i] = string(l.name(utils.TEXT(item.CategoryIds[i]), utils.TEXT(item.Categories[i])))
+		}
+	}
+	for i := range item.ModifierGroups {
+		group := &item.ModifierGroups[i]
+		group.GroupName = l.name(group.ModifierGroupId, group.GroupName)
+		for j := range group.Modifiers {
+			m := &group.Modifiers[j]
+			m.ModifierName = l.name(m.ModifierId, m.ModifierName)
+		}
+	}
+}
+
+// categories translates a category tree.
+func (l localizer) categories(categories []models.Category) {
+	for i := range categories {
+		c := &categories[i]
+		c.CategoryName, c.Description = l.name(c.CategoryId, c.CategoryName), l.description(c.CategoryId, c.Description)
+		l.categories(c.Children)
+	}
+}
@@ -0,0 +1,110 @@
+-- Adds stores with their own stock, menu overrides and orders. Databases
+-- created before stores get one store, "Main store", which takes over the
+-- stock of every ingredient and all existing orders and stock movements.
+BEGIN;
+
+CREATE TABLE stores (
+    store_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    store_name VARCHAR(255) NOT NULL UNIQUE,
+    address TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- Stock of each ingredient held by a store
+CREATE TABLE store_inventory (
+    store_id UUID NOT NULL REFERENCES stores(store_id) ON DELETE CASCADE,
+    ingredient_id UUID NOT NULL REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
+    quantity DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    reorder_level DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (reorder_level >= 0),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (store_id, ingredient_id)
+);
+
+-- Per-store menu overrides: a NULL price keeps the menu_items price
+CREATE TABLE store_menu_items (
+    store_id UUID NOT NULL REFERENCES stores(store_id) ON DELETE CASCADE,
+    menu_item_id UUID NOT NULL REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
+    price DECIMAL(10,2) CHECK (price >= 0),
+    is_available BOOLEAN NOT NULL DEFAULT TRUE,
+    PRIMARY KEY (store_id, menu_item_id)
+);
+
+INSERT INTO stores (store_name) VALUES ('Main store');
+
+INSERT INTO store_inventory (store_id, ingredient_id, quantity, reorder_level)
+SELECT s.store_id, i.ingredient_id, i.quantity, i.reorder_level
+FROM inventory i, stores s;
+
+ALTER TABLE orders ADD COLUMN store_id UUID REFERENCES stores(store_id) ON DELETE RESTRICT;
+UPDATE orders SET store_id = (SELECT store_id FROM stores);
+ALTER TABLE orders ALTER COLUMN store_id SET NOT NULL;
+
+ALTER TABLE inventory_transactions ADD COLUMN store_id UUID REFERENCES stores(store_id) ON DELETE RESTRICT;
+UPDATE inventory_transactions SET store_id = (SELECT store_id FROM stores);
+ALTER TABLE inventory_transactions ALTER COLUMN store_id SET NOT NULL;
+
+-- Stock is deducted by the application per store from now on
+DROP TRIGGER trigger_update_inventory_on_order_complete ON orders;
+DROP FUNCTION update_inventory_on_order_complete();
+
+DROP TRIGGER trigger_check_inventory_levels ON inventory;
+DROP INDEX idx_inventory_reorder_level;
+ALTER TABLE inventory DROP COLUMN quantity, DROP COLUMN reorder_level;
+
+CREATE INDEX idx_store_inventory_ingredient_id ON store_inventory(ingredient_id);
+CREATE INDEX idx_store_menu_items_menu_item_id ON store_menu_items(menu_item_id);
+CREATE INDEX idx_inventory_transactions_store_id ON inventory_transactions(store_id);
+CREATE INDEX idx_orders_store_id ON orders(store_id);
+
+CREATE TRIGGER update_stores_timestamp
+    BEFORE UPDATE ON stores
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
+CREATE TRIGGER update_store_inventory_timestamp
+    BEFORE UPDATE ON store_inventory
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
+CREATE OR REPLACE FUNCTION check_inventory_levels()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.quantity <= NEW.reorder_level AND OLD.quantity > OLD.reorder_level THEN
+        -- In a real system, this would send alerts
+        RAISE NOTICE 'Inventory for % in store % is low (%)',
+            (SELECT ingredient_name FROM inventory WHERE ingredient_id = NEW.ingredient_id),
+            NEW.store_id, NEW.quantity;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_check_inventory_levels
+AFTER UPDATE ON store_inventory
+FOR EACH ROW EXECUTE FUNCTION check_inventory_levels();
+
+-- order_items has no total_price column; totals are quantity * unit_price
+CREATE OR REPLACE FUNCTION update_order_total_price()
+RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE orders
+    SET total_price = (
+        SELECT COALESCE(SUM(quantity * unit_price), 0)
+        FROM order_items
+        WHERE order_id =
+            CASE
+              WHEN TG_OP = 'DELETE' THEN OLD.order_id
+              ELSE NEW.order_id
+            END
+    )
+    WHERE order_id =
+        CASE
+          WHEN TG_OP = 'DELETE' THEN OLD.order_id
+          ELSE NEW.order_id
+        END;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+COMMIT;
@@ -0,0 +1,43 @@
+-- Adds stock transfers between stores.
+BEGIN;
+
+CREATE TYPE all_transfer_status AS ENUM ('REQUESTED', 'SHIPPED', 'RECEIVED', 'CANCELLED');
+
+-- Stock moved between stores
+CREATE TABLE stock_transfers (
+    transfer_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    from_store_id UUID NOT NULL REFERENCES stores(store_id) ON DELETE RESTRICT,
+    to_store_id UUID NOT NULL REFERENCES stores(store_id) ON DELETE RESTRICT,
+    transfer_status all_transfer_status NOT NULL DEFAULT 'REQUESTED',
+    notes TEXT NOT NULL DEFAULT '',
+    shipped_at TIMESTAMP WITH TIME ZONE,
+    received_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CHECK (from_store_id <> to_store_id)
+);
+
+CREATE TABLE stock_transfer_items (
+    transfer_item_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transfer_id UUID NOT NULL REFERENCES stock_transfers(transfer_id) ON DELETE CASCADE,
+    ingredient_id UUID NOT NULL REFERENCES inventory(ingredient_id) ON DELETE RESTRICT,
+    requested_quantity DECIMAL(10,2) NOT NULL CHECK (requested_quantity > 0),
+    shipped_quantity DECIMAL(10,2) CHECK (shipped_quantity >= 0),
+    received_quantity DECIMAL(10,2) CHECK (received_quantity >= 0),
+    -- Negative when less arrived than was shipped
+    discrepancy DECIMAL(10,2) GENERATED ALWAYS AS (received_quantity - shipped_quantity) STORED,
+    discrepancy_notes TEXT NOT NULL DEFAULT '',
+    UNIQUE(transfer_id, ingredient_id)
+);
+
+CREATE INDEX idx_stock_transfers_from_store_id ON stock_transfers(from_store_id);
+CREATE INDEX idx_stock_transfers_to_store_id ON stock_transfers(to_store_id);
+CREATE INDEX idx_stock_transfers_status ON stock_transfers(transfer_status);
+CREATE INDEX idx_stock_transfer_items_transfer_id ON stock_transfer_items(transfer_id);
+
+CREATE TRIGGER update_stock_transfers_timestamp
+    BEFORE UPDATE ON stock_transfers
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
+COMMIT;
@@ -0,0 +1,34 @@
+-- Adds ingredient costs and prepared ingredients made in-house from
+-- sub-recipes, with the batches produced by each store.
+BEGIN;
+
+ALTER TABLE inventory
+    ADD COLUMN unit_cost DECIMAL(10,4) NOT NULL DEFAULT 0 CHECK (unit_cost >= 0),
+    ADD COLUMN is_prepared BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN batch_yield DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (batch_yield >= 0);
+
+-- Components consumed by one batch of a prepared ingredient
+CREATE TABLE ingredient_recipes (
+    ingredient_recipe_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    prepared_ingredient_id UUID NOT NULL REFERENCES inventory(ingredient_id) ON DELETE CASCADE,
+    component_ingredient_id UUID NOT NULL REFERENCES inventory(ingredient_id) ON DELETE RESTRICT,
+    quantity DECIMAL(10,2) NOT NULL CHECK (quantity > 0),
+    UNIQUE(prepared_ingredient_id, component_ingredient_id),
+    CHECK (prepared_ingredient_id <> component_ingredient_id)
+);
+
+-- Batches of prepared ingredients produced in a store
+CREATE TABLE ingredient_productions (
+    production_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    ingredient_id UUID NOT NULL REFERENCES inventory(ingredient_id) ON DELETE RESTRICT,
+    store_id UUID NOT NULL REFERENCES stores(store_id) ON DELETE RESTRICT,
+    batches DECIMAL(10,2) NOT NULL CHECK (batches > 0),
+    quantity DECIMAL(10,2) NOT NULL CHECK (quantity >= 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_ingredient_recipes_component_ingredient_id ON ingredient_recipes(component_ingredient_id);
+CREATE INDEX idx_ingredient_productions_ingredient_id ON ingredient_productions(ingredient_id);
+CREATE INDEX idx_ingredient_productions_store_id ON ingredient_productions(store_id);
+
+COMMIT;
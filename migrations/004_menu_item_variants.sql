@@ -0,0 +1,29 @@
+-- Adds sizes of menu items with their own prices and recipes. Existing
+-- order items keep no variant.
+BEGIN;
+
+-- Sizes of a menu item. A variant uses its own recipe lines when it has
+-- any, otherwise the menu item recipe scaled by recipe_multiplier.
+CREATE TABLE menu_item_variants (
+    variant_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    menu_item_id UUID NOT NULL REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
+    variant_name VARCHAR(100) NOT NULL,
+    price DECIMAL(10,2) NOT NULL CHECK (price >= 0),
+    recipe_multiplier DECIMAL(10,4) NOT NULL DEFAULT 1 CHECK (recipe_multiplier > 0),
+    display_order INT NOT NULL DEFAULT 0,
+    UNIQUE(menu_item_id, variant_name)
+);
+
+CREATE TABLE menu_item_variant_ingredients (
+    variant_id UUID NOT NULL REFERENCES menu_item_variants(variant_id) ON DELETE CASCADE,
+    ingredient_id UUID NOT NULL REFERENCES inventory(ingredient_id) ON DELETE RESTRICT,
+    quantity DECIMAL(10,2) NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (variant_id, ingredient_id)
+);
+
+ALTER TABLE order_items ADD COLUMN variant_id UUID REFERENCES menu_item_variants(variant_id) ON DELETE RESTRICT;
+
+CREATE INDEX idx_order_items_variant_id ON order_items(variant_id);
+CREATE INDEX idx_menu_item_variants_menu_item_id ON menu_item_variants(menu_item_id);
+
+COMMIT;
@@ -0,0 +1,66 @@
+-- Adds priced modifiers, such as milk type or extra shots, that change the
+-- recipe of the menu items they are attached to.
+BEGIN;
+
+CREATE TYPE all_modifier_action AS ENUM ('ADD', 'REMOVE', 'SUBSTITUTE');
+
+-- Modifier groups such as milk type or extra shots, attached to menu items
+CREATE TABLE modifier_groups (
+    modifier_group_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    group_name VARCHAR(100) NOT NULL UNIQUE,
+    min_selections INT NOT NULL DEFAULT 0 CHECK (min_selections >= 0),
+    max_selections INT NOT NULL DEFAULT 1 CHECK (max_selections >= min_selections),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE TABLE modifiers (
+    modifier_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    modifier_group_id UUID NOT NULL REFERENCES modifier_groups(modifier_group_id) ON DELETE CASCADE,
+    modifier_name VARCHAR(100) NOT NULL,
+    price_delta DECIMAL(10,2) NOT NULL DEFAULT 0,
+    UNIQUE(modifier_group_id, modifier_name)
+);
+
+-- Recipe changes of a modifier: ADD adds ingredient_id, REMOVE drops
+-- replaces_ingredient_id, SUBSTITUTE swaps replaces_ingredient_id for
+-- ingredient_id (keeping its quantity when quantity is 0)
+CREATE TABLE modifier_ingredients (
+    modifier_ingredient_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    modifier_id UUID NOT NULL REFERENCES modifiers(modifier_id) ON DELETE CASCADE,
+    modifier_action all_modifier_action NOT NULL,
+    ingredient_id UUID REFERENCES inventory(ingredient_id) ON DELETE RESTRICT,
+    replaces_ingredient_id UUID REFERENCES inventory(ingredient_id) ON DELETE RESTRICT,
+    quantity DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    CHECK (
+        (modifier_action = 'ADD' AND ingredient_id IS NOT NULL AND quantity > 0) OR
+        (modifier_action = 'REMOVE' AND replaces_ingredient_id IS NOT NULL) OR
+        (modifier_action = 'SUBSTITUTE' AND ingredient_id IS NOT NULL AND replaces_ingredient_id IS NOT NULL)
+    )
+);
+
+CREATE TABLE menu_item_modifier_groups (
+    menu_item_id UUID NOT NULL REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
+    modifier_group_id UUID NOT NULL REFERENCES modifier_groups(modifier_group_id) ON DELETE CASCADE,
+    PRIMARY KEY (menu_item_id, modifier_group_id)
+);
+
+-- Modifiers chosen for an order item with the price delta charged
+CREATE TABLE order_item_modifiers (
+    order_item_id UUID NOT NULL REFERENCES order_items(order_item_id) ON DELETE CASCADE,
+    modifier_id UUID NOT NULL REFERENCES modifiers(modifier_id) ON DELETE RESTRICT,
+    price_delta DECIMAL(10,2) NOT NULL,
+    PRIMARY KEY (order_item_id, modifier_id)
+);
+
+CREATE INDEX idx_modifiers_modifier_group_id ON modifiers(modifier_group_id);
+CREATE INDEX idx_modifier_ingredients_modifier_id ON modifier_ingredients(modifier_id);
+CREATE INDEX idx_menu_item_modifier_groups_modifier_group_id ON menu_item_modifier_groups(modifier_group_id);
+CREATE INDEX idx_order_item_modifiers_modifier_id ON order_item_modifiers(modifier_id);
+
+CREATE TRIGGER update_modifier_groups_timestamp
+    BEFORE UPDATE ON modifier_groups
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
+COMMIT;
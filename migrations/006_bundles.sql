@@ -0,0 +1,50 @@
+-- Adds bundle menu items, whose revenue is allocated to the menu items they
+-- are made of, and the order_item_sales view that sales reports read.
+BEGIN;
+
+-- Components of a bundle menu item. A slot with one option is a fixed
+-- component; with several the order picks one, as in "any pastry"
+CREATE TABLE bundle_slots (
+    slot_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    bundle_id UUID NOT NULL REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
+    slot_name VARCHAR(100) NOT NULL,
+    quantity DECIMAL(10,2) NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    display_order INT NOT NULL DEFAULT 0,
+    UNIQUE(bundle_id, slot_name)
+);
+
+CREATE TABLE bundle_slot_options (
+    slot_id UUID NOT NULL REFERENCES bundle_slots(slot_id) ON DELETE CASCADE,
+    menu_item_id UUID NOT NULL REFERENCES menu_items(menu_item_id) ON DELETE RESTRICT,
+    PRIMARY KEY (slot_id, menu_item_id)
+);
+
+-- Menu items sold as part of a bundle order item. allocated_price is the
+-- share of one bundle's price credited to the component
+CREATE TABLE order_item_components (
+    order_item_component_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    order_item_id UUID NOT NULL REFERENCES order_items(order_item_id) ON DELETE CASCADE,
+    menu_item_id UUID NOT NULL REFERENCES menu_items(menu_item_id) ON DELETE RESTRICT,
+    slot_name VARCHAR(100) NOT NULL,
+    quantity DECIMAL(10,2) NOT NULL CHECK (quantity > 0),
+    allocated_price DECIMAL(10,2) NOT NULL CHECK (allocated_price >= 0)
+);
+
+-- Menu items sold with their revenue; bundle order items count as their components
+CREATE VIEW order_item_sales AS
+SELECT oi.order_id, oi.order_item_id, oi.menu_item_id, oi.variant_id,
+    oi.quantity, oi.quantity * oi.unit_price AS revenue
+FROM order_items oi
+WHERE NOT EXISTS (SELECT 1 FROM order_item_components c WHERE c.order_item_id = oi.order_item_id)
+UNION ALL
+SELECT oi.order_id, oi.order_item_id, c.menu_item_id, NULL::uuid,
+    oi.quantity * c.quantity, oi.quantity * c.allocated_price
+FROM order_items oi
+JOIN order_item_components c USING(order_item_id);
+
+CREATE INDEX idx_bundle_slots_bundle_id ON bundle_slots(bundle_id);
+CREATE INDEX idx_bundle_slot_options_menu_item_id ON bundle_slot_options(menu_item_id);
+CREATE INDEX idx_order_item_components_order_item_id ON order_item_components(order_item_id);
+CREATE INDEX idx_order_item_components_menu_item_id ON order_item_components(menu_item_id);
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- Adds the allergens and diets of ingredients, which menu items inherit.
+BEGIN;
+
+-- Allergens the ingredient contains (dairy, nuts, gluten, ...) and the
+-- diets it suits (vegan, vegetarian, ...)
+ALTER TABLE inventory
+    ADD COLUMN allergens TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN diets TEXT[] NOT NULL DEFAULT '{}';
+
+COMMIT;
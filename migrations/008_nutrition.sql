@@ -0,0 +1,12 @@
+-- Adds nutrition per unit of ingredients, from which menu items get theirs
+-- per serving.
+BEGIN;
+
+ALTER TABLE inventory
+    ADD COLUMN kcal DECIMAL(10,4) NOT NULL DEFAULT 0 CHECK (kcal >= 0),
+    ADD COLUMN sugar_g DECIMAL(10,4) NOT NULL DEFAULT 0 CHECK (sugar_g >= 0),
+    ADD COLUMN fat_g DECIMAL(10,4) NOT NULL DEFAULT 0 CHECK (fat_g >= 0),
+    ADD COLUMN protein_g DECIMAL(10,4) NOT NULL DEFAULT 0 CHECK (protein_g >= 0),
+    ADD COLUMN caffeine_mg DECIMAL(10,4) NOT NULL DEFAULT 0 CHECK (caffeine_mg >= 0);
+
+COMMIT;
@@ -0,0 +1,48 @@
+-- Adds availability windows for menu items and categories.
+BEGIN;
+
+-- Windows in which a menu item, or every item of a category, can be sold.
+-- NULL bounds are open; a window with start_time after end_time runs past
+-- midnight. days_of_week uses ISO numbering (1 = Monday ... 7 = Sunday)
+CREATE TABLE menu_schedules (
+    schedule_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    menu_item_id UUID REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
+    category TEXT,
+    start_time TIME,
+    end_time TIME,
+    days_of_week INT[] NOT NULL DEFAULT '{1,2,3,4,5,6,7}',
+    start_date DATE,
+    end_date DATE,
+    CHECK ((menu_item_id IS NULL) <> (category IS NULL)),
+    CHECK (start_date IS NULL OR end_date IS NULL OR start_date <= end_date),
+    CHECK (days_of_week <@ '{1,2,3,4,5,6,7}')
+);
+
+CREATE INDEX idx_menu_schedules_menu_item_id ON menu_schedules(menu_item_id);
+CREATE INDEX idx_menu_schedules_category ON menu_schedules(category);
+
+-- Whether a menu item can be sold at a given time. An item follows its own
+-- schedules, or those of its categories when it has none, and is always
+-- orderable when neither has any.
+CREATE OR REPLACE FUNCTION menu_item_orderable(item UUID, at TIMESTAMP WITH TIME ZONE)
+RETURNS BOOLEAN AS $$
+    WITH windows AS (
+        SELECT s.* FROM menu_schedules s WHERE s.menu_item_id = item
+        UNION ALL
+        SELECT s.* FROM menu_schedules s
+        JOIN menu_items mi ON s.category = ANY(mi.categories)
+        WHERE mi.menu_item_id = item
+        AND NOT EXISTS (SELECT 1 FROM menu_schedules WHERE menu_item_id = item)
+    )
+    SELECT NOT EXISTS (SELECT 1 FROM windows) OR EXISTS (
+        SELECT 1 FROM windows w
+        WHERE EXTRACT(ISODOW FROM at)::int = ANY(w.days_of_week)
+        AND (w.start_date IS NULL OR at::date >= w.start_date)
+        AND (w.end_date IS NULL OR at::date <= w.end_date)
+        AND (w.start_time IS NULL OR w.end_time IS NULL
+            OR (w.start_time <= w.end_time AND at::time >= w.start_time AND at::time < w.end_time)
+            OR (w.start_time > w.end_time AND (at::time >= w.start_time OR at::time < w.end_time)))
+    );
+$$ LANGUAGE sql STABLE;
+
+COMMIT;
@@ -0,0 +1,34 @@
+-- Adds menu versions: drafts of the whole menu that are published at once,
+-- possibly on a schedule, and kept for rollback.
+BEGIN;
+
+CREATE TYPE all_menu_version_status AS ENUM ('DRAFT', 'SCHEDULED', 'PUBLISHED', 'ARCHIVED');
+
+-- Cleared when a published menu version no longer lists the item
+ALTER TABLE menu_items ADD COLUMN is_active BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- Snapshots of the whole menu. Drafts can be edited and previewed;
+-- publishing applies the items to menu_items in one transaction, at
+-- publish_at when scheduled. Older versions are kept for rollback
+CREATE TABLE menu_versions (
+    version_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    version_number INT GENERATED ALWAYS AS IDENTITY UNIQUE,
+    version_name VARCHAR(255) NOT NULL DEFAULT '',
+    version_status all_menu_version_status NOT NULL DEFAULT 'DRAFT',
+    items JSONB NOT NULL DEFAULT '[]'::JSONB,
+    publish_at TIMESTAMP WITH TIME ZONE,
+    published_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CHECK (version_status <> 'SCHEDULED' OR publish_at IS NOT NULL)
+);
+
+CREATE UNIQUE INDEX idx_menu_versions_published ON menu_versions(version_status) WHERE version_status = 'PUBLISHED';
+CREATE INDEX idx_menu_versions_publish_at ON menu_versions(publish_at) WHERE version_status = 'SCHEDULED';
+
+CREATE TRIGGER update_menu_versions_timestamp
+    BEFORE UPDATE ON menu_versions
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
+COMMIT;
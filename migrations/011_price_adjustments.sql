@@ -0,0 +1,59 @@
+-- Adds tags on menu items and bulk price adjustments that can be reverted.
+-- Earlier price_history rows have no previous_price and belong to no batch.
+BEGIN;
+
+CREATE TYPE all_price_change_type AS ENUM ('PERCENT', 'ABSOLUTE');
+CREATE TYPE all_price_rounding AS ENUM ('NEAREST', 'UP', 'DOWN');
+
+-- Free-form labels (seasonal, espresso, ...) for targeting groups of items
+ALTER TABLE menu_items ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+
+-- Bulk price changes. The price_history rows of a batch point to it, so
+-- the batch can be reverted to their previous_price
+CREATE TABLE price_adjustments (
+    adjustment_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    reason TEXT NOT NULL DEFAULT '',
+    category TEXT,
+    tag TEXT,
+    menu_item_ids UUID[] NOT NULL DEFAULT '{}',
+    change_type all_price_change_type NOT NULL,
+    change_value DECIMAL(10,4) NOT NULL,
+    round_to DECIMAL(10,2) NOT NULL DEFAULT 0.01 CHECK (round_to > 0),
+    rounding all_price_rounding NOT NULL DEFAULT 'NEAREST',
+    reverted_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+ALTER TABLE price_history
+    ADD COLUMN previous_price DECIMAL(10,2),
+    -- Set when the change was part of a bulk price adjustment
+    ADD COLUMN adjustment_id UUID REFERENCES price_adjustments(adjustment_id) ON DELETE SET NULL;
+
+CREATE INDEX idx_menu_items_tags ON menu_items USING GIN(tags);
+CREATE INDEX idx_price_history_adjustment_id ON price_history(adjustment_id);
+
+CREATE OR REPLACE FUNCTION track_menu_item_price_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        -- Insert initial price history record for new menu items
+        INSERT INTO price_history (menu_item_id, price, effective_from)
+        VALUES (NEW.menu_item_id, NEW.price, now());
+    ELSIF TG_OP = 'UPDATE' AND NEW.price <> OLD.price THEN
+        -- Close previous price period
+        UPDATE price_history
+        SET effective_to = now()
+        WHERE menu_item_id = NEW.menu_item_id
+          AND effective_to IS NULL;
+
+        -- Insert new price with NULL effective_to; bulk adjustments name
+        -- their batch in the app.price_adjustment_id setting
+        INSERT INTO price_history (menu_item_id, price, previous_price, adjustment_id, effective_from)
+        VALUES (NEW.menu_item_id, NEW.price, OLD.price,
+            NULLIF(current_setting('app.price_adjustment_id', true), '')::uuid, now());
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+COMMIT;
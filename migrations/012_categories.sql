@@ -0,0 +1,128 @@
+-- Moves databases created before the categories table from the free
+-- menu_items.categories TEXT[] to managed categories. Spellings that differ
+-- only in case or surrounding spaces become one category, named after the
+-- spelling most items use. Menu version snapshots get the category_ids of
+-- their category names. Categories that are still near-duplicates
+-- ("Coffee" and "Coffees") can then be merged by relinking their items
+-- and deleting one of them.
+BEGIN;
+
+CREATE TABLE categories (
+    category_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    category_name VARCHAR(100) NOT NULL CHECK (btrim(category_name) <> ''),
+    parent_id UUID REFERENCES categories(category_id) ON DELETE RESTRICT,
+    description TEXT NOT NULL DEFAULT '',
+    display_order INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CHECK (parent_id <> category_id)
+);
+
+CREATE TABLE menu_item_categories (
+    menu_item_id UUID NOT NULL REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
+    category_id UUID NOT NULL REFERENCES categories(category_id) ON DELETE CASCADE,
+    PRIMARY KEY (menu_item_id, category_id)
+);
+
+CREATE UNIQUE INDEX idx_categories_name ON categories(lower(category_name));
+CREATE INDEX idx_categories_parent_id ON categories(parent_id);
+CREATE INDEX idx_menu_item_categories_category_id ON menu_item_categories(category_id);
+
+CREATE TRIGGER update_categories_timestamp
+    BEFORE UPDATE ON categories
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
+-- Every spelling in use, by items, menu versions, schedules and price adjustments
+WITH spellings AS (
+    SELECT btrim(c) AS name FROM menu_items, unnest(categories) AS c
+    UNION ALL
+    SELECT btrim(c)
+    FROM menu_versions, jsonb_array_elements(items) AS item,
+        jsonb_array_elements_text(CASE WHEN jsonb_typeof(item->'categories') = 'array'
+            THEN item->'categories' ELSE '[]'::jsonb END) AS c
+    UNION ALL
+    SELECT btrim(category) FROM menu_schedules WHERE category IS NOT NULL
+    UNION ALL
+    SELECT btrim(category) FROM price_adjustments WHERE category IS NOT NULL
+)
+INSERT INTO categories (category_name)
+SELECT DISTINCT ON (lower(name)) name
+FROM spellings
+WHERE name <> ''
+GROUP BY name
+ORDER BY lower(name), count(*) DESC, name;
+
+INSERT INTO menu_item_categories (menu_item_id, category_id)
+SELECT DISTINCT mi.menu_item_id, cat.category_id
+FROM menu_items mi, unnest(mi.categories) AS c
+JOIN categories cat ON lower(cat.category_name) = lower(btrim(c));
+
+-- Snapshot items name their categories; publishing reads category_ids
+UPDATE menu_versions mv SET items = (
+    SELECT COALESCE(jsonb_agg(
+        CASE WHEN jsonb_typeof(e.item->'categories') = 'array' THEN
+            e.item || jsonb_build_object(
+                'category_ids', COALESCE(cats.ids, '[]'::jsonb),
+                'categories', COALESCE(cats.names, '[]'::jsonb))
+        ELSE e.item END
+        ORDER BY e.ord), '[]'::jsonb)
+    FROM jsonb_array_elements(mv.items) WITH ORDINALITY AS e(item, ord)
+    LEFT JOIN LATERAL (
+        SELECT jsonb_agg(DISTINCT cat.category_id) AS ids, jsonb_agg(DISTINCT cat.category_name) AS names
+        FROM jsonb_array_elements_text(e.item->'categories') AS c
+        JOIN categories cat ON lower(cat.category_name) = lower(btrim(c))
+    ) cats ON jsonb_typeof(e.item->'categories') = 'array'
+);
+
+ALTER TABLE menu_schedules ADD COLUMN category_id UUID REFERENCES categories(category_id) ON DELETE CASCADE;
+UPDATE menu_schedules s SET category_id = cat.category_id
+FROM categories cat
+WHERE lower(cat.category_name) = lower(btrim(s.category));
+DELETE FROM menu_schedules WHERE menu_item_id IS NULL AND category_id IS NULL;
+-- drops the old menu_item_id-or-category check with it
+ALTER TABLE menu_schedules DROP COLUMN category;
+ALTER TABLE menu_schedules ADD CHECK ((menu_item_id IS NULL) <> (category_id IS NULL));
+CREATE INDEX idx_menu_schedules_category_id ON menu_schedules(category_id);
+
+ALTER TABLE price_adjustments ADD COLUMN category_id UUID REFERENCES categories(category_id) ON DELETE SET NULL;
+UPDATE price_adjustments a SET category_id = cat.category_id
+FROM categories cat
+WHERE lower(cat.category_name) = lower(btrim(a.category));
+ALTER TABLE price_adjustments DROP COLUMN category;
+
+-- menu_item_orderable reads menu_item_categories from now on
+CREATE OR REPLACE FUNCTION menu_item_orderable(item UUID, at TIMESTAMP WITH TIME ZONE)
+RETURNS BOOLEAN AS $$
+    WITH RECURSIVE item_categories AS (
+        SELECT c.category_id, c.parent_id
+        FROM menu_item_categories mc
+        JOIN categories c USING(category_id)
+        WHERE mc.menu_item_id = item
+        UNION
+        SELECT p.category_id, p.parent_id
+        FROM categories p
+        JOIN item_categories ic ON p.category_id = ic.parent_id
+    ),
+    windows AS (
+        SELECT s.* FROM menu_schedules s WHERE s.menu_item_id = item
+        UNION ALL
+        SELECT s.* FROM menu_schedules s
+        JOIN item_categories ic USING(category_id)
+        WHERE NOT EXISTS (SELECT 1 FROM menu_schedules WHERE menu_item_id = item)
+    )
+    SELECT NOT EXISTS (SELECT 1 FROM windows) OR EXISTS (
+        SELECT 1 FROM windows w
+        WHERE EXTRACT(ISODOW FROM at)::int = ANY(w.days_of_week)
+        AND (w.start_date IS NULL OR at::date >= w.start_date)
+        AND (w.end_date IS NULL OR at::date <= w.end_date)
+        AND (w.start_time IS NULL OR w.end_time IS NULL
+            OR (w.start_time <= w.end_time AND at::time >= w.start_time AND at::time < w.end_time)
+            OR (w.start_time > w.end_time AND (at::time >= w.start_time OR at::time < w.end_time)))
+    );
+$$ LANGUAGE sql STABLE;
+
+DROP INDEX idx_menu_items_categories;
+ALTER TABLE menu_items DROP COLUMN categories;
+
+COMMIT;
@@ -0,0 +1,13 @@
+-- Replaces hard deletes of menu items, customers and ingredients with
+-- archiving. Menu items deactivated by a published menu version count as
+-- archived from now on.
+BEGIN;
+
+ALTER TABLE menu_items ADD COLUMN archived_at TIMESTAMP WITH TIME ZONE;
+UPDATE menu_items SET archived_at = updated_at WHERE NOT is_active;
+ALTER TABLE menu_items DROP COLUMN is_active;
+
+ALTER TABLE customers ADD COLUMN archived_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE inventory ADD COLUMN archived_at TIMESTAMP WITH TIME ZONE;
+
+COMMIT;
@@ -0,0 +1,19 @@
+-- Adds pictures of menu items.
+BEGIN;
+
+CREATE TABLE menu_item_images (
+    image_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    menu_item_id UUID NOT NULL REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
+    image_key TEXT NOT NULL UNIQUE,
+    thumbnail_key TEXT NOT NULL UNIQUE,
+    content_type VARCHAR(50) NOT NULL,
+    width INT NOT NULL CHECK (width > 0),
+    height INT NOT NULL CHECK (height > 0),
+    size_bytes INT NOT NULL CHECK (size_bytes > 0),
+    display_order INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_menu_item_images_menu_item_id ON menu_item_images(menu_item_id);
+
+COMMIT;
@@ -0,0 +1,32 @@
+-- Adds translations of menu content.
+BEGIN;
+
+-- Text of menu items, categories, modifier groups and modifiers in other
+-- locales than the default one their own columns are written in. Each row
+-- translates exactly one of them
+CREATE TABLE translations (
+    translation_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    locale VARCHAR(35) NOT NULL CHECK (locale ~ '^[a-z]{2,3}(-[a-z0-9]{2,8})*$'),
+    menu_item_id UUID REFERENCES menu_items(menu_item_id) ON DELETE CASCADE,
+    category_id UUID REFERENCES categories(category_id) ON DELETE CASCADE,
+    modifier_group_id UUID REFERENCES modifier_groups(modifier_group_id) ON DELETE CASCADE,
+    modifier_id UUID REFERENCES modifiers(modifier_id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL CHECK (btrim(name) <> ''),
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CHECK (num_nonnulls(menu_item_id, category_id, modifier_group_id, modifier_id) = 1)
+);
+
+CREATE UNIQUE INDEX idx_translations_menu_item_id ON translations(menu_item_id, locale) WHERE menu_item_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_translations_category_id ON translations(category_id, locale) WHERE category_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_translations_modifier_group_id ON translations(modifier_group_id, locale) WHERE modifier_group_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_translations_modifier_id ON translations(modifier_id, locale) WHERE modifier_id IS NOT NULL;
+CREATE INDEX idx_translations_locale ON translations(locale);
+
+CREATE TRIGGER update_translations_timestamp
+    BEFORE UPDATE ON translations
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
+COMMIT;
@@ -0,0 +1,16 @@
+-- Turns the customer name and email indexes into trigram indexes for partial
+-- and fuzzy search, indexes phone numbers the same way and records which
+-- customer a merged duplicate went into.
+BEGIN;
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+DROP INDEX IF EXISTS idx_customers_full_name;
+DROP INDEX IF EXISTS idx_customers_email;
+CREATE INDEX idx_customers_full_name ON customers USING GIN (full_name gin_trgm_ops);
+CREATE INDEX idx_customers_email ON customers USING GIN (email gin_trgm_ops);
+CREATE INDEX idx_customers_phone_number ON customers USING GIN (phone_number gin_trgm_ops);
+
+ALTER TABLE customers ADD COLUMN merged_into UUID REFERENCES customers(customer_id) ON DELETE SET NULL;
+
+COMMIT;
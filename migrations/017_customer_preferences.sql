@@ -0,0 +1,24 @@
+-- Gives customer preferences a schema: favourite_drink, milk_preference,
+-- sugar_level and allergies. Other keys and values of the wrong JSON type
+-- are dropped; the service validates the values from now on.
+BEGIN;
+
+UPDATE customers
+SET preferences = COALESCE((
+    SELECT jsonb_object_agg(e.key, e.value)
+    FROM jsonb_each(preferences) e
+    WHERE (e.key IN ('favourite_drink', 'milk_preference', 'sugar_level') AND jsonb_typeof(e.value) = 'string')
+    OR (e.key = 'allergies' AND jsonb_typeof(e.value) = 'array'
+        AND NOT jsonb_path_exists(e.value, '$[*] ? (@.type() != "string")'))
+), '{}')
+WHERE jsonb_typeof(preferences) = 'object';
+
+UPDATE customers SET preferences = '{}' WHERE preferences IS NULL OR jsonb_typeof(preferences) <> 'object';
+
+ALTER TABLE customers
+    ALTER COLUMN preferences SET NOT NULL,
+    ADD CONSTRAINT customers_preferences_check CHECK (jsonb_typeof(preferences) = 'object');
+
+CREATE INDEX idx_customers_preferences ON customers USING GIN (preferences jsonb_path_ops);
+
+COMMIT;
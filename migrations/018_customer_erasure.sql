@@ -0,0 +1,18 @@
+-- Adds erasure of customer personal data with an audit trail.
+BEGIN;
+
+ALTER TABLE customers ADD COLUMN erased_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE customer_erasures (
+    erasure_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    customer_id UUID NOT NULL REFERENCES customers(customer_id) ON DELETE RESTRICT,
+    requested_by VARCHAR(255) NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    erased_fields TEXT[] NOT NULL,
+    orders_kept INT NOT NULL CHECK (orders_kept >= 0),
+    erased_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_customer_erasures_customer_id ON customer_erasures(customer_id);
+
+COMMIT;
@@ -0,0 +1,27 @@
+-- Adds saved customer segment definitions for RFM analysis.
+BEGIN;
+
+-- Saved RFM segment definitions. A customer belongs to the first segment,
+-- lowest priority first, whose score ranges hold its recency, frequency and
+-- monetary scores; the built-in segments of the service apply after them.
+CREATE TABLE customer_segments (
+    segment_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    segment_name VARCHAR(100) NOT NULL UNIQUE CHECK (btrim(segment_name) <> ''),
+    description TEXT NOT NULL DEFAULT '',
+    min_recency INT NOT NULL DEFAULT 1 CHECK (min_recency BETWEEN 1 AND 5),
+    max_recency INT NOT NULL DEFAULT 5 CHECK (max_recency BETWEEN min_recency AND 5),
+    min_frequency INT NOT NULL DEFAULT 1 CHECK (min_frequency BETWEEN 1 AND 5),
+    max_frequency INT NOT NULL DEFAULT 5 CHECK (max_frequency BETWEEN min_frequency AND 5),
+    min_monetary INT NOT NULL DEFAULT 1 CHECK (min_monetary BETWEEN 1 AND 5),
+    max_monetary INT NOT NULL DEFAULT 5 CHECK (max_monetary BETWEEN min_monetary AND 5),
+    priority INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE TRIGGER update_customer_segments_timestamp
+    BEFORE UPDATE ON customer_segments
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
+COMMIT;
@@ -0,0 +1,46 @@
+-- Phone numbers are kept in E.164 form and emails lower-cased, and an email
+-- or phone number belongs to one active customer. Existing phone numbers
+-- lose their formatting and 00 becomes +; national numbers are left as they
+-- are until the customer is next updated.
+--
+-- Customers sharing a contact must be merged first (GET /customer/duplicates
+-- and POST /customer/merge), otherwise the migration stops and lists them.
+BEGIN;
+
+ALTER TABLE customers ALTER COLUMN phone_number TYPE VARCHAR(16);
+
+UPDATE customers SET email = lower(btrim(email)) WHERE email <> lower(btrim(email));
+
+UPDATE customers SET phone_number = regexp_replace(phone_number, '[\s().-]', '', 'g')
+WHERE phone_number ~ '[\s().-]';
+
+UPDATE customers SET phone_number = '+' || substr(phone_number, 3)
+WHERE phone_number ~ '^00[1-9][0-9]{6,14}$';
+
+ALTER TABLE customers ADD CONSTRAINT customers_email_check CHECK (email = lower(email));
+
+DO $$
+DECLARE
+    taken TEXT;
+BEGIN
+    SELECT string_agg(contact, ', ') INTO taken
+    FROM (
+        SELECT email AS contact FROM customers
+        WHERE email <> '' AND merged_into IS NULL AND erased_at IS NULL
+        GROUP BY email HAVING count(*) > 1
+        UNION ALL
+        SELECT phone_number FROM customers
+        WHERE phone_number <> '' AND merged_into IS NULL AND erased_at IS NULL
+        GROUP BY phone_number HAVING count(*) > 1
+    ) shared;
+    IF taken IS NOT NULL THEN
+        RAISE EXCEPTION 'customers share contact details, merge them first: %', taken;
+    END IF;
+END $$;
+
+CREATE UNIQUE INDEX idx_customers_email_unique ON customers(email)
+    WHERE email <> '' AND merged_into IS NULL AND erased_at IS NULL;
+CREATE UNIQUE INDEX idx_customers_phone_number_unique ON customers(phone_number)
+    WHERE phone_number <> '' AND merged_into IS NULL AND erased_at IS NULL;
+
+COMMIT;
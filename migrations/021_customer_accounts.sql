@@ -0,0 +1,44 @@
+-- Adds customer accounts: passwords, one-time login codes and sessions.
+BEGIN;
+
+-- Password of a customer account, as a salted PBKDF2 hash. Customers without
+-- one log in with codes sent to their email or phone
+CREATE TABLE customer_credentials (
+    customer_id UUID PRIMARY KEY REFERENCES customers(customer_id) ON DELETE CASCADE,
+    password_hash TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- One-time login codes sent to customers; only their hash is kept. A code is
+-- used up once it logs in, when a newer one is sent or after too many tries
+CREATE TABLE customer_login_codes (
+    code_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    customer_id UUID NOT NULL REFERENCES customers(customer_id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    attempts INT NOT NULL DEFAULT 0 CHECK (attempts >= 0),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- Customer sessions, by the hash of their bearer token
+CREATE TABLE customer_sessions (
+    session_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    customer_id UUID NOT NULL REFERENCES customers(customer_id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- Indexes for customer_login_codes and customer_sessions tables
+CREATE INDEX idx_customer_login_codes_customer_id ON customer_login_codes(customer_id);
+CREATE INDEX idx_customer_sessions_customer_id ON customer_sessions(customer_id);
+
+CREATE TRIGGER update_customer_credentials_timestamp
+    BEFORE UPDATE ON customer_credentials
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
+COMMIT;
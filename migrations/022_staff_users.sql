@@ -0,0 +1,42 @@
+-- Adds staff users with roles and the log of denied staff requests.
+BEGIN;
+
+CREATE TYPE all_staff_role AS ENUM ('BARISTA', 'SHIFT_LEAD', 'MANAGER', 'ADMIN');
+
+-- Staff users. A staff user with a store works in that store only; without
+-- one in all of them. What each role may do is set by models.RolePermissions
+CREATE TABLE staff_users (
+    staff_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    username VARCHAR(50) NOT NULL UNIQUE CHECK (username ~ '^[a-z0-9._-]{3,50}$'),
+    full_name VARCHAR(255) NOT NULL DEFAULT '',
+    staff_role all_staff_role NOT NULL,
+    store_id UUID REFERENCES stores(store_id) ON DELETE RESTRICT,
+    password_hash TEXT NOT NULL,
+    -- Set instead of deleting; archived staff cannot log in
+    archived_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- Requests to staff endpoints that were denied, with who made them when known
+CREATE TABLE access_denials (
+    denial_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    staff_id UUID REFERENCES staff_users(staff_id) ON DELETE SET NULL,
+    method VARCHAR(10) NOT NULL,
+    path TEXT NOT NULL,
+    permission VARCHAR(50) NOT NULL,
+    reason TEXT NOT NULL,
+    remote_addr VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- Indexes for access_denials table
+CREATE INDEX idx_access_denials_created_at ON access_denials(created_at);
+CREATE INDEX idx_access_denials_staff_id ON access_denials(staff_id);
+
+CREATE TRIGGER update_staff_users_timestamp
+    BEFORE UPDATE ON staff_users
+    FOR EACH ROW
+    EXECUTE FUNCTION update_timestamp();
+
+COMMIT;
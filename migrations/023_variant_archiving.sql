@@ -0,0 +1,7 @@
+-- Variants a menu item no longer lists are archived instead of deleted, so
+-- that removing a size that has been ordered no longer fails.
+BEGIN;
+
+ALTER TABLE menu_item_variants ADD COLUMN archived_at TIMESTAMP WITH TIME ZONE;
+
+COMMIT;
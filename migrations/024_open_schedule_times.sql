@@ -0,0 +1,43 @@
+-- Windows with only a start or only an end time were treated as open all
+-- day. A window with only start_time now opens at that time and one with
+-- only end_time closes at it, as the schedule docs describe.
+BEGIN;
+
+-- Whether a menu item can be sold at a given time. An item follows its own
+-- schedules, or those of its categories and their parents when it has none,
+-- and is always orderable when neither has any.
+CREATE OR REPLACE FUNCTION menu_item_orderable(item UUID, at TIMESTAMP WITH TIME ZONE)
+RETURNS BOOLEAN AS $$
+    WITH RECURSIVE item_categories AS (
+        SELECT c.category_id, c.parent_id
+        FROM menu_item_categories mc
+        JOIN categories c USING(category_id)
+        WHERE mc.menu_item_id = item
+        UNION
+        SELECT p.category_id, p.parent_id
+        FROM categories p
+        JOIN item_categories ic ON p.category_id = ic.parent_id
+    ),
+    windows AS (
+        SELECT s.* FROM menu_schedules s WHERE s.menu_item_id = item
+        UNION ALL
+        SELECT s.* FROM menu_schedules s
+        JOIN item_categories ic USING(category_id)
+        WHERE NOT EXISTS (SELECT 1 FROM menu_schedules WHERE menu_item_id = item)
+    )
+    SELECT NOT EXISTS (SELECT 1 FROM windows) OR EXISTS (
+        SELECT 1 FROM windows w
+        WHERE EXTRACT(ISODOW FROM at)::int = ANY(w.days_of_week)
+        AND (w.start_date IS NULL OR at::date >= w.start_date)
+        AND (w.end_date IS NULL OR at::date <= w.end_date)
+        AND CASE
+            WHEN w.start_time IS NULL AND w.end_time IS NULL THEN TRUE
+            WHEN w.end_time IS NULL THEN at::time >= w.start_time
+            WHEN w.start_time IS NULL THEN at::time < w.end_time
+            WHEN w.start_time <= w.end_time THEN at::time >= w.start_time AND at::time < w.end_time
+            ELSE at::time >= w.start_time OR at::time < w.end_time
+        END
+    );
+$$ LANGUAGE sql STABLE;
+
+COMMIT;
@@ -0,0 +1,16 @@
+-- Menu versions remember the live menu they were drafted from, so that
+-- publishing applies only what a version changes and refuses to overwrite
+-- items edited live since. Existing drafts and scheduled versions are taken
+-- to be based on the published version, as of their creation.
+BEGIN;
+
+ALTER TABLE menu_versions
+    ADD COLUMN base_items JSONB NOT NULL DEFAULT '[]'::JSONB,
+    ADD COLUMN based_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now();
+
+UPDATE menu_versions v
+SET base_items = COALESCE((SELECT items FROM menu_versions WHERE version_status = 'PUBLISHED'), '[]'::JSONB),
+    based_at = v.created_at
+WHERE v.version_status IN ('DRAFT', 'SCHEDULED');
+
+COMMIT;
@@ -0,0 +1,94 @@
+-- Records the prices of variants and the price overrides of stores in
+-- price_history, so bulk price adjustments can change and revert them too.
+-- Their history starts with the first change after this migration.
+BEGIN;
+
+ALTER TABLE price_history
+    -- Set when the row is the price of a variant or the price override of a
+    -- store rather than the menu_items price
+    ADD COLUMN variant_id UUID REFERENCES menu_item_variants(variant_id) ON DELETE CASCADE,
+    ADD COLUMN store_id UUID REFERENCES stores(store_id) ON DELETE CASCADE;
+
+CREATE INDEX idx_price_history_variant_id ON price_history(variant_id) WHERE variant_id IS NOT NULL;
+CREATE INDEX idx_price_history_store_id ON price_history(store_id) WHERE store_id IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION track_menu_item_price_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        -- Insert initial price history record for new menu items
+        INSERT INTO price_history (menu_item_id, price, effective_from)
+        VALUES (NEW.menu_item_id, NEW.price, now());
+    ELSIF TG_OP = 'UPDATE' AND NEW.price <> OLD.price THEN
+        -- Close previous price period
+        UPDATE price_history
+        SET effective_to = now()
+        WHERE menu_item_id = NEW.menu_item_id
+          AND variant_id IS NULL AND store_id IS NULL
+          AND effective_to IS NULL;
+
+        -- Insert new price with NULL effective_to; bulk adjustments name
+        -- their batch in the app.price_adjustment_id setting
+        INSERT INTO price_history (menu_item_id, price, previous_price, adjustment_id, effective_from)
+        VALUES (NEW.menu_item_id, NEW.price, OLD.price,
+            NULLIF(current_setting('app.price_adjustment_id', true), '')::uuid, now());
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Variant prices and store price overrides are tracked the same way, in
+-- rows carrying their variant_id or store_id
+CREATE OR REPLACE FUNCTION track_variant_price_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.price = OLD.price THEN
+        RETURN NEW;
+    END IF;
+    UPDATE price_history
+    SET effective_to = now()
+    WHERE variant_id = NEW.variant_id
+      AND effective_to IS NULL;
+
+    INSERT INTO price_history (menu_item_id, variant_id, price, previous_price, adjustment_id, effective_from)
+    VALUES (NEW.menu_item_id, NEW.variant_id, NEW.price,
+        CASE WHEN TG_OP = 'UPDATE' THEN OLD.price END,
+        NULLIF(current_setting('app.price_adjustment_id', true), '')::uuid, now());
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_track_variant_price_change
+AFTER INSERT OR UPDATE OF price ON menu_item_variants
+FOR EACH ROW EXECUTE FUNCTION track_variant_price_change();
+
+CREATE OR REPLACE FUNCTION track_store_price_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.price IS NOT DISTINCT FROM OLD.price THEN
+        RETURN NEW;
+    END IF;
+    UPDATE price_history
+    SET effective_to = now()
+    WHERE store_id = COALESCE(NEW.store_id, OLD.store_id)
+      AND menu_item_id = COALESCE(NEW.menu_item_id, OLD.menu_item_id)
+      AND effective_to IS NULL;
+
+    -- Dropping the override or its price ends its price period
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    ELSIF NEW.price IS NOT NULL THEN
+        INSERT INTO price_history (menu_item_id, store_id, price, previous_price, adjustment_id, effective_from)
+        VALUES (NEW.menu_item_id, NEW.store_id, NEW.price,
+            CASE WHEN TG_OP = 'UPDATE' THEN OLD.price END,
+            NULLIF(current_setting('app.price_adjustment_id', true), '')::uuid, now());
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_track_store_price_change
+AFTER INSERT OR UPDATE OF price OR DELETE ON store_menu_items
+FOR EACH ROW EXECUTE FUNCTION track_store_price_change();
+
+COMMIT;
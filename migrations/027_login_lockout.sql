@@ -0,0 +1,10 @@
+-- Counts wrong customer passwords in a row so that password logins can be
+-- locked for a while after too many of them.
+BEGIN;
+
+ALTER TABLE customer_credentials
+    -- Wrong passwords in a row; too many lock password logins until locked_until
+    ADD COLUMN failed_logins INT NOT NULL DEFAULT 0 CHECK (failed_logins >= 0),
+    ADD COLUMN locked_until TIMESTAMP WITH TIME ZONE;
+
+COMMIT;
@@ -0,0 +1,50 @@
+-- Menu schedules were read in the time zone of the database session, UTC
+-- by default. Stores get a time zone and menu_item_orderable reads the
+-- schedules in that of the store selling the item.
+BEGIN;
+
+ALTER TABLE stores
+    -- IANA name of the zone the menu schedules of the store are read in
+    ADD COLUMN time_zone TEXT NOT NULL DEFAULT 'UTC';
+
+DROP FUNCTION menu_item_orderable(UUID, TIMESTAMP WITH TIME ZONE);
+
+-- Whether a menu item can be sold at a given time. Schedules are read in
+-- the time zone tz, that of the store selling the item. An item follows its
+-- own schedules, or those of its categories and their parents when it has
+-- none, and is always orderable when neither has any.
+CREATE OR REPLACE FUNCTION menu_item_orderable(item UUID, at TIMESTAMP WITH TIME ZONE, tz TEXT)
+RETURNS BOOLEAN AS $$
+    WITH RECURSIVE item_categories AS (
+        SELECT c.category_id, c.parent_id
+        FROM menu_item_categories mc
+        JOIN categories c USING(category_id)
+        WHERE mc.menu_item_id = item
+        UNION
+        SELECT p.category_id, p.parent_id
+        FROM categories p
+        JOIN item_categories ic ON p.category_id = ic.parent_id
+    ),
+    windows AS (
+        SELECT s.* FROM menu_schedules s WHERE s.menu_item_id = item
+        UNION ALL
+        SELECT s.* FROM menu_schedules s
+        JOIN item_categories ic USING(category_id)
+        WHERE NOT EXISTS (SELECT 1 FROM menu_schedules WHERE menu_item_id = item)
+    )
+    SELECT NOT EXISTS (SELECT 1 FROM windows) OR EXISTS (
+        SELECT 1 FROM windows w, (SELECT at AT TIME ZONE tz AS local_at) l
+        WHERE EXTRACT(ISODOW FROM l.local_at)::int = ANY(w.days_of_week)
+        AND (w.start_date IS NULL OR l.local_at::date >= w.start_date)
+        AND (w.end_date IS NULL OR l.local_at::date <= w.end_date)
+        AND CASE
+            WHEN w.start_time IS NULL AND w.end_time IS NULL THEN TRUE
+            WHEN w.end_time IS NULL THEN l.local_at::time >= w.start_time
+            WHEN w.start_time IS NULL THEN l.local_at::time < w.end_time
+            WHEN w.start_time <= w.end_time THEN l.local_at::time >= w.start_time AND l.local_at::time < w.end_time
+            ELSE l.local_at::time >= w.start_time OR l.local_at::time < w.end_time
+        END
+    );
+$$ LANGUAGE sql STABLE;
+
+COMMIT;
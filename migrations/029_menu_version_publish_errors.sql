@@ -0,0 +1,10 @@
+-- Scheduled menu versions were published in one transaction, so one that
+-- failed kept all of them from being published. Each is now published on
+-- its own, and one that fails goes back to draft with the reason kept here.
+BEGIN;
+
+ALTER TABLE menu_versions
+    -- Why the version went back to draft when it failed to publish on schedule
+    ADD COLUMN publish_error TEXT NOT NULL DEFAULT '';
+
+COMMIT;
@@ -0,0 +1,14 @@
+-- Locks staff logins for a while after too many wrong passwords, as for
+-- customers, and ends the tokens of a member of staff when their password
+-- changes. Staff are logged out once when this is applied, as their tokens
+-- were issued before the password_changed_at it sets.
+BEGIN;
+
+ALTER TABLE staff_users
+    -- Wrong passwords in a row; too many lock logins until locked_until
+    ADD COLUMN failed_logins INT NOT NULL DEFAULT 0 CHECK (failed_logins >= 0),
+    ADD COLUMN locked_until TIMESTAMP WITH TIME ZONE,
+    -- Tokens issued before the password last changed are no longer accepted
+    ADD COLUMN password_changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now();
+
+COMMIT;
@@ -0,0 +1,35 @@
+package models
+
+import "frappuccino/utils"
+
+// AccountRegistration opens a customer account with a password.
+type AccountRegistration struct {
+	Customer
+	Password string `json:"password"`
+}
+
+// Login identifies a customer by email, or by phone number read as in
+// Country, and proves it with either a password or a login code.
+type Login struct {
+	Email       string `json:"email"`
+	PhoneNumber string `json:"phone_number"`
+	Country     string `json:"country"`
+	Password    string `json:"password"`
+	Code        string `json:"code"`
+}
+
+// CustomerSession is a logged-in customer. Token is sent back as
+// "Authorization: Bearer <token>" and is only known when the session starts.
+type CustomerSession struct {
+	Token      string     `json:"token"`
+	CustomerId utils.TEXT `json:"customer_id"`
+	ExpiresAt  utils.TIME `json:"expires_at"`
+}
+
+// PasswordChange sets the password of the logged-in customer or member of
+// staff. CurrentPassword is needed once the customer has a password, and
+// from staff changing their own.
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password"`
+	Password        string `json:"password"`
+}
@@ -1,18 +1,24 @@
 package models
 
+import "time"
+
 // TotalSales
 type TotalSales struct {
-	Value float64 `json:"total_sales"`
+	StoreId string  `json:"store_id,omitempty"`
+	Value   float64 `json:"total_sales"`
 }
 
 // Popular Items
 type PopularItems struct {
-	Items []PopularItem `json:"popular_items"`
+	StoreId string        `json:"store_id,omitempty"`
+	Items   []PopularItem `json:"popular_items"`
 }
 
 type PopularItem struct {
-	ItemName     string `json:"item_name"`
-	OrderedTimes int    `json:"ordered_times"`
+	ItemName     string  `json:"item_name"`
+	VariantName  string  `json:"variant_name,omitempty"`
+	OrderedTimes int     `json:"ordered_times"`
+	Revenue      float64 `json:"revenue"`
 }
 
 // Search
@@ -48,3 +54,84 @@ type OrderedItemByPeriod struct {
 	Date  string
 	Count int
 }
+
+// CustomerRFM scores a customer from 1 to 5 on the recency of their last
+// order, their number of orders and what they spent, relative to the other
+// customers, and names the segment the scores put them in.
+type CustomerRFM struct {
+	CustomerId  string    `json:"customer_id"`
+	FullName    string    `json:"full_name"`
+	Email       string    `json:"email"`
+	PhoneNumber string    `json:"phone_number"`
+	LastOrderAt time.Time `json:"last_order_at"`
+	DaysSince   int       `json:"days_since_last_order"`
+	Orders      int       `json:"orders"`
+	Spent       float64   `json:"spent"`
+	Recency     int       `json:"recency"`
+	Frequency   int       `json:"frequency"`
+	Monetary    int       `json:"monetary"`
+	Segment     string    `json:"segment"`
+}
+
+type CustomerSegments struct {
+	StoreId   string         `json:"store_id,omitempty"`
+	Segment   string         `json:"segment,omitempty"`
+	Counts    map[string]int `json:"counts"`
+	Customers []CustomerRFM  `json:"customers"`
+}
+
+// CohortRetention shows, per monthly signup cohort, which share of its
+// customers ordered in each of the months after the signup month.
+type CohortRetention struct {
+	StoreId string   `json:"store_id,omitempty"`
+	Months  int      `json:"months"`
+	Cohorts []Cohort `json:"cohorts"`
+}
+
+// Cohort is one row of a retention table. Active[i] customers ordered in
+// month i+1 after signing up, Retention[i] percent of the cohort. Months
+// that have not ended yet are left out.
+type Cohort struct {
+	Cohort    string    `json:"cohort"`
+	Customers int       `json:"customers"`
+	Active    []int     `json:"active"`
+	Retention []float64 `json:"retention"`
+}
+
+// CohortActivity counts the customers of a cohort that ordered in a month
+// after signing up; month 0 counts the whole cohort.
+type CohortActivity struct {
+	Cohort    string
+	Month     int
+	Customers int
+}
+
+// CustomerLTV is what a customer has spent so far.
+type CustomerLTV struct {
+	CustomerId        string     `json:"customer_id"`
+	FullName          string     `json:"full_name"`
+	Cohort            string     `json:"cohort"`
+	Orders            int        `json:"orders"`
+	Revenue           float64    `json:"revenue"`
+	AverageOrderValue float64    `json:"average_order_value"`
+	FirstOrderAt      *time.Time `json:"first_order_at"`
+	LastOrderAt       *time.Time `json:"last_order_at"`
+}
+
+// CohortLTV is the lifetime value of the customers of a signup cohort.
+type CohortLTV struct {
+	Cohort            string  `json:"cohort"`
+	Customers         int     `json:"customers"`
+	PayingCustomers   int     `json:"paying_customers"`
+	Orders            int     `json:"orders"`
+	Revenue           float64 `json:"revenue"`
+	LTV               float64 `json:"ltv"`
+	AverageOrderValue float64 `json:"average_order_value"`
+}
+
+type LifetimeValue struct {
+	StoreId   string        `json:"store_id,omitempty"`
+	GroupBy   string        `json:"group_by"`
+	Customers []CustomerLTV `json:"customers,omitempty"`
+	Cohorts   []CohortLTV   `json:"cohorts,omitempty"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "frappuccino/utils"
+
+// BundleSlot is a component of a bundle menu item. With a single option the
+// component is fixed; with several the order picks one of Options.
+type BundleSlot struct {
+	SlotId       utils.TEXT   `json:"slot_id"`
+	BundleId     utils.TEXT   `json:"bundle_id"`
+	SlotName     utils.TEXT   `json:"slot_name"`
+	Quantity     utils.DEC    `json:"quantity"`
+	DisplayOrder utils.INT    `json:"display_order"`
+	Options      []utils.TEXT `json:"options"`
+}
+
+// BundleSelection picks the menu item of a choice slot in an order item.
+type BundleSelection struct {
+	SlotId     utils.TEXT `json:"slot_id"`
+	MenuItemId utils.TEXT `json:"menu_item_id"`
+}
+
+// OrderItemComponent is a menu item sold as part of a bundle order item.
+// AllocatedPrice is its share of the price of one bundle.
+type OrderItemComponent struct {
+	MenuItemId     utils.TEXT `json:"menu_item_id"`
+	SlotName       utils.TEXT `json:"slot_name"`
+	Quantity       utils.DEC  `json:"quantity"`
+	AllocatedPrice utils.DEC  `json:"allocated_price"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "frappuccino/utils"
+
+// Category groups menu items. Categories form a tree through ParentId;
+// siblings are shown in DisplayOrder.
+type Category struct {
+	CategoryId   utils.TEXT `json:"category_id"`
+	CategoryName utils.TEXT `json:"category_name"`
+	ParentId     utils.TEXT `json:"parent_id,omitempty"`
+	Description  utils.TEXT `json:"description"`
+	DisplayOrder utils.INT  `json:"display_order"`
+	Children     []Category `json:"children,omitempty"`
+	CreatedAt    utils.TIME `json:"created_at"`
+	UpdatedAt    utils.TIME `json:"updated_at"`
+}
+
+// CategorySales is what the items of a category sold. With rollup to parent
+// categories a category also counts the sales of its subcategories.
+type CategorySales struct {
+	CategoryId   string  `json:"category_id"`
+	CategoryName string  `json:"category_name"`
+	Quantity     int     `json:"quantity"`
+	Revenue      float64 `json:"revenue"`
+}
+
+type SalesByCategory struct {
+	StoreId    string          `json:"store_id,omitempty"`
+	GroupBy    string          `json:"group_by"`
+	Categories []CategorySales `json:"categories"`
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
+)
+
+// phoneCountry is how phone numbers are written in a country: its calling
+// code, the trunk prefix dialled before national numbers, if any, and how
+// many digits national numbers have without it.
+type phoneCountry struct {
+	CallingCode string
+	TrunkPrefix string
+	MinDigits   int
+	MaxDigits   int
+}
+
+// phoneCountries are the countries, by ISO 3166 code, whose national
+// numbers are understood. International numbers of other countries are
+// only checked against E.164.
+var phoneCountries = map[string]phoneCountry{
+	"AE": {"971", "0", 8, 9},
+	"AU": {"61", "0", 9, 9},
+	"BR": {"55", "0", 10, 11},
+	"CA": {"1", "1", 10, 10},
+	"CN": {"86", "0", 10, 11},
+	"DE": {"49", "0", 6, 13},
+	"ES": {"34", "", 9, 9},
+	"FR": {"33", "0", 9, 9},
+	"GB": {"44", "0", 9, 10},
+	"IN": {"91", "0", 10, 10},
+	"IT": {"39", "", 6, 11},
+	"JP": {"81", "0", 9, 10},
+	"KG": {"996", "0", 9, 9},
+	"KR": {"82", "0", 8, 10},
+	"KZ": {"7", "8", 10, 10},
+	"MX": {"52", "", 10, 10},
+	"NL": {"31", "0", 9, 9},
+	"RU": {"7", "8", 10, 10},
+	"TR": {"90", "0", 10, 10},
+	"UA": {"380", "0", 9, 9},
+	"US": {"1", "1", 10, 10},
+	"UZ": {"998", "", 9, 9},
+}
+
+// NormalizePhone returns phone in E.164 form, such as +77011234567. Spaces,
+// dashes, dots and parentheses are ignored. Numbers starting with + or 00
+// are international; other numbers are national numbers of country, which
+// is then required. Numbers of known countries must have as many digits as
+// their national numbers do.
+func NormalizePhone(phone, country string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9', r == '+':
+			return r
+		case r == ' ', r == '-', r == '.', r == '(', r == ')':
+			return -1
+		}
+		return '?'
+	}, strings.TrimSpace(phone))
+	if strings.ContainsRune(digits, '?') || strings.LastIndex(digits, "+") > 0 {
+		return "", errors.New("may only have digits, a leading + and spaces, dashes, dots or parentheses")
+	}
+
+	country = strings.ToUpper(strings.TrimSpace(country))
+	var international string
+	switch {
+	case strings.HasPrefix(digits, "+"):
+		international = digits[1:]
+	case strings.HasPrefix(digits, "00"):
+		international = digits[2:]
+	default:
+		c, ok := phoneCountries[country]
+		if !ok {
+			return "", errors.New("needs a +country code, or a known country for national numbers")
+		}
+		national := digits
+		if c.TrunkPrefix != "" && strings.HasPrefix(national, c.TrunkPrefix) && len(national)-len(c.TrunkPrefix) >= c.MinDigits {
+			national = national[len(c.TrunkPrefix):]
+		}
+		if len(national) < c.MinDigits || len(national) > c.MaxDigits {
+			return "", fmt.Errorf("is not a valid %s number", country)
+		}
+		international = c.CallingCode + national
+	}
+
+	if len(international) < 7 || len(international) > 15 || international[0] == '0' {
+		return "", errors.New("is not a valid international number")
+	}
+	if valid, known := validNational(international); known && !valid {
+		return "", errors.New("has the wrong number of digits for its country")
+	}
+	return "+" + international, nil
+}
+
+// validNational checks the national part of an international number against
+// the countries with its calling code. known is false when no such country
+// is listed in phoneCountries.
+func validNational(international string) (valid, known bool) {
+	for _, c := range phoneCountries {
+		if !strings.HasPrefix(international, c.CallingCode) {
+			continue
+		}
+		known = true
+		n := len(international) - len(c.CallingCode)
+		if n >= c.MinDigits && n <= c.MaxDigits {
+			return true, true
+		}
+	}
+	return false, known
+}
+
+// NormalizeEmail returns email trimmed and lower-cased after checking that
+// it is a plain address with a domain name.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if len(email) > 254 {
+		return "", errors.New("is longer than 254 characters")
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || address.Name != "" {
+		return "", errors.New("is not a valid email address")
+	}
+	domain := email[strings.LastIndex(email, "@")+1:]
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 || slices.Contains(labels, "") {
+		return "", errors.New("needs a domain name such as example.com")
+	}
+	return email, nil
+}
@@ -1,15 +1,133 @@
 package models
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"frappuccino/utils"
+	"slices"
 )
 
 type Customer struct {
-	CustomerId  utils.TEXT  `json:"customer_id"`
-	FullName    utils.TEXT  `json:"full_name"`
-	PhoneNumber utils.TEXT  `json:"phone_number"`
+	CustomerId  utils.TEXT `json:"customer_id"`
+	FullName    utils.TEXT `json:"full_name"`
+	PhoneNumber utils.TEXT `json:"phone_number"`
+	// Country, an ISO 3166 code such as KZ, reads a phone number given
+	// without its country code. It is not stored.
+	Country     utils.TEXT  `json:"country,omitempty"`
 	Email       utils.TEXT  `json:"email"`
-	Preferences utils.JSONB `json:"preferences"`
+	Preferences Preferences `json:"preferences"`
+	ArchivedAt  *utils.TIME `json:"archived_at"`
+	MergedInto  utils.TEXT  `json:"merged_into,omitempty"`
+	ErasedAt    *utils.TIME `json:"erased_at,omitempty"`
 	CreatedAt   utils.TIME  `json:"created_at"`
 	UpdatedAt   utils.TIME  `json:"updated_at"`
 }
+
+// CustomerFilter selects customers. Query matches names and emails partly
+// or fuzzily and phone numbers by their digits. Customers match Preferences
+// when they have all the preferences it sets.
+type CustomerFilter struct {
+	Query       string
+	Archived    bool
+	Preferences Preferences
+}
+
+// Values accepted in customer preferences.
+var (
+	MilkPreferences = []string{"whole", "skim", "oat", "almond", "soy", "coconut", "none"}
+	SugarLevels     = []string{"none", "low", "medium", "high"}
+	Allergens       = []string{"dairy", "nuts", "peanuts", "gluten", "soy", "egg", "sesame"}
+)
+
+// Preferences are what a customer usually has. FavouriteDrink is the id of
+// a menu item. Unset preferences are left out of the stored JSON.
+type Preferences struct {
+	FavouriteDrink utils.TEXT `json:"favourite_drink,omitempty"`
+	MilkPreference utils.TEXT `json:"milk_preference,omitempty"`
+	SugarLevel     utils.TEXT `json:"sugar_level,omitempty"`
+	Allergies      []string   `json:"allergies,omitempty"`
+}
+
+// UnmarshalJSON rejects preferences outside the schema.
+func (p *Preferences) UnmarshalJSON(data []byte) error {
+	type preferences Preferences
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var v preferences
+	if err := decoder.Decode(&v); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidPreferences, err)
+	}
+	*p = Preferences(v)
+	return nil
+}
+
+// Validate checks the values of the preferences.
+func (p Preferences) Validate() error {
+	if p.MilkPreference != "" && !slices.Contains(MilkPreferences, string(p.MilkPreference)) {
+		return fmt.Errorf("milk_preference %q: %w", p.MilkPreference, ErrInvalidPreferences)
+	}
+	if p.SugarLevel != "" && !slices.Contains(SugarLevels, string(p.SugarLevel)) {
+		return fmt.Errorf("sugar_level %q: %w", p.SugarLevel, ErrInvalidPreferences)
+	}
+	for i, allergy := range p.Allergies {
+		if !slices.Contains(Allergens, allergy) || slices.Contains(p.Allergies[:i], allergy) {
+			return fmt.Errorf("allergy %q: %w", allergy, ErrInvalidPreferences)
+		}
+	}
+	return nil
+}
+
+// Customizations are the customizations an order item of the customer gets
+// when the order leaves them out: milk, sugar and allergies.
+func (p Preferences) Customizations() utils.JSONB {
+	if p.MilkPreference == "" && p.SugarLevel == "" && len(p.Allergies) == 0 {
+		return nil
+	}
+	data, _ := json.Marshal(Preferences{MilkPreference: p.MilkPreference, SugarLevel: p.SugarLevel, Allergies: p.Allergies})
+	return utils.JSONB(data)
+}
+
+// DuplicateCustomers is a pair of active customers that are likely the same
+// person, with why: "email", "phone" and "name" when the names are similar.
+type DuplicateCustomers struct {
+	Customer       Customer  `json:"customer"`
+	Duplicate      Customer  `json:"duplicate"`
+	Reasons        []string  `json:"reasons"`
+	NameSimilarity utils.DEC `json:"name_similarity"`
+}
+
+// CustomerMerge folds duplicate customers into the surviving one.
+type CustomerMerge struct {
+	SurvivorId   utils.TEXT   `json:"survivor_id"`
+	DuplicateIds []utils.TEXT `json:"duplicate_ids"`
+}
+
+// CustomerMergeResult is the surviving customer after a merge.
+type CustomerMergeResult struct {
+	Customer    Customer     `json:"customer"`
+	Merged      []utils.TEXT `json:"merged"`
+	OrdersMoved utils.INT    `json:"orders_moved"`
+}
+
+// CustomerExport is all the data kept about a customer: their record, the
+// records merged into it, their orders and the erasures of their data. No
+// loyalty history is kept, so there is none in it.
+type CustomerExport struct {
+	ExportedAt      utils.TIME        `json:"exported_at"`
+	Customer        Customer          `json:"customer"`
+	MergedCustomers []Customer        `json:"merged_customers"`
+	Orders          []Order           `json:"orders"`
+	Erasures        []CustomerErasure `json:"erasures"`
+}
+
+// CustomerErasure records that the personal data of a customer was erased.
+type CustomerErasure struct {
+	ErasureId    utils.TEXT    `json:"erasure_id"`
+	CustomerId   utils.TEXT    `json:"customer_id"`
+	RequestedBy  utils.TEXT    `json:"requested_by"`
+	Reason       utils.TEXT    `json:"reason"`
+	ErasedFields utils.TEXTARR `json:"erased_fields"`
+	OrdersKept   utils.INT     `json:"orders_kept"`
+	ErasedAt     utils.TIME    `json:"erased_at"`
+}
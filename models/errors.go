@@ -1,12 +1,86 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"sort"
+	"strings"
+)
 
 var (
-	ErrInvalidQuantity       = errors.New("quantity cannot be negative")
-	ErrInvalidReorderLevel   = errors.New("reorder level cannot be negative")
-	ErrInvalidIngredientId   = errors.New("Id be positive")
-	ErrInvalidIngredientName = errors.New("ingredient name cannot be empty")
+	ErrInvalidQuantity         = errors.New("quantity cannot be negative")
+	ErrInvalidReorderLevel     = errors.New("reorder level cannot be negative")
+	ErrInvalidUnitCost         = errors.New("unit cost cannot be negative")
+	ErrInvalidIngredientId     = errors.New("Id be positive")
+	ErrInvalidIngredientName   = errors.New("ingredient name cannot be empty")
+	ErrMissingStore            = errors.New("store context is required")
+	ErrInvalidStoreName        = errors.New("store name cannot be empty")
+	ErrInvalidTimeZone         = errors.New("time zone must be an IANA time zone name")
+	ErrInsufficientStock       = errors.New("not enough inventory in store")
+	ErrItemNotAvailable        = errors.New("menu item is not available in store")
+	ErrInvalidTransfer         = errors.New("transfer needs two different stores and at least one item, and ships and receives only its own items")
+	ErrTransferStatus          = errors.New("transfer is not in a state that allows this action")
+	ErrRecipeCycle             = errors.New("recipe would make the ingredient depend on itself")
+	ErrInvalidRecipe           = errors.New("recipe needs a positive batch yield and at least one component")
+	ErrNotPrepared             = errors.New("ingredient is not prepared in-house")
+	ErrInvalidVariant          = errors.New("variant does not belong to the menu item")
+	ErrInvalidGroupBy          = errors.New("unsupported group_by value")
+	ErrInvalidModifier         = errors.New("invalid modifier selection")
+	ErrInvalidModifierGroup    = errors.New("modifier group needs a name and 0 <= min_selections <= max_selections")
+	ErrInvalidBundle           = errors.New("invalid bundle")
+	ErrInvalidNutrition        = errors.New("nutrition values cannot be negative")
+	ErrInvalidSchedule         = errors.New("schedule needs either a menu item or an existing category, HH:MM times, YYYY-MM-DD dates and days 1-7")
+	ErrOutsideSchedule         = errors.New("menu item is outside its availability window")
+	ErrMenuVersionStatus       = errors.New("menu version is not in a state that allows this action")
+	ErrMenuVersionConflict     = errors.New("menu items the version changes were edited after it was drafted")
+	ErrInvalidImport           = errors.New("import file is malformed")
+	ErrInvalidPriceAdjustment  = errors.New("price adjustment needs a target, a PERCENT or ABSOLUTE change and a positive round_to")
+	ErrPriceAdjustmentReverted = errors.New("price adjustment is already reverted")
+	ErrInvalidCategory         = errors.New("category needs a unique name and an existing parent that is not one of its subcategories")
+	ErrCategoryInUse           = errors.New("category has subcategories")
+	ErrInvalidImage            = errors.New("image must be a JPEG, PNG or GIF of at most 8000 pixels a side and 25 megapixels")
+	ErrImageTooLarge           = errors.New("image is larger than 5 MB")
+	ErrInvalidTranslation      = errors.New("translation needs a locale other than the default, a name and exactly one existing menu item, category, modifier group or modifier")
+	ErrInvalidLocale           = errors.New("locale must be a language tag such as fr or pt-br")
+	ErrInvalidOrderFilter      = errors.New("status must be PENDING, COMPLETED or CANCELLED and page and page_size positive")
+	ErrInvalidOrderItemChange  = errors.New("only the quantity and customizations of an order item can be changed")
+	ErrNothingToReorder        = errors.New("none of the items of the order can be ordered any more")
+	ErrInvalidMerge            = errors.New("merge needs a survivor and other, not yet merged customers as duplicates")
+	ErrInvalidPreferences      = errors.New("preferences take favourite_drink, an existing menu item, milk_preference, sugar_level and allergies from the accepted values")
+	ErrCustomerErased          = errors.New("customer data is erased")
+	ErrInvalidSegment          = errors.New("segment needs a unique name and score ranges within 1 to 5")
+	ErrInvalidCohortRange      = errors.New("months must be 1 to 36 and from a YYYY-MM month")
+	ErrInvalidContact          = errors.New("invalid customer contact details")
+	ErrContactTaken            = errors.New("contact details are used by another customer")
+	ErrInvalidPassword         = errors.New("password must be 8 to 128 characters")
+	ErrInvalidLogin            = errors.New("login needs an email or a phone number")
+	ErrInvalidCredentials      = errors.New("wrong login details")
+	ErrLoginLocked             = errors.New("too many failed logins, try again later")
+	ErrWrongPassword           = errors.New("current password is wrong")
+	ErrLoginCodesUnavailable   = errors.New("login codes cannot be sent, log in with a password")
+	ErrUnauthenticated         = errors.New("login required")
+	ErrInvalidStaff            = errors.New("staff user needs a unique username of 3 to 50 lowercase letters, digits, dots, dashes or underscores, a role and an existing store if any")
+	ErrForbidden               = errors.New("not allowed for your role or store")
+	ErrLastAdmin               = errors.New("there must be an active admin left")
 )
 
 type APIError struct{}
+
+// ValidationError tells what is wrong with each field of an input, by the
+// JSON name of the field. Err is the kind of error, such as ErrInvalidContact.
+type ValidationError struct {
+	Err    error             `json:"-"`
+	Fields map[string]string `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field, problem := range e.Fields {
+		fields = append(fields, field+" "+problem)
+	}
+	sort.Strings(fields)
+	return e.Err.Error() + ": " + strings.Join(fields, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
@@ -7,9 +7,38 @@ type MenuItems struct {
 	ItemName        utils.TEXT    `json:"item_name"`
 	ItemDescription utils.TEXT    `json:"item_description"`
 	Price           utils.DEC     `json:"price"`
-	Categories      utils.TEXTARR `json:"categories"`
-	CreatedAt       utils.TIME    `json:"created_at"`
-	UpdatedAt       utils.TIME    `json:"updated_at"`
+	CategoryIds     utils.TEXTARR `json:"category_ids"`
+	// Categories holds the names of CategoryIds. Only imports read it, to
+	// find categories by name
+	Categories utils.TEXTARR `json:"categories"`
+	Tags       utils.TEXTARR `json:"tags"`
+	// Orderable is false outside the item's availability windows
+	Orderable      bool                   `json:"orderable"`
+	Ingredients    []MenuItemsIngredients `json:"ingredients,omitempty"`
+	Variants       []MenuItemVariant      `json:"variants,omitempty"`
+	ModifierGroups []ModifierGroup        `json:"modifier_groups,omitempty"`
+	BundleSlots    []BundleSlot           `json:"bundle_slots,omitempty"`
+	Images         []MenuItemImage        `json:"images,omitempty"`
+	Allergens      utils.TEXTARR          `json:"allergens"`
+	Diets          utils.TEXTARR          `json:"diets"`
+	Nutrition      *Nutrition             `json:"nutrition,omitempty"`
+	ArchivedAt     *utils.TIME            `json:"archived_at"`
+	CreatedAt      utils.TIME             `json:"created_at"`
+	UpdatedAt      utils.TIME             `json:"updated_at"`
+}
+
+// MenuFilter narrows a menu listing. Items containing any of
+// ExcludeAllergens or not suiting all of Diets are left out, and so are
+// modifiers adding any of ExcludeAllergens and items outside their
+// availability windows unless All is set. Archived lists the archived items
+// instead of the menu. Locales are the preferred locales of the texts, most
+// preferred first.
+type MenuFilter struct {
+	ExcludeAllergens []string
+	Diets            []string
+	All              bool
+	Archived         bool
+	Locales          []string
 }
 
 type MenuItemsIngredients struct {
@@ -20,6 +49,46 @@ type MenuItemsIngredients struct {
 	Quantity             utils.DEC  `json:"quantity"`
 }
 
+// MenuItemVariant is a size of a menu item with its own price. Ingredients,
+// when set, replace the menu item recipe; otherwise the recipe is scaled by
+// RecipeMultiplier.
+type MenuItemVariant struct {
+	VariantId        utils.TEXT        `json:"variant_id"`
+	MenuItemId       utils.TEXT        `json:"menu_item_id"`
+	VariantName      utils.TEXT        `json:"variant_name"`
+	Price            utils.DEC         `json:"price"`
+	RecipeMultiplier utils.DEC         `json:"recipe_multiplier"`
+	DisplayOrder     utils.INT         `json:"display_order"`
+	Ingredients      []RecipeComponent `json:"ingredients,omitempty"`
+}
+
+// Recipe returns the recipe lines of one serving of the variant given the
+// recipe of its menu item.
+func (v MenuItemVariant) Recipe(base []MenuItemsIngredients) []MenuItemsIngredients {
+	if len(v.Ingredients) > 0 {
+		recipe := make([]MenuItemsIngredients, 0, len(v.Ingredients))
+		for _, c := range v.Ingredients {
+			recipe = append(recipe, MenuItemsIngredients{
+				MenuItemId:     v.MenuItemId,
+				IngredientId:   c.IngredientId,
+				IngredientName: c.IngredientName,
+				Quantity:       c.Quantity,
+			})
+		}
+		return recipe
+	}
+	multiplier := v.RecipeMultiplier
+	if multiplier <= 0 {
+		multiplier = 1
+	}
+	recipe := make([]MenuItemsIngredients, 0, len(base))
+	for _, ingredient := range base {
+		ingredient.Quantity *= multiplier
+		recipe = append(recipe, ingredient)
+	}
+	return recipe
+}
+
 // func (m *Menu) Marshal(dtoMenu *dto.Menu) {
 // }
 
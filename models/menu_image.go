@@ -0,0 +1,20 @@
+package models
+
+import "frappuccino/utils"
+
+// MenuItemImage is a picture of a menu item with its thumbnail. The files
+// live in media storage under ImageKey and ThumbnailKey.
+type MenuItemImage struct {
+	ImageId      utils.TEXT `json:"image_id"`
+	MenuItemId   utils.TEXT `json:"menu_item_id"`
+	ImageKey     utils.TEXT `json:"-"`
+	ThumbnailKey utils.TEXT `json:"-"`
+	URL          utils.TEXT `json:"url"`
+	ThumbnailURL utils.TEXT `json:"thumbnail_url"`
+	ContentType  utils.TEXT `json:"content_type"`
+	Width        utils.INT  `json:"width"`
+	Height       utils.INT  `json:"height"`
+	SizeBytes    utils.INT  `json:"size_bytes"`
+	DisplayOrder utils.INT  `json:"display_order"`
+	CreatedAt    utils.TIME `json:"created_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "frappuccino/utils"
+
+// MenuImportRow is a menu item read from an import file. Row is the line
+// (CSV) or position (JSON) the item starts at, for error reports. Recipe
+// lines may name their ingredient instead of giving its id.
+type MenuImportRow struct {
+	Row  int
+	Item MenuItems
+}
+
+// MenuImportResult reports what an import did, or would do in a dry run.
+// Nothing is applied when Errors is not empty.
+type MenuImportResult struct {
+	DryRun  bool              `json:"dry_run"`
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Errors  []MenuImportError `json:"errors"`
+}
+
+type MenuImportError struct {
+	Row      int        `json:"row"`
+	ItemName utils.TEXT `json:"item_name"`
+	Error    string     `json:"error"`
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"frappuccino/utils"
+	"reflect"
+	"sort"
+)
+
+const (
+	MenuVersionDraft     = "DRAFT"
+	MenuVersionScheduled = "SCHEDULED"
+	MenuVersionPublished = "PUBLISHED"
+	MenuVersionArchived  = "ARCHIVED"
+)
+
+// MenuVersion is a snapshot of the whole menu. BaseItems is the live menu
+// the version was drafted from, at BasedAt. Publishing applies what Items
+// change from BaseItems to the live menu: items added or changed are saved
+// and items dropped are taken off the menu, while live items the version
+// does not touch stay as they are.
+type MenuVersion struct {
+	VersionId     utils.TEXT  `json:"version_id"`
+	VersionNumber utils.INT   `json:"version_number"`
+	VersionName   utils.TEXT  `json:"version_name"`
+	VersionStatus utils.TEXT  `json:"version_status"`
+	Items         []MenuItems `json:"items"`
+	BaseItems     []MenuItems `json:"-"`
+	BasedAt       utils.TIME  `json:"based_at"`
+	PublishAt     *utils.TIME `json:"publish_at"`
+	PublishedAt   *utils.TIME `json:"published_at"`
+	// PublishError is why the version was not published when scheduled;
+	// it went back to draft then. Scheduling or publishing clears it.
+	PublishError utils.TEXT `json:"publish_error,omitempty"`
+	CreatedAt    utils.TIME `json:"created_at"`
+	UpdatedAt    utils.TIME `json:"updated_at"`
+}
+
+// MenuVersionDiff lists the menu items added, removed and changed going
+// from one version to another.
+type MenuVersionDiff struct {
+	FromVersionId utils.TEXT       `json:"from_version_id"`
+	ToVersionId   utils.TEXT       `json:"to_version_id"`
+	Added         []MenuItems      `json:"added"`
+	Removed       []MenuItems      `json:"removed"`
+	Changed       []MenuItemChange `json:"changed"`
+}
+
+type MenuItemChange struct {
+	MenuItemId utils.TEXT    `json:"menu_item_id"`
+	ItemName   utils.TEXT    `json:"item_name"`
+	Fields     []FieldChange `json:"fields"`
+}
+
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// DiffMenus lists the menu items added, removed and changed going from one
+// menu to another, comparing only what a menu version defines of them.
+func DiffMenus(from, to []MenuItems) MenuVersionDiff {
+	var diff MenuVersionDiff
+	old := make(map[utils.TEXT]MenuItems, len(from))
+	for _, item := range from {
+		old[item.MenuItemId] = item
+	}
+	for _, item := range to {
+		prev, ok := old[item.MenuItemId]
+		if !ok {
+			diff.Added = append(diff.Added, item)
+			continue
+		}
+		delete(old, item.MenuItemId)
+		if fields := itemChanges(prev, item); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, MenuItemChange{
+				MenuItemId: item.MenuItemId,
+				ItemName:   item.ItemName,
+				Fields:     fields,
+			})
+		}
+	}
+	for _, item := range from {
+		if _, ok := old[item.MenuItemId]; ok {
+			diff.Removed = append(diff.Removed, item)
+		}
+	}
+	return diff
+}
+
+// itemChanges lists the fields of a menu item that a version edits.
+func itemChanges(from, to MenuItems) []FieldChange {
+	var changes []FieldChange
+	compare := func(field string, a, b any) {
+		if !reflect.DeepEqual(a, b) {
+			changes = append(changes, FieldChange{Field: field, From: a, To: b})
+		}
+	}
+	from, to = definedFields(from), definedFields(to)
+	compare("item_name", from.ItemName, to.ItemName)
+	compare("item_description", from.ItemDescription, to.ItemDescription)
+	compare("price", from.Price, to.Price)
+	compare("category_ids", from.CategoryIds, to.CategoryIds)
+	compare("tags", from.Tags, to.Tags)
+	compare("ingredients", from.Ingredients, to.Ingredients)
+	compare("variants", from.Variants, to.Variants)
+	compare("bundle_slots", from.BundleSlots, to.BundleSlots)
+	return changes
+}
+
+// definedFields clears the ids and names that publishing assigns, so that
+// only what a version defines is compared.
+func definedFields(item MenuItems) MenuItems {
+	if len(item.CategoryIds) == 0 {
+		item.CategoryIds = nil
+	} else {
+		item.CategoryIds = append(utils.TEXTARR{}, item.CategoryIds...)
+		sort.Strings(item.CategoryIds)
+	}
+	if len(item.Tags) == 0 {
+		item.Tags = nil
+	}
+	var ingredients []MenuItemsIngredients
+	for _, line := range item.Ingredients {
+		ingredients = append(ingredients, MenuItemsIngredients{IngredientId: line.IngredientId, Quantity: line.Quantity})
+	}
+	item.Ingredients = ingredients
+	var variants []MenuItemVariant
+	for _, v := range item.Variants {
+		var recipe []RecipeComponent
+		for _, c := range v.Ingredients {
+			recipe = append(recipe, RecipeComponent{IngredientId: c.IngredientId, Quantity: c.Quantity})
+		}
+		v.VariantId, v.MenuItemId, v.Ingredients = "", "", recipe
+		if v.RecipeMultiplier <= 0 {
+			v.RecipeMultiplier = 1
+		}
+		variants = append(variants, v)
+	}
+	item.Variants = variants
+	var slots []BundleSlot
+	for _, slot := range item.BundleSlots {
+		slot.SlotId, slot.BundleId = "", ""
+		slots = append(slots, slot)
+	}
+	item.BundleSlots = slots
+	return item
+}
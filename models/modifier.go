@@ -0,0 +1,88 @@
+package models
+
+import "frappuccino/utils"
+
+const (
+	ModifierActionAdd        = "ADD"
+	ModifierActionRemove     = "REMOVE"
+	ModifierActionSubstitute = "SUBSTITUTE"
+)
+
+// ModifierGroup is a choice offered on menu items, such as milk type.
+// An order item must pick between MinSelections and MaxSelections of its modifiers.
+type ModifierGroup struct {
+	ModifierGroupId utils.TEXT `json:"modifier_group_id"`
+	GroupName       utils.TEXT `json:"group_name"`
+	MinSelections   utils.INT  `json:"min_selections"`
+	MaxSelections   utils.INT  `json:"max_selections"`
+	Modifiers       []Modifier `json:"modifiers"`
+	CreatedAt       utils.TIME `json:"created_at"`
+	UpdatedAt       utils.TIME `json:"updated_at"`
+}
+
+type Modifier struct {
+	ModifierId      utils.TEXT           `json:"modifier_id"`
+	ModifierGroupId utils.TEXT           `json:"modifier_group_id"`
+	ModifierName    utils.TEXT           `json:"modifier_name"`
+	PriceDelta      utils.DEC            `json:"price_delta"`
+	Ingredients     []ModifierIngredient `json:"ingredients,omitempty"`
+	// Allergens brought in by the ingredients the modifier adds
+	Allergens utils.TEXTARR `json:"allergens,omitempty"`
+}
+
+// ModifierIngredient is one recipe change of a modifier. ADD adds Quantity of
+// IngredientId, REMOVE drops ReplacesIngredientId and SUBSTITUTE replaces
+// ReplacesIngredientId with IngredientId, keeping its quantity when Quantity is 0.
+type ModifierIngredient struct {
+	Action               utils.TEXT `json:"action"`
+	IngredientId         utils.TEXT `json:"ingredient_id,omitempty"`
+	ReplacesIngredientId utils.TEXT `json:"replaces_ingredient_id,omitempty"`
+	Quantity             utils.DEC  `json:"quantity"`
+}
+
+// ApplyModifiers returns recipe changed by the ingredient changes of modifiers.
+func ApplyModifiers(recipe []MenuItemsIngredients, modifiers []Modifier) []MenuItemsIngredients {
+	result := make([]MenuItemsIngredients, len(recipe))
+	copy(result, recipe)
+	for _, m := range modifiers {
+		for _, change := range m.Ingredients {
+			switch change.Action {
+			case ModifierActionAdd:
+				result = addIngredient(result, change.IngredientId, change.Quantity)
+			case ModifierActionRemove:
+				result, _ = removeIngredient(result, change.ReplacesIngredientId)
+			case ModifierActionSubstitute:
+				var replaced utils.DEC
+				result, replaced = removeIngredient(result, change.ReplacesIngredientId)
+				quantity := change.Quantity
+				if quantity == 0 {
+					quantity = replaced
+				}
+				if quantity > 0 {
+					result = addIngredient(result, change.IngredientId, quantity)
+				}
+			}
+		}
+	}
+	return result
+}
+
+func addIngredient(recipe []MenuItemsIngredients, id utils.TEXT, quantity utils.DEC) []MenuItemsIngredients {
+	for i := range recipe {
+		if recipe[i].IngredientId == id {
+			recipe[i].Quantity += quantity
+			return recipe
+		}
+	}
+	return append(recipe, MenuItemsIngredients{IngredientId: id, Quantity: quantity})
+}
+
+func removeIngredient(recipe []MenuItemsIngredients, id utils.TEXT) ([]MenuItemsIngredients, utils.DEC) {
+	for i := range recipe {
+		if recipe[i].IngredientId == id {
+			quantity := recipe[i].Quantity
+			return append(recipe[:i:i], recipe[i+1:]...), quantity
+		}
+	}
+	return recipe, 0
+}
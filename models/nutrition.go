@@ -0,0 +1,42 @@
+package models
+
+import "frappuccino/utils"
+
+// Nutrition of one unit of an ingredient or one serving of a menu item.
+type Nutrition struct {
+	Kcal       utils.DEC `json:"kcal"`
+	SugarG     utils.DEC `json:"sugar_g"`
+	FatG       utils.DEC `json:"fat_g"`
+	ProteinG   utils.DEC `json:"protein_g"`
+	CaffeineMg utils.DEC `json:"caffeine_mg"`
+}
+
+// Add returns n plus quantity times o.
+func (n Nutrition) Add(o Nutrition, quantity utils.DEC) Nutrition {
+	return Nutrition{
+		Kcal:       n.Kcal + o.Kcal*quantity,
+		SugarG:     n.SugarG + o.SugarG*quantity,
+		FatG:       n.FatG + o.FatG*quantity,
+		ProteinG:   n.ProteinG + o.ProteinG*quantity,
+		CaffeineMg: n.CaffeineMg + o.CaffeineMg*quantity,
+	}
+}
+
+// Valid reports whether no value is negative.
+func (n Nutrition) Valid() bool {
+	return n.Kcal >= 0 && n.SugarG >= 0 && n.FatG >= 0 && n.ProteinG >= 0 && n.CaffeineMg >= 0
+}
+
+// MenuItemNutrition is the nutrition of one serving of a menu item and of each of its variants.
+type MenuItemNutrition struct {
+	MenuItemId utils.TEXT         `json:"menu_item_id"`
+	ItemName   utils.TEXT         `json:"item_name"`
+	Nutrition  Nutrition          `json:"nutrition"`
+	Variants   []VariantNutrition `json:"variants,omitempty"`
+}
+
+type VariantNutrition struct {
+	VariantId   utils.TEXT `json:"variant_id"`
+	VariantName utils.TEXT `json:"variant_name"`
+	Nutrition   Nutrition  `json:"nutrition"`
+}
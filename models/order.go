@@ -20,6 +20,7 @@ import "frappuccino/utils"
 type Order struct {
 	OrderId             utils.TEXT `json:"order_id"`
 	CustomerId          utils.TEXT `json:"customer_id"`
+	StoreId             utils.TEXT `json:"store_id"`
 	OrderItems          []OrderItems
 	SpecialInstructions utils.TEXT `json:"special_instructions"`
 	TotalPrice          utils.DEC  `json:"total_price"`
@@ -30,12 +31,18 @@ type Order struct {
 }
 
 type OrderItems struct {
-	OrderItemId    utils.TEXT  `json:"order_item_id"`
-	MenuItemId     utils.TEXT  `json:"menu_item_id"`
-	OrderId        utils.TEXT  `json:"order_id"`
-	Customizations utils.JSONB `json:"customizations"`
-	Quantity       utils.DEC   `json:"quantity"`
-	UnitPrice      utils.DEC   `json:"unit_price"`
+	OrderItemId    utils.TEXT           `json:"order_item_id"`
+	MenuItemId     utils.TEXT           `json:"menu_item_id"`
+	ItemName       utils.TEXT           `json:"item_name,omitempty"`
+	VariantId      utils.TEXT           `json:"variant_id,omitempty"`
+	VariantName    utils.TEXT           `json:"variant_name,omitempty"`
+	Modifiers      []utils.TEXT         `json:"modifiers,omitempty"`
+	Selections     []BundleSelection    `json:"bundle_selections,omitempty"`
+	Components     []OrderItemComponent `json:"components,omitempty"`
+	OrderId        utils.TEXT           `json:"order_id"`
+	Customizations utils.JSONB          `json:"customizations"`
+	Quantity       utils.DEC            `json:"quantity"`
+	UnitPrice      utils.DEC            `json:"unit_price"`
 }
 
 type OrderStatusHistory struct {
@@ -45,3 +52,39 @@ type OrderStatusHistory struct {
 	OrderStatus          utils.TEXT `json:"order_status"`
 	UpdatedAt            utils.TIME `json:"updated_at"`
 }
+
+// OrderStatuses are the values of the all_order_status enum.
+var OrderStatuses = []string{"PENDING", "COMPLETED", "CANCELLED"}
+
+// OrderFilter selects a page of an order history. No statuses means all,
+// and no StoreId the orders of every store.
+type OrderFilter struct {
+	Statuses []string
+	StoreId  string
+	Page     int
+	PageSize int
+}
+
+// OrderPage is one page of an order history, newest orders first.
+type OrderPage struct {
+	Orders   []Order `json:"orders"`
+	Page     int     `json:"page"`
+	PageSize int     `json:"page_size"`
+	Total    int     `json:"total"`
+}
+
+// Reorder is the result of placing a past order again. Order is nil when
+// none of its items can be ordered any more.
+type Reorder struct {
+	Order       *Order            `json:"order"`
+	Unavailable []UnavailableItem `json:"unavailable"`
+}
+
+// UnavailableItem is an item of a past order that could not be reordered.
+type UnavailableItem struct {
+	MenuItemId utils.TEXT `json:"menu_item_id"`
+	ItemName   utils.TEXT `json:"item_name"`
+	VariantId  utils.TEXT `json:"variant_id,omitempty"`
+	Quantity   utils.DEC  `json:"quantity"`
+	Reason     utils.TEXT `json:"reason"`
+}
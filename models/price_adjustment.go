@@ -0,0 +1,44 @@
+package models
+
+import "frappuccino/utils"
+
+const (
+	PriceChangePercent  = "PERCENT"
+	PriceChangeAbsolute = "ABSOLUTE"
+
+	RoundingNearest = "NEAREST"
+	RoundingUp      = "UP"
+	RoundingDown    = "DOWN"
+)
+
+// PriceAdjustment changes the price of every menu item matching all of
+// CategoryId (or one of its subcategories), Tag and MenuItemIds that are set, by ChangeValue percent or by
+// ChangeValue absolute, rounding the new price to a multiple of RoundTo.
+// The prices of their variants and the price overrides of stores change with them.
+type PriceAdjustment struct {
+	AdjustmentId utils.TEXT    `json:"adjustment_id"`
+	Reason       utils.TEXT    `json:"reason"`
+	CategoryId   utils.TEXT    `json:"category_id"`
+	Tag          utils.TEXT    `json:"tag"`
+	MenuItemIds  utils.TEXTARR `json:"menu_item_ids"`
+	ChangeType   utils.TEXT    `json:"change_type"`
+	ChangeValue  utils.DEC     `json:"change_value"`
+	RoundTo      utils.DEC     `json:"round_to"`
+	Rounding     utils.TEXT    `json:"rounding"`
+	Changes      []PriceChange `json:"changes"`
+	RevertedAt   *utils.TIME   `json:"reverted_at"`
+	CreatedAt    utils.TIME    `json:"created_at"`
+}
+
+// PriceChange is the change of the price of a menu item, of one of its
+// variants when VariantId is set, or of its price in a store when StoreId is set.
+type PriceChange struct {
+	MenuItemId  utils.TEXT `json:"menu_item_id"`
+	ItemName    utils.TEXT `json:"item_name"`
+	VariantId   utils.TEXT `json:"variant_id,omitempty"`
+	VariantName utils.TEXT `json:"variant_name,omitempty"`
+	StoreId     utils.TEXT `json:"store_id,omitempty"`
+	StoreName   utils.TEXT `json:"store_name,omitempty"`
+	OldPrice    utils.DEC  `json:"old_price"`
+	NewPrice    utils.DEC  `json:"new_price"`
+}
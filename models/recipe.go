@@ -0,0 +1,48 @@
+package models
+
+import "frappuccino/utils"
+
+// Recipe of a prepared ingredient: one batch consumes Components and yields BatchYield units.
+type Recipe struct {
+	IngredientId utils.TEXT        `json:"ingredient_id"`
+	BatchYield   utils.DEC         `json:"batch_yield"`
+	Components   []RecipeComponent `json:"components"`
+}
+
+type RecipeComponent struct {
+	IngredientId   utils.TEXT `json:"ingredient_id"`
+	IngredientName utils.TEXT `json:"ingredient_name"`
+	Quantity       utils.DEC  `json:"quantity"`
+}
+
+type Production struct {
+	ProductionId utils.TEXT `json:"production_id"`
+	IngredientId utils.TEXT `json:"ingredient_id"`
+	StoreId      utils.TEXT `json:"store_id"`
+	Batches      utils.DEC  `json:"batches"`
+	Quantity     utils.DEC  `json:"quantity"`
+	CreatedAt    utils.TIME `json:"created_at"`
+}
+
+// IngredientCost is the cost of one unit of an ingredient, including its sub-recipes.
+type IngredientCost struct {
+	IngredientId utils.TEXT `json:"ingredient_id"`
+	UnitCost     utils.DEC  `json:"unit_cost"`
+}
+
+// MenuItemCost is the ingredient cost of one serving of a menu item.
+type MenuItemCost struct {
+	MenuItemId utils.TEXT `json:"menu_item_id"`
+	VariantId  utils.TEXT `json:"variant_id,omitempty"`
+	Price      utils.DEC  `json:"price"`
+	Cost       utils.DEC  `json:"cost"`
+	Margin     utils.DEC  `json:"margin"`
+}
+
+// Availability is how much of an ingredient or how many servings of a menu
+// item a store can provide, counting what it can still produce from sub-recipes.
+type Availability struct {
+	Id       utils.TEXT `json:"id"`
+	StoreId  utils.TEXT `json:"store_id"`
+	Quantity utils.DEC  `json:"quantity"`
+}
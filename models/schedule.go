@@ -0,0 +1,21 @@
+package models
+
+import "frappuccino/utils"
+
+// MenuSchedule is a window in which a menu item, or every item of a
+// category and its subcategories, can be sold. Empty bounds are open: a
+// window with only a StartTime runs from then to midnight and one with only
+// an EndTime from midnight until then. Times are HH:MM and a window whose
+// start is after its end runs past midnight. Days, dates and times are
+// those of the time zone of the store selling the item. DaysOfWeek uses ISO
+// numbering, 1 for Monday to 7 for Sunday.
+type MenuSchedule struct {
+	ScheduleId utils.TEXT `json:"schedule_id"`
+	MenuItemId utils.TEXT `json:"menu_item_id,omitempty"`
+	CategoryId utils.TEXT `json:"category_id,omitempty"`
+	StartTime  utils.TEXT `json:"start_time,omitempty"`
+	EndTime    utils.TEXT `json:"end_time,omitempty"`
+	DaysOfWeek []int64    `json:"days_of_week"`
+	StartDate  utils.TEXT `json:"start_date,omitempty"`
+	EndDate    utils.TEXT `json:"end_date,omitempty"`
+}
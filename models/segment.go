@@ -0,0 +1,48 @@
+package models
+
+import "frappuccino/utils"
+
+// SegmentDefinition groups customers by ranges of their RFM scores, each
+// from 1 (worst) to 5 (best).
+type SegmentDefinition struct {
+	SegmentId    utils.TEXT `json:"segment_id,omitempty"`
+	SegmentName  utils.TEXT `json:"segment_name"`
+	Description  utils.TEXT `json:"description"`
+	MinRecency   utils.INT  `json:"min_recency"`
+	MaxRecency   utils.INT  `json:"max_recency"`
+	MinFrequency utils.INT  `json:"min_frequency"`
+	MaxFrequency utils.INT  `json:"max_frequency"`
+	MinMonetary  utils.INT  `json:"min_monetary"`
+	MaxMonetary  utils.INT  `json:"max_monetary"`
+	Priority     utils.INT  `json:"priority"`
+	CreatedAt    utils.TIME `json:"created_at"`
+	UpdatedAt    utils.TIME `json:"updated_at"`
+}
+
+// Matches tells whether the scores of a customer fall in the segment.
+func (d SegmentDefinition) Matches(c CustomerRFM) bool {
+	return d.MinRecency <= utils.INT(c.Recency) && utils.INT(c.Recency) <= d.MaxRecency &&
+		d.MinFrequency <= utils.INT(c.Frequency) && utils.INT(c.Frequency) <= d.MaxFrequency &&
+		d.MinMonetary <= utils.INT(c.Monetary) && utils.INT(c.Monetary) <= d.MaxMonetary
+}
+
+// DefaultSegments are the built-in segments, in the order they are tried.
+// Every combination of scores falls in one of them.
+var DefaultSegments = []SegmentDefinition{
+	{SegmentName: "champions", Description: "Ordered recently, order often and spend the most",
+		MinRecency: 4, MaxRecency: 5, MinFrequency: 4, MaxFrequency: 5, MinMonetary: 4, MaxMonetary: 5},
+	{SegmentName: "loyal", Description: "Order often and not long ago",
+		MinRecency: 3, MaxRecency: 5, MinFrequency: 4, MaxFrequency: 5, MinMonetary: 1, MaxMonetary: 5},
+	{SegmentName: "new", Description: "Ordered recently for the first times",
+		MinRecency: 4, MaxRecency: 5, MinFrequency: 1, MaxFrequency: 1, MinMonetary: 1, MaxMonetary: 5},
+	{SegmentName: "potential_loyalists", Description: "Ordered recently, a few times",
+		MinRecency: 4, MaxRecency: 5, MinFrequency: 2, MaxFrequency: 3, MinMonetary: 1, MaxMonetary: 5},
+	{SegmentName: "at_risk", Description: "Used to order often but not lately",
+		MinRecency: 1, MaxRecency: 2, MinFrequency: 3, MaxFrequency: 5, MinMonetary: 1, MaxMonetary: 5},
+	{SegmentName: "hibernating", Description: "Ordered a few times, a while ago",
+		MinRecency: 2, MaxRecency: 2, MinFrequency: 1, MaxFrequency: 2, MinMonetary: 1, MaxMonetary: 5},
+	{SegmentName: "lost", Description: "Ordered rarely and long ago",
+		MinRecency: 1, MaxRecency: 1, MinFrequency: 1, MaxFrequency: 2, MinMonetary: 1, MaxMonetary: 5},
+	{SegmentName: "needs_attention", Description: "Everyone else",
+		MinRecency: 1, MaxRecency: 5, MinFrequency: 1, MaxFrequency: 5, MinMonetary: 1, MaxMonetary: 5},
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"frappuccino/utils"
+	"slices"
+)
+
+// Role is what a staff user is, which sets what they may do.
+type Role string
+
+const (
+	RoleBarista   Role = "BARISTA"
+	RoleShiftLead Role = "SHIFT_LEAD"
+	RoleManager   Role = "MANAGER"
+	RoleAdmin     Role = "ADMIN"
+)
+
+// Roles are the staff roles, least powerful first.
+var Roles = []Role{RoleBarista, RoleShiftLead, RoleManager, RoleAdmin}
+
+// Permission is something staff endpoints require.
+type Permission string
+
+const (
+	// PermOrders is taking orders, following them up and reordering for customers.
+	PermOrders Permission = "orders"
+	// PermOrdersManage is changing the items of orders and deleting orders.
+	PermOrdersManage Permission = "orders.manage"
+	// PermCustomers is looking up, adding and updating customers.
+	PermCustomers Permission = "customers"
+	// PermCustomersManage is archiving, merging, exporting and erasing customers.
+	PermCustomersManage Permission = "customers.manage"
+	// PermInventory is seeing stock levels and transfers.
+	PermInventory Permission = "inventory"
+	// PermInventoryManage is updating stock, producing and moving it between stores.
+	PermInventoryManage Permission = "inventory.manage"
+	// PermCatalog is managing ingredients, recipes, the menu, its prices and costs.
+	PermCatalog Permission = "catalog"
+	// PermReports is sales and customer reports and segments.
+	PermReports Permission = "reports"
+	// PermStores is managing stores.
+	PermStores Permission = "stores"
+	// PermStaff is managing staff users and reading denied requests.
+	PermStaff Permission = "staff"
+)
+
+// RolePermissions is the permission matrix: what each role may do.
+var RolePermissions = map[Role][]Permission{
+	RoleBarista: {PermOrders, PermCustomers, PermInventory},
+	RoleShiftLead: {PermOrders, PermCustomers, PermInventory,
+		PermOrdersManage, PermInventoryManage},
+	RoleManager: {PermOrders, PermCustomers, PermInventory,
+		PermOrdersManage, PermInventoryManage,
+		PermCustomersManage, PermCatalog, PermReports},
+	RoleAdmin: {PermOrders, PermCustomers, PermInventory,
+		PermOrdersManage, PermInventoryManage,
+		PermCustomersManage, PermCatalog, PermReports,
+		PermStores, PermStaff},
+}
+
+// Can tells whether the role has the permission.
+func (r Role) Can(permission Permission) bool {
+	return slices.Contains(RolePermissions[r], permission)
+}
+
+// StaffUser is a member of staff. Password is only read, when creating the
+// user or changing their password, and is never returned. Logins are locked
+// until LockedUntil after too many wrong passwords, and tokens issued before
+// PasswordChangedAt are not accepted.
+type StaffUser struct {
+	StaffId           utils.TEXT  `json:"staff_id"`
+	Username          utils.TEXT  `json:"username"`
+	FullName          utils.TEXT  `json:"full_name"`
+	Role              Role        `json:"role"`
+	StoreId           utils.TEXT  `json:"store_id,omitempty"`
+	Password          string      `json:"password,omitempty"`
+	LockedUntil       *utils.TIME `json:"locked_until,omitempty"`
+	PasswordChangedAt utils.TIME  `json:"password_changed_at"`
+	ArchivedAt        *utils.TIME `json:"archived_at"`
+	CreatedAt         utils.TIME  `json:"created_at"`
+	UpdatedAt         utils.TIME  `json:"updated_at"`
+}
+
+// StaffLogin logs a member of staff in.
+type StaffLogin struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// StaffSession is a logged-in member of staff. Token is a signed token sent
+// back as "Authorization: Bearer <token>".
+type StaffSession struct {
+	Token     string     `json:"token"`
+	Staff     StaffUser  `json:"staff"`
+	ExpiresAt utils.TIME `json:"expires_at"`
+}
+
+// AccessDenial records a denied request to a staff endpoint. StaffId is
+// empty when the request did not come from a logged-in member of staff;
+// such denials are logged but not kept.
+type AccessDenial struct {
+	DenialId   utils.TEXT `json:"denial_id"`
+	StaffId    utils.TEXT `json:"staff_id,omitempty"`
+	Username   utils.TEXT `json:"username,omitempty"`
+	Method     utils.TEXT `json:"method"`
+	Path       utils.TEXT `json:"path"`
+	Permission Permission `json:"permission"`
+	Reason     utils.TEXT `json:"reason"`
+	RemoteAddr utils.TEXT `json:"remote_addr"`
+	CreatedAt  utils.TIME `json:"created_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "frappuccino/utils"
+
+type Store struct {
+	StoreId   utils.TEXT `json:"store_id"`
+	StoreName utils.TEXT `json:"store_name"`
+	Address   utils.TEXT `json:"address"`
+	// TimeZone is the IANA zone, such as Europe/Berlin, the menu schedules
+	// of the store are read in. It is UTC when not given.
+	TimeZone  utils.TEXT `json:"time_zone"`
+	CreatedAt utils.TIME `json:"created_at"`
+	UpdatedAt utils.TIME `json:"updated_at"`
+}
+
+// StoreMenuItem overrides a menu item for a single store.
+// A nil Price keeps the price from menu_items. Variants of the item cost
+// as much more or less in the store as the item itself.
+type StoreMenuItem struct {
+	StoreId     utils.TEXT `json:"store_id"`
+	MenuItemId  utils.TEXT `json:"menu_item_id"`
+	Price       *utils.DEC `json:"price"`
+	IsAvailable bool       `json:"is_available"`
+}
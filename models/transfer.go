@@ -0,0 +1,43 @@
+package models
+
+import "frappuccino/utils"
+
+const (
+	TransferStatusRequested = "REQUESTED"
+	TransferStatusShipped   = "SHIPPED"
+	TransferStatusReceived  = "RECEIVED"
+	TransferStatusCancelled = "CANCELLED"
+)
+
+type Transfer struct {
+	TransferId     utils.TEXT     `json:"transfer_id"`
+	FromStoreId    utils.TEXT     `json:"from_store_id"`
+	ToStoreId      utils.TEXT     `json:"to_store_id"`
+	TransferStatus utils.TEXT     `json:"transfer_status"`
+	Notes          utils.TEXT     `json:"notes"`
+	Items          []TransferItem `json:"items"`
+	ShippedAt      *utils.TIME    `json:"shipped_at"`
+	ReceivedAt     *utils.TIME    `json:"received_at"`
+	CreatedAt      utils.TIME     `json:"created_at"`
+	UpdatedAt      utils.TIME     `json:"updated_at"`
+}
+
+// TransferItem is one ingredient line of a transfer. Shipped and received
+// quantities stay nil until the transfer reaches that state.
+type TransferItem struct {
+	TransferItemId    utils.TEXT `json:"transfer_item_id"`
+	IngredientId      utils.TEXT `json:"ingredient_id"`
+	RequestedQuantity utils.DEC  `json:"requested_quantity"`
+	ShippedQuantity   *utils.DEC `json:"shipped_quantity"`
+	ReceivedQuantity  *utils.DEC `json:"received_quantity"`
+	Discrepancy       *utils.DEC `json:"discrepancy"`
+	DiscrepancyNotes  utils.TEXT `json:"discrepancy_notes"`
+}
+
+// InTransitStock is the quantity of an ingredient shipped to a store but not yet received.
+type InTransitStock struct {
+	StoreId        utils.TEXT `json:"store_id"`
+	IngredientId   utils.TEXT `json:"ingredient_id"`
+	IngredientName utils.TEXT `json:"ingredient_name"`
+	Quantity       utils.DEC  `json:"quantity"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import "frappuccino/utils"
+
+// DefaultLocale is the locale menu content is written in. Other locales
+// come from translations and fall back to it.
+const DefaultLocale = "en"
+
+// Translation is the text of a menu item, category, modifier group or
+// modifier in one locale. Exactly one of the ids is set; Description is
+// only shown for menu items and categories.
+type Translation struct {
+	TranslationId   utils.TEXT `json:"translation_id"`
+	Locale          utils.TEXT `json:"locale"`
+	MenuItemId      utils.TEXT `json:"menu_item_id,omitempty"`
+	CategoryId      utils.TEXT `json:"category_id,omitempty"`
+	ModifierGroupId utils.TEXT `json:"modifier_group_id,omitempty"`
+	ModifierId      utils.TEXT `json:"modifier_id,omitempty"`
+	Name            utils.TEXT `json:"name"`
+	Description     utils.TEXT `json:"description"`
+	CreatedAt       utils.TIME `json:"created_at"`
+	UpdatedAt       utils.TIME `json:"updated_at"`
+}
+
+// TargetId returns the id of what the translation translates.
+func (t Translation) TargetId() utils.TEXT {
+	for _, id := range []utils.TEXT{t.MenuItemId, t.CategoryId, t.ModifierGroupId, t.ModifierId} {
+		if id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// MissingTranslations lists what has no translation in a locale.
+type MissingTranslations struct {
+	Locale  utils.TEXT           `json:"locale"`
+	Count   utils.INT            `json:"count"`
+	Missing []UntranslatedTarget `json:"missing"`
+}
+
+// UntranslatedTarget is a menu item, category, modifier group or modifier
+// lacking a translation. Type is menu_item, category, modifier_group or modifier.
+type UntranslatedTarget struct {
+	Type utils.TEXT `json:"type"`
+	Id   utils.TEXT `json:"id"`
+	Name utils.TEXT `json:"name"`
+}
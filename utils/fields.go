@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"database/sql/driver"
 	"encoding/json"
 	"time"
 )
@@ -16,3 +17,21 @@ type INT int
 type JSONB json.RawMessage
 
 type TIME time.Time
+
+func (t TIME) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Time(t))
+}
+
+func (t *TIME) UnmarshalJSON(data []byte) error {
+	var v time.Time
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*t = TIME(v)
+	return nil
+}
+
+// Value lets TIME be passed as a query argument.
+func (t TIME) Value() (driver.Value, error) {
+	return time.Time(t), nil
+}